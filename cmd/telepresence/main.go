@@ -11,6 +11,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/rootd"
@@ -38,7 +39,7 @@ func main() {
 
 	var cmd *cobra.Command
 	if isDaemon() {
-		// Avoid the initialization of all subcommands except for [connector|daemon]-foreground and
+		// Avoid the initialization of all subcommands except for [connector|daemon|daemons]-foreground and
 		// avoids checks for legacy commands.
 		cmd = &cobra.Command{
 			Use:  "telepresence",
@@ -52,6 +53,7 @@ func main() {
 		}
 		cmd.AddCommand(userd.Command(commands.GetCommands, []userd.DaemonService{}, []trafficmgr.SessionService{}))
 		cmd.AddCommand(rootd.Command())
+		cmd.AddCommand(docker.Command())
 		if err := cmd.ExecuteContext(ctx); err != nil {
 			fmt.Fprintf(cmd.ErrOrStderr(), "%s: error: %v\n", cmd.CommandPath(), err)
 			os.Exit(1)
@@ -67,6 +69,12 @@ func main() {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
+		name, err := cli.ConnectionName()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		ctx = client.WithConnectionName(ctx, name)
 		cmd = cli.Command(ctx)
 		cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
 			return errcat.User.New(err)
@@ -99,7 +107,7 @@ func summarizeLogs(ctx context.Context, cmd *cobra.Command) {
 	w := cmd.ErrOrStderr()
 	first := true
 	for _, proc := range []string{rootd.ProcessName, userd.ProcessName} {
-		if summary, err := logging.SummarizeLog(ctx, proc); err != nil {
+		if summary, err := logging.SummarizeLog(ctx, client.ConnectionProcessName(ctx, proc)); err != nil {
 			fmt.Fprintf(w, "failed to scan %s logs: %v\n", proc, err)
 		} else if summary != "" {
 			if first {
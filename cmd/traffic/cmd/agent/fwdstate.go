@@ -9,6 +9,7 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
 	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
 )
 
@@ -102,7 +103,7 @@ func (fs *fwdState) HandleIntercepts(ctx context.Context, cepts []*manager.Inter
 					PodIp:             fs.PodIP(),
 					SftpPort:          int32(fs.SftpPort()),
 					MountPoint:        fs.mountPoint,
-					MechanismArgsDesc: "all TCP connections",
+					MechanismArgsDesc: mechanismArgsDesc(cept),
 					Environment:       fs.env,
 				})
 			case fs.chosenIntercept == nil:
@@ -120,7 +121,7 @@ func (fs *fwdState) HandleIntercepts(ctx context.Context, cepts []*manager.Inter
 					PodIp:             fs.PodIP(),
 					SftpPort:          int32(fs.SftpPort()),
 					MountPoint:        fs.mountPoint,
-					MechanismArgsDesc: "all TCP connections",
+					MechanismArgsDesc: mechanismArgsDesc(cept),
 					Environment:       fs.env,
 				})
 			default:
@@ -137,10 +138,35 @@ func (fs *fwdState) HandleIntercepts(ctx context.Context, cepts []*manager.Inter
 					Id:                cept.Id,
 					Disposition:       manager.InterceptDispositionType_AGENT_ERROR,
 					Message:           msg,
-					MechanismArgsDesc: "all TCP connections",
+					MechanismArgsDesc: mechanismArgsDesc(cept),
 				})
 			}
 		}
 	}
 	return reviews
 }
+
+// mechanismArgsDesc returns a description of what the given intercept will intercept.
+func mechanismArgsDesc(cept *manager.InterceptInfo) string {
+	spec := cept.Spec
+	desc := connectionsDesc(spec)
+	if spec.TlsSecret != "" {
+		desc += fmt.Sprintf(", TLS terminated using secret %q", spec.TlsSecret)
+	}
+	return desc
+}
+
+// connectionsDesc returns a description of the connections, or calls, that the given intercept spec will intercept.
+func connectionsDesc(spec *manager.InterceptSpec) string {
+	switch {
+	case spec.Mirror:
+		return "a mirror of all TCP connections"
+	case spec.Weight > 0 && spec.Weight < 100:
+		return fmt.Sprintf("%d%% of all TCP connections", spec.Weight)
+	case len(spec.GrpcMatch) > 0:
+		if m, err := matcher.NewGRPCRequestFromMap(spec.GrpcMatch); err == nil {
+			return m.String()
+		}
+	}
+	return "all TCP connections"
+}
@@ -10,9 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 
-	"github.com/coreos/go-iptables/iptables"
 	"gopkg.in/yaml.v3"
 	core "k8s.io/api/core/v1"
 
@@ -23,9 +21,6 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
 )
 
-const nat = "nat"
-const inboundChain = "TEL_INBOUND"
-
 type config struct {
 	agentconfig.Sidecar
 }
@@ -44,103 +39,102 @@ func loadConfig(ctx context.Context) (*config, error) {
 	return &c, nil
 }
 
-func (c *config) configureIptables(ctx context.Context, iptables *iptables.IPTables, loopback string) error {
-	// These iptables rules implement routing such that a packet directed to the appPort will hit the agentPort instead.
-	// If there's no mesh this is simply request -> agent -> app (or intercept)
-	// However, if there's a service mesh we want to make sure we don't bypass the mesh, so the traffic
-	// will flow request -> mesh -> agent -> app
+// ipFamily is the IP family of a set of redirect rules.
+type ipFamily int
+
+const (
+	ipv4 ipFamily = iota
+	ipv6
+)
 
-	// A service mesh will typically use an UID different from the one used by this process
-	agentUID := strconv.Itoa(os.Getuid())
+func (f ipFamily) String() string {
+	if f == ipv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
 
-	outputInsertCount := 0
+// localhost returns the CIDR of the localhost address of the family.
+func (f ipFamily) localhost() string {
+	if f == ipv6 {
+		return "::1/128"
+	}
+	return "127.0.0.1/32"
+}
+
+// redirect is a rule that redirects traffic to a container port to the port of the agent.
+type redirect struct {
+	containerPort uint16
+	agentPort     uint16
+}
+
+// protoRedirects are the redirects of one protocol.
+type protoRedirects struct {
+	proto     core.Protocol
+	redirects []redirect
+}
+
+// redirects returns the redirects of the symbolic intercepts of the config, grouped by protocol. Protocols
+// that have no redirects are omitted.
+func (c *config) redirects() []protoRedirects {
+	var prs []protoRedirects
 	for _, proto := range []core.Protocol{core.ProtocolTCP, core.ProtocolUDP} {
-		hasRule := false
-	nextCn:
+		var rds []redirect
 		for _, cn := range c.Containers {
 			for _, ic := range agentconfig.PortUniqueIntercepts(cn) {
 				if proto == ic.Protocol {
-					hasRule = true
-					break nextCn
+					rds = append(rds, redirect{containerPort: ic.ContainerPort, agentPort: ic.AgentPort})
 				}
 			}
 		}
-		if !hasRule {
-			// no rules for the given proto
-			continue
+		if len(rds) > 0 {
+			prs = append(prs, protoRedirects{proto: proto, redirects: rds})
 		}
+	}
+	return prs
+}
 
-		// Clearing the inbound chain will create it if it doesn't exist, or clear it out if it does.
-		chain := inboundChain + "_" + string(proto)
-		err := iptables.ClearChain(nat, chain)
-		if err != nil {
-			return fmt.Errorf("failed to clear chain %s: %w", chain, err)
-		}
+// firewall is a backend that programs the rules that route traffic directed to the app ports to the
+// agent ports instead. If there's no mesh, this is simply request -> agent -> app (or intercept).
+// However, if there's a service mesh, we want to make sure that we don't bypass the mesh, so the
+// traffic will flow request -> mesh -> agent -> app.
+type firewall interface {
+	// install replaces the rules of the given family with rules for the given redirects. Traffic that
+	// heads out on the loopback interface and is owned by the given agentUID is exempted from the mesh.
+	install(ctx context.Context, family ipFamily, loopback, agentUID string, prs []protoRedirects) error
 
-		// Use our inbound chain to direct traffic coming into the app port to the agent port.
-		for _, cn := range c.Containers {
-			for _, ic := range agentconfig.PortUniqueIntercepts(cn) {
-				if proto == ic.Protocol {
-					err = iptables.AppendUnique(nat, chain,
-						"-p", strings.ToLower(string(proto)), "--dport", strconv.Itoa(int(ic.ContainerPort)),
-						"-j", "REDIRECT", "--to-ports", strconv.Itoa(int(ic.AgentPort)))
-					if err != nil {
-						return fmt.Errorf("failed to append rule to %s: %w", chain, err)
-					}
-					hasRule = true
-				}
-			}
-		}
+	// clean removes all rules of the given family that install has added for the given agentUID.
+	clean(ctx context.Context, family ipFamily, agentUID string) error
+}
 
-		// Direct everything coming into PREROUTING into our own inbound chain.
-		// We do this as an append instead of an insert because this will prevent us from interfering with a service mesh
-		// if one exists. If a service mesh exists, its PREROUTING rules will kick in before ours, ensuring traffic
-		// coming into the pod does not bypass the mesh.
-		err = iptables.AppendUnique(nat, "PREROUTING",
-			"-p", strings.ToLower(string(proto)),
-			"-j", chain)
-		if err != nil {
-			return fmt.Errorf("failed to append prerouting rule to direct to %s: %w", chain, err)
-		}
+const (
+	backendAuto     = "auto"
+	backendIptables = "iptables"
+	backendNftables = "nftables"
+)
 
-		// Any traffic heading out of the loopback and into the app port (other than traffic from the agent) needs to
-		// be redirected to the agent. This will ensure that if there's a service mesh, when the mesh's proxy goes to
-		// request the application, it will get a response via the traffic agent.
-		err = iptables.Insert(nat, "OUTPUT", 1,
-			"-o", loopback,
-			"-m", "owner", "!", "--uid-owner", agentUID,
-			"-j", chain)
-		if err != nil {
-			return fmt.Errorf("failed to insert ! --gid-owner rule in OUTPUT: %w", err)
+// newFirewall returns the firewall of the given backend. An empty or "auto" backend will use iptables
+// when the kernel supports its nat table, and nftables otherwise. Iptables is preferred because
+// service meshes typically use it, and the agent's rules must interact with the mesh's rules.
+func newFirewall(ctx context.Context, backend string) (firewall, error) {
+	switch backend {
+	case backendIptables:
+		return newIptablesFirewall(ctx)
+	case backendNftables:
+		return newNftablesFirewall(ctx)
+	case "", backendAuto:
+		fw, err := newIptablesFirewall(ctx)
+		if err == nil {
+			return fw, nil
 		}
-		outputInsertCount++
-
-		// Any agent traffic heading out on the loopback but NOT towards localhost needs to be processed in case
-		// it needs to be redirected. This is so that if the traffic agent requests its own IP, it doesn't just
-		// serve the app but actually goes through the agent, and thus through any intercepts.
-		// This is needed to support requesting an intercepted pod by IP (or to intercept a headless service).
-		err = iptables.Insert(nat, "OUTPUT", 1,
-			"-o", loopback,
-			"-p", strings.ToLower(string(proto)),
-			"!", "-d", "127.0.0.1/32",
-			"-m", "owner", "--uid-owner", agentUID,
-			"-j", chain)
-		if err != nil {
-			return fmt.Errorf("failed to insert --gid-owner rule in OUTPUT: %w", err)
+		dlog.Infof(ctx, "iptables is not usable, probing for nftables: %v", err)
+		if fw, nftErr := newNftablesFirewall(ctx); nftErr == nil {
+			return fw, nil
 		}
-		outputInsertCount++
-	}
-
-	// Finally, any other traffic heading out of the traffic agent should pass by unperturbed -- it should obviously not be
-	// redirected back into the agent, but it also should not pass through a mesh proxy.
-	// This will include not just agent->manager traffic but also the agent requesting 127.0.0.1:appPort to serve the application
-	err := iptables.Insert(nat, "OUTPUT", 1+outputInsertCount,
-		"-m", "owner", "--uid-owner", agentUID,
-		"-j", "RETURN")
-	if err != nil {
-		return fmt.Errorf("failed to insert --gid-owner rule in OUTPUT: %w", err)
+		return nil, fmt.Errorf("found no usable firewall backend: %w", err)
+	default:
+		return nil, fmt.Errorf("invalid firewall backend %q, must be %q, %q, or %q", backend, backendAuto, backendIptables, backendNftables)
 	}
-	return nil
 }
 
 func findLoopback(ctx context.Context) (string, error) {
@@ -160,7 +154,77 @@ func findLoopback(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("unable to find loopback network interface")
 }
 
-// Main is the main function for the agent init container
+// findFamilies returns the IP families of the addresses of the pod's network interfaces. Pods that
+// have no addresses other than link-local ones are assumed to be IPv4 only.
+func findFamilies(ctx context.Context) (hasIPv4, hasIPv6 bool, err error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			dlog.Warnf(ctx, "unable to get addresses of interface %s: %v", iface.Name, err)
+			continue
+		}
+		for _, addr := range addrs {
+			if ipn, ok := addr.(*net.IPNet); ok && ipn.IP.IsGlobalUnicast() {
+				if ipn.IP.To4() != nil {
+					hasIPv4 = true
+				} else {
+					hasIPv6 = true
+				}
+			}
+		}
+	}
+	if !(hasIPv4 || hasIPv6) {
+		hasIPv4 = true
+	}
+	return hasIPv4, hasIPv6, nil
+}
+
+// agentUID returns the UID of the traffic-agent container of the given config. It's the RunAsUser of the
+// agent's security context when one is declared. Otherwise, the agent runs with the same image and pod
+// security context as this init container, and hence with the same UID.
+func agentUID(sc *agentconfig.Sidecar) int64 {
+	if s := sc.SecurityContext; s != nil && s.RunAsUser != nil {
+		return *s.RunAsUser
+	}
+	return int64(os.Getuid())
+}
+
+func (c *config) configureFirewall(ctx context.Context, fw firewall, loopback string, uid int64) error {
+	hasIPv4, hasIPv6, err := findFamilies(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A service mesh will typically use an UID different from the one used by the traffic-agent
+	agentUID := strconv.FormatInt(uid, 10)
+	prs := c.redirects()
+	if hasIPv4 {
+		if err = fw.install(ctx, ipv4, loopback, agentUID, prs); err != nil {
+			return err
+		}
+	}
+	if hasIPv6 {
+		if err = fw.install(ctx, ipv6, loopback, agentUID, prs); err != nil {
+			if hasIPv4 {
+				// The IPv4 rules are in place, so a dual-stack pod can still be intercepted using IPv4.
+				dlog.Warnf(ctx, "unable to configure IPv6 redirects, only IPv4 traffic can be intercepted: %v", err)
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Main is the main function for the agent init container. When called with the argument "clean", the
+// rules that have been installed by a previous run are removed.
 func Main(ctx context.Context, args ...string) error {
 	dlog.Infof(ctx, "Traffic Agent Init %s", version.Version)
 	defer func() {
@@ -168,25 +232,67 @@ func Main(ctx context.Context, args ...string) error {
 			dlog.Error(ctx, derror.PanicToError(r))
 		}
 	}()
+	if len(args) > 0 && args[0] != "clean" {
+		return fmt.Errorf("unknown argument %q", args[0])
+	}
 	cfg, err := loadConfig(ctx)
+	if len(args) > 0 {
+		uid := int64(os.Getuid())
+		if err == nil {
+			uid = agentUID(&cfg.Sidecar)
+		} else {
+			dlog.Warnf(ctx, "removing the rules of UID %d: %v", uid, err)
+		}
+		err = CleanFirewall(ctx, uid)
+	} else if err == nil {
+		err = ConfigureFirewall(ctx, &cfg.Sidecar, agentUID(&cfg.Sidecar))
+	}
 	if err != nil {
 		dlog.Error(ctx, err)
-		return err
 	}
+	return err
+}
 
+// ConfigureFirewall installs the rules that redirect the intercepted ports of the given config in the
+// network namespace of the calling thread, using the config's firewall backend. The traffic that is owned
+// by the given agentUID is treated as the traffic-agent's own.
+func ConfigureFirewall(ctx context.Context, sc *agentconfig.Sidecar, agentUID int64) error {
 	lo, err := findLoopback(ctx)
 	if err != nil {
-		dlog.Error(ctx, err)
 		return err
 	}
-	it, err := iptables.New()
+	fw, err := newFirewall(ctx, sc.FirewallBackend)
 	if err != nil {
-		err = fmt.Errorf("unable to create iptables instance: %w", err)
-		dlog.Error(ctx, err)
 		return err
 	}
-	if err = cfg.configureIptables(ctx, it, lo); err != nil {
-		dlog.Error(ctx, err)
+	return (&config{Sidecar: *sc}).configureFirewall(ctx, fw, lo, agentUID)
+}
+
+// CleanFirewall removes the rules that ConfigureFirewall has installed for the given agentUID from the
+// network namespace of the calling thread. Both families are cleaned in all available backends, because
+// the backend that installed the rules may have been chosen by probing.
+func CleanFirewall(ctx context.Context, agentUID int64) error {
+	uid := strconv.FormatInt(agentUID, 10)
+	var errs derror.MultiError
+	cleaned := false
+	for _, backend := range []string{backendIptables, backendNftables} {
+		fw, err := newFirewall(ctx, backend)
+		if err != nil {
+			dlog.Debugf(ctx, "skipping %s: %v", backend, err)
+			continue
+		}
+		cleaned = true
+		for _, family := range []ipFamily{ipv4, ipv6} {
+			if err = fw.clean(ctx, family, uid); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
-	return err
+	if !cleaned {
+		errs = append(errs, fmt.Errorf("found no usable firewall backend"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
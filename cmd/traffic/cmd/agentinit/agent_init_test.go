@@ -0,0 +1,116 @@
+//go:build !windows
+// +build !windows
+
+package agentinit
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+// recordingFirewall records the agent UIDs that the rules are installed with.
+type recordingFirewall struct {
+	agentUIDs []string
+}
+
+func (f *recordingFirewall) install(_ context.Context, _ ipFamily, _, agentUID string, _ []protoRedirects) error {
+	f.agentUIDs = append(f.agentUIDs, agentUID)
+	return nil
+}
+
+func (f *recordingFirewall) clean(context.Context, ipFamily, string) error {
+	return nil
+}
+
+func testConfig() *config {
+	return &config{Sidecar: agentconfig.Sidecar{
+		Containers: []*agentconfig.Container{
+			{
+				Name: "echo",
+				Intercepts: []*agentconfig.Intercept{
+					{ContainerPort: 8080, AgentPort: 9900, Protocol: core.ProtocolTCP},
+					{ContainerPort: 8080, AgentPort: 9900, Protocol: core.ProtocolTCP},
+					{ContainerPort: 53, AgentPort: 9901, Protocol: core.ProtocolUDP},
+				},
+			},
+			{
+				Name: "other",
+				Intercepts: []*agentconfig.Intercept{
+					{ContainerPort: 8443, AgentPort: 9902, Protocol: core.ProtocolTCP},
+				},
+			},
+		},
+	}}
+}
+
+func Test_redirects(t *testing.T) {
+	assert.Equal(t, []protoRedirects{
+		{proto: core.ProtocolTCP, redirects: []redirect{{8080, 9900}, {8443, 9902}}},
+		{proto: core.ProtocolUDP, redirects: []redirect{{53, 9901}}},
+	}, testConfig().redirects())
+	assert.Empty(t, (&config{}).redirects())
+}
+
+func Test_agentUID(t *testing.T) {
+	sc := &testConfig().Sidecar
+	assert.Equal(t, int64(os.Getuid()), agentUID(sc))
+	sc.SecurityContext = &agentconfig.SecurityContext{}
+	assert.Equal(t, int64(os.Getuid()), agentUID(sc))
+	uid := int64(1234)
+	sc.SecurityContext.RunAsUser = &uid
+	assert.Equal(t, uid, agentUID(sc))
+}
+
+func Test_configureFirewall(t *testing.T) {
+	fw := &recordingFirewall{}
+	require.NoError(t, testConfig().configureFirewall(dlog.NewTestContext(t, false), fw, "lo", 1234))
+	require.NotEmpty(t, fw.agentUIDs)
+	for _, uid := range fw.agentUIDs {
+		assert.Equal(t, "1234", uid)
+	}
+}
+
+func Test_nftInstallScript(t *testing.T) {
+	prs := testConfig().redirects()
+	assert.Equal(t, `table ip telepresence
+delete table ip telepresence
+table ip telepresence {
+	chain inbound_tcp {
+		tcp dport 8080 redirect to :9900
+		tcp dport 8443 redirect to :9902
+	}
+	chain inbound_udp {
+		udp dport 53 redirect to :9901
+	}
+	chain prerouting {
+		type nat hook prerouting priority -90; policy accept;
+		meta l4proto tcp jump inbound_tcp
+		meta l4proto udp jump inbound_udp
+	}
+	chain output {
+		type nat hook output priority -110; policy accept;
+		oifname "lo" meta skuid != 7777 jump inbound_tcp
+		oifname "lo" meta l4proto tcp ip daddr != 127.0.0.1/32 meta skuid 7777 jump inbound_tcp
+		oifname "lo" meta skuid != 7777 jump inbound_udp
+		oifname "lo" meta l4proto udp ip daddr != 127.0.0.1/32 meta skuid 7777 jump inbound_udp
+		meta skuid 7777 return
+	}
+}
+`, nftInstallScript(ipv4, "lo", "7777", prs))
+
+	script := nftInstallScript(ipv6, "lo", "7777", prs)
+	assert.Contains(t, script, "table ip6 telepresence {\n")
+	assert.Contains(t, script, "oifname \"lo\" meta l4proto tcp ip6 daddr != ::1/128 meta skuid 7777 jump inbound_tcp\n")
+}
+
+func Test_nftDeleteTable(t *testing.T) {
+	assert.Equal(t, "table ip6 telepresence\ndelete table ip6 telepresence\n", nftDeleteTable(ipv6))
+}
@@ -0,0 +1,208 @@
+//go:build !windows
+// +build !windows
+
+package agentinit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+	core "k8s.io/api/core/v1"
+)
+
+const nat = "nat"
+const inboundChain = "TEL_INBOUND"
+
+// iptablesFirewall programs the rules using the iptables and ip6tables binaries.
+type iptablesFirewall struct {
+	ipv4 *iptables.IPTables
+}
+
+// newIptablesFirewall returns a firewall that uses iptables, provided that the iptables binary exists and
+// that the kernel supports its nat table.
+func newIptablesFirewall(ctx context.Context) (firewall, error) {
+	it, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create iptables instance: %w", err)
+	}
+	if _, err = it.ListChains(nat); err != nil {
+		return nil, fmt.Errorf("unable to list the chains of the iptables nat table: %w", err)
+	}
+	return &iptablesFirewall{ipv4: it}, nil
+}
+
+func (f *iptablesFirewall) tables(family ipFamily) (*iptables.IPTables, error) {
+	if family == ipv4 {
+		return f.ipv4, nil
+	}
+	it, err := iptables.NewWithProtocol(iptables.ProtocolIPv6)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ip6tables instance: %w", err)
+	}
+	return it, nil
+}
+
+func chainName(proto core.Protocol) string {
+	return inboundChain + "_" + string(proto)
+}
+
+// outputRules returns the rules that install inserts into the OUTPUT chain for the given protocol.
+func outputRules(family ipFamily, loopback, agentUID string, proto core.Protocol) [][]string {
+	chain := chainName(proto)
+	return [][]string{
+		// Any traffic heading out of the loopback and into the app port (other than traffic from the agent) needs to
+		// be redirected to the agent. This will ensure that if there's a service mesh, when the mesh's proxy goes to
+		// request the application, it will get a response via the traffic agent.
+		{
+			"-o", loopback,
+			"-m", "owner", "!", "--uid-owner", agentUID,
+			"-j", chain,
+		},
+		// Any agent traffic heading out on the loopback but NOT towards localhost needs to be processed in case
+		// it needs to be redirected. This is so that if the traffic agent requests its own IP, it doesn't just
+		// serve the app but actually goes through the agent, and thus through any intercepts.
+		// This is needed to support requesting an intercepted pod by IP (or to intercept a headless service).
+		{
+			"-o", loopback,
+			"-p", strings.ToLower(string(proto)),
+			"!", "-d", family.localhost(),
+			"-m", "owner", "--uid-owner", agentUID,
+			"-j", chain,
+		},
+	}
+}
+
+// returnRule is the rule that lets any other traffic heading out of the traffic agent pass by unperturbed.
+func returnRule(agentUID string) []string {
+	return []string{
+		"-m", "owner", "--uid-owner", agentUID,
+		"-j", "RETURN",
+	}
+}
+
+func (f *iptablesFirewall) install(ctx context.Context, family ipFamily, loopback, agentUID string, prs []protoRedirects) error {
+	it, err := f.tables(family)
+	if err != nil {
+		return err
+	}
+
+	// Remove the rules of a previous run. Rules are inserted into the OUTPUT chain, so they would otherwise
+	// be duplicated if the init container is restarted.
+	if err = cleanTables(it, agentUID); err != nil {
+		return fmt.Errorf("failed to remove %s rules: %w", family, err)
+	}
+
+	outputInsertCount := 0
+	for _, pr := range prs {
+		proto := pr.proto
+
+		// Clearing the inbound chain will create it if it doesn't exist, or clear it out if it does.
+		chain := chainName(proto)
+		err = it.ClearChain(nat, chain)
+		if err != nil {
+			return fmt.Errorf("failed to clear %s chain %s: %w", family, chain, err)
+		}
+
+		// Use our inbound chain to direct traffic coming into the app port to the agent port.
+		for _, rd := range pr.redirects {
+			err = it.AppendUnique(nat, chain,
+				"-p", strings.ToLower(string(proto)), "--dport", fmt.Sprint(rd.containerPort),
+				"-j", "REDIRECT", "--to-ports", fmt.Sprint(rd.agentPort))
+			if err != nil {
+				return fmt.Errorf("failed to append rule to %s chain %s: %w", family, chain, err)
+			}
+		}
+
+		// Direct everything coming into PREROUTING into our own inbound chain.
+		// We do this as an append instead of an insert because this will prevent us from interfering with a service mesh
+		// if one exists. If a service mesh exists, its PREROUTING rules will kick in before ours, ensuring traffic
+		// coming into the pod does not bypass the mesh.
+		err = it.AppendUnique(nat, "PREROUTING",
+			"-p", strings.ToLower(string(proto)),
+			"-j", chain)
+		if err != nil {
+			return fmt.Errorf("failed to append %s prerouting rule to direct to %s: %w", family, chain, err)
+		}
+
+		for _, rule := range outputRules(family, loopback, agentUID, proto) {
+			if err = it.Insert(nat, "OUTPUT", 1, rule...); err != nil {
+				return fmt.Errorf("failed to insert %s rule in OUTPUT: %w", family, err)
+			}
+			outputInsertCount++
+		}
+	}
+
+	// Finally, any other traffic heading out of the traffic agent should pass by unperturbed -- it should obviously not be
+	// redirected back into the agent, but it also should not pass through a mesh proxy.
+	// This will include not just agent->manager traffic but also the agent requesting 127.0.0.1:appPort to serve the application
+	err = it.Insert(nat, "OUTPUT", 1+outputInsertCount, returnRule(agentUID)...)
+	if err != nil {
+		return fmt.Errorf("failed to insert %s --uid-owner rule in OUTPUT: %w", family, err)
+	}
+	return nil
+}
+
+func (f *iptablesFirewall) clean(ctx context.Context, family ipFamily, agentUID string) error {
+	it, err := f.tables(family)
+	if err != nil {
+		if family == ipv6 {
+			// Without ip6tables, there can be no rules to remove.
+			return nil
+		}
+		return err
+	}
+	if err = cleanTables(it, agentUID); err != nil {
+		return fmt.Errorf("failed to remove %s rules: %w", family, err)
+	}
+	return nil
+}
+
+// cleanTables removes the rules that jump to the inbound chains from the PREROUTING and OUTPUT chains,
+// the RETURN rule of the given agent UID, and the inbound chains.
+func cleanTables(it *iptables.IPTables, agentUID string) error {
+	for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+		rules, err := it.List(nat, chain)
+		if err != nil {
+			return fmt.Errorf("failed to list rules in %s: %w", chain, err)
+		}
+		for _, rule := range rules {
+			// The rules are listed in the form "-A <chain> <rulespec>"
+			fields := strings.Fields(rule)
+			if len(fields) < 4 || fields[0] != "-A" {
+				continue
+			}
+			spec := fields[2:]
+			if target := spec[len(spec)-1]; spec[len(spec)-2] == "-j" && strings.HasPrefix(target, inboundChain+"_") {
+				if err = it.Delete(nat, chain, spec...); err != nil {
+					return fmt.Errorf("failed to delete rule from %s: %w", chain, err)
+				}
+			}
+		}
+	}
+	for {
+		exists, err := it.Exists(nat, "OUTPUT", returnRule(agentUID)...)
+		if err != nil {
+			return fmt.Errorf("failed to check rule in OUTPUT: %w", err)
+		}
+		if !exists {
+			break
+		}
+		if err = it.Delete(nat, "OUTPUT", returnRule(agentUID)...); err != nil {
+			return fmt.Errorf("failed to delete rule from OUTPUT: %w", err)
+		}
+	}
+	chains, err := it.ListChains(nat)
+	if err != nil {
+		return fmt.Errorf("failed to list nat chains: %w", err)
+	}
+	for _, chain := range chains {
+		if strings.HasPrefix(chain, inboundChain+"_") {
+			if err = it.ClearAndDeleteChain(nat, chain); err != nil {
+				return fmt.Errorf("failed to delete chain %s: %w", chain, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+//go:build !windows
+// +build !windows
+
+package agentinit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+
+	"github.com/datawire/dlib/dexec"
+)
+
+const nftTable = "telepresence"
+
+// The priorities of the nftables base chains, relative to the priority of the iptables nat chains (-100) that
+// a service mesh typically uses. The prerouting chain is evaluated after the mesh's, which corresponds to
+// appending to PREROUTING, and the output chain is evaluated before the mesh's, which corresponds to
+// inserting first in OUTPUT.
+const (
+	nftPreroutingPriority = -90
+	nftOutputPriority     = -110
+)
+
+// nftablesFirewall programs the rules using the nft binary. All rules are kept in a table of their own, so
+// replacing or removing them is a matter of deleting that table.
+//
+// A verdict in one nftables table doesn't stop the base chains of other tables from seeing the traffic, so
+// the RETURN rule that exempts the agent's own traffic from a service mesh is inserted into the nat table
+// that the mesh's iptables (which on such nodes is iptables-nft) uses, using iptables-nft.
+type nftablesFirewall struct{}
+
+// newNftablesFirewall returns a firewall that uses nftables, provided that the nft binary exists and that
+// the kernel supports nftables.
+func newNftablesFirewall(ctx context.Context) (firewall, error) {
+	if err := dexec.CommandContext(ctx, "nft", "list", "tables").Run(); err != nil {
+		return nil, fmt.Errorf("unable to list nftables tables: %w", err)
+	}
+	return nftablesFirewall{}, nil
+}
+
+func (f ipFamily) nftName() string {
+	if f == ipv6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+func nftChainName(proto core.Protocol) string {
+	return "inbound_" + strings.ToLower(string(proto))
+}
+
+// nftDeleteTable returns a script that deletes the table of the given family. The table is declared before
+// it's deleted, because deleting a table that doesn't exist is an error.
+func nftDeleteTable(family ipFamily) string {
+	return fmt.Sprintf("table %[1]s %[2]s\ndelete table %[1]s %[2]s\n", family.nftName(), nftTable)
+}
+
+// nftInstallScript returns a script that replaces the table of the given family with a table that
+// contains the same rules as those that the iptablesFirewall installs.
+func nftInstallScript(family ipFamily, loopback, agentUID string, prs []protoRedirects) string {
+	sb := strings.Builder{}
+	sb.WriteString(nftDeleteTable(family))
+	fmt.Fprintf(&sb, "table %s %s {\n", family.nftName(), nftTable)
+
+	// Use our inbound chains to direct traffic coming into the app port to the agent port.
+	for _, pr := range prs {
+		proto := strings.ToLower(string(pr.proto))
+		fmt.Fprintf(&sb, "\tchain %s {\n", nftChainName(pr.proto))
+		for _, rd := range pr.redirects {
+			fmt.Fprintf(&sb, "\t\t%s dport %d redirect to :%d\n", proto, rd.containerPort, rd.agentPort)
+		}
+		sb.WriteString("\t}\n")
+	}
+
+	// Direct everything coming into prerouting into our own inbound chains, after a service mesh has had
+	// its chance to redirect it, so that traffic coming into the pod does not bypass the mesh.
+	fmt.Fprintf(&sb, "\tchain prerouting {\n\t\ttype nat hook prerouting priority %d; policy accept;\n", nftPreroutingPriority)
+	for _, pr := range prs {
+		fmt.Fprintf(&sb, "\t\tmeta l4proto %s jump %s\n", strings.ToLower(string(pr.proto)), nftChainName(pr.proto))
+	}
+	sb.WriteString("\t}\n")
+
+	fmt.Fprintf(&sb, "\tchain output {\n\t\ttype nat hook output priority %d; policy accept;\n", nftOutputPriority)
+	for _, pr := range prs {
+		chain := nftChainName(pr.proto)
+
+		// Any traffic heading out of the loopback and into the app port (other than traffic from the agent)
+		// is redirected to the agent, so that a mesh's proxy gets a response via the traffic agent.
+		fmt.Fprintf(&sb, "\t\toifname %q meta skuid != %s jump %s\n", loopback, agentUID, chain)
+
+		// Agent traffic heading out on the loopback but NOT towards localhost is processed in case it needs
+		// to be redirected, so that requests for the pod's own IP go through the agent and its intercepts.
+		fmt.Fprintf(&sb, "\t\toifname %q meta l4proto %s %s daddr != %s meta skuid %s jump %s\n",
+			loopback, strings.ToLower(string(pr.proto)), family.nftName(), family.localhost(), agentUID, chain)
+	}
+
+	// Any other traffic heading out of the traffic agent passes by unperturbed. The exemption from the mesh
+	// is added by exemptFromMesh.
+	fmt.Fprintf(&sb, "\t\tmeta skuid %s return\n", agentUID)
+	sb.WriteString("\t}\n}\n")
+	return sb.String()
+}
+
+func runNft(ctx context.Context, script string) error {
+	cmd := dexec.CommandContext(ctx, "nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	return cmd.Run()
+}
+
+// xtablesNft returns the iptables-nft command of the family.
+func (f ipFamily) xtablesNft() string {
+	if f == ipv6 {
+		return "ip6tables-nft"
+	}
+	return "iptables-nft"
+}
+
+func runXtablesNft(ctx context.Context, family ipFamily, args ...string) error {
+	cmd := dexec.CommandContext(ctx, family.xtablesNft(), append([]string{"-t", nat}, args...)...)
+	cmd.DisableLogging = true
+	return cmd.Run()
+}
+
+// exemptFromMesh inserts the RETURN rule of the given agent UID first in the OUTPUT chain of the nat
+// table that iptables-nft uses, so that the traffic-agent's own traffic passes by the redirects of a
+// service mesh, just like it does with the iptablesFirewall. The table and chain are created if they
+// don't exist, so that the rule precedes the rules of a mesh that is programmed later.
+func exemptFromMesh(ctx context.Context, family ipFamily, agentUID string) error {
+	if err := removeMeshExemption(ctx, family, agentUID); err != nil {
+		return err
+	}
+	if err := runXtablesNft(ctx, family, append([]string{"-I", "OUTPUT", "1"}, returnRule(agentUID)...)...); err != nil {
+		return fmt.Errorf("failed to insert %s --uid-owner rule in OUTPUT using %s: %w", family, family.xtablesNft(), err)
+	}
+	return nil
+}
+
+// removeMeshExemption removes the RETURN rules that exemptFromMesh has inserted.
+func removeMeshExemption(ctx context.Context, family ipFamily, agentUID string) error {
+	// The check fails when the rule, the chain, or the table doesn't exist.
+	for runXtablesNft(ctx, family, append([]string{"-C", "OUTPUT"}, returnRule(agentUID)...)...) == nil {
+		if err := runXtablesNft(ctx, family, append([]string{"-D", "OUTPUT"}, returnRule(agentUID)...)...); err != nil {
+			return fmt.Errorf("failed to delete %s --uid-owner rule from OUTPUT using %s: %w", family, family.xtablesNft(), err)
+		}
+	}
+	return nil
+}
+
+func (nftablesFirewall) install(ctx context.Context, family ipFamily, loopback, agentUID string, prs []protoRedirects) error {
+	if err := runNft(ctx, nftInstallScript(family, loopback, agentUID, prs)); err != nil {
+		return fmt.Errorf("failed to install %s nftables rules: %w", family, err)
+	}
+	return exemptFromMesh(ctx, family, agentUID)
+}
+
+func (nftablesFirewall) clean(ctx context.Context, family ipFamily, agentUID string) error {
+	if err := runNft(ctx, nftDeleteTable(family)); err != nil {
+		return fmt.Errorf("failed to remove %s nftables rules: %w", family, err)
+	}
+	return removeMeshExemption(ctx, family, agentUID)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package cni
+
+import (
+	"context"
+	"errors"
+)
+
+// The CNI plugin enters the network namespaces of pods, which is only possible on Linux nodes.
+
+// Main is the main function of the CNI plugin.
+func Main(ctx context.Context, args ...string) error {
+	return errors.New("the traffic CNI plugin is only supported on linux")
+}
+
+// InstallMain is the main function of the container of the CNI DaemonSet.
+func InstallMain(ctx context.Context, args ...string) error {
+	return errors.New("the traffic CNI plugin is only supported on linux")
+}
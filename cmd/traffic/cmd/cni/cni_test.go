@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package cni
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+func Test_parseArgs(t *testing.T) {
+	args := parseArgs("IgnoreUnknown=1;K8S_POD_NAMESPACE=default;K8S_POD_NAME=echo-7d4c5f9-x2x;K8S_POD_INFRA_CONTAINER_ID=abc")
+	assert.Equal(t, "default", args["K8S_POD_NAMESPACE"])
+	assert.Equal(t, "echo-7d4c5f9-x2x", args["K8S_POD_NAME"])
+	assert.Empty(t, parseArgs(""))
+}
+
+func Test_podAgentName(t *testing.T) {
+	pod := &core.Pod{Spec: core.PodSpec{
+		Containers: []core.Container{{Name: "echo"}},
+		Volumes:    agentconfig.AgentVolumes("echo"),
+	}}
+	assert.Empty(t, podAgentName(pod), "pod without a traffic-agent")
+	pod.Spec.Containers = append(pod.Spec.Containers, core.Container{Name: agentconfig.ContainerName})
+	assert.Equal(t, "echo", podAgentName(pod))
+}
+
+func Test_podAgentUID(t *testing.T) {
+	podUID, agentUID := int64(1000), int64(2000)
+	pod := &core.Pod{Spec: core.PodSpec{
+		Containers: []core.Container{{Name: "echo"}, {Name: agentconfig.ContainerName}},
+	}}
+	assert.Equal(t, int64(0), podAgentUID(pod))
+	pod.Spec.SecurityContext = &core.PodSecurityContext{RunAsUser: &podUID}
+	assert.Equal(t, podUID, podAgentUID(pod))
+	pod.Spec.Containers[1].SecurityContext = &core.SecurityContext{RunAsUser: &agentUID}
+	assert.Equal(t, agentUID, podAgentUID(pod))
+}
+
+func readPlugins(t *testing.T, file string) []any {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var conf map[string]any
+	require.NoError(t, json.Unmarshal(data, &conf))
+	plugins, ok := conf["plugins"].([]any)
+	require.True(t, ok)
+	return plugins
+}
+
+func Test_addPlugin(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20-other.conflist"), []byte(`{"plugins":[]}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "10-flannel.conf"),
+		[]byte(`{"cniVersion":"0.3.1","name":"cbr0","type":"flannel","delegate":{"isDefaultGateway":true}}`), 0o644))
+
+	// A single plugin configuration is converted into a list
+	require.NoError(t, addPlugin(ctx, dir, "/etc/cni/net.d/traffic-cni.kubeconfig"))
+	assert.NoFileExists(t, filepath.Join(dir, "10-flannel.conf"))
+	listFile := filepath.Join(dir, "10-flannel.conflist")
+	plugins := readPlugins(t, listFile)
+	require.Len(t, plugins, 2)
+	assert.Equal(t, "flannel", plugins[0].(map[string]any)["type"])
+	assert.Equal(t, map[string]any{"type": PluginType, "kubeconfig": "/etc/cni/net.d/traffic-cni.kubeconfig"}, plugins[1])
+
+	// Adding again is a no-op
+	require.NoError(t, addPlugin(ctx, dir, "/etc/cni/net.d/traffic-cni.kubeconfig"))
+	assert.Len(t, readPlugins(t, listFile), 2)
+
+	require.NoError(t, removePlugin(dir))
+	plugins = readPlugins(t, listFile)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "flannel", plugins[0].(map[string]any)["type"])
+	assert.Len(t, readPlugins(t, filepath.Join(dir, "20-other.conflist")), 0)
+}
+
+func Test_del(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	conf := &netConf{Kubeconfig: filepath.Join(t.TempDir(), "missing.kubeconfig")}
+	args := map[string]string{"K8S_POD_NAME": "hello", "K8S_POD_NAMESPACE": "default"}
+
+	// A network namespace that is already gone has no rules to remove
+	assert.NoError(t, del(ctx, conf, "", args))
+	assert.NoError(t, del(ctx, conf, filepath.Join(t.TempDir(), "netns"), args))
+
+	// Neither does one of a pod that can't be read
+	assert.NoError(t, del(ctx, conf, t.TempDir(), args))
+}
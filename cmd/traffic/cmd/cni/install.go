@@ -0,0 +1,330 @@
+//go:build linux
+// +build linux
+
+package cni
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/version"
+)
+
+const (
+	kubeconfigFile    = PluginType + ".kubeconfig"
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// installEnv is the configuration of the installer, read from the environment.
+type installEnv struct {
+	// BinDir is where the node's CNI binaries are mounted in the installer's container.
+	BinDir string `env:"CNI_BIN_DIR,default=/host/opt/cni/bin"`
+
+	// ConfDir is where the node's CNI network configurations are mounted in the installer's container.
+	ConfDir string `env:"CNI_CONF_DIR,default=/host/etc/cni/net.d"`
+
+	// HostConfDir is the path of the node's CNI network configurations on the node.
+	HostConfDir string `env:"CNI_HOST_CONF_DIR,default=/etc/cni/net.d"`
+
+	// Interval is how often the installer verifies that the plugin is present in the network configuration.
+	Interval time.Duration `env:"CNI_CHECK_INTERVAL,default=10s"`
+}
+
+// InstallMain is the main function of the container of the CNI DaemonSet. It installs the plugin binary
+// and a kubeconfig for the plugin on the node, and adds the plugin to the node's primary network
+// configuration. The kubeconfig and the network configuration are kept up to date until the container
+// is terminated, at which time the plugin is uninstalled.
+func InstallMain(ctx context.Context, args ...string) error {
+	dlog.Infof(ctx, "Traffic CNI installer %s", version.Version)
+	var env installEnv
+	if err := envconfig.Process(ctx, &env); err != nil {
+		return err
+	}
+
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{
+		EnableSignalHandling: true,
+	})
+	g.Go("cni-install", func(ctx context.Context) error {
+		if err := installBinary(env.BinDir); err != nil {
+			return err
+		}
+		if err := verifyBinary(ctx, filepath.Join(env.BinDir, PluginType)); err != nil {
+			_ = uninstall(ctx, &env)
+			return err
+		}
+		hostKubeconfig := filepath.Join(env.HostConfDir, kubeconfigFile)
+		ticker := time.NewTicker(env.Interval)
+		defer ticker.Stop()
+		for {
+			if err := writeKubeconfig(filepath.Join(env.ConfDir, kubeconfigFile)); err != nil {
+				dlog.Errorf(ctx, "unable to write kubeconfig: %v", err)
+			}
+			if err := addPlugin(ctx, env.ConfDir, hostKubeconfig); err != nil {
+				dlog.Errorf(ctx, "unable to add %s to the network configuration: %v", PluginType, err)
+			}
+			select {
+			case <-ctx.Done():
+				return uninstall(ctx, &env)
+			case <-ticker.C:
+			}
+		}
+	})
+	return g.Wait()
+}
+
+func uninstall(ctx context.Context, env *installEnv) error {
+	dlog.Infof(ctx, "Uninstalling %s", PluginType)
+	err := removePlugin(env.ConfDir)
+	for _, file := range []string{filepath.Join(env.ConfDir, kubeconfigFile), filepath.Join(env.BinDir, PluginType)} {
+		if rmErr := os.Remove(file); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// installBinary copies the executable of this process to the plugin binary in the given directory.
+func installBinary(binDir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(exe)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return writeFileAtomic(filepath.Join(binDir, PluginType), 0o755, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// verifyBinary verifies that the installed plugin binary can run on the host. The container runtime
+// executes it outside of this container, so it must not depend on a dynamic loader or libraries of the
+// image, and it must answer the VERSION command.
+func verifyBinary(ctx context.Context, file string) error {
+	ef, err := elf.Open(file)
+	if err != nil {
+		return fmt.Errorf("unable to read plugin binary %s: %w", file, err)
+	}
+	defer ef.Close()
+	for _, p := range ef.Progs {
+		if p.Type == elf.PT_INTERP {
+			return fmt.Errorf("plugin binary %s is dynamically linked, so it might not run on the host", file)
+		}
+	}
+	cmd := dexec.CommandContext(ctx, file)
+	cmd.Env = append(os.Environ(), "CNI_COMMAND=VERSION")
+	cmd.DisableLogging = true
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("plugin binary %s failed to report its version: %w", file, err)
+	}
+	var vi struct {
+		SupportedVersions []string `json:"supportedVersions"`
+	}
+	if err = json.Unmarshal(out, &vi); err != nil || len(vi.SupportedVersions) == 0 {
+		return fmt.Errorf("plugin binary %s reported an invalid version %q", file, out)
+	}
+	return nil
+}
+
+// writeFileAtomic writes a temporary file and renames it, so that the plugin binary and the network
+// configuration are never seen in a partially written state.
+func writeFileAtomic(file string, perm os.FileMode, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// writeKubeconfig writes a kubeconfig that uses the credentials of the installer's service account. The
+// file is rewritten when the token is rotated.
+func writeKubeconfig(file string) error {
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return err
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return err
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	cfg := clientcmdapi.NewConfig()
+	cfg.Clusters["local"] = &clientcmdapi.Cluster{
+		Server:                   "https://" + net.JoinHostPort(host, port),
+		CertificateAuthorityData: ca,
+	}
+	cfg.AuthInfos[PluginType] = &clientcmdapi.AuthInfo{Token: string(token)}
+	cfg.Contexts[PluginType] = &clientcmdapi.Context{Cluster: "local", AuthInfo: PluginType}
+	cfg.CurrentContext = PluginType
+	data, err := clientcmd.Write(*cfg)
+	if err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(file); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return writeFileAtomic(file, 0o600, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// primaryConfFile returns the network configuration that the container runtime uses, which is the first
+// file in lexical order that has one of the extensions that libcni recognizes.
+func primaryConfFile(confDir string) (string, error) {
+	des, err := os.ReadDir(confDir)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, de := range des {
+		if de.IsDir() {
+			continue
+		}
+		switch filepath.Ext(de.Name()) {
+		case ".conf", ".conflist", ".json":
+			files = append(files, de.Name())
+		}
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("found no network configuration in %s", confDir)
+	}
+	sort.Strings(files)
+	return filepath.Join(confDir, files[0]), nil
+}
+
+// readConfList reads the given network configuration. A configuration of a single plugin is returned as a
+// list that contains that plugin.
+func readConfList(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var conf map[string]any
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+	if _, ok := conf["plugins"]; !ok {
+		conf = map[string]any{
+			"cniVersion": conf["cniVersion"],
+			"name":       conf["name"],
+			"plugins":    []any{conf},
+		}
+	}
+	return conf, nil
+}
+
+// writeConfList writes the given network configuration list in place of the given file. A file that
+// isn't a .conflist is replaced by one that is.
+func writeConfList(file string, conf map[string]any) error {
+	listFile := file
+	if filepath.Ext(file) != ".conflist" {
+		listFile = strings.TrimSuffix(file, filepath.Ext(file)) + ".conflist"
+	}
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(listFile, 0o644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return err
+	}
+	if listFile != file {
+		return os.Remove(file)
+	}
+	return nil
+}
+
+func pluginIndex(plugins []any) int {
+	for i, p := range plugins {
+		if m, ok := p.(map[string]any); ok && m["type"] == PluginType {
+			return i
+		}
+	}
+	return -1
+}
+
+// addPlugin adds the plugin last in the chain of plugins of the primary network configuration, unless it
+// is already present.
+func addPlugin(ctx context.Context, confDir, kubeconfig string) error {
+	file, err := primaryConfFile(confDir)
+	if err != nil {
+		return err
+	}
+	conf, err := readConfList(file)
+	if err != nil {
+		return err
+	}
+	plugins, _ := conf["plugins"].([]any)
+	i := pluginIndex(plugins)
+	if i >= 0 && i == len(plugins)-1 && plugins[i].(map[string]any)["kubeconfig"] == kubeconfig {
+		return nil
+	}
+	if i >= 0 {
+		plugins = append(plugins[:i], plugins[i+1:]...)
+	}
+	conf["plugins"] = append(plugins, map[string]any{
+		"type":       PluginType,
+		"kubeconfig": kubeconfig,
+	})
+	dlog.Infof(ctx, "Adding %s to %s", PluginType, file)
+	return writeConfList(file, conf)
+}
+
+// removePlugin removes the plugin from the primary network configuration.
+func removePlugin(confDir string) error {
+	file, err := primaryConfFile(confDir)
+	if err != nil {
+		return err
+	}
+	conf, err := readConfList(file)
+	if err != nil {
+		return err
+	}
+	plugins, _ := conf["plugins"].([]any)
+	i := pluginIndex(plugins)
+	if i < 0 {
+		return nil
+	}
+	conf["plugins"] = append(plugins[:i], plugins[i+1:]...)
+	return writeConfList(file, conf)
+}
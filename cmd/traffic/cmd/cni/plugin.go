@@ -0,0 +1,255 @@
+//go:build linux
+// +build linux
+
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v3"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agentinit"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+// PluginType is the type of the plugin in the CNI network configuration, and the name of the plugin's binary.
+const PluginType = "traffic-cni"
+
+// supportedVersions are the versions of the CNI specification that the plugin supports.
+var supportedVersions = []string{"0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+
+// netConf is the part of the CNI network configuration that the plugin uses.
+type netConf struct {
+	CNIVersion string          `json:"cniVersion"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	PrevResult json.RawMessage `json:"prevResult,omitempty"`
+
+	// Kubeconfig is the path of the kubeconfig that the plugin uses when it reads pods and ConfigMaps.
+	Kubeconfig string `json:"kubeconfig"`
+}
+
+// cniError is the error that a CNI plugin prints on stdout when it fails.
+type cniError struct {
+	CNIVersion string `json:"cniVersion"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+}
+
+// errCodeInternal is the CNI error code used for all failures. Codes between 100 and 999 are plugin specific.
+const errCodeInternal = 999
+
+// Main is the main function of the CNI plugin. It is invoked by the container runtime with the CNI_COMMAND
+// and related variables in the environment, and the network configuration on stdin, as a plugin that is
+// chained after the pod network plugin. On ADD, it applies the same redirects that the tel-agent-init
+// container applies, to pods that have a traffic-agent, and on DEL, it removes them again. The result of
+// the previous plugin is passed on unmodified.
+func Main(ctx context.Context, args ...string) error {
+	cmd := os.Getenv("CNI_COMMAND")
+	if cmd == "VERSION" {
+		return writeJSON(os.Stdout, map[string]any{"cniVersion": supportedVersions[len(supportedVersions)-1], "supportedVersions": supportedVersions})
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return writeError(ctx, "", fmt.Errorf("unable to read network configuration: %w", err))
+	}
+	var conf netConf
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return writeError(ctx, "", fmt.Errorf("unable to parse network configuration: %w", err))
+	}
+	switch cmd {
+	case "ADD":
+		if err = add(ctx, &conf, os.Getenv("CNI_NETNS"), parseArgs(os.Getenv("CNI_ARGS"))); err != nil {
+			return writeError(ctx, conf.CNIVersion, err)
+		}
+		return writeResult(&conf)
+	case "DEL":
+		if err = del(ctx, &conf, os.Getenv("CNI_NETNS"), parseArgs(os.Getenv("CNI_ARGS"))); err != nil {
+			return writeError(ctx, conf.CNIVersion, err)
+		}
+		return nil
+	case "CHECK":
+		return nil
+	default:
+		return writeError(ctx, conf.CNIVersion, fmt.Errorf("unknown CNI_COMMAND %q", cmd))
+	}
+}
+
+// parseArgs parses the CNI_ARGS, which is a semicolon separated list of key=value pairs.
+func parseArgs(s string) map[string]string {
+	args := make(map[string]string)
+	for _, kv := range strings.Split(s, ";") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			args[k] = v
+		}
+	}
+	return args
+}
+
+// getPod returns a client and the pod that the CNI_ARGS name, or a nil pod if they name no Kubernetes pod.
+func getPod(ctx context.Context, conf *netConf, args map[string]string) (kubernetes.Interface, *core.Pod, error) {
+	podName, namespace := args["K8S_POD_NAME"], args["K8S_POD_NAMESPACE"]
+	if podName == "" || namespace == "" {
+		// Not a Kubernetes pod
+		return nil, nil, nil
+	}
+	rc, err := clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load kubeconfig %s: %w", conf.Kubeconfig, err)
+	}
+	ki, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	pod, err := ki.CoreV1().Pods(namespace).Get(ctx, podName, meta.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get pod %s.%s: %w", podName, namespace, err)
+	}
+	return ki, pod, nil
+}
+
+func add(ctx context.Context, conf *netConf, netNS string, args map[string]string) error {
+	ki, pod, err := getPod(ctx, conf, args)
+	if err != nil || pod == nil {
+		return err
+	}
+	agentName := podAgentName(pod)
+	if agentName == "" {
+		return nil
+	}
+	namespace := pod.Namespace
+	cm, err := ki.CoreV1().ConfigMaps(namespace).Get(ctx, agentconfig.ConfigMap, meta.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get ConfigMap %s.%s: %w", agentconfig.ConfigMap, namespace, err)
+	}
+	data, ok := cm.Data[agentName]
+	if !ok {
+		return fmt.Errorf("ConfigMap %s.%s has no entry for %s", agentconfig.ConfigMap, namespace, agentName)
+	}
+	var sc agentconfig.Sidecar
+	if err = yaml.Unmarshal([]byte(data), &sc); err != nil {
+		return fmt.Errorf("unable to decode agent config %s: %w", agentName, err)
+	}
+	if !agentconfig.NeedInitContainer(&sc) {
+		return nil
+	}
+	dlog.Infof(ctx, "configuring redirects of pod %s.%s", pod.Name, namespace)
+	return inNetNS(netNS, func() error {
+		return agentinit.ConfigureFirewall(ctx, &sc, podAgentUID(pod))
+	})
+}
+
+// del removes the redirects that add has applied. The CNI specification requires that DEL succeeds when
+// the network namespace or the pod is already gone, and then there are no rules left to remove.
+func del(ctx context.Context, conf *netConf, netNS string, args map[string]string) error {
+	if netNS == "" {
+		return nil
+	}
+	if _, err := os.Stat(netNS); err != nil {
+		return nil
+	}
+	_, pod, err := getPod(ctx, conf, args)
+	if err != nil {
+		dlog.Debugf(ctx, "not removing redirects: %v", err)
+		return nil
+	}
+	if pod == nil || podAgentName(pod) == "" {
+		return nil
+	}
+	dlog.Infof(ctx, "removing redirects of pod %s.%s", pod.Name, pod.Namespace)
+	return inNetNS(netNS, func() error {
+		return agentinit.CleanFirewall(ctx, podAgentUID(pod))
+	})
+}
+
+// podAgentName returns the name of the ConfigMap entry that the traffic-agent of the given pod uses, or
+// an empty string if the pod has no traffic-agent.
+func podAgentName(pod *core.Pod) string {
+	hasAgent := false
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == agentconfig.ContainerName {
+			hasAgent = true
+			break
+		}
+	}
+	if !hasAgent {
+		return ""
+	}
+	for i := range pod.Spec.Volumes {
+		v := &pod.Spec.Volumes[i]
+		if v.Name == agentconfig.ConfigVolumeName && v.ConfigMap != nil && len(v.ConfigMap.Items) == 1 {
+			return v.ConfigMap.Items[0].Key
+		}
+	}
+	return ""
+}
+
+// podAgentUID returns the UID that the traffic-agent container of the given pod runs with. The plugin runs
+// as root on the node, so unlike the init container, it can't use its own UID.
+func podAgentUID(pod *core.Pod) int64 {
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if c.Name == agentconfig.ContainerName && c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil {
+			return *c.SecurityContext.RunAsUser
+		}
+	}
+	if sc := pod.Spec.SecurityContext; sc != nil && sc.RunAsUser != nil {
+		return *sc.RunAsUser
+	}
+	// The traffic-agent image doesn't declare a user, so the agent runs as root.
+	return 0
+}
+
+// inNetNS calls the given function on a thread that has entered the network namespace at the given path.
+// The thread is never unlocked, so it is terminated when the function returns. Processes that the
+// function starts inherit the network namespace.
+func inNetNS(path string, f func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		ns, err := os.Open(path)
+		if err != nil {
+			errCh <- fmt.Errorf("unable to open network namespace: %w", err)
+			return
+		}
+		defer ns.Close()
+		if err = unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+			errCh <- fmt.Errorf("unable to enter network namespace %s: %w", path, err)
+			return
+		}
+		errCh <- f()
+	}()
+	return <-errCh
+}
+
+// writeResult writes the result of the ADD command, which is the result of the previous plugin.
+func writeResult(conf *netConf) error {
+	if len(conf.PrevResult) > 0 {
+		_, err := os.Stdout.Write(conf.PrevResult)
+		return err
+	}
+	return writeJSON(os.Stdout, map[string]any{"cniVersion": conf.CNIVersion})
+}
+
+// writeError writes the given error in the form that the CNI specification mandates, and returns it.
+func writeError(ctx context.Context, version string, err error) error {
+	dlog.Error(ctx, err)
+	_ = writeJSON(os.Stdout, &cniError{CNIVersion: version, Code: errCodeInternal, Msg: err.Error()})
+	return err
+}
+
+func writeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
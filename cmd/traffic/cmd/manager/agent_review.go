@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+// reviewAgentPeer verifies that a call made on behalf of the given agent comes from a pod of the workload
+// that the agent claims to serve. The name, namespace, and pod IP of an agent are reported by the agent
+// itself, so they can't be trusted unless the caller's address is the pod IP of such a pod.
+func reviewAgentPeer(ctx context.Context, agent *rpc.AgentInfo) error {
+	podIP := net.ParseIP(agent.PodIp)
+	if podIP == nil {
+		return status.Errorf(codes.PermissionDenied, "agent %s.%s has no valid pod IP", agent.Name, agent.Namespace)
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "unable to determine the address of the agent")
+	}
+	if peerIP := peerAddrIP(p.Addr); !podIP.Equal(peerIP) {
+		return status.Errorf(codes.PermissionDenied, "the call comes from %s, not from the pod IP %s of agent %s.%s",
+			peerIP, podIP, agent.Name, agent.Namespace)
+	}
+
+	pods, err := k8sapi.GetK8sInterface(ctx).CoreV1().Pods(agent.Namespace).List(ctx, meta.ListOptions{
+		FieldSelector: "status.podIP=" + agent.PodIp,
+	})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "unable to list the pods of agent %s.%s: %v", agent.Name, agent.Namespace, err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.PodIP != agent.PodIp {
+			continue
+		}
+		wl, err := agentmap.FindOwnerWorkload(ctx, k8sapi.Pod(pod))
+		if err != nil {
+			dlog.Debugf(ctx, "unable to find the workload of pod %s.%s: %v", pod.Name, pod.Namespace, err)
+			continue
+		}
+		if wl.GetName() == agent.Name {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "no pod of workload %s.%s has the IP %s", agent.Name, agent.Namespace, agent.PodIp)
+}
+
+// peerAddrIP returns the IP of the given peer address, or nil if it has no IP.
+func peerAddrIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case nil:
+		return nil
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return nil
+		}
+		return net.ParseIP(host)
+	}
+}
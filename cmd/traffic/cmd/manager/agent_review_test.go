@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+func TestReviewAgentPeer(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+
+	isController := true
+	fakeClient := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hello-1",
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       "hello",
+					Controller: &isController,
+				}},
+			},
+			Status: corev1.PodStatus{PodIP: "10.1.0.5"},
+		},
+	)
+	ctx = k8sapi.WithK8sInterface(ctx, fakeClient)
+
+	from := func(ip string) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 43210}})
+	}
+	agent := func(name, podIP string) *rpc.AgentInfo {
+		return &rpc.AgentInfo{Name: name, Namespace: "default", PodIp: podIP}
+	}
+
+	assert.NoError(t, reviewAgentPeer(from("10.1.0.5"), agent("hello", "10.1.0.5")))
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		agent *rpc.AgentInfo
+	}{
+		{"no peer", ctx, agent("hello", "10.1.0.5")},
+		{"other caller", from("10.1.0.6"), agent("hello", "10.1.0.5")},
+		{"claims pod IP of caller", from("10.1.0.6"), agent("hello", "10.1.0.6")},
+		{"claims other workload", from("10.1.0.5"), agent("other", "10.1.0.5")},
+		{"claims other namespace", from("10.1.0.5"), &rpc.AgentInfo{Name: "hello", Namespace: "other", PodIp: "10.1.0.5"}},
+		{"no pod IP", from("10.1.0.5"), agent("hello", "")},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, codes.PermissionDenied, status.Code(reviewAgentPeer(tt.ctx, tt.agent)))
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+// workloadResources are the resources of the kinds of workloads that can be intercepted.
+var workloadResources = map[string]string{ //nolint:gochecknoglobals // constant
+	"Deployment":  "deployments",
+	"ReplicaSet":  "replicasets",
+	"StatefulSet": "statefulsets",
+}
+
+// reviewClientToken verifies the given Kubernetes bearer token of a client using a TokenReview, and returns
+// the user that the token authenticates.
+func reviewClientToken(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	if token == "" {
+		// Tell the client that it may retry with its token. This fails when the call didn't come through
+		// a gRPC server, and then there's no one to tell.
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(k8sapi.BearerTokenRequiredKey, "true"))
+		return nil, status.Error(codes.Unauthenticated, "the traffic-manager requires the Kubernetes bearer token of the client, but none was provided")
+	}
+	tr, err := k8sapi.GetK8sInterface(ctx).AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, meta.CreateOptions{})
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "unable to review the Kubernetes bearer token of the client: %v", err)
+	}
+	if !tr.Status.Authenticated {
+		msg := "the Kubernetes bearer token of the client is not valid"
+		if tr.Status.Error != "" {
+			msg += ": " + tr.Status.Error
+		}
+		return nil, status.Error(codes.Unauthenticated, msg)
+	}
+	return &tr.Status.User, nil
+}
+
+// checkInterceptAccess uses a SubjectAccessReview to verify that the Kubernetes user of the client session
+// with the given ID is allowed to patch the workload that the given spec intercepts. The check is only made
+// when the traffic-manager is configured with ClientAccessReview.
+func (m *Manager) checkInterceptAccess(ctx context.Context, sessionID string, spec *rpc.InterceptSpec) error {
+	if !managerutil.GetEnv(ctx).ClientAccessReview {
+		return nil
+	}
+	user := m.state.GetClientUser(sessionID)
+	if user == nil {
+		return status.Error(codes.PermissionDenied, "the Kubernetes user of the client has not been verified")
+	}
+	kind := spec.WorkloadKind
+	if kind == "" {
+		kind = "Deployment"
+	}
+	resource, ok := workloadResources[kind]
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "unsupported workload kind %q", kind)
+	}
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	sar, err := k8sapi.GetK8sInterface(ctx).AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: spec.Namespace,
+				Verb:      "patch",
+				Group:     "apps",
+				Resource:  resource,
+				Name:      spec.Agent,
+			},
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+		},
+	}, meta.CreateOptions{})
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "unable to review the access of user %q: %v", user.Username, err)
+	}
+	if !sar.Status.Allowed {
+		return status.Errorf(codes.PermissionDenied, "user %q is not allowed to patch %s %s.%s", user.Username, resource, spec.Agent, spec.Namespace)
+	}
+	return nil
+}
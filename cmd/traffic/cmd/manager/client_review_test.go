@@ -0,0 +1,117 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sVersion "k8s.io/apimachinery/pkg/version"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	testdata "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/test"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+func TestClientReview(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+
+	fakeClient := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	})
+	fakeClient.Discovery().(*fakeDiscovery.FakeDiscovery).FakedServerVersion = &k8sVersion.Info{
+		GitVersion: "v1.17.0",
+	}
+
+	// The token "alice-token" authenticates alice, who may only patch the deployment "hello".
+	fakeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if tr.Spec.Token == "alice-token" {
+			tr.Status.Authenticated = true
+			tr.Status.User = authenticationv1.UserInfo{Username: "alice@example.com", Groups: []string{"developers"}}
+		} else {
+			tr.Status.Error = "invalid bearer token"
+		}
+		return true, tr, nil
+	})
+	var sarSpecs []authorizationv1.SubjectAccessReviewSpec
+	fakeClient.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		sarSpecs = append(sarSpecs, sar.Spec)
+		ra := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "alice@example.com" && ra.Resource == "deployments" && ra.Name == "hello"
+		return true, sar, nil
+	})
+
+	ctx = k8sapi.WithK8sInterface(ctx, fakeClient)
+	ctx = managerutil.WithEnv(ctx, &managerutil.Env{
+		PodCIDRStrategy:    "environment",
+		PodCIDRs:           "192.168.0.0/16",
+		ClientTokenReview:  true,
+		ClientAccessReview: true,
+	})
+	m, ctx, err := NewManager(ctx)
+	require.NoError(t, err)
+
+	alice := testdata.GetTestClients(t)["alice"]
+
+	// A client without a token, or with an invalid token, is rejected.
+	_, err = m.ArriveAsClient(ctx, alice)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	alice.BearerToken = "bogus"
+	_, err = m.ArriveAsClient(ctx, alice)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Contains(t, err.Error(), "invalid bearer token")
+
+	alice.BearerToken = "alice-token"
+	sess, err := m.ArriveAsClient(ctx, alice)
+	require.NoError(t, err)
+	assert.Empty(t, m.state.GetClient(sess.SessionId).BearerToken, "the bearer token must not be retained")
+
+	spec := func(agent string) *rpc.InterceptSpec {
+		return &rpc.InterceptSpec{
+			Name:       agent,
+			Namespace:  "default",
+			Client:     alice.Name,
+			Agent:      agent,
+			Mechanism:  "tcp",
+			TargetHost: "asdf",
+			TargetPort: 9876,
+		}
+	}
+
+	// The verified user is recorded in the intercept.
+	ii, err := m.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", ii.VerifiedUser)
+	require.NotEmpty(t, sarSpecs)
+	got := sarSpecs[len(sarSpecs)-1]
+	assert.Equal(t, []string{"developers"}, got.Groups)
+	assert.Equal(t, authorizationv1.ResourceAttributes{
+		Namespace: "default",
+		Verb:      "patch",
+		Group:     "apps",
+		Resource:  "deployments",
+		Name:      "hello",
+	}, *got.ResourceAttributes)
+
+	// An intercept of a workload that the user isn't allowed to patch is denied.
+	_, err = m.PrepareIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec("other")})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = m.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec("other")})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
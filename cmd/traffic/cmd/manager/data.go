@@ -7,6 +7,7 @@ import (
 	"github.com/blang/semver"
 
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
 )
 
 func validateClient(client *rpc.ClientInfo) string {
@@ -76,6 +77,23 @@ func validateIntercept(spec *rpc.InterceptSpec) string {
 		return "namespace must not be empty"
 	case spec.Mechanism == "":
 		return "mechanism must not be empty"
+	case spec.TargetInCluster && spec.TargetHost == "":
+		return "target host must not be empty when the target is in the cluster"
+	case spec.TargetInCluster && (spec.TargetPort <= 0 || spec.TargetPort > 0xffff):
+		return fmt.Sprintf("target port %d is not a valid port number", spec.TargetPort)
+	case spec.Weight < 0 || spec.Weight > 99:
+		return fmt.Sprintf("weight %d is not a percentage between 1 and 99", spec.Weight)
+	case spec.Mirror && spec.Weight != 0:
+		return "a mirrored intercept cannot have a weight"
+	case (spec.StickyHeader != "" || spec.StickyClientIp) && spec.Weight == 0:
+		return "sticky routing requires a weight"
+	case len(spec.GrpcMatch) > 0 && (spec.Mirror || spec.Weight != 0):
+		return "a gRPC match cannot be combined with a mirror or a weight"
+	}
+	if len(spec.GrpcMatch) > 0 {
+		if _, err := matcher.NewGRPCRequestFromMap(spec.GrpcMatch); err != nil {
+			return err.Error()
+		}
 	}
 
 	return ""
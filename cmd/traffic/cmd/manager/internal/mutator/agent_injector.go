@@ -17,6 +17,7 @@ import (
 	admission "k8s.io/api/admission/v1"
 	core "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/strings/slices"
 
@@ -101,13 +102,25 @@ func (a *agentInjector) inject(ctx context.Context, req *admission.AdmissionRequ
 		attribute.String("tel2."+agentconfig.InjectAnnotation, ia),
 	)
 
+	policy := env.AgentInjectPolicy
+	if !isDelete {
+		if policy, err = injectPolicy(ctx, env, pod); err != nil {
+			return nil, err
+		}
+	}
+	span.SetAttributes(attribute.Stringer("tel2.inject-policy", policy))
+	if policy == agentconfig.Never {
+		dlog.Debugf(ctx, `The %s.%s pod has inject policy %s; skipping`, pod.Name, pod.Namespace, policy)
+		return nil, nil
+	}
+
 	var config *agentconfig.Sidecar
 	switch ia {
 	case "false", "disabled":
 		dlog.Debugf(ctx, `The %s.%s pod is explicitly disabled using a %q annotation; skipping`, pod.Name, pod.Namespace, agentconfig.InjectAnnotation)
 		return nil, nil
 	case "":
-		if env.AgentInjectPolicy != agentconfig.OnDemand {
+		if policy == agentconfig.WhenEnabled {
 			dlog.Debugf(ctx, `The %s.%s pod has not enabled %s container injection through %q annotation; skipping`,
 				pod.Name, pod.Namespace, agentconfig.ContainerName, agentconfig.InjectAnnotation)
 			return nil, nil
@@ -124,7 +137,7 @@ func (a *agentInjector) inject(ctx context.Context, req *admission.AdmissionRequ
 		switch {
 		case config == nil && isDelete:
 			return nil, nil
-		case config == nil && ia != "enabled":
+		case config == nil && !(ia == "enabled" || policy == agentconfig.Always):
 			dlog.Debugf(ctx, `The %s.%s pod has not enabled %s container injection through %q configmap or through %q annotation; skipping`,
 				pod.Name, pod.Namespace, agentconfig.ContainerName, agentconfig.ConfigMap, agentconfig.InjectAnnotation)
 			return nil, nil
@@ -149,7 +162,16 @@ func (a *agentInjector) inject(ctx context.Context, req *admission.AdmissionRequ
 		if isDelete {
 			return nil, nil
 		}
-		if config, err = agentmap.Generate(ctx, wl, env.GeneratorConfig(a.getAgentImage(ctx))); err != nil {
+		requested := config != nil || ia == "enabled"
+		agentImage := keptAgentImage(config, env.AgentUpgradePolicy, func() string { return a.getAgentImage(ctx) })
+		if config, err = agentmap.Generate(ctx, wl, env.GeneratorConfig(agentImage)); err != nil {
+			if !requested {
+				// The Always policy applies to all pods that its rule selects, including those that have no
+				// service to intercept, and such pods must not be denied.
+				dlog.Debugf(ctx, "The %s.%s pod has inject policy %s but can't have a %s container: %v; skipping",
+					pod.Name, pod.Namespace, policy, agentconfig.ContainerName, err)
+				return nil, nil
+			}
 			return nil, err
 		}
 		config.RecordInSpan(span)
@@ -185,6 +207,22 @@ func (a *agentInjector) inject(ctx context.Context, req *admission.AdmissionRequ
 	return patches, nil
 }
 
+// injectPolicy returns the inject policy of the workload that owns the given pod. The global policy is
+// returned when there are no inject rules, or when the pod has no workload.
+func injectPolicy(ctx context.Context, env *managerutil.Env, pod *core.Pod) (agentconfig.InjectPolicy, error) {
+	if len(env.AgentInjectRules) == 0 {
+		return env.AgentInjectPolicy, nil
+	}
+	wl, err := agentmap.FindOwnerWorkload(ctx, k8sapi.Pod(pod))
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return env.AgentInjectPolicy, nil
+		}
+		return 0, err
+	}
+	return env.InjectPolicy(ctx, wl)
+}
+
 func (a *agentInjector) getAgentImage(ctx context.Context) string {
 	a.Lock()
 	defer a.Unlock()
@@ -207,19 +245,9 @@ func (a *agentInjector) upgradeLegacy(ctx context.Context) {
 	a.agentConfigs.UninstallV25(ctx)
 }
 
-func needInitContainer(config *agentconfig.Sidecar) bool {
-	for _, cc := range config.Containers {
-		for _, ic := range cc.Intercepts {
-			if ic.Headless || ic.TargetPortNumeric {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 func addInitContainer(ctx context.Context, pod *core.Pod, config *agentconfig.Sidecar, patches patchOps) patchOps {
-	if !needInitContainer(config) {
+	// When the CNI plugin is enabled, it applies the redirects when the pod's network is set up.
+	if !agentconfig.NeedInitContainer(config) || managerutil.GetEnv(ctx).AgentCNI {
 		for i, oc := range pod.Spec.InitContainers {
 			if agentconfig.InitContainerName == oc.Name {
 				return append(patches, patchOperation{
@@ -232,7 +260,7 @@ func addInitContainer(ctx context.Context, pod *core.Pod, config *agentconfig.Si
 	}
 
 	pis := pod.Spec.InitContainers
-	ic := agentconfig.InitContainer(config.AgentImage)
+	ic := agentconfig.InitContainer(config)
 	if len(pis) == 0 {
 		return append(patches, patchOperation{
 			Op:    "replace",
@@ -245,6 +273,8 @@ func addInitContainer(ctx context.Context, pod *core.Pod, config *agentconfig.Si
 		oc := &pis[i]
 		if ic.Name == oc.Name {
 			if ic.Image == oc.Image &&
+				(ic.ImagePullPolicy == "" || ic.ImagePullPolicy == oc.ImagePullPolicy) &&
+				compareResources(ic.Resources, oc.Resources) &&
 				slices.Equal(ic.Args, oc.Args) &&
 				compareVolumeMounts(ic.VolumeMounts, oc.VolumeMounts) &&
 				compareCapabilities(ic.SecurityContext, oc.SecurityContext) {
@@ -335,6 +365,15 @@ func compareCapabilities(a *core.SecurityContext, b *core.SecurityContext) bool
 	return compareCaps(ac.Add, bc.Add) && compareCaps(ac.Drop, bc.Drop)
 }
 
+// compareResources compares the configured resources a with the resources b. Resources that aren't
+// configured are not compared, because Kubernetes may assign defaults.
+func compareResources(a, b core.ResourceRequirements) bool {
+	if len(a.Requests) == 0 && len(a.Limits) == 0 {
+		return true
+	}
+	return cmp.Equal(a, b, cmp.Comparer(func(a, b resource.Quantity) bool { return a.Cmp(b) == 0 }))
+}
+
 // compareVolumeMounts compares two VolumeMount slices but will not include volume mounts using "kube-api-access-" prefix
 func compareVolumeMounts(a, b []core.VolumeMount) bool {
 	stripKubeAPI := func(vs []core.VolumeMount) []core.VolumeMount {
@@ -351,11 +390,20 @@ func compareVolumeMounts(a, b []core.VolumeMount) bool {
 }
 
 func containerEqual(a, b *core.Container) bool {
-	// skips contain defaults assigned by Kubernetes that are not zero values
+	// skips contain defaults assigned by Kubernetes that are not zero values. The pull policy and
+	// resources are only compared when they are configured for the agent.
+	skips := []string{"TerminationMessagePath", "TerminationMessagePolicy"}
+	if b.ImagePullPolicy == "" {
+		skips = append(skips, "ImagePullPolicy")
+	}
+	if len(b.Resources.Requests) == 0 && len(b.Resources.Limits) == 0 {
+		skips = append(skips, "Resources")
+	}
 	return cmp.Equal(a, b,
 		cmp.Comparer(compareProbes),
 		cmp.Comparer(compareVolumeMounts),
-		cmpopts.IgnoreFields(core.Container{}, "ImagePullPolicy", "Resources", "TerminationMessagePath", "TerminationMessagePolicy"))
+		cmp.Comparer(func(a, b resource.Quantity) bool { return a.Cmp(b) == 0 }),
+		cmpopts.IgnoreFields(core.Container{}, skips...))
 }
 
 // addAgentContainer creates a patch operation to add the traffic-agent container
@@ -14,6 +14,7 @@ import (
 	admission "k8s.io/api/admission/v1"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -1317,6 +1318,81 @@ func TestTrafficAgentInjector(t *testing.T) {
 			"",
 			nil,
 		},
+		{
+			"Apply Patch: Numeric port with CNI plugin",
+			&core.Pod{
+				ObjectMeta: podObjectMeta("numeric-port"),
+				Spec: core.PodSpec{
+					Containers: []core.Container{{
+						Name:  "some-container",
+						Image: "some-app-image",
+						Ports: []core.ContainerPort{{ContainerPort: 8888}}},
+					},
+				},
+			},
+			true,
+			`- op: add
+  path: /spec/containers/-
+  value:
+    args:
+    - agent
+    env:
+    - name: _TEL_AGENT_POD_IP
+      valueFrom:
+        fieldRef:
+          apiVersion: v1
+          fieldPath: status.podIP
+    - name: _TEL_AGENT_NAME
+      valueFrom:
+        fieldRef:
+          apiVersion: v1
+          fieldPath: metadata.name
+    image: docker.io/datawire/tel2:2.6.0
+    name: traffic-agent
+    ports:
+    - containerPort: 9900
+      protocol: TCP
+    readinessProbe:
+      exec:
+        command:
+        - /bin/stat
+        - /tmp/agent/ready
+    resources: {}
+    volumeMounts:
+    - mountPath: /tel_pod_info
+      name: traffic-annotations
+    - mountPath: /etc/traffic-agent
+      name: traffic-config
+    - mountPath: /tel_app_exports
+      name: export-volume
+    - mountPath: /tmp
+      name: tel-agent-tmp
+- op: replace
+  path: /spec/volumes
+  value:
+  - downwardAPI:
+      items:
+      - fieldRef:
+          apiVersion: v1
+          fieldPath: metadata.annotations
+        path: annotations
+    name: traffic-annotations
+  - configMap:
+      items:
+      - key: numeric-port
+        path: config.yaml
+      name: telepresence-agents
+    name: traffic-config
+  - emptyDir: {}
+    name: export-volume
+  - emptyDir: {}
+    name: tel-agent-tmp
+`,
+			"",
+			&managerutil.Env{
+				AgentCNI: true,
+			},
+		},
 		{
 			"Apply Patch: Numeric port with init containers",
 			&core.Pod{
@@ -1622,7 +1698,7 @@ func TestTrafficAgentInjector(t *testing.T) {
 				ae := reflect.ValueOf(test.envAdditions).Elem()
 				for i := ae.NumField() - 1; i >= 0; i-- {
 					ef := ae.Field(i)
-					if (ef.Kind() == reflect.String || ef.Kind() == reflect.Int32) && !ef.IsZero() {
+					if (ef.Kind() == reflect.String || ef.Kind() == reflect.Int32 || ef.Kind() == reflect.Bool) && !ef.IsZero() {
 						ne.Field(i).Set(ef)
 					}
 				}
@@ -1656,6 +1732,178 @@ func TestTrafficAgentInjector(t *testing.T) {
 	}
 }
 
+func TestAddInitContainer(t *testing.T) {
+	ctx := managerutil.WithEnv(dlog.NewTestContext(t, false), &managerutil.Env{})
+	config := &agentconfig.Sidecar{
+		AgentImage: "docker.io/datawire/tel2:2.6.0",
+		PullPolicy: "Always",
+		Resources: &agentconfig.ResourceRequirements{
+			Limits: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+		},
+		Containers: []*agentconfig.Container{{
+			Name:       "echo",
+			Intercepts: []*agentconfig.Intercept{{ContainerPort: 8080, AgentPort: 9900, TargetPortNumeric: true}},
+		}},
+	}
+	pod := &core.Pod{}
+	patches := addInitContainer(ctx, pod, config, nil)
+	require.Len(t, patches, 1)
+	ics, ok := patches[0].Value.([]core.Container)
+	require.True(t, ok)
+	require.Len(t, ics, 1)
+	ic := ics[0]
+	assert.Equal(t, core.PullAlways, ic.ImagePullPolicy)
+	assert.Equal(t, "100m", ic.Resources.Limits.Cpu().String())
+
+	// Re-processing a pod with an equal init container is a no-op
+	pod.Spec.InitContainers = ics
+	assert.Empty(t, addInitContainer(ctx, pod, config, nil))
+
+	// Changed resources replace the init container
+	config.Resources = &agentconfig.ResourceRequirements{
+		Limits: core.ResourceList{core.ResourceCPU: resource.MustParse("200m")},
+	}
+	patches = addInitContainer(ctx, pod, config, nil)
+	require.Len(t, patches, 1)
+	assert.Equal(t, "replace", patches[0].Op)
+}
+
+func TestInjectRules(t *testing.T) {
+	var rules agentconfig.InjectRules
+	require.NoError(t, rules.EnvDecode(`
+- namespaceSelector:
+    matchLabels:
+      critical: "true"
+  policy: Never
+- workloadSelector:
+    matchLabels:
+      app: echo
+  workloadKinds: [Deployment]
+  policy: Always
+`))
+	env := &managerutil.Env{
+		ManagerNamespace:  "default",
+		AgentRegistry:     "docker.io/datawire",
+		AgentImage:        "tel2:2.6.0",
+		AgentPort:         9900,
+		AgentInjectPolicy: agentconfig.WhenEnabled,
+		AgentInjectRules:  rules,
+	}
+
+	pod := func(ns string, annotations map[string]string) *core.Pod {
+		return &core.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:        "echo-6699c6cb54-",
+				Namespace:   ns,
+				Annotations: annotations,
+				Labels:      map[string]string{"app": "echo"},
+				OwnerReferences: []meta.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       "echo",
+					Controller: boolP(true),
+				}},
+			},
+			Spec: core.PodSpec{
+				Containers: []core.Container{{
+					Name:  "echo",
+					Image: "echo-image",
+					Ports: []core.ContainerPort{{Name: "http", ContainerPort: 8080}},
+				}},
+			},
+		}
+	}
+	namespace := func(name string, labels map[string]string) *core.Namespace {
+		return &core.Namespace{ObjectMeta: meta.ObjectMeta{Name: name, Labels: labels}}
+	}
+	deployment := func(ns string, labels map[string]string) *apps.Deployment {
+		p := pod(ns, nil)
+		return &apps.Deployment{
+			TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: ns, Labels: labels},
+			Spec: apps.DeploymentSpec{
+				Template: core.PodTemplateSpec{ObjectMeta: p.ObjectMeta, Spec: p.Spec},
+				Selector: &meta.LabelSelector{MatchLabels: map[string]string{"app": "echo"}},
+			},
+		}
+	}
+	service := func(ns string) *core.Service {
+		return &core.Service{
+			ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: ns},
+			Spec: core.ServiceSpec{
+				Ports:    []core.ServicePort{{Protocol: "TCP", Port: 80, TargetPort: intstr.FromString("http")}},
+				Selector: map[string]string{"app": "echo"},
+			},
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		namespace("dev", nil),
+		namespace("critical", map[string]string{"critical": "true"}),
+		namespace("other", nil),
+		namespace("no-service", nil),
+		deployment("dev", map[string]string{"app": "echo"}),
+		deployment("no-service", map[string]string{"app": "echo"}),
+		deployment("critical", map[string]string{"app": "echo"}),
+		deployment("other", nil),
+		service("dev"),
+		service("critical"),
+		service("other"),
+	)
+
+	tests := []struct {
+		name        string
+		pod         *core.Pod
+		wantPatches bool
+	}{
+		{
+			"Always rule injects without annotation",
+			pod("dev", nil),
+			true,
+		},
+		{
+			"Always rule skips a workload that has no service",
+			pod("no-service", nil),
+			false,
+		},
+		{
+			"Always rule is overridden by disabled annotation",
+			pod("dev", map[string]string{install.InjectAnnotation: "disabled"}),
+			false,
+		},
+		{
+			"Never rule overrides enabled annotation",
+			pod("critical", map[string]string{install.InjectAnnotation: "enabled"}),
+			false,
+		},
+		{
+			"Global policy applies when no rule matches",
+			pod("other", nil),
+			false,
+		},
+		{
+			"Global policy applies when no rule matches, with enabled annotation",
+			pod("other", map[string]string{install.InjectAnnotation: "enabled"}),
+			true,
+		},
+	}
+	for _, test := range tests {
+		test := test // pin it
+		t.Run(test.name, func(t *testing.T) {
+			ctx := dlog.NewTestContext(t, false)
+			ctx = managerutil.WithEnv(ctx, env)
+			ctx = k8sapi.WithK8sInterface(ctx, clientset)
+			a := agentInjector{agentConfigs: NewWatcher(""), agentImage: "docker.io/datawire/tel2:2.6.0"}
+			patches, err := a.inject(ctx, toAdmissionRequest(podResource, test.pod))
+			require.NoError(t, err)
+			if test.wantPatches {
+				assert.NotEmpty(t, patches)
+			} else {
+				assert.Empty(t, patches)
+			}
+		})
+	}
+}
+
 func requireContains(t *testing.T, err error, expected string) {
 	if expected == "" {
 		require.NoError(t, err)
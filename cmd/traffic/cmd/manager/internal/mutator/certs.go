@@ -0,0 +1,199 @@
+package mutator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+const (
+	tlsCAFile = `ca.crt`
+
+	// certCheckInterval is how often the mounted certificate files are checked for changes, and the
+	// certificate for expiry.
+	certCheckInterval = time.Minute
+)
+
+// webhookCerts provides the TLS certificate of the mutating webhook service. The certificate is reloaded
+// when the files of the mounted Secret change, e.g. when cert-manager renews it, and it is rotated by the
+// traffic-manager when it is about to expire.
+type webhookCerts struct {
+	sync.RWMutex
+	cert *tls.Certificate
+	ca   []byte
+
+	// expires is the time when the certificate, or the CA that signed it, expires.
+	expires time.Time
+
+	// files is the content of the mounted files that the certificate was last loaded from.
+	files []byte
+}
+
+// readTLSFiles returns the certificate, key, and CA of the mounted Secret. The CA is optional.
+func readTLSFiles() (crt, key, ca []byte, err error) {
+	if crt, err = os.ReadFile(filepath.Join(tlsDir, tlsCertFile)); err != nil {
+		return nil, nil, nil, err
+	}
+	if key, err = os.ReadFile(filepath.Join(tlsDir, tlsKeyFile)); err != nil {
+		return nil, nil, nil, err
+	}
+	if ca, err = os.ReadFile(filepath.Join(tlsDir, tlsCAFile)); err != nil && !os.IsNotExist(err) {
+		return nil, nil, nil, err
+	}
+	return crt, key, ca, nil
+}
+
+// certExpiry returns the earliest expiry of the given certificate and the certificates of the given PEM
+// encoded CA.
+func certExpiry(cert *x509.Certificate, caPem []byte) time.Time {
+	expires := cert.NotAfter
+	for {
+		var block *pem.Block
+		if block, caPem = pem.Decode(caPem); block == nil {
+			break
+		}
+		if ca, err := x509.ParseCertificate(block.Bytes); err == nil && ca.NotAfter.Before(expires) {
+			expires = ca.NotAfter
+		}
+	}
+	return expires
+}
+
+// set replaces the certificate with the given PEM encoded certificate, key, and CA.
+func (c *webhookCerts) set(crt, key, ca []byte) error {
+	cert, err := tls.X509KeyPair(crt, key)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return err
+	}
+	c.Lock()
+	c.cert = &cert
+	c.ca = ca
+	c.expires = certExpiry(cert.Leaf, ca)
+	c.Unlock()
+	return nil
+}
+
+// reload loads the certificate from the mounted files, unless they are unchanged since the last reload.
+func (c *webhookCerts) reload(ctx context.Context) error {
+	crt, key, ca, err := readTLSFiles()
+	if err != nil {
+		return err
+	}
+	files := bytes.Join([][]byte{crt, key, ca}, nil)
+	if bytes.Equal(files, c.files) {
+		return nil
+	}
+	if err = c.set(crt, key, ca); err != nil {
+		return err
+	}
+	c.files = files
+	dlog.Infof(ctx, "Loaded mutating webhook certificate that expires %s", c.getExpires().Format(time.RFC3339))
+	return nil
+}
+
+func (c *webhookCerts) getExpires() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return c.expires
+}
+
+// GetCertificate is used as the tls.Config.GetCertificate of the mutating webhook service.
+func (c *webhookCerts) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.cert == nil {
+		return nil, errors.New("no mutating webhook certificate has been loaded")
+	}
+	return c.cert, nil
+}
+
+// run reloads the certificate when the mounted files change, and rotates it when it is about to expire,
+// until the given context is cancelled. Rotation is disabled when the WebhookRenewBefore is zero.
+func (c *webhookCerts) run(ctx context.Context) error {
+	env := managerutil.GetEnv(ctx)
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.reload(ctx); err != nil {
+			dlog.Errorf(ctx, "unable to reload mutating webhook certificate: %v", err)
+		}
+		if env.WebhookRenewBefore > 0 && time.Until(c.getExpires()) < env.WebhookRenewBefore {
+			if err := c.rotate(ctx, env); err != nil {
+				dlog.Errorf(ctx, "unable to rotate mutating webhook certificate: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// rotate generates a new key pair and CA, and stores them in the webhook configuration and the Secret
+// before the service starts using them. The previous CA remains in the webhook configuration's caBundle
+// so that the API server trusts the service while it switches to the new certificate.
+func (c *webhookCerts) rotate(ctx context.Context, env *managerutil.Env) error {
+	dlog.Infof(ctx, "Rotating mutating webhook certificate that expires %s", c.getExpires().Format(time.RFC3339))
+	crt, key, ca, err := install.GenerateKeys(env.ManagerNamespace)
+	if err != nil {
+		return err
+	}
+	c.RLock()
+	oldCA := c.ca
+	c.RUnlock()
+
+	ki := k8sapi.GetK8sInterface(ctx)
+	whAPI := ki.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	wh, err := whAPI.Get(ctx, env.GetWebhookName(), meta.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get MutatingWebhookConfiguration %s: %w", env.GetWebhookName(), err)
+	}
+	bundle := append(append([]byte{}, ca...), oldCA...)
+	for i := range wh.Webhooks {
+		wh.Webhooks[i].ClientConfig.CABundle = bundle
+	}
+	if _, err = whAPI.Update(ctx, wh, meta.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update MutatingWebhookConfiguration %s: %w", env.GetWebhookName(), err)
+	}
+
+	secretAPI := ki.CoreV1().Secrets(env.ManagerNamespace)
+	secret, err := secretAPI.Get(ctx, env.WebhookSecret, meta.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get Secret %s.%s: %w", env.WebhookSecret, env.ManagerNamespace, err)
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[tlsCAFile] = ca
+	secret.Data[tlsCertFile] = crt
+	secret.Data[tlsKeyFile] = key
+	if _, err = secretAPI.Update(ctx, secret, meta.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update Secret %s.%s: %w", env.WebhookSecret, env.ManagerNamespace, err)
+	}
+
+	// The mounted files are updated by the kubelet eventually. There's no need to wait for that.
+	if err = c.set(crt, key, ca); err != nil {
+		return err
+	}
+	dlog.Infof(ctx, "Rotated mutating webhook certificate, the new certificate expires %s", c.getExpires().Format(time.RFC3339))
+	return nil
+}
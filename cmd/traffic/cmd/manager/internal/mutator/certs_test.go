@@ -0,0 +1,77 @@
+package mutator
+
+import (
+	"bytes"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admreg "k8s.io/api/admissionregistration/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+func TestWebhookCertsRotate(t *testing.T) {
+	env := &managerutil.Env{
+		ManagerNamespace:   "ambassador",
+		WebhookSecret:      "mutator-webhook-tls",
+		WebhookRenewBefore: 30 * 24 * time.Hour,
+	}
+	crt, key, ca, err := install.GenerateKeys(env.ManagerNamespace)
+	require.NoError(t, err)
+
+	clientset := fake.NewSimpleClientset(
+		&admreg.MutatingWebhookConfiguration{
+			ObjectMeta: meta.ObjectMeta{Name: env.GetWebhookName()},
+			Webhooks: []admreg.MutatingWebhook{{
+				Name:         "agent-injector.getambassador.io",
+				ClientConfig: admreg.WebhookClientConfig{CABundle: ca},
+			}},
+		},
+		&core.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: env.WebhookSecret, Namespace: env.ManagerNamespace},
+			Data:       map[string][]byte{tlsCAFile: ca, tlsCertFile: crt, tlsKeyFile: key},
+		},
+	)
+	ctx := dlog.NewTestContext(t, false)
+	ctx = managerutil.WithEnv(ctx, env)
+	ctx = k8sapi.WithK8sInterface(ctx, clientset)
+
+	certs := &webhookCerts{}
+	require.NoError(t, certs.set(crt, key, ca))
+	oldCert, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+
+	// The CA that GenerateKeys creates expires before the certificate that it signs.
+	assert.WithinDuration(t, time.Now().AddDate(1, 0, 0), certs.getExpires(), time.Minute)
+
+	require.NoError(t, certs.rotate(ctx, env))
+	newCert, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldCert.Certificate[0], newCert.Certificate[0])
+
+	secret, err := clientset.CoreV1().Secrets(env.ManagerNamespace).Get(ctx, env.WebhookSecret, meta.GetOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, ca, secret.Data[tlsCAFile])
+	assert.Equal(t, newCert.Certificate[0], mustDecodePEM(t, secret.Data[tlsCertFile]))
+
+	wh, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, env.GetWebhookName(), meta.GetOptions{})
+	require.NoError(t, err)
+	bundle := wh.Webhooks[0].ClientConfig.CABundle
+	assert.True(t, bytes.HasPrefix(bundle, secret.Data[tlsCAFile]), "caBundle must contain the new CA")
+	assert.True(t, bytes.HasSuffix(bundle, ca), "caBundle must retain the previous CA")
+}
+
+func mustDecodePEM(t *testing.T, data []byte) []byte {
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	return block.Bytes
+}
@@ -2,6 +2,7 @@ package mutator
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -53,7 +54,9 @@ func (p patchOps) String() string {
 
 type mutatorFunc func(context.Context, *admission.AdmissionRequest) (patchOps, error)
 
-func ServeMutator(ctx context.Context) error {
+// ServeMutator serves the agent-injector webhook. It also runs the controller that upgrades the traffic-agents,
+// using the given UpgradeState to publish the upgrade status.
+func ServeMutator(ctx context.Context, us UpgradeState) error {
 	certPath := filepath.Join(tlsDir, tlsCertFile)
 	keyPath := filepath.Join(tlsDir, tlsKeyFile)
 	missing := ""
@@ -72,6 +75,10 @@ func ServeMutator(ctx context.Context) error {
 		dlog.Infof(ctx, "%q is not present so mutator service is disabled", missing)
 		return nil
 	}
+	certs := &webhookCerts{}
+	if err := certs.reload(ctx); err != nil {
+		return err
+	}
 
 	var ai *agentInjector
 	mux := http.NewServeMux()
@@ -132,16 +139,21 @@ func ServeMutator(ctx context.Context) error {
 		dtime.SleepWithContext(ctx, time.Second) // Give the server some time to start
 		return cw.Run(ctx)
 	})
+	dgroup.ParentGroup(ctx).Go("webhook-certs", certs.run)
+	dgroup.ParentGroup(ctx).Go("agent-upgrade", newAgentUpgrader(cw, us).run)
 
 	wrapped := otelhttp.NewHandler(mux, "agent-injector", otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 		return operation + r.URL.Path
 	}))
-	server := &dhttp.ServerConfig{Handler: wrapped}
+	server := &dhttp.ServerConfig{
+		Handler:   wrapped,
+		TLSConfig: &tls.Config{GetCertificate: certs.GetCertificate},
+	}
 	addr := ":" + strconv.Itoa(install.MutatorWebhookPortHTTPS)
 
 	dlog.Infof(ctx, "Mutating webhook service is listening on %v", addr)
 	defer dlog.Info(ctx, "Mutating webhook service stopped")
-	if err = server.ListenAndServeTLS(ctx, addr, certPath, keyPath); err != nil {
+	if err = server.ListenAndServeTLS(ctx, addr, "", ""); err != nil {
 		return fmt.Errorf("mutating webhook service stopped. %w", err)
 	}
 	return nil
@@ -0,0 +1,213 @@
+package mutator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+// UpgradeState gives the agent upgrade controller access to the traffic-manager state.
+type UpgradeState interface {
+	// HasIntercepts returns true if the agent with the given name and namespace has intercepts.
+	HasIntercepts(name, namespace string) bool
+
+	// SetAgentUpgrade publishes the upgrade status of the agents with the given name and namespace.
+	SetAgentUpgrade(name, namespace string, upgrade *rpc.AgentUpgrade)
+
+	// GetAgentsByName returns the agents with the given name and namespace, keyed by session ID.
+	GetAgentsByName(name, namespace string) map[string]*rpc.AgentInfo
+}
+
+const upgradeInterval = 15 * time.Second
+
+// agentUpgrader upgrades the traffic-agents that are configured in the telepresence-agents ConfigMaps when
+// their image differs from the image that the traffic-manager injects, or when the running agents report
+// another version than the one that the image is tagged with. It respects the AgentUpgradePolicy and never
+// has more than AgentUpgradeMaxUnavailable workloads rolling out at the same time.
+type agentUpgrader struct {
+	agentConfigs Map
+	state        UpgradeState
+
+	// rollouts contains the workloads that are rolling out due to an upgrade, keyed by "<agent name>.<namespace>"
+	rollouts map[string]*agentconfig.Sidecar
+
+	// restarted contains the target image of the workloads that have been rolled out by the upgrader, keyed
+	// by "<agent name>.<namespace>". A workload whose agents report another version is rolled out only once
+	// for each target image, so that an image that reports another version than its tag doesn't cause
+	// endless rollouts.
+	restarted map[string]string
+
+	// targetImage is the image that the agents are upgraded to, once it has been resolved.
+	targetImage string
+}
+
+// keptAgentImage returns the image to use when the config of the given existing agent is regenerated. Unless
+// the upgrade policy is NeverUpgrade, the agent keeps its image, because only the agentUpgrader may change
+// it. The image that the given function returns is used for new agents, and for all agents when the policy
+// is NeverUpgrade, so that they are upgraded when their pods are restarted for other reasons.
+func keptAgentImage(ac *agentconfig.Sidecar, policy agentconfig.UpgradePolicy, injectedImage func() string) string {
+	if policy != agentconfig.NeverUpgrade && ac != nil && ac.AgentImage != "" {
+		return ac.AgentImage
+	}
+	return injectedImage()
+}
+
+func newAgentUpgrader(agentConfigs Map, state UpgradeState) *agentUpgrader {
+	return &agentUpgrader{
+		agentConfigs: agentConfigs,
+		state:        state,
+		rollouts:     make(map[string]*agentconfig.Sidecar),
+		restarted:    make(map[string]string),
+	}
+}
+
+// imageVersion returns the version that the tag of the given image names, or nil when the tag isn't a version.
+func imageVersion(image string) *semver.Version {
+	i := strings.LastIndexByte(image, ':')
+	if i < 0 || strings.ContainsRune(image[i:], '/') {
+		return nil
+	}
+	v, err := semver.Parse(strings.TrimPrefix(image[i+1:], "v"))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// reportedVersion returns a version, other than the given one, that a running agent of the given config
+// reports, or an empty string when all agents report the given version.
+func (u *agentUpgrader) reportedVersion(ac *agentconfig.Sidecar, target *semver.Version) string {
+	for _, ai := range u.state.GetAgentsByName(ac.AgentName, ac.Namespace) {
+		if v, err := semver.Parse(strings.TrimPrefix(ai.Version, "v")); err == nil && !v.Equals(*target) {
+			return ai.Version
+		}
+	}
+	return ""
+}
+
+func (u *agentUpgrader) run(ctx context.Context) error {
+	ticker := time.NewTicker(upgradeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			u.upgradeAll(ctx, u.resolveTargetImage(ctx))
+		}
+	}
+}
+
+// resolveTargetImage returns the image that the agents are upgraded to. The image is resolved once, using
+// the same priorities as managerutil.GetAgentImage. The image that GetAgentImage falls back to when
+// Ambassador Cloud can't be reached isn't a target, because it may not be the preferred image, so an empty
+// string is returned and the resolution is retried on the next call.
+func (u *agentUpgrader) resolveTargetImage(ctx context.Context) string {
+	if u.targetImage != "" {
+		return u.targetImage
+	}
+	env := managerutil.GetEnv(ctx)
+	img := ""
+	if env.AgentImage == "" {
+		var err error
+		if img, err = managerutil.AgentImageFromSystemA(ctx); err != nil {
+			dlog.Debugf(ctx, "unable to get Ambassador Cloud preferred agent image, no agents are upgraded: %v", err)
+			return ""
+		}
+	}
+	if img == "" {
+		img = env.QualifiedAgentImage()
+	}
+	dlog.Infof(ctx, "upgrading agents to image %s", img)
+	u.targetImage = img
+	return img
+}
+
+func (u *agentUpgrader) upgradeAll(ctx context.Context, targetImage string) {
+	if targetImage == "" {
+		return
+	}
+
+	// Workloads that are done rolling out no longer count against the max-unavailable budget.
+	for key, ac := range u.rollouts {
+		wl, err := k8sapi.GetWorkload(ctx, ac.WorkloadName, ac.Namespace, ac.WorkloadKind)
+		if err == nil && !wl.Updated(wl.GetGeneration()) {
+			continue
+		}
+		dlog.Debugf(ctx, "Upgrade of %s %s.%s is complete", ac.WorkloadKind, ac.WorkloadName, ac.Namespace)
+		delete(u.rollouts, key)
+		u.state.SetAgentUpgrade(ac.AgentName, ac.Namespace, nil)
+	}
+
+	env := managerutil.GetEnv(ctx)
+	for _, ac := range u.agentConfigs.Sidecars(ctx) {
+		key := ac.AgentName + "." + ac.Namespace
+		if _, ok := u.rollouts[key]; ok {
+			continue
+		}
+		if ac.AgentImage == targetImage {
+			// The config is up-to-date, but the agents that run may not be.
+			if tv := imageVersion(targetImage); tv == nil || u.restarted[key] == targetImage || u.reportedVersion(ac, tv) == "" {
+				u.state.SetAgentUpgrade(ac.AgentName, ac.Namespace, nil)
+				continue
+			}
+		}
+		upgrade := &rpc.AgentUpgrade{Image: ac.AgentImage, TargetImage: targetImage}
+		switch {
+		case env.AgentUpgradePolicy == agentconfig.NeverUpgrade:
+			upgrade.Status = rpc.AgentUpgrade_OUTDATED
+			upgrade.Reason = "the upgrade policy is " + env.AgentUpgradePolicy.String()
+		case env.AgentUpgradePolicy == agentconfig.UpgradeWhenIdle && u.state.HasIntercepts(ac.AgentName, ac.Namespace):
+			upgrade.Status = rpc.AgentUpgrade_OUTDATED
+			upgrade.Reason = "the agent is intercepted"
+		case len(u.rollouts) >= env.AgentUpgradeMaxUnavailable:
+			upgrade.Status = rpc.AgentUpgrade_PENDING
+		default:
+			if err := u.upgrade(ctx, ac, targetImage); err != nil {
+				dlog.Error(ctx, err)
+				continue
+			}
+			upgrade.Status = rpc.AgentUpgrade_IN_PROGRESS
+			u.rollouts[key] = ac
+		}
+		u.state.SetAgentUpgrade(ac.AgentName, ac.Namespace, upgrade)
+	}
+}
+
+// upgrade regenerates the agent config using the given image, stores it, and triggers a rollout of the workload.
+// A config that already uses the given image is just rolled out again.
+func (u *agentUpgrader) upgrade(ctx context.Context, ac *agentconfig.Sidecar, targetImage string) error {
+	wl, err := k8sapi.GetWorkload(ctx, ac.WorkloadName, ac.Namespace, ac.WorkloadKind)
+	if err != nil {
+		return fmt.Errorf("unable to upgrade agent %s.%s: %w", ac.AgentName, ac.Namespace, err)
+	}
+	// Agents that still report another version once this rollout is complete won't be restarted again.
+	u.restarted[ac.AgentName+"."+ac.Namespace] = targetImage
+	if ac.AgentImage == targetImage {
+		dlog.Infof(ctx, "Restarting agent %s.%s because it doesn't run the version of %s", ac.AgentName, ac.Namespace, targetImage)
+		triggerRollout(ctx, wl)
+		return nil
+	}
+	nac, err := agentmap.Generate(ctx, wl, managerutil.GetEnv(ctx).GeneratorConfig(targetImage))
+	if err != nil {
+		return fmt.Errorf("unable to upgrade agent %s.%s: %w", ac.AgentName, ac.Namespace, err)
+	}
+
+	// The snapshot is updated to prevent a second rollout when the ConfigMap watcher sees the change.
+	if err = u.agentConfigs.Store(ctx, nac, true); err != nil {
+		return fmt.Errorf("unable to upgrade agent %s.%s: %w", ac.AgentName, ac.Namespace, err)
+	}
+	dlog.Infof(ctx, "Upgrading agent %s.%s from %s to %s", ac.AgentName, ac.Namespace, ac.AgentImage, targetImage)
+	triggerRollout(ctx, wl)
+	return nil
+}
@@ -0,0 +1,255 @@
+package mutator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+type fakeUpgradeState struct {
+	intercepted map[string]bool
+	upgrades    map[string]*rpc.AgentUpgrade
+	versions    map[string]string
+}
+
+func (s *fakeUpgradeState) GetAgentsByName(name, namespace string) map[string]*rpc.AgentInfo {
+	if v, ok := s.versions[name+"."+namespace]; ok {
+		return map[string]*rpc.AgentInfo{"session-1": {Name: name, Namespace: namespace, Version: v}}
+	}
+	return nil
+}
+
+func (s *fakeUpgradeState) HasIntercepts(name, namespace string) bool {
+	return s.intercepted[name+"."+namespace]
+}
+
+func (s *fakeUpgradeState) SetAgentUpgrade(name, namespace string, upgrade *rpc.AgentUpgrade) {
+	if upgrade == nil {
+		delete(s.upgrades, name+"."+namespace)
+	} else {
+		s.upgrades[name+"."+namespace] = upgrade
+	}
+}
+
+func upgradeTestWorkload(name string) (*apps.Deployment, *core.Service) {
+	labels := map[string]string{"app": name}
+	dep := &apps.Deployment{
+		TypeMeta: meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{
+			Name:       name,
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: apps.DeploymentSpec{
+			Replicas: int32P(1),
+			Selector: &meta.LabelSelector{MatchLabels: labels},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: meta.ObjectMeta{Labels: labels},
+				Spec: core.PodSpec{
+					Containers: []core.Container{{
+						Name:  name,
+						Image: "datawire/echo-server:latest",
+						Ports: []core.ContainerPort{{Name: "http", ContainerPort: 8080}},
+					}},
+				},
+			},
+		},
+		Status: apps.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           1,
+			UpdatedReplicas:    1,
+			AvailableReplicas:  1,
+		},
+	}
+	svc := &core.Service{
+		TypeMeta: meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       types.UID("uid-" + name),
+		},
+		Spec: core.ServiceSpec{
+			Selector: labels,
+			Ports: []core.ServicePort{{
+				Name:       "http",
+				Port:       80,
+				TargetPort: intstr.FromString("http"),
+			}},
+		},
+	}
+	return dep, svc
+}
+
+func TestAgentUpgrader(t *testing.T) {
+	const (
+		oldImage = "docker.io/datawire/tel2:2.6.0"
+		newImage = "docker.io/datawire/tel2:2.7.3"
+	)
+
+	setup := func(t *testing.T, policy agentconfig.UpgradePolicy) (context.Context, *configWatcher, *fakeUpgradeState) {
+		echo, echoSvc := upgradeTestWorkload("echo")
+		hello, helloSvc := upgradeTestWorkload("hello")
+		ctx := dlog.NewTestContext(t, false)
+		ctx = k8sapi.WithK8sInterface(ctx, fake.NewSimpleClientset(echo, echoSvc, hello, helloSvc))
+		env := &managerutil.Env{
+			ManagerNamespace:           "default",
+			AgentRegistry:              "docker.io/datawire",
+			AgentPort:                  9900,
+			AgentUpgradePolicy:         policy,
+			AgentUpgradeMaxUnavailable: 1,
+		}
+		ctx = managerutil.WithEnv(ctx, env)
+
+		cw := NewWatcher("")
+		for _, dep := range []*apps.Deployment{echo, hello} {
+			ac, err := agentmap.Generate(ctx, k8sapi.Deployment(dep), env.GeneratorConfig(oldImage))
+			require.NoError(t, err)
+			require.NoError(t, cw.Store(ctx, ac, false))
+		}
+		cm, err := k8sapi.GetK8sInterface(ctx).CoreV1().ConfigMaps("default").Get(ctx, agentconfig.ConfigMap, meta.GetOptions{})
+		require.NoError(t, err)
+		cw.data["default"] = cm.Data
+		return ctx, cw, &fakeUpgradeState{
+			intercepted: make(map[string]bool),
+			upgrades:    make(map[string]*rpc.AgentUpgrade),
+			versions:    make(map[string]string),
+		}
+	}
+
+	agentImage := func(t *testing.T, ctx context.Context, name string) string {
+		cm, err := k8sapi.GetK8sInterface(ctx).CoreV1().ConfigMaps("default").Get(ctx, agentconfig.ConfigMap, meta.GetOptions{})
+		require.NoError(t, err)
+		var ac agentconfig.Sidecar
+		require.NoError(t, decode(cm.Data[name], &ac))
+		return ac.AgentImage
+	}
+
+	restarted := func(t *testing.T, ctx context.Context, name string) bool {
+		dep, err := k8sapi.GetK8sInterface(ctx).AppsV1().Deployments("default").Get(ctx, name, meta.GetOptions{})
+		require.NoError(t, err)
+		_, ok := dep.Spec.Template.Annotations[install.DomainPrefix+"restartedAt"]
+		return ok
+	}
+
+	t.Run("never", func(t *testing.T) {
+		ctx, cw, us := setup(t, agentconfig.NeverUpgrade)
+		newAgentUpgrader(cw, us).upgradeAll(ctx, newImage)
+		require.Contains(t, us.upgrades, "echo.default")
+		u := us.upgrades["echo.default"]
+		assert.Equal(t, rpc.AgentUpgrade_OUTDATED, u.Status)
+		assert.Equal(t, oldImage, u.Image)
+		assert.Equal(t, newImage, u.TargetImage)
+		assert.Equal(t, "the upgrade policy is Never", u.Reason)
+		assert.Equal(t, oldImage, agentImage(t, ctx, "echo"))
+		assert.False(t, restarted(t, ctx, "echo"))
+	})
+
+	t.Run("when idle", func(t *testing.T) {
+		ctx, cw, us := setup(t, agentconfig.UpgradeWhenIdle)
+		us.intercepted["echo.default"] = true
+		newAgentUpgrader(cw, us).upgradeAll(ctx, newImage)
+		assert.Equal(t, rpc.AgentUpgrade_OUTDATED, us.upgrades["echo.default"].Status)
+		assert.Equal(t, "the agent is intercepted", us.upgrades["echo.default"].Reason)
+		assert.Equal(t, oldImage, agentImage(t, ctx, "echo"))
+		assert.Equal(t, rpc.AgentUpgrade_IN_PROGRESS, us.upgrades["hello.default"].Status)
+		assert.Equal(t, newImage, agentImage(t, ctx, "hello"))
+		assert.True(t, restarted(t, ctx, "hello"))
+	})
+
+	t.Run("immediately with max unavailable", func(t *testing.T) {
+		ctx, cw, us := setup(t, agentconfig.UpgradeImmediately)
+		us.intercepted["echo.default"] = true
+		u := newAgentUpgrader(cw, us)
+		u.upgradeAll(ctx, newImage)
+		assert.Equal(t, rpc.AgentUpgrade_IN_PROGRESS, us.upgrades["echo.default"].Status)
+		assert.Equal(t, newImage, agentImage(t, ctx, "echo"))
+		assert.True(t, restarted(t, ctx, "echo"))
+		assert.Equal(t, rpc.AgentUpgrade_PENDING, us.upgrades["hello.default"].Status)
+		assert.Equal(t, oldImage, agentImage(t, ctx, "hello"))
+
+		// The fake clientset reports the echo rollout as complete, so hello is upgraded next.
+		u.upgradeAll(ctx, newImage)
+		assert.NotContains(t, us.upgrades, "echo.default")
+		assert.Equal(t, rpc.AgentUpgrade_IN_PROGRESS, us.upgrades["hello.default"].Status)
+		assert.Equal(t, newImage, agentImage(t, ctx, "hello"))
+
+		u.upgradeAll(ctx, newImage)
+		assert.Empty(t, us.upgrades)
+	})
+
+	t.Run("reported version", func(t *testing.T) {
+		ctx, cw, us := setup(t, agentconfig.UpgradeImmediately)
+		ki := k8sapi.GetK8sInterface(ctx)
+
+		// Both configs use the new image, but only the hello agent has been restarted since.
+		for _, name := range []string{"echo", "hello"} {
+			dep, err := ki.AppsV1().Deployments("default").Get(ctx, name, meta.GetOptions{})
+			require.NoError(t, err)
+			ac, err := agentmap.Generate(ctx, k8sapi.Deployment(dep), managerutil.GetEnv(ctx).GeneratorConfig(newImage))
+			require.NoError(t, err)
+			require.NoError(t, cw.Store(ctx, ac, false))
+		}
+		cm, err := ki.CoreV1().ConfigMaps("default").Get(ctx, agentconfig.ConfigMap, meta.GetOptions{})
+		require.NoError(t, err)
+		cw.data["default"] = cm.Data
+		us.versions["echo.default"] = "v2.6.0"
+		us.versions["hello.default"] = "v2.7.3"
+
+		u := newAgentUpgrader(cw, us)
+		u.upgradeAll(ctx, newImage)
+		assert.Equal(t, rpc.AgentUpgrade_IN_PROGRESS, us.upgrades["echo.default"].Status)
+		assert.True(t, restarted(t, ctx, "echo"))
+		assert.NotContains(t, us.upgrades, "hello.default")
+		assert.False(t, restarted(t, ctx, "hello"))
+
+		// The rollout is complete. An agent that still reports another version isn't restarted again.
+		u.upgradeAll(ctx, newImage)
+		assert.Empty(t, us.upgrades)
+		assert.Empty(t, u.rollouts)
+	})
+}
+
+func Test_keptAgentImage(t *testing.T) {
+	injected := func() string { return "docker.io/datawire/tel2:2.7.3" }
+	old := &agentconfig.Sidecar{AgentImage: "docker.io/datawire/tel2:2.6.0"}
+	assert.Equal(t, "docker.io/datawire/tel2:2.6.0", keptAgentImage(old, agentconfig.UpgradeWhenIdle, injected))
+	assert.Equal(t, "docker.io/datawire/tel2:2.7.3", keptAgentImage(&agentconfig.Sidecar{}, agentconfig.UpgradeWhenIdle, injected))
+	assert.Equal(t, "docker.io/datawire/tel2:2.7.3", keptAgentImage(nil, agentconfig.UpgradeImmediately, injected))
+
+	// With the Never policy, agents get the injected image when their pods are restarted
+	assert.Equal(t, "docker.io/datawire/tel2:2.7.3", keptAgentImage(old, agentconfig.NeverUpgrade, injected))
+}
+
+func Test_imageVersion(t *testing.T) {
+	assert.Equal(t, "2.7.3", imageVersion("docker.io/datawire/tel2:2.7.3").String())
+	assert.Equal(t, "2.7.3", imageVersion("localhost:5000/tel2:v2.7.3").String())
+	assert.Nil(t, imageVersion("localhost:5000/tel2"))
+	assert.Nil(t, imageVersion("docker.io/datawire/tel2:latest"))
+}
+
+func Test_resolveTargetImage(t *testing.T) {
+	env := &managerutil.Env{AgentRegistry: "docker.io/datawire", AgentImage: "tel2:2.7.3"}
+	ctx := managerutil.WithEnv(dlog.NewTestContext(t, false), env)
+	u := newAgentUpgrader(nil, nil)
+	assert.Equal(t, "docker.io/datawire/tel2:2.7.3", u.resolveTargetImage(ctx))
+
+	// The image is resolved once
+	env.AgentImage = "tel2:2.8.0"
+	assert.Equal(t, "docker.io/datawire/tel2:2.7.3", u.resolveTargetImage(ctx))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +34,7 @@ type Map interface {
 	Run(context.Context) error
 	Delete(context.Context, string, string) error
 	Store(context.Context, *agentconfig.Sidecar, bool) error
+	Sidecars(context.Context) []*agentconfig.Sidecar
 	DeleteMapsAndRolloutAll(ctx context.Context)
 	UninstallV25(ctx context.Context)
 }
@@ -233,6 +235,32 @@ func (c *configWatcher) GetInto(key, ns string, into any) (bool, error) {
 	return true, nil
 }
 
+// Sidecars returns the generated agent configs of the current snapshot, sorted by namespace and name.
+func (c *configWatcher) Sidecars(ctx context.Context) []*agentconfig.Sidecar {
+	var acs []*agentconfig.Sidecar
+	c.RLock()
+	for _, nm := range c.data {
+		for _, v := range nm {
+			ac := &agentconfig.Sidecar{}
+			if err := decode(v, ac); err != nil {
+				dlog.Errorf(ctx, "failed to decode ConfigMap entry %q into an agent config", v)
+				continue
+			}
+			if !(ac.Create || ac.Manual) {
+				acs = append(acs, ac)
+			}
+		}
+	}
+	c.RUnlock()
+	sort.Slice(acs, func(i, j int) bool {
+		if acs[i].Namespace != acs[j].Namespace {
+			return acs[i].Namespace < acs[j].Namespace
+		}
+		return acs[i].AgentName < acs[j].AgentName
+	})
+	return acs
+}
+
 // Delete will delete an agent config from the agents ConfigMap for the given namespace. It will
 // also update the current snapshot.
 // An attempt to delete a manually added config is a no-op
@@ -525,7 +553,7 @@ func (c *configWatcher) affectedConfigs(ctx context.Context, svc *core.Service,
 func (c *configWatcher) updateSvc(ctx context.Context, svc *core.Service, isDelete bool) {
 	// Does the snapshot contain workloads that we didn't find using the service's Spec.Selector?
 	// If so, include them, or if workload for the config entry isn't found, delete that entry
-	cfg := managerutil.GetEnv(ctx).GeneratorConfig(managerutil.GetAgentImage(ctx))
+	env := managerutil.GetEnv(ctx)
 	for _, ac := range c.affectedConfigs(ctx, svc, isDelete) {
 		wl, err := k8sapi.GetWorkload(ctx, ac.WorkloadName, ac.Namespace, ac.WorkloadKind)
 		if err != nil {
@@ -540,7 +568,9 @@ func (c *configWatcher) updateSvc(ctx context.Context, svc *core.Service, isDele
 			continue
 		}
 		dlog.Debugf(ctx, "Regenerating config entry for %s %s.%s", ac.WorkloadKind, ac.WorkloadName, ac.Namespace)
-		acn, err := agentmap.Generate(ctx, wl, cfg)
+		acn, err := agentmap.Generate(ctx, wl, env.GeneratorConfig(keptAgentImage(ac, env.AgentUpgradePolicy, func() string {
+			return managerutil.GetAgentImage(ctx)
+		})))
 		if err != nil {
 			dlog.Error(ctx, err)
 			continue
@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -77,6 +79,16 @@ func (s *State) PrepareIntercept(ctx context.Context, cr *managerrpc.CreateInter
 	if err != nil {
 		return interceptError(err)
 	}
+	if spec.TargetInCluster {
+		if err = checkClusterTarget(ctx, spec, ic.Protocol); err != nil {
+			return interceptError(err)
+		}
+	}
+	if spec.TlsSecret != "" {
+		if err = checkTLSTermination(ctx, ic); err != nil {
+			return interceptError(err)
+		}
+	}
 	if err = s.waitForAgent(ctx, ac.AgentName, ac.Namespace); err != nil {
 		return interceptError(err)
 	}
@@ -91,6 +103,64 @@ func (s *State) PrepareIntercept(ctx context.Context, cr *managerrpc.CreateInter
 	}, nil
 }
 
+// clusterTargetDialTimeout is the timeout used when verifying that an intercept's cluster target is reachable.
+const clusterTargetDialTimeout = 5 * time.Second
+
+// lookupClusterTarget and dialClusterTarget are used by checkClusterTarget. They are variables so that tests
+// can replace them.
+var (
+	lookupClusterTarget = net.DefaultResolver.LookupHost
+	dialClusterTarget   = (&net.Dialer{Timeout: clusterTargetDialTimeout}).DialContext
+)
+
+// clusterTargetHost returns the target host of the given spec, qualified with the namespace of the intercepted
+// workload when it is a short name. The check is performed from the traffic-manager's pod, where a short name
+// would otherwise resolve in the traffic-manager's namespace.
+func clusterTargetHost(spec *managerrpc.InterceptSpec) string {
+	host := spec.TargetHost
+	if spec.Namespace == "" || net.ParseIP(host) != nil || strings.Contains(host, ".") {
+		return host
+	}
+	return host + "." + spec.Namespace
+}
+
+// checkClusterTarget verifies that the target of an intercept that is directed to another address in the
+// cluster can be reached from within the cluster.
+func checkClusterTarget(ctx context.Context, spec *managerrpc.InterceptSpec, proto core.Protocol) error {
+	host := clusterTargetHost(spec)
+	if proto == core.ProtocolUDP {
+		// There's no way to tell if an UDP port is reachable, so settle for checking that the host can be resolved.
+		if _, err := lookupClusterTarget(ctx, host); err != nil {
+			return errcat.User.Newf("unable to resolve cluster target host %s: %v", host, err)
+		}
+		return nil
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(spec.TargetPort)))
+	conn, err := dialClusterTarget(ctx, "tcp", addr)
+	if err != nil {
+		return errcat.User.Newf("cluster target %s is not reachable: %v", addr, err)
+	}
+	_ = conn.Close()
+	return nil
+}
+
+// checkTLSTermination verifies that the traffic-agent can terminate TLS for the given intercept. The
+// application protocol of the port is determined using the configured AppProtocolStrategy, and TLS
+// termination is refused when that protocol is known to be cleartext.
+func checkTLSTermination(ctx context.Context, ic *agentconfig.Intercept) error {
+	if ic.Protocol != core.ProtocolTCP {
+		return errcat.User.Newf("TLS cannot be terminated on %s port %d of service %s", ic.Protocol, ic.ServicePort, ic.ServiceName)
+	}
+	appProto := ic.AppProtocol
+	sp := core.ServicePort{Name: ic.ServicePortName, AppProtocol: &appProto}
+	switch ap := k8sapi.GetAppProto(ctx, managerutil.GetEnv(ctx).AppProtocolStrategy, &sp); ap {
+	case "http", "http2", "grpc", "h2c":
+		return errcat.User.Newf("TLS cannot be terminated on port %d of service %s because it uses the cleartext application protocol %q",
+			ic.ServicePort, ic.ServiceName, ap)
+	}
+	return nil
+}
+
 func (s *State) qualifiedAgentImage(ctx context.Context, extended bool) (img string, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -250,10 +320,19 @@ func (s *State) loadAgentConfig(
 				"annotation %s.%s/%s=true but workload has no corresponding entry in the %s ConfigMap",
 				wl.GetName(), wl.GetNamespace(), install.ManualInjectAnnotation, agentconfig.ConfigMap)
 		}
+		env := managerutil.GetEnv(ctx)
+		policy, err := env.InjectPolicy(ctx, wl)
+		if err != nil {
+			return nil, err
+		}
+		if policy == agentconfig.Never {
+			return nil, errcat.User.Newf("%s %s.%s is not interceptable because its inject policy is %s",
+				wl.GetKind(), wl.GetName(), wl.GetNamespace(), policy)
+		}
 		if cm.Data == nil {
 			cm.Data = make(map[string]string)
 		}
-		ac, err = agentmap.Generate(ctx, wl, managerutil.GetEnv(ctx).GeneratorConfig(agentImage))
+		ac, err = agentmap.Generate(ctx, wl, env.GeneratorConfig(agentImage))
 		if err != nil {
 			return nil, err
 		}
@@ -452,6 +531,10 @@ type interceptState struct {
 	finalizers  []InterceptFinalizer
 	interceptID string
 	clientCtx   context.Context
+
+	// tlsCertificate is the certificate of the intercept's TLS secret. It's kept here rather than in
+	// the InterceptInfo so that it's never sent to anyone but the intercepted agents.
+	tlsCertificate *managerrpc.TLSCertificate
 }
 
 func newInterceptState(clientCtx context.Context, tmCtx context.Context, interceptID string) *interceptState {
@@ -0,0 +1,59 @@
+package state
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+
+	managerrpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestCheckClusterTarget_QualifiesShortNames(t *testing.T) {
+	origLookup, origDial := lookupClusterTarget, dialClusterTarget
+	defer func() {
+		lookupClusterTarget, dialClusterTarget = origLookup, origDial
+	}()
+
+	var looked, dialed string
+	lookupClusterTarget = func(_ context.Context, host string) ([]string, error) {
+		looked = host
+		return []string{"10.0.0.1"}, nil
+	}
+	dialClusterTarget = func(_ context.Context, _, addr string) (net.Conn, error) {
+		dialed = addr
+		c1, c2 := net.Pipe()
+		_ = c2.Close()
+		return c1, nil
+	}
+
+	tests := []struct {
+		name       string
+		targetHost string
+		wantHost   string
+	}{
+		{"short name", "svc", "svc.workload-ns"},
+		{"namespaced name", "svc.other-ns", "svc.other-ns"},
+		{"fqdn", "svc.other-ns.svc.cluster.local", "svc.other-ns.svc.cluster.local"},
+		{"ipv4", "10.1.2.3", "10.1.2.3"},
+		{"ipv6", "fd00::1", "fd00::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &managerrpc.InterceptSpec{
+				Namespace:       "workload-ns",
+				TargetHost:      tt.targetHost,
+				TargetPort:      8080,
+				TargetInCluster: true,
+			}
+			require.NoError(t, checkClusterTarget(context.Background(), spec, core.ProtocolTCP))
+			assert.Equal(t, net.JoinHostPort(tt.wantHost, "8080"), dialed)
+
+			require.NoError(t, checkClusterTarget(context.Background(), spec, core.ProtocolUDP))
+			assert.Equal(t, tt.wantHost, looked)
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
+	authenticationv1 "k8s.io/api/authentication/v1"
 
 	"github.com/datawire/dlib/dlog"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
@@ -157,8 +158,25 @@ type clientSessionState struct {
 	sessionState
 	name string
 	pool *tunnel.Pool
+
+	// user is the Kubernetes user of the client, as verified by a TokenReview. Nil when unverified.
+	user *authenticationv1.UserInfo
+}
+
+// lostClient identifies the client of a client session that has been removed. Only that client may
+// reclaim the ID of the session. Lost clients are only remembered in memory, so a session that was lost
+// because the traffic-manager restarted can't be reclaimed.
+type lostClient struct {
+	name       string
+	installID  string
+	user       string
+	lastMarked time.Time
 }
 
+// lostClientRetention is for how long after its last heartbeat the ID of a removed client session can be
+// reclaimed.
+const lostClientRetention = 7 * 24 * time.Hour
+
 type agentSessionState struct {
 	sessionState
 	agent           *rpc.AgentInfo
@@ -195,6 +213,8 @@ type State struct {
 	//  7. `cfgMapLocks` access must be concurrency protected
 	//  8. `cachedAgentImage` access must be concurrency protected
 	//  9. `interceptState` must be concurrency protected and updated/deleted in sync with intercepts
+	// 10. `agentUpgrades` needs to stay in-sync with the `Upgrade` of the agents in `agents`
+	// 11. `lostClients` needs to be updated in-sync with `clients`
 	intercepts       watchable.Map[*rpc.InterceptInfo]
 	agents           watchable.Map[*rpc.AgentInfo]        // info for agent sessions
 	clients          watchable.Map[*rpc.ClientInfo]       // info for client sessions
@@ -205,6 +225,8 @@ type State struct {
 	llSubs           *loglevelSubscribers
 	cfgMapLocks      map[string]*sync.Mutex
 	cachedAgentImage string
+	agentUpgrades    map[string]*rpc.AgentUpgrade // upgrade status keyed by "<agent name>.<namespace>"
+	lostClients      map[string]*lostClient       // clients of removed client sessions, keyed by session ID
 }
 
 func NewState(ctx context.Context) *State {
@@ -215,6 +237,8 @@ func NewState(ctx context.Context) *State {
 		agentsByName:    make(map[string]map[string]*rpc.AgentInfo),
 		cfgMapLocks:     make(map[string]*sync.Mutex),
 		interceptStates: make(map[string]*interceptState),
+		agentUpgrades:   make(map[string]*rpc.AgentUpgrade),
+		lostClients:     make(map[string]*lostClient),
 		timedLogLevel:   log.NewTimedLevel(loglevel, log.SetLevel),
 		llSubs:          newLoglevelSubscribers(),
 	}
@@ -362,8 +386,17 @@ func (s *State) unlockedRemoveSession(sessionID string) {
 			}
 			// remove the session
 			s.agents.Delete(sessionID)
-		} else {
-			s.clients.Delete(sessionID)
+		} else if client, ok := s.clients.LoadAndDelete(sessionID); ok {
+			// Remember the client, so that it, and only it, can reclaim the session ID.
+			lc := &lostClient{
+				name:       client.Name,
+				installID:  client.InstallId,
+				lastMarked: sess.LastMarked(),
+			}
+			if cs, ok := sess.(*clientSessionState); ok && cs.user != nil {
+				lc.user = cs.user.Username
+			}
+			s.lostClients[sessionID] = lc
 		}
 
 		delete(s.sessions, sessionID)
@@ -389,6 +422,11 @@ func (s *State) ExpireSessions(ctx context.Context, clientMoment, agentMoment ti
 			}
 		}
 	}
+	for id, lc := range s.lostClients {
+		if lc.lastMarked.Before(clientMoment.Add(-lostClientRetention)) {
+			delete(s.lostClients, id)
+		}
+	}
 }
 
 // SessionDone returns a channel that is closed when the session with the given ID terminates.  If
@@ -406,20 +444,52 @@ func (s *State) SessionDone(id string) (<-chan struct{}, error) {
 
 // Sessions: Clients ///////////////////////////////////////////////////////////////////////////////
 
-func (s *State) AddClient(client *rpc.ClientInfo, now time.Time) string {
-	// Use non-sequential things (i.e., UUIDs, not just a counter) as the session ID, because
-	// the session ID also exists in external systems (the client, SystemA), so it's confusing
-	// (to both humans and computers) if the manager restarts and those existing session IDs
-	// suddenly refer to different sessions.
-	sessionID := uuid.New().String()
-	return s.addClient(sessionID, client, now)
+// AddClient adds a session for the given client, and returns the ID of that session. The user is the
+// verified Kubernetes user of the client, or nil when the user isn't verified.
+func (s *State) AddClient(client *rpc.ClientInfo, user *authenticationv1.UserInfo, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A client that lost its session may reclaim the ID of that session, so that the intercepts that it
+	// recreates get the same IDs as before. Session IDs are part of intercept IDs, so they aren't secret,
+	// and the ID is only given back to the client of the lost session.
+	sessionID := client.SessionId
+	if lc, ok := s.lostClients[sessionID]; ok && lc.ownedBy(client, user) {
+		delete(s.lostClients, sessionID)
+	} else {
+		sessionID = ""
+	}
+	if sessionID == "" {
+		// Use non-sequential things (i.e., UUIDs, not just a counter) as the session ID, because
+		// the session ID also exists in external systems (the client, SystemA), so it's confusing
+		// (to both humans and computers) if the manager restarts and those existing session IDs
+		// suddenly refer to different sessions.
+		sessionID = uuid.New().String()
+	}
+	sessionID = s.unlockedAddClient(sessionID, client, now)
+	if user != nil {
+		s.sessions[sessionID].(*clientSessionState).user = user
+	}
+	return sessionID
+}
+
+// ownedBy returns true if the given client, with the given verified user, is the client that was lost.
+func (lc *lostClient) ownedBy(client *rpc.ClientInfo, user *authenticationv1.UserInfo) bool {
+	username := ""
+	if user != nil {
+		username = user.Username
+	}
+	return lc.name == client.Name && lc.installID == client.InstallId && lc.user == username
 }
 
 // addClient is like AddClient, but takes a sessionID, for testing purposes
 func (s *State) addClient(sessionID string, client *rpc.ClientInfo, now time.Time) string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.unlockedAddClient(sessionID, client, now)
+}
 
+func (s *State) unlockedAddClient(sessionID string, client *rpc.ClientInfo, now time.Time) string {
 	if oldClient, hasConflict := s.clients.LoadOrStore(sessionID, client); hasConflict {
 		panic(fmt.Errorf("duplicate id %q, existing %+v, new %+v", sessionID, oldClient, client))
 	}
@@ -431,6 +501,17 @@ func (s *State) addClient(sessionID string, client *rpc.ClientInfo, now time.Tim
 	return sessionID
 }
 
+// GetClientUser returns the verified Kubernetes user of the client session with the given ID, or nil
+// if the session doesn't exist or if its user wasn't verified.
+func (s *State) GetClientUser(sessionID string) *authenticationv1.UserInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sess, ok := s.sessions[sessionID].(*clientSessionState); ok {
+		return sess.user
+	}
+	return nil
+}
+
 func (s *State) GetClient(sessionID string) *rpc.ClientInfo {
 	ret, _ := s.clients.Load(sessionID)
 	return ret
@@ -462,6 +543,9 @@ func (s *State) AddAgent(agent *rpc.AgentInfo, now time.Time) string {
 	defer s.mu.Unlock()
 
 	sessionID := uuid.New().String()
+	if upgrade, ok := s.agentUpgrades[agent.Name+"."+agent.Namespace]; ok {
+		agent.Upgrade = upgrade
+	}
 	if oldAgent, hasConflict := s.agents.LoadOrStore(sessionID, agent); hasConflict {
 		panic(fmt.Errorf("duplicate id %q, existing %+v, new %+v", sessionID, oldAgent, agent))
 	}
@@ -518,6 +602,35 @@ func (s *State) GetAgentsByName(name, namespace string) map[string]*rpc.AgentInf
 	return ret
 }
 
+// SetAgentUpgrade sets the upgrade status of all agents with the given name and namespace, including
+// agents that arrive later on. A nil upgrade clears the status.
+func (s *State) SetAgentUpgrade(name, namespace string, upgrade *rpc.AgentUpgrade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := name + "." + namespace
+	if proto.Equal(s.agentUpgrades[key], upgrade) {
+		return
+	}
+	if upgrade == nil {
+		delete(s.agentUpgrades, key)
+	} else {
+		s.agentUpgrades[key] = upgrade
+	}
+	for sessionID, agent := range s.agentsByName[name] {
+		if agent.Namespace != namespace {
+			continue
+		}
+		agent = proto.Clone(agent).(*rpc.AgentInfo)
+		agent.Upgrade = upgrade
+		s.agentsByName[name][sessionID] = agent
+		s.agents.Store(sessionID, agent)
+		if as, ok := s.sessions[sessionID].(*agentSessionState); ok {
+			as.agent = agent
+		}
+	}
+}
+
 func (s *State) WatchAgents(
 	ctx context.Context,
 	filter func(sessionID string, agent *rpc.AgentInfo) bool,
@@ -531,7 +644,12 @@ func (s *State) WatchAgents(
 
 // Intercepts //////////////////////////////////////////////////////////////////////////////////////
 
-func (s *State) AddIntercept(sessionID, clusterID, apiKey string, client *rpc.ClientInfo, spec *rpc.InterceptSpec) (*rpc.InterceptInfo, error) {
+func (s *State) AddIntercept(
+	sessionID, clusterID, apiKey string,
+	client *rpc.ClientInfo,
+	spec *rpc.InterceptSpec,
+	tlsCert *rpc.TLSCertificate,
+) (*rpc.InterceptInfo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -554,6 +672,9 @@ func (s *State) AddIntercept(sessionID, clusterID, apiKey string, client *rpc.Cl
 		},
 		ApiKey: apiKey,
 	}
+	if sess.user != nil {
+		cept.VerifiedUser = sess.user.Username
+	}
 
 	// Wrap each potential-state-change in a
 	//
@@ -572,6 +693,7 @@ func (s *State) AddIntercept(sessionID, clusterID, apiKey string, client *rpc.Cl
 	}
 
 	state := newInterceptState(sess.ctx, s.ctx, cept.Id)
+	state.tlsCertificate = tlsCert
 	s.interceptStates[interceptID] = state
 
 	return cept, nil
@@ -588,6 +710,30 @@ func (s *State) AddInterceptFinalizer(interceptID string, finalizer InterceptFin
 	return nil
 }
 
+// GetInterceptTLSCertificate returns the certificate that the agents use to terminate TLS for the
+// given intercept, provided that the agent with the given session ID is an agent of the intercepted
+// workload.
+func (s *State) GetInterceptTLSCertificate(agentSessionID, interceptID string) (*rpc.TLSCertificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	agent, ok := s.agents.Load(agentSessionID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "agent session %q not found", agentSessionID)
+	}
+	cept, ok := s.intercepts.Load(interceptID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no such intercept %s", interceptID)
+	}
+	if cept.Spec.Agent != agent.Name || cept.Spec.Namespace != agent.Namespace {
+		return nil, status.Errorf(codes.PermissionDenied, "intercept %s doesn't target agent %s.%s", interceptID, agent.Name, agent.Namespace)
+	}
+	state, ok := s.interceptStates[interceptID]
+	if !ok || state.tlsCertificate == nil {
+		return nil, status.Errorf(codes.NotFound, "intercept %s has no TLS certificate", interceptID)
+	}
+	return state.tlsCertificate, nil
+}
+
 // getAgentsInterceptedByClient returns the session IDs for each agent that are currently
 // intercepted by the client with the given client session ID.
 func (s *State) getAgentsInterceptedByClient(clientSessionID string) []string {
@@ -664,6 +810,18 @@ func (s *State) GetIntercept(interceptID string) (*rpc.InterceptInfo, bool) {
 	return s.intercepts.Load(interceptID)
 }
 
+// HasIntercepts returns true if the agent with the given name and namespace has intercepts.
+func (s *State) HasIntercepts(name, namespace string) bool {
+	return len(s.intercepts.LoadAllMatching(func(_ string, ii *rpc.InterceptInfo) bool {
+		return ii.Spec.Agent == name && ii.Spec.Namespace == namespace
+	})) > 0
+}
+
+// GetInterceptsMatching returns the intercepts that match the given filter.
+func (s *State) GetInterceptsMatching(filter func(string, *rpc.InterceptInfo) bool) map[string]*rpc.InterceptInfo {
+	return s.intercepts.LoadAllMatching(filter)
+}
+
 func (s *State) WatchIntercepts(
 	ctx context.Context,
 	filter func(sessionID string, intercept *rpc.InterceptInfo) bool,
@@ -5,6 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
 	manager "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/state"
 	testdata "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/test"
 )
@@ -70,6 +74,36 @@ func TestStateInternal(topT *testing.T) {
 		a.Len(agents, 0)
 	})
 
+	topT.Run("agent-upgrade", func(t *testing.T) {
+		a := assertNew(t)
+
+		clock := &FakeClock{}
+		state := manager.NewState(ctx)
+
+		d1 := state.AddAgent(proto.Clone(testAgents["demo1"]).(*rpc.AgentInfo), clock.Now())
+		h := state.AddAgent(proto.Clone(testAgents["hello"]).(*rpc.AgentInfo), clock.Now())
+
+		upgrade := &rpc.AgentUpgrade{
+			Status:      rpc.AgentUpgrade_PENDING,
+			Image:       "docker.io/datawire/tel2:2.6.0",
+			TargetImage: "docker.io/datawire/tel2:2.7.3",
+		}
+		state.SetAgentUpgrade("demo", "default", upgrade)
+		a.Equal(upgrade, state.GetAgent(d1).Upgrade)
+		a.Nil(state.GetAgent(h).Upgrade)
+
+		// agents that arrive later get the same status
+		d2 := state.AddAgent(proto.Clone(testAgents["demo2"]).(*rpc.AgentInfo), clock.Now())
+		a.Equal(upgrade, state.GetAgent(d2).Upgrade)
+		for _, agent := range state.GetAgentsByName("demo", "default") {
+			a.Equal(upgrade, agent.Upgrade)
+		}
+
+		state.SetAgentUpgrade("demo", "default", nil)
+		a.Nil(state.GetAgent(d1).Upgrade)
+		a.Nil(state.GetAgent(d2).Upgrade)
+	})
+
 	topT.Run("presence-redundant", func(t *testing.T) {
 		a := assertNew(t)
 
@@ -77,9 +111,9 @@ func TestStateInternal(topT *testing.T) {
 		epoch := clock.Now()
 		state := manager.NewState(ctx)
 
-		c1 := state.AddClient(testClients["alice"], clock.Now())
-		c2 := state.AddClient(testClients["bob"], clock.Now())
-		c3 := state.AddClient(testClients["cameron"], clock.Now())
+		c1 := state.AddClient(testClients["alice"], nil, clock.Now())
+		c2 := state.AddClient(testClients["bob"], nil, clock.Now())
+		c3 := state.AddClient(testClients["cameron"], nil, clock.Now())
 
 		a.True(state.HasClient(c1))
 		a.True(state.HasClient(c2))
@@ -124,4 +158,69 @@ func TestStateInternal(topT *testing.T) {
 		a.False(state.Mark(c2, clock.Now()))
 		a.False(state.Mark(c3, clock.Now()))
 	})
+
+	topT.Run("presence-reclaim", func(t *testing.T) {
+		a := assertNew(t)
+
+		clock := &FakeClock{}
+		state := manager.NewState(ctx)
+
+		withSessionID := func(name, sessionID string) *rpc.ClientInfo {
+			client := proto.Clone(testClients[name]).(*rpc.ClientInfo)
+			client.SessionId = sessionID
+			return client
+		}
+
+		c1 := state.AddClient(testClients["alice"], nil, clock.Now())
+		state.RemoveSession(ctx, c1)
+		a.False(state.HasClient(c1))
+
+		// Only the client that lost the session can reclaim its ID
+		a.NotEqual(c1, state.AddClient(withSessionID("bob", c1), nil, clock.Now()))
+		impostor := withSessionID("alice", c1)
+		impostor.InstallId = "install_id_for_mallory"
+		a.NotEqual(c1, state.AddClient(impostor, nil, clock.Now()))
+
+		// A client that lost its session gets the same session ID back
+		a.Equal(c1, state.AddClient(withSessionID("alice", c1), nil, clock.Now()))
+		a.True(state.HasClient(c1))
+
+		// but not while that session is in use
+		c2 := state.AddClient(withSessionID("bob", c1), nil, clock.Now())
+		a.NotEqual(c1, c2)
+		a.True(state.HasClient(c2))
+
+		// and not if it isn't a valid session ID
+		a.NotEqual("asdf", state.AddClient(withSessionID("cameron", "asdf"), nil, clock.Now()))
+
+		// and not twice
+		state.RemoveSession(ctx, c1)
+		a.Equal(c1, state.AddClient(withSessionID("alice", c1), nil, clock.Now()))
+		a.NotEqual(c1, state.AddClient(withSessionID("alice", c1), nil, clock.Now()))
+
+		// A verified user must be the same user
+		c3 := state.AddClient(testClients["cameron"], &authenticationv1.UserInfo{Username: "cameron"}, clock.Now())
+		state.RemoveSession(ctx, c3)
+		a.NotEqual(c3, state.AddClient(withSessionID("cameron", c3), &authenticationv1.UserInfo{Username: "mallory"}, clock.Now()))
+		a.NotEqual(c3, state.AddClient(withSessionID("cameron", c3), nil, clock.Now()))
+		a.Equal(c3, state.AddClient(withSessionID("cameron", c3), &authenticationv1.UserInfo{Username: "cameron"}, clock.Now()))
+		a.Equal("cameron", state.GetClientUser(c3).Username)
+
+		// A session that expired can be reclaimed until the retention of lost clients ends
+		c4 := state.AddClient(testClients["alice"], nil, clock.Now())
+		clock.When = 60
+		state.ExpireSessions(ctx, clock.Now().Add(-30*time.Second), clock.Now())
+		a.False(state.HasClient(c4))
+		a.Equal(c4, state.AddClient(withSessionID("alice", c4), nil, clock.Now()))
+		state.ExpireSessions(ctx, clock.Now().Add(time.Second), clock.Now())
+		a.False(state.HasClient(c4))
+		state.ExpireSessions(ctx, clock.Now().Add(8*24*time.Hour), clock.Now())
+		a.NotEqual(c4, state.AddClient(withSessionID("alice", c4), nil, clock.Now()))
+
+		// Lost clients are only remembered in memory, so a restarted traffic-manager can't give the ID back
+		c5 := state.AddClient(testClients["bob"], nil, clock.Now())
+		state.RemoveSession(ctx, c5)
+		restarted := manager.NewState(ctx)
+		a.NotEqual(c5, restarted.AddClient(withSessionID("bob", c5), nil, clock.Now()))
+	})
 }
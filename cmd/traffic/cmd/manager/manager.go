@@ -80,7 +80,11 @@ func Main(ctx context.Context, _ ...string) error {
 
 	g.Go("prometheus", mgr.servePrometheus)
 
-	g.Go("agent-injector", mutator.ServeMutator)
+	g.Go("preview", mgr.servePreviews)
+
+	g.Go("agent-injector", func(ctx context.Context) error {
+		return mutator.ServeMutator(ctx, mgr.state)
+	})
 
 	g.Go("session-gc", mgr.runSessionGCLoop)
 
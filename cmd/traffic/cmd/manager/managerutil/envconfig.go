@@ -2,11 +2,14 @@ package managerutil
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
@@ -35,6 +38,33 @@ type Env struct {
 	MaxReceiveSize      resource.Quantity          `env:"TELEPRESENCE_MAX_RECEIVE_SIZE,default=4Mi"`
 	AppProtocolStrategy k8sapi.AppProtocolStrategy `env:"TELEPRESENCE_APP_PROTO_STRATEGY,default="`
 	AgentInjectPolicy   agentconfig.InjectPolicy   `env:"AGENT_INJECT_POLICY,default="`
+	AgentInjectRules    agentconfig.InjectRules    `env:"AGENT_INJECT_RULES,default="`
+	AgentFirewall       string                     `env:"AGENT_FIREWALL_BACKEND,default=auto"`
+	AgentCNI            bool                       `env:"AGENT_CNI_ENABLED,default=false"`
+	ClientSessionTTL    time.Duration              `env:"CLIENT_SESSION_TTL,default=24h"`
+
+	AgentPullPolicy      string                           `env:"AGENT_IMAGE_PULL_POLICY,default="`
+	AgentResources       agentconfig.ResourceRequirements `env:"AGENT_RESOURCES,default="`
+	AgentSecurityContext agentconfig.SecurityContext      `env:"AGENT_SECURITY_CONTEXT,default="`
+	AgentEnv             agentconfig.EnvVars              `env:"AGENT_ENV,default="`
+
+	WebhookSecret      string        `env:"AGENT_INJECTOR_SECRET,default=mutator-webhook-tls"`
+	WebhookName        string        `env:"AGENT_INJECTOR_WEBHOOK_NAME,default="`
+	WebhookRenewBefore time.Duration `env:"AGENT_INJECTOR_CERT_RENEW_BEFORE,default=720h"`
+
+	AgentUpgradePolicy         agentconfig.UpgradePolicy `env:"AGENT_UPGRADE_POLICY,default="`
+	AgentUpgradeMaxUnavailable int                       `env:"AGENT_UPGRADE_MAX_UNAVAILABLE,default=1"`
+
+	PreviewBaseDomain string `env:"PREVIEW_BASE_DOMAIN,default="`
+	PreviewPort       int32  `env:"PREVIEW_PORT,default=8082"`
+	PreviewTLS        bool   `env:"PREVIEW_TLS,default=true"`
+
+	OIDCIssuerURL     string `env:"OIDC_ISSUER_URL,default="`
+	OIDCClientID      string `env:"OIDC_CLIENT_ID,default="`
+	OIDCIdentityClaim string `env:"OIDC_IDENTITY_CLAIM,default=email"`
+
+	ClientTokenReview  bool `env:"CLIENT_TOKEN_REVIEW,default=false"`
+	ClientAccessReview bool `env:"CLIENT_ACCESS_REVIEW,default=false"`
 
 	PodCIDRStrategy string `env:"POD_CIDR_STRATEGY,default=auto"`
 	PodCIDRs        string `env:"POD_CIDRS,default="`
@@ -50,14 +80,24 @@ type Env struct {
 type envKey struct{}
 
 func (e *Env) GeneratorConfig(qualifiedAgentImage string) *agentmap.GeneratorConfig {
-	return &agentmap.GeneratorConfig{
+	gc := &agentmap.GeneratorConfig{
 		AgentPort:           uint16(e.AgentPort),
 		APIPort:             uint16(e.APIPort),
 		TracingPort:         uint16(e.TracingPort),
 		QualifiedAgentImage: qualifiedAgentImage,
 		ManagerNamespace:    e.ManagerNamespace,
 		LogLevel:            e.LogLevel,
+		FirewallBackend:     e.AgentFirewall,
+		PullPolicy:          e.AgentPullPolicy,
+		Env:                 e.AgentEnv,
+	}
+	if !e.AgentResources.IsEmpty() {
+		gc.Resources = &e.AgentResources
+	}
+	if !e.AgentSecurityContext.IsEmpty() {
+		gc.SecurityContext = &e.AgentSecurityContext
 	}
+	return gc
 }
 
 func (e *Env) QualifiedAgentImage() string {
@@ -68,6 +108,27 @@ func (e *Env) QualifiedAgentImage() string {
 	return e.AgentRegistry + "/" + img
 }
 
+// InjectPolicy returns the policy of the first of the AgentInjectRules that matches the given workload, or
+// the AgentInjectPolicy when no rule matches.
+func (e *Env) InjectPolicy(ctx context.Context, wl k8sapi.Workload) (agentconfig.InjectPolicy, error) {
+	if len(e.AgentInjectRules) == 0 {
+		return e.AgentInjectPolicy, nil
+	}
+	ns, err := k8sapi.GetK8sInterface(ctx).CoreV1().Namespaces().Get(ctx, wl.GetNamespace(), meta.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("unable to get namespace %s: %w", wl.GetNamespace(), err)
+	}
+	return e.AgentInjectRules.PolicyFor(ns.Labels, wl.GetKind(), wl.GetLabels(), e.AgentInjectPolicy), nil
+}
+
+// GetWebhookName returns the name of the MutatingWebhookConfiguration of the agent-injector.
+func (e *Env) GetWebhookName() string {
+	if e.WebhookName != "" {
+		return e.WebhookName
+	}
+	return "agent-injector-webhook-" + e.ManagerNamespace
+}
+
 func (e *Env) GetManagedNamespaces() []string {
 	if mns := e.ManagedNamespaces; mns != "" {
 		return strings.Split(mns, " ")
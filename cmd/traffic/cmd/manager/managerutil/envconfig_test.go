@@ -6,11 +6,15 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
 )
 
@@ -40,10 +44,19 @@ func TestEnvconfig(t *testing.T) {
 		AgentImage:          "",
 		AgentPort:           9900,
 		MaxReceiveSize:      resource.MustParse("4Mi"),
+		AgentFirewall:       "auto",
+		ClientSessionTTL:    24 * time.Hour,
+		WebhookSecret:       "mutator-webhook-tls",
+		WebhookRenewBefore:  30 * 24 * time.Hour,
+		PreviewPort:         8082,
+		PreviewTLS:          true,
+		OIDCIdentityClaim:   "email",
 		PodCIDRStrategy:     "auto",
 		DNSServiceName:      "coredns",
 		DNSServiceNamespace: "kube-system",
 		LogLevel:            "info",
+
+		AgentUpgradeMaxUnavailable: 1,
 	}
 
 	testcases := map[string]struct {
@@ -62,6 +75,51 @@ func TestEnvconfig(t *testing.T) {
 				e.SystemAHost = "app.getambassador.io"
 			},
 		},
+		"agent settings": {
+			Input: map[string]string{
+				"AGENT_IMAGE_PULL_POLICY": "Always",
+				"AGENT_RESOURCES":         `{"limits":{"cpu":"500m"},"requests":{"cpu":"100m","memory":"64Mi"}}`,
+				"AGENT_SECURITY_CONTEXT":  `{"runAsNonRoot":true}`,
+				"AGENT_ENV":               `{"TZ":"UTC"}`,
+			},
+			Output: func(e *managerutil.Env) {
+				e.AgentPullPolicy = "Always"
+				e.AgentResources = agentconfig.ResourceRequirements{
+					Limits: core.ResourceList{core.ResourceCPU: resource.MustParse("500m")},
+					Requests: core.ResourceList{
+						core.ResourceCPU:    resource.MustParse("100m"),
+						core.ResourceMemory: resource.MustParse("64Mi"),
+					},
+				}
+				runAsNonRoot := true
+				e.AgentSecurityContext = agentconfig.SecurityContext{RunAsNonRoot: &runAsNonRoot}
+				e.AgentEnv = agentconfig.EnvVars{"TZ": "UTC"}
+			},
+		},
+		"inject rules": {
+			Input: map[string]string{
+				"AGENT_INJECT_POLICY": "WhenEnabled",
+				"AGENT_INJECT_RULES":  `[{"namespaceSelector":{"matchLabels":{"env":"dev"}},"workloadKinds":["Deployment"],"policy":"Always"}]`,
+			},
+			Output: func(e *managerutil.Env) {
+				e.AgentInjectPolicy = agentconfig.WhenEnabled
+				e.AgentInjectRules = agentconfig.InjectRules{{
+					NamespaceSelector: &meta.LabelSelector{MatchLabels: map[string]string{"env": "dev"}},
+					WorkloadKinds:     []string{"Deployment"},
+					Policy:            agentconfig.Always,
+				}}
+			},
+		},
+		"agent upgrade": {
+			Input: map[string]string{
+				"AGENT_UPGRADE_POLICY":          "WhenIdle",
+				"AGENT_UPGRADE_MAX_UNAVAILABLE": "3",
+			},
+			Output: func(e *managerutil.Env) {
+				e.AgentUpgradePolicy = agentconfig.UpgradeWhenIdle
+				e.AgentUpgradeMaxUnavailable = 3
+			},
+		},
 	}
 
 	for tcName, tc := range testcases {
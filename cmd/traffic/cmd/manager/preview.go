@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+)
+
+// previewHostIDLength is the number of random bytes in the generated host name of a self-hosted preview URL.
+const previewHostIDLength = 8
+
+// servePreviews serves the self-hosted preview URLs if env.PreviewBaseDomain is set. Requests are routed to
+// an intercept using their host name, or their x-telepresence-intercept-id header, and are forwarded to the
+// intercept's ingress in the same way as requests that arrive from Ambassador Cloud.
+func (m *Manager) servePreviews(ctx context.Context) error {
+	env := managerutil.GetEnv(ctx)
+	if env.PreviewBaseDomain == "" {
+		dlog.Info(ctx, "Preview router not started")
+		return nil
+	}
+	sc := &dhttp.ServerConfig{
+		Handler: http.HandlerFunc(m.servePreview),
+	}
+	dlog.Infof(ctx, "Preview router for *.%s started on port: %d", env.PreviewBaseDomain, env.PreviewPort)
+	return sc.ListenAndServe(ctx, ":"+strconv.Itoa(int(env.PreviewPort)))
+}
+
+// previewHost returns the host of the given preview URL.
+func previewHost(previewURL string) string {
+	if i := strings.Index(previewURL, "://"); i >= 0 {
+		previewURL = previewURL[i+3:]
+	}
+	return strings.TrimSuffix(previewURL, "/")
+}
+
+// previewIntercept returns the intercept that the given request is routed to, or nil if there's no such
+// intercept.
+func (m *Manager) previewIntercept(r *http.Request) *rpc.InterceptInfo {
+	if id := r.Header.Get(restapi.HeaderInterceptID); id != "" {
+		if ii, ok := m.state.GetIntercept(id); ok && ii.PreviewDomain != "" {
+			return ii
+		}
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, ii := range m.state.GetInterceptsMatching(func(_ string, ii *rpc.InterceptInfo) bool {
+		return ii.PreviewDomain != "" && strings.EqualFold(previewHost(ii.PreviewDomain), host)
+	}) {
+		return ii
+	}
+	return nil
+}
+
+func (m *Manager) servePreview(w http.ResponseWriter, r *http.Request) {
+	ii := m.previewIntercept(r)
+	if ii == nil {
+		http.Error(w, "no intercept has a preview URL for "+r.Host, http.StatusNotFound)
+		return
+	}
+	l5Host := ii.PreviewSpec.GetIngress().GetL5Host()
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "http"
+			r.URL.Host = l5Host
+			r.Host = l5Host
+			r.Header.Set(restapi.HeaderInterceptID, ii.Id)
+			for k, v := range ii.PreviewSpec.GetAddRequestHeaders() {
+				r.Header.Set(k, v)
+			}
+		},
+		// The ingress is dialed for each request. DialIntercept returns a TLS connection when the ingress
+		// uses TLS, so the request itself is always sent in clear text.
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return m.DialIntercept(ctx, ii.Id)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// addSelfHostedPreviewDomain assigns a preview URL with a generated host name in the env.PreviewBaseDomain
+// to the given intercept.
+func (m *Manager) addSelfHostedPreviewDomain(ctx context.Context, interceptID string, spec *rpc.PreviewSpec) (*rpc.InterceptInfo, error) {
+	env := managerutil.GetEnv(ctx)
+	id := make([]byte, previewHostIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if env.PreviewTLS {
+		scheme = "https"
+	}
+	previewURL := scheme + "://" + hex.EncodeToString(id) + "." + env.PreviewBaseDomain
+	intercept := m.state.UpdateIntercept(interceptID, func(intercept *rpc.InterceptInfo) {
+		if intercept.PreviewDomain != "" {
+			return
+		}
+		intercept.PreviewDomain = previewURL
+		intercept.PreviewSpec = spec
+	})
+	if intercept == nil {
+		return nil, status.Errorf(codes.NotFound, "Intercept with ID %q not found for this session", interceptID)
+	}
+	return intercept, nil
+}
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sVersion "k8s.io/apimachinery/pkg/version"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	testdata "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/internal/test"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+)
+
+func TestPreviewHost(t *testing.T) {
+	assert.Equal(t, "abc.preview.example.com", previewHost("https://abc.preview.example.com"))
+	assert.Equal(t, "abc.preview.example.com", previewHost("http://abc.preview.example.com/"))
+	assert.Equal(t, "abc.preview.example.com", previewHost("abc.preview.example.com"))
+}
+
+func TestSelfHostedPreview(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+
+	// The ingress records the requests that the preview router forwards to it.
+	var got *http.Request
+	ingress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ingress.Close()
+	host, port, err := net.SplitHostPort(ingress.Listener.Addr().String())
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	fakeClient := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	})
+	fakeClient.Discovery().(*fakeDiscovery.FakeDiscovery).FakedServerVersion = &k8sVersion.Info{
+		GitVersion: "v1.17.0",
+	}
+	ctx = k8sapi.WithK8sInterface(ctx, fakeClient)
+	ctx = managerutil.WithEnv(ctx, &managerutil.Env{
+		PodCIDRStrategy:   "environment",
+		PodCIDRs:          "192.168.0.0/16",
+		PreviewBaseDomain: "preview.example.com",
+		PreviewTLS:        true,
+	})
+	m, ctx, err := NewManager(ctx)
+	require.NoError(t, err)
+
+	alice := testdata.GetTestClients(t)["alice"]
+	sess, err := m.ArriveAsClient(ctx, alice)
+	require.NoError(t, err)
+	_, err = m.CreateIntercept(ctx, &rpc.CreateInterceptRequest{
+		Session: sess,
+		InterceptSpec: &rpc.InterceptSpec{
+			Name:       "first",
+			Namespace:  "default",
+			Client:     alice.Name,
+			Agent:      "hello",
+			Mechanism:  "tcp",
+			TargetHost: "asdf",
+			TargetPort: 9876,
+		},
+	})
+	require.NoError(t, err)
+
+	ii, err := m.UpdateIntercept(ctx, &rpc.UpdateInterceptRequest{
+		Session: sess,
+		Name:    "first",
+		PreviewDomainAction: &rpc.UpdateInterceptRequest_AddPreviewDomain{
+			AddPreviewDomain: &rpc.PreviewSpec{
+				Ingress: &rpc.IngressInfo{
+					Host:   host,
+					Port:   int32(portNum),
+					L5Host: "hello.default",
+				},
+				AddRequestHeaders: map[string]string{"x-extra": "yes"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(ii.PreviewDomain, "https://"), ii.PreviewDomain)
+	require.True(t, strings.HasSuffix(ii.PreviewDomain, ".preview.example.com"), ii.PreviewDomain)
+
+	serve := func(r *http.Request) int {
+		got = nil
+		rec := httptest.NewRecorder()
+		m.servePreview(rec, r)
+		return rec.Code
+	}
+
+	// Routed by host name
+	require.Equal(t, http.StatusOK, serve(httptest.NewRequest(http.MethodGet, "http://"+previewHost(ii.PreviewDomain)+"/path", nil)))
+	require.NotNil(t, got)
+	assert.Equal(t, "hello.default", got.Host)
+	assert.Equal(t, "/path", got.URL.Path)
+	assert.Equal(t, ii.Id, got.Header.Get(restapi.HeaderInterceptID))
+	assert.Equal(t, "yes", got.Header.Get("x-extra"))
+
+	// Routed by intercept id
+	r := httptest.NewRequest(http.MethodGet, "http://preview.example.com/", nil)
+	r.Header.Set(restapi.HeaderInterceptID, ii.Id)
+	require.Equal(t, http.StatusOK, serve(r))
+	require.NotNil(t, got)
+
+	// Unknown host
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest(http.MethodGet, "http://other.preview.example.com/", nil)))
+	assert.Nil(t, got)
+
+	// Removed preview URL
+	_, err = m.UpdateIntercept(ctx, &rpc.UpdateInterceptRequest{
+		Session:             sess,
+		Name:                "first",
+		PreviewDomainAction: &rpc.UpdateInterceptRequest_RemovePreviewDomain{RemovePreviewDomain: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, serve(httptest.NewRequest(http.MethodGet, "http://"+previewHost(ii.PreviewDomain)+"/", nil)))
+}
@@ -17,6 +17,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	empty "google.golang.org/protobuf/types/known/emptypb"
+	authenticationv1 "k8s.io/api/authentication/v1"
 
 	"github.com/datawire/dlib/dlog"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
@@ -27,6 +28,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/a8rcloud"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/oidc"
 	"github.com/telepresenceio/telepresence/v2/pkg/tracing"
 	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
@@ -45,6 +47,9 @@ type Manager struct {
 	clusterInfo cluster.Info
 	cloudConfig *rpc.AmbassadorCloudConfig
 
+	// idVerifier verifies the ID tokens of the clients when an OpenID Connect issuer is configured.
+	idVerifier *oidc.Verifier
+
 	rpc.UnsafeManagerServer
 }
 
@@ -91,6 +96,9 @@ func NewManager(ctx context.Context) (*Manager, context.Context, error) {
 		return nil, nil, err
 	}
 	ret.cloudConfig = cloudConfig
+	if env := managerutil.GetEnv(ctx); env.OIDCIssuerURL != "" {
+		ret.idVerifier = oidc.NewVerifier(env.OIDCIssuerURL, env.OIDCClientID)
+	}
 	ctx = a8rcloud.WithSystemAPool[managerutil.SystemaCRUDClient](ctx, a8rcloud.UnauthdTrafficManagerConnName, &managerutil.UnauthdConnProvider{Config: cloudConfig})
 	ctx = a8rcloud.WithSystemAPool[managerutil.SystemaCRUDClient](ctx, a8rcloud.TrafficManagerConnName, &ReverseConnProvider{ret})
 	ret.ctx = ctx
@@ -158,7 +166,31 @@ func (m *Manager) ArriveAsClient(ctx context.Context, client *rpc.ClientInfo) (*
 		return nil, status.Errorf(codes.InvalidArgument, val)
 	}
 
-	sessionID := m.state.AddClient(client, m.clock.Now())
+	if m.idVerifier != nil {
+		if client.IdToken == "" {
+			return nil, status.Error(codes.Unauthenticated, `an OpenID Connect ID token is required, please use "telepresence login"`)
+		}
+		claims, err := m.idVerifier.Verify(ctx, client.IdToken)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		// The verified identity replaces the name that the client reported, and the token isn't
+		// retained in the client's session.
+		client.Name = claims.Identity(managerutil.GetEnv(ctx).OIDCIdentityClaim)
+		client.IdToken = ""
+	}
+
+	var user *authenticationv1.UserInfo
+	if env := managerutil.GetEnv(ctx); env.ClientTokenReview || env.ClientAccessReview {
+		var err error
+		if user, err = reviewClientToken(ctx, client.BearerToken); err != nil {
+			return nil, err
+		}
+	}
+	// The bearer token is never retained in the client's session.
+	client.BearerToken = ""
+
+	sessionID := m.state.AddClient(client, user, m.clock.Now())
 
 	installId := client.GetInstallId()
 	return &rpc.SessionInfo{
@@ -257,6 +289,9 @@ func infosEqual(a, b *rpc.AgentInfo) bool {
 	if a.Name != b.Name || a.Namespace != b.Namespace || a.Product != b.Product || a.Version != b.Version {
 		return false
 	}
+	if !proto.Equal(a.Upgrade, b.Upgrade) {
+		return false
+	}
 	for i, am := range ams {
 		bm := bms[i]
 		if am.Name != bm.Name || am.Product != bm.Product || am.Version != bm.Version {
@@ -432,6 +467,9 @@ func (m *Manager) PrepareIntercept(ctx context.Context, request *rpc.CreateInter
 	dlog.Debugf(ctx, "PrepareIntercept called")
 	span := trace.SpanFromContext(ctx)
 	tracing.RecordInterceptSpec(span, request.InterceptSpec)
+	if err := m.checkInterceptAccess(ctx, request.GetSession().GetSessionId(), request.InterceptSpec); err != nil {
+		return nil, err
+	}
 	return m.state.PrepareIntercept(ctx, request)
 }
 
@@ -451,11 +489,24 @@ func (m *Manager) CreateIntercept(ctx context.Context, ciReq *rpc.CreateIntercep
 		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
 	}
 
+	if m.idVerifier != nil {
+		// Intercepts are attributed to the verified identity of the client.
+		spec.Client = client.Name
+	}
+
 	if val := validateIntercept(spec); val != "" {
 		return nil, status.Errorf(codes.InvalidArgument, val)
 	}
 
-	interceptInfo, err := m.state.AddIntercept(sessionID, m.clusterInfo.GetClusterID(), apiKey, client, spec)
+	if spec.TlsSecret != "" && (len(ciReq.TlsCertificate.GetCertPem()) == 0 || len(ciReq.TlsCertificate.GetKeyPem()) == 0) {
+		return nil, status.Errorf(codes.InvalidArgument, "the certificate of TLS secret %s is missing", spec.TlsSecret)
+	}
+
+	if err := m.checkInterceptAccess(ctx, sessionID, spec); err != nil {
+		return nil, err
+	}
+
+	interceptInfo, err := m.state.AddIntercept(sessionID, m.clusterInfo.GetClusterID(), apiKey, client, spec, ciReq.TlsCertificate)
 	if err != nil {
 		return nil, err
 	}
@@ -529,6 +580,10 @@ func (m *Manager) UpdateIntercept(ctx context.Context, req *rpc.UpdateInterceptR
 		// Have SystemA create the preview domain.
 		// Apply that to the intercept.
 		// Oh no, something went wrong.  Clean up.
+		if managerutil.GetEnv(ctx).PreviewBaseDomain != "" {
+			// Self-hosted preview URLs are served by the traffic-manager, so SystemA isn't involved.
+			return m.addSelfHostedPreviewDomain(ctx, interceptID, action.AddPreviewDomain)
+		}
 		intercept, err := m.addInterceptDomain(ctx, interceptID, action)
 		if err != nil {
 			return nil, err
@@ -559,7 +614,7 @@ func (m *Manager) removeInterceptDomain(ctx context.Context, interceptID string)
 		domain = intercept.PreviewDomain
 		intercept.PreviewDomain = ""
 	})
-	if domain != "" {
+	if domain != "" && managerutil.GetEnv(ctx).PreviewBaseDomain == "" {
 		if sa, err := systemaPool.Get(ctx); err != nil {
 			dlog.Errorln(ctx, "systema: acquire connection:", err)
 		} else {
@@ -710,6 +765,22 @@ func (m *Manager) GetIntercept(ctx context.Context, request *rpc.GetInterceptReq
 	}
 }
 
+// GetInterceptTLSCertificate lets an agent retrieve the certificate that it uses to terminate TLS for an intercept.
+func (m *Manager) GetInterceptTLSCertificate(ctx context.Context, req *rpc.GetInterceptTLSCertificateRequest) (*rpc.TLSCertificate, error) {
+	ctx = managerutil.WithSessionInfo(ctx, req.GetSession())
+	dlog.Debugf(ctx, "GetInterceptTLSCertificate called: %s", req.InterceptId)
+	sessionID := req.GetSession().GetSessionId()
+	agent := m.state.GetAgent(sessionID)
+	if agent == nil {
+		return nil, status.Errorf(codes.NotFound, "agent session %q not found", sessionID)
+	}
+	// The certificate's private key must only reach the pods of the intercepted workload.
+	if err := reviewAgentPeer(ctx, agent); err != nil {
+		return nil, err
+	}
+	return m.state.GetInterceptTLSCertificate(sessionID, req.InterceptId)
+}
+
 // ReviewIntercept lets an agent approve or reject an intercept.
 func (m *Manager) ReviewIntercept(ctx context.Context, rIReq *rpc.ReviewInterceptRequest) (*empty.Empty, error) {
 	ctx = managerutil.WithSessionInfo(ctx, rIReq.GetSession())
@@ -871,11 +942,15 @@ func (m *Manager) WatchClusterInfo(session *rpc.SessionInfo, stream rpc.Manager_
 	return m.clusterInfo.Watch(ctx, stream)
 }
 
-const clientSessionTTL = 24 * time.Hour
+const defaultClientSessionTTL = 24 * time.Hour
 const agentSessionTTL = 15 * time.Second
 
 // expire removes stale sessions.
 func (m *Manager) expire(ctx context.Context) {
+	clientSessionTTL := defaultClientSessionTTL
+	if env := managerutil.GetEnv(ctx); env != nil && env.ClientSessionTTL > 0 {
+		clientSessionTTL = env.ClientSessionTTL
+	}
 	now := m.clock.Now()
 	m.state.ExpireSessions(ctx, now.Add(-clientSessionTTL), now.Add(-agentSessionTTL))
 }
@@ -5,16 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
 	empty "google.golang.org/protobuf/types/known/emptypb"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,6 +40,7 @@ import (
 	mockmanagerutil "github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil/mocks"
 	"github.com/telepresenceio/telepresence/v2/pkg/a8rcloud"
 	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/oidc"
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
 )
 
@@ -628,7 +637,137 @@ func TestUpdateIntercept(t *testing.T) {
 	})
 }
 
+func TestCreateIntercept_TargetInCluster(t *testing.T) {
+	dlog.SetFallbackLogger(dlog.WrapTB(t, false))
+	ctx := dlog.NewTestContext(t, false)
+	testClients := testdata.GetTestClients(t)
+	testAgents := testdata.GetTestAgents(t)
+	version.Version = "testing"
+
+	conn := getTestClientConn(ctx, t)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	sess, err := client.ArriveAsClient(ctx, testClients["alice"])
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		targetHost string
+		targetPort int32
+	}{
+		{name: "empty host", targetHost: "", targetPort: 8080},
+		{name: "zero port", targetHost: "echo.default", targetPort: 0},
+		{name: "port out of range", targetHost: "echo.default", targetPort: 0x10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{
+				Session: sess,
+				InterceptSpec: &rpc.InterceptSpec{
+					Name:            "in-cluster",
+					Namespace:       "default",
+					Client:          testClients["alice"].Name,
+					Agent:           testAgents["hello"].Name,
+					Mechanism:       "tcp",
+					TargetHost:      tt.targetHost,
+					TargetPort:      tt.targetPort,
+					TargetInCluster: true,
+				},
+			})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestArriveAsClient_OIDC(t *testing.T) {
+	dlog.SetFallbackLogger(dlog.WrapTB(t, false))
+	ctx := dlog.NewTestContext(t, false)
+	testClients := testdata.GetTestClients(t)
+	testAgents := testdata.GetTestAgents(t)
+	version.Version = "testing"
+
+	// A provider that signs its ID tokens with a symmetric key.
+	signingKey := jose.JSONWebKey{Key: []byte("0123456789abcdef0123456789abcdef"), KeyID: "key-1", Algorithm: string(jose.HS256)}
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidc.DiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&oidc.Provider{
+			Issuer:                issuer,
+			AuthorizationEndpoint: issuer + "/authorize",
+			TokenEndpoint:         issuer + "/token",
+			JWKSURI:               issuer + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{signingKey}})
+	})
+	provider := httptest.NewServer(mux)
+	defer provider.Close()
+	issuer = provider.URL
+
+	idToken := func(aud string) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: signingKey}, nil)
+		require.NoError(t, err)
+		tok, err := jwt.Signed(signer).Claims(map[string]any{
+			"iss":   issuer,
+			"sub":   "1234",
+			"aud":   aud,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"email": "alice@example.com",
+		}).CompactSerialize()
+		require.NoError(t, err)
+		return tok
+	}
+
+	conn := getTestClientConnWithEnv(ctx, t, &managerutil.Env{
+		PodCIDRStrategy:   "environment",
+		PodCIDRs:          "192.168.0.0/16",
+		OIDCIssuerURL:     issuer,
+		OIDCClientID:      "telepresence",
+		OIDCIdentityClaim: "email",
+	})
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	arrive := func(idToken string) (*rpc.SessionInfo, error) {
+		ci := proto.Clone(testClients["alice"]).(*rpc.ClientInfo)
+		ci.IdToken = idToken
+		return client.ArriveAsClient(ctx, ci)
+	}
+
+	_, err := arrive("")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = arrive(idToken("other"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	sess, err := arrive(idToken("telepresence"))
+	require.NoError(t, err)
+	ii, err := client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{
+		Session: sess,
+		InterceptSpec: &rpc.InterceptSpec{
+			Name:       "first",
+			Namespace:  "default",
+			Client:     testClients["alice"].Name,
+			Agent:      testAgents["hello"].Name,
+			Mechanism:  "tcp",
+			TargetHost: "asdf",
+			TargetPort: 9876,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", ii.Spec.Client, "intercept is attributed to the verified identity")
+}
+
 func getTestClientConn(ctx context.Context, t *testing.T) *grpc.ClientConn {
+	return getTestClientConnWithEnv(ctx, t, &managerutil.Env{
+		MaxReceiveSize:  resource.Quantity{},
+		PodCIDRStrategy: "environment",
+		PodCIDRs:        "192.168.0.0/16",
+	})
+}
+
+func getTestClientConnWithEnv(ctx context.Context, t *testing.T, env *managerutil.Env) *grpc.ClientConn {
 	const bufsize = 64 * 1024
 	var cancel func()
 	ctx, cancel = context.WithCancel(ctx)
@@ -647,11 +786,7 @@ func getTestClientConn(ctx context.Context, t *testing.T) *grpc.ClientConn {
 		GitVersion: "v1.17.0",
 	}
 	ctx = k8sapi.WithK8sInterface(ctx, fakeClient)
-	ctx = managerutil.WithEnv(ctx, &managerutil.Env{
-		MaxReceiveSize:  resource.Quantity{},
-		PodCIDRStrategy: "environment",
-		PodCIDRs:        "192.168.0.0/16",
-	})
+	ctx = managerutil.WithEnv(ctx, env)
 
 	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
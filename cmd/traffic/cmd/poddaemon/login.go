@@ -43,3 +43,9 @@ func (loginExecutor) GetLicense(ctx context.Context, id string) (string, string,
 func (loginExecutor) GetUserInfo(ctx context.Context, refresh bool) (*authdata.UserInfo, error) {
 	panic("not implemented")
 }
+
+// GetIDToken returns an empty string, because the pod-daemon doesn't log in using an OpenID Connect
+// provider.
+func (loginExecutor) GetIDToken(ctx context.Context) (string, error) {
+	return "", nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agent"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agentinit"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/cni"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/poddaemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
@@ -17,10 +18,12 @@ import (
 
 func main() {
 	cmds := map[string]func(ctx context.Context, args ...string) error{
-		"agent":      agent.Main,
-		"agent-init": agentinit.Main,
-		"manager":    manager.Main,
-		"pod-daemon": poddaemon.Main,
+		"agent":       agent.Main,
+		"agent-init":  agentinit.Main,
+		"cni":         cni.Main,
+		"cni-install": cni.InstallMain,
+		"manager":     manager.Main,
+		"pod-daemon":  poddaemon.Main,
 	}
 
 	var name string
@@ -1,6 +1,7 @@
 package agentconfig
 
 import (
+	"sort"
 	"strconv"
 	"strings"
 
@@ -32,6 +33,16 @@ func AgentContainer(
 		evs = appendAppContainerEnv(app, cc, evs)
 		efs = appendAppContainerEnvFrom(app, cc, efs)
 	})
+	if len(config.Env) > 0 {
+		keys := make([]string, 0, len(config.Env))
+		for k := range config.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			evs = append(evs, core.EnvVar{Name: k, Value: config.Env[k]})
+		}
+	}
 	if config.APIPort > 0 {
 		evs = append(evs, core.EnvVar{
 			Name:  EnvAPIPort,
@@ -85,14 +96,15 @@ func AgentContainer(
 	if len(efs) == 0 {
 		efs = nil
 	}
-	return &core.Container{
-		Name:         ContainerName,
-		Image:        config.AgentImage,
-		Args:         []string{"agent"},
-		Ports:        ports,
-		Env:          evs,
-		EnvFrom:      efs,
-		VolumeMounts: mounts,
+	ac := &core.Container{
+		Name:            ContainerName,
+		Image:           config.AgentImage,
+		ImagePullPolicy: core.PullPolicy(config.PullPolicy),
+		Args:            []string{"agent"},
+		Ports:           ports,
+		Env:             evs,
+		EnvFrom:         efs,
+		VolumeMounts:    mounts,
 		ReadinessProbe: &core.Probe{
 			ProbeHandler: core.ProbeHandler{
 				Exec: &core.ExecAction{
@@ -101,13 +113,23 @@ func AgentContainer(
 			},
 		},
 	}
+	if !config.Resources.IsEmpty() {
+		ac.Resources = core.ResourceRequirements(*config.Resources)
+	}
+	if !config.SecurityContext.IsEmpty() {
+		ac.SecurityContext = (*core.SecurityContext)(config.SecurityContext)
+	}
+	return ac
 }
 
-func InitContainer(qualifiedAgentImage string) *core.Container {
-	return &core.Container{
-		Name:  InitContainerName,
-		Image: qualifiedAgentImage,
-		Args:  []string{"agent-init"},
+// InitContainer will return a configured init container. It uses the image, pull policy, and resources
+// of the traffic-agent, but not its security context, because the init container needs NET_ADMIN.
+func InitContainer(config *Sidecar) *core.Container {
+	ic := &core.Container{
+		Name:            InitContainerName,
+		Image:           config.AgentImage,
+		ImagePullPolicy: core.PullPolicy(config.PullPolicy),
+		Args:            []string{"agent-init"},
 		VolumeMounts: []core.VolumeMount{{
 			Name:      ConfigVolumeName,
 			MountPath: ConfigMountPoint,
@@ -118,6 +140,10 @@ func InitContainer(qualifiedAgentImage string) *core.Container {
 			},
 		},
 	}
+	if !config.Resources.IsEmpty() {
+		ic.Resources = core.ResourceRequirements(*config.Resources)
+	}
+	return ic
 }
 
 func AgentVolumes(agentName string) []core.Volume {
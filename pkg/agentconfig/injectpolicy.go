@@ -1,6 +1,7 @@
 package agentconfig
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"gopkg.in/yaml.v3"
@@ -10,7 +11,7 @@ import (
 // a pod.
 type InjectPolicy int
 
-var epNames = [...]string{"OnDemand", "WhenEnabled"}
+var epNames = [...]string{"OnDemand", "WhenEnabled", "Never", "Always"}
 
 const (
 	// OnDemand tells the injector to inject the traffic-agent the first time someone makes an attempt
@@ -28,6 +29,16 @@ const (
 	// created or updated when the telepresence.getambassador.io/inject-traffic-agent annotation is
 	// present and set to "enabled".
 	WhenEnabled
+
+	// Never tells the injector to never inject the traffic-agent, regardless of the
+	// telepresence.getambassador.io/inject-traffic-agent annotation. Workloads that have no
+	// traffic-agent cannot be intercepted. This policy is intended for InjectRules.
+	Never
+
+	// Always tells the injector to inject the traffic-agent in advance into all pods that are created
+	// or updated, unless the telepresence.getambassador.io/inject-traffic-agent annotation is set to
+	// "disabled". This policy is intended for InjectRules.
+	Always
 )
 
 func (aps InjectPolicy) String() string {
@@ -58,6 +69,18 @@ func (aps *InjectPolicy) EnvDecode(val string) (err error) {
 	return nil
 }
 
+func (aps InjectPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(aps.String())
+}
+
+func (aps *InjectPolicy) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return aps.EnvDecode(s)
+}
+
 func (aps *InjectPolicy) UnmarshalYAML(node *yaml.Node) (err error) {
 	var s string
 	if err := node.Decode(&s); err != nil {
@@ -0,0 +1,78 @@
+package agentconfig
+
+import (
+	"fmt"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/strings/slices"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// InjectRule decides the InjectPolicy of the workloads that it matches. A rule matches a workload
+// when all of its declared selectors match. A rule that declares no selectors matches all workloads.
+type InjectRule struct {
+	// NamespaceSelector selects the workloads by the labels of their namespace.
+	NamespaceSelector *meta.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// WorkloadSelector selects the workloads by their labels.
+	WorkloadSelector *meta.LabelSelector `json:"workloadSelector,omitempty"`
+
+	// WorkloadKinds selects the workloads by their kind, e.g. "Deployment" or "StatefulSet".
+	WorkloadKinds []string `json:"workloadKinds,omitempty"`
+
+	// Policy is the policy of the matched workloads. Defaults to OnDemand.
+	Policy InjectPolicy `json:"policy"`
+}
+
+// InjectRules is a list of InjectRule. The first rule that matches a workload decides its InjectPolicy.
+type InjectRules []*InjectRule
+
+// EnvDecode parses the given YAML or JSON list into the InjectRules. An empty string is ignored.
+func (rs *InjectRules) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	var ds InjectRules
+	if err := k8syaml.UnmarshalStrict([]byte(val), &ds); err != nil {
+		return err
+	}
+	for i, r := range ds {
+		if _, err := meta.LabelSelectorAsSelector(r.NamespaceSelector); err != nil {
+			return fmt.Errorf("inject rule %d has an invalid namespaceSelector: %w", i, err)
+		}
+		if _, err := meta.LabelSelectorAsSelector(r.WorkloadSelector); err != nil {
+			return fmt.Errorf("inject rule %d has an invalid workloadSelector: %w", i, err)
+		}
+	}
+	*rs = ds
+	return nil
+}
+
+func selectorMatches(ls *meta.LabelSelector, lbs map[string]string) bool {
+	if ls == nil {
+		return true
+	}
+	sel, err := meta.LabelSelectorAsSelector(ls)
+	return err == nil && sel.Matches(labels.Set(lbs))
+}
+
+// Matches returns true if the rule matches a workload of the given kind and labels, in a namespace
+// with the given labels.
+func (r *InjectRule) Matches(nsLabels map[string]string, kind string, wlLabels map[string]string) bool {
+	if len(r.WorkloadKinds) > 0 && !slices.Contains(r.WorkloadKinds, kind) {
+		return false
+	}
+	return selectorMatches(r.NamespaceSelector, nsLabels) && selectorMatches(r.WorkloadSelector, wlLabels)
+}
+
+// PolicyFor returns the policy of the first rule that matches a workload of the given kind and labels,
+// in a namespace with the given labels. The given default policy is returned when no rule matches.
+func (rs InjectRules) PolicyFor(nsLabels map[string]string, kind string, wlLabels map[string]string, dflt InjectPolicy) InjectPolicy {
+	for _, r := range rs {
+		if r.Matches(nsLabels, kind, wlLabels) {
+			return r.Policy
+		}
+	}
+	return dflt
+}
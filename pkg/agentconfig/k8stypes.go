@@ -0,0 +1,97 @@
+package agentconfig
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+	core "k8s.io/api/core/v1"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// ResourceRequirements are the compute resources of the traffic-agent container.
+//
+// The Kubernetes types declare their field names in JSON tags, and quantities are only marshalled
+// correctly to JSON, so this type and SecurityContext are marshalled to YAML using their JSON form.
+type ResourceRequirements core.ResourceRequirements
+
+// SecurityContext is the security context of the traffic-agent container.
+type SecurityContext core.SecurityContext
+
+// EnvVars are additional environment variables of the traffic-agent container.
+type EnvVars map[string]string
+
+func (r *ResourceRequirements) MarshalYAML() (any, error) {
+	return jsonForm((*core.ResourceRequirements)(r))
+}
+
+func (r *ResourceRequirements) UnmarshalYAML(node *yaml.Node) error {
+	return decodeJSONForm(node, (*core.ResourceRequirements)(r))
+}
+
+// EnvDecode parses the given YAML or JSON into the ResourceRequirements. An empty string is ignored.
+func (r *ResourceRequirements) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	return k8syaml.UnmarshalStrict([]byte(val), (*core.ResourceRequirements)(r))
+}
+
+// IsEmpty returns true if no requests or limits are declared.
+func (r *ResourceRequirements) IsEmpty() bool {
+	return r == nil || len(r.Requests) == 0 && len(r.Limits) == 0
+}
+
+func (s *SecurityContext) MarshalYAML() (any, error) {
+	return jsonForm((*core.SecurityContext)(s))
+}
+
+func (s *SecurityContext) UnmarshalYAML(node *yaml.Node) error {
+	return decodeJSONForm(node, (*core.SecurityContext)(s))
+}
+
+// EnvDecode parses the given YAML or JSON into the SecurityContext. An empty string is ignored.
+func (s *SecurityContext) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	return k8syaml.UnmarshalStrict([]byte(val), (*core.SecurityContext)(s))
+}
+
+// IsEmpty returns true if nothing is declared in the SecurityContext.
+func (s *SecurityContext) IsEmpty() bool {
+	return s == nil || *s == SecurityContext{}
+}
+
+// EnvDecode parses the given YAML or JSON object into the EnvVars. An empty string is ignored.
+func (e *EnvVars) EnvDecode(val string) error {
+	if val == "" {
+		return nil
+	}
+	return k8syaml.UnmarshalStrict([]byte(val), (*map[string]string)(e))
+}
+
+// jsonForm returns the given value in the form that it has after a JSON round trip.
+func jsonForm(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var jv any
+	if err = json.Unmarshal(data, &jv); err != nil {
+		return nil, err
+	}
+	return jv, nil
+}
+
+// decodeJSONForm decodes the given node into the given value, using the JSON form of the node.
+func decodeJSONForm(node *yaml.Node, v any) error {
+	var jv any
+	if err := node.Decode(&jv); err != nil {
+		return err
+	}
+	data, err := json.Marshal(jv)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -133,6 +133,23 @@ type Sidecar struct {
 	// The port used by the agent's GRPC tracing server
 	TracingPort uint16 `json:"tracingPort,omitempty" yaml:"tracingPort,omitempty"`
 
+	// The firewall backend that the init container uses when it configures the redirect rules. One of
+	// "iptables" or "nftables". An empty string or "auto" means that the backend is chosen by probing
+	// the kernel of the node.
+	FirewallBackend string `json:"firewallBackend,omitempty" yaml:"firewallBackend,omitempty"`
+
+	// The pull policy of the traffic-agent image
+	PullPolicy string `json:"pullPolicy,omitempty" yaml:"pullPolicy,omitempty"`
+
+	// The compute resources of the traffic-agent container
+	Resources *ResourceRequirements `json:"resources,omitempty" yaml:"resources,omitempty"`
+
+	// The security context of the traffic-agent container
+	SecurityContext *SecurityContext `json:"securityContext,omitempty" yaml:"securityContext,omitempty"`
+
+	// Additional environment variables of the traffic-agent container
+	Env EnvVars `json:"env,omitempty" yaml:"env,omitempty"`
+
 	// The intercepts managed by the agent
 	Containers []*Container `json:"containers,omitempty" yaml:"containers,omitempty"`
 }
@@ -0,0 +1,50 @@
+package agentconfig
+
+import (
+	"fmt"
+)
+
+// UpgradePolicy specifies when the traffic-manager will upgrade traffic-agents that run an image that
+// differs from the one that the traffic-manager injects.
+type UpgradePolicy int
+
+var upNames = [...]string{"Never", "WhenIdle", "Immediately"}
+
+const (
+	// NeverUpgrade tells the traffic-manager to leave the agents alone. They will be upgraded when their
+	// pods are restarted for other reasons.
+	//
+	// This is the default setting.
+	NeverUpgrade UpgradePolicy = iota
+
+	// UpgradeWhenIdle tells the traffic-manager to upgrade agents that aren't currently intercepted.
+	UpgradeWhenIdle
+
+	// UpgradeImmediately tells the traffic-manager to upgrade agents regardless of if they are intercepted
+	// or not. Active intercepts will be interrupted by the rollout.
+	UpgradeImmediately
+)
+
+func (up UpgradePolicy) String() string {
+	return upNames[up]
+}
+
+func NewUpgradePolicy(s string) (UpgradePolicy, error) {
+	for i, n := range upNames {
+		if s == n {
+			return UpgradePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid UpgradePolicy: %q", s)
+}
+
+func (up *UpgradePolicy) EnvDecode(val string) (err error) {
+	var us UpgradePolicy
+	if val == "" {
+		us = NeverUpgrade
+	} else if us, err = NewUpgradePolicy(val); err != nil {
+		return err
+	}
+	*up = us
+	return nil
+}
@@ -42,3 +42,17 @@ func PortUniqueIntercepts(cn *Container) []*Intercept {
 	}
 	return ics
 }
+
+// NeedInitContainer returns true when the given config has intercepts that require that traffic to the
+// intercepted container port is redirected to the agent port, i.e. intercepts of numeric target ports
+// and of headless services.
+func NeedInitContainer(config *Sidecar) bool {
+	for _, cc := range config.Containers {
+		for _, ic := range cc.Intercepts {
+			if ic.Headless || ic.TargetPortNumeric {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -29,6 +29,14 @@ type GeneratorConfig struct {
 	QualifiedAgentImage string
 	ManagerNamespace    string
 	LogLevel            string
+	FirewallBackend     string
+
+	// Defaults for the traffic-agent container. Each can be overridden by an annotation on the workload's
+	// pod template.
+	PullPolicy      string
+	Resources       *agentconfig.ResourceRequirements
+	SecurityContext *agentconfig.SecurityContext
+	Env             agentconfig.EnvVars
 }
 
 func GenerateForPod(ctx context.Context, pod *core.Pod, env *GeneratorConfig) (*agentconfig.Sidecar, error) {
@@ -89,17 +97,21 @@ func Generate(ctx context.Context, wl k8sapi.Workload, cfg *GeneratorConfig) (sc
 	}
 
 	ag := &agentconfig.Sidecar{
-		AgentImage:   cfg.QualifiedAgentImage,
-		AgentName:    wl.GetName(),
-		LogLevel:     cfg.LogLevel,
-		Namespace:    wl.GetNamespace(),
-		WorkloadName: wl.GetName(),
-		WorkloadKind: wl.GetKind(),
-		ManagerHost:  ManagerAppName + "." + cfg.ManagerNamespace,
-		ManagerPort:  ManagerPortHTTP,
-		APIPort:      cfg.APIPort,
-		TracingPort:  cfg.TracingPort,
-		Containers:   ccs,
+		AgentImage:      cfg.QualifiedAgentImage,
+		AgentName:       wl.GetName(),
+		LogLevel:        cfg.LogLevel,
+		Namespace:       wl.GetNamespace(),
+		WorkloadName:    wl.GetName(),
+		WorkloadKind:    wl.GetKind(),
+		ManagerHost:     ManagerAppName + "." + cfg.ManagerNamespace,
+		ManagerPort:     ManagerPortHTTP,
+		APIPort:         cfg.APIPort,
+		TracingPort:     cfg.TracingPort,
+		Containers:      ccs,
+		FirewallBackend: cfg.FirewallBackend,
+	}
+	if err = applyAgentSettings(pod, cfg, ag); err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", wl.GetName(), wl.GetNamespace(), err)
 	}
 	ag.RecordInSpan(span)
 	return ag, nil
@@ -0,0 +1,93 @@
+package agentmap
+
+import (
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+const (
+	PullPolicyAnnotation      = agentconfig.DomainPrefix + "agent-image-pull-policy"
+	ResourcesAnnotation       = agentconfig.DomainPrefix + "agent-resources"
+	SecurityContextAnnotation = agentconfig.DomainPrefix + "agent-security-context"
+	EnvAnnotation             = agentconfig.DomainPrefix + "agent-env"
+)
+
+// applyAgentSettings assigns the pull policy, resources, security context, and additional environment of the
+// traffic-agent container to the given Sidecar. The settings of the GeneratorConfig are used unless they are
+// overridden by annotations on the given pod template. An error is returned if a setting is invalid.
+func applyAgentSettings(pod *core.PodTemplateSpec, cfg *GeneratorConfig, ag *agentconfig.Sidecar) error {
+	ag.PullPolicy = cfg.PullPolicy
+	ag.Resources = cfg.Resources
+	ag.SecurityContext = cfg.SecurityContext
+	ag.Env = cfg.Env
+
+	as := pod.Annotations
+	if v, ok := as[PullPolicyAnnotation]; ok {
+		ag.PullPolicy = v
+	}
+	if v, ok := as[ResourcesAnnotation]; ok {
+		rr := &agentconfig.ResourceRequirements{}
+		if err := rr.EnvDecode(v); err != nil {
+			return fmt.Errorf("unable to parse annotation %s: %w", ResourcesAnnotation, err)
+		}
+		ag.Resources = rr
+	}
+	if v, ok := as[SecurityContextAnnotation]; ok {
+		sc := &agentconfig.SecurityContext{}
+		if err := sc.EnvDecode(v); err != nil {
+			return fmt.Errorf("unable to parse annotation %s: %w", SecurityContextAnnotation, err)
+		}
+		ag.SecurityContext = sc
+	}
+	if v, ok := as[EnvAnnotation]; ok {
+		env := agentconfig.EnvVars{}
+		if err := env.EnvDecode(v); err != nil {
+			return fmt.Errorf("unable to parse annotation %s: %w", EnvAnnotation, err)
+		}
+		// The annotation adds to, or overrides, the globally configured environment.
+		merged := make(agentconfig.EnvVars, len(ag.Env)+len(env))
+		for k, v := range ag.Env {
+			merged[k] = v
+		}
+		for k, v := range env {
+			merged[k] = v
+		}
+		ag.Env = merged
+	}
+	return validateAgentSettings(ag)
+}
+
+func validateAgentSettings(ag *agentconfig.Sidecar) error {
+	switch core.PullPolicy(ag.PullPolicy) {
+	case "", core.PullAlways, core.PullIfNotPresent, core.PullNever:
+	default:
+		return fmt.Errorf("invalid traffic-agent image pull policy %q, must be %q, %q, or %q",
+			ag.PullPolicy, core.PullAlways, core.PullIfNotPresent, core.PullNever)
+	}
+	if rr := ag.Resources; rr != nil {
+		for name, limit := range rr.Limits {
+			if request, ok := rr.Requests[name]; ok && request.Cmp(limit) > 0 {
+				return fmt.Errorf("traffic-agent %s request %s is greater than its limit %s", name, request.String(), limit.String())
+			}
+		}
+	}
+	if sc := ag.SecurityContext; sc != nil {
+		if sc.Privileged != nil && *sc.Privileged && sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+			return fmt.Errorf("traffic-agent security context cannot be privileged when privilege escalation is disallowed")
+		}
+	}
+	for k := range ag.Env {
+		if errs := validation.IsEnvVarName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid traffic-agent environment variable name %q: %s", k, strings.Join(errs, ", "))
+		}
+		if strings.HasPrefix(k, agentconfig.EnvPrefix) {
+			return fmt.Errorf("invalid traffic-agent environment variable name %q: the prefix %s is reserved", k, agentconfig.EnvPrefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package agentmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+func TestApplyAgentSettings(t *testing.T) {
+	cfg := &GeneratorConfig{
+		PullPolicy: "IfNotPresent",
+		Resources: &agentconfig.ResourceRequirements{
+			Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+		},
+		Env: agentconfig.EnvVars{"TZ": "UTC", "LANG": "C"},
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		check       func(t *testing.T, ag *agentconfig.Sidecar)
+		wantErr     string
+	}{
+		{
+			"defaults",
+			nil,
+			func(t *testing.T, ag *agentconfig.Sidecar) {
+				assert.Equal(t, "IfNotPresent", ag.PullPolicy)
+				assert.Equal(t, cfg.Resources, ag.Resources)
+				assert.Nil(t, ag.SecurityContext)
+				assert.Equal(t, cfg.Env, ag.Env)
+			},
+			"",
+		},
+		{
+			"annotations override",
+			map[string]string{
+				PullPolicyAnnotation:      "Always",
+				ResourcesAnnotation:       `{"limits":{"memory":"128Mi"}}`,
+				SecurityContextAnnotation: "runAsNonRoot: true",
+				EnvAnnotation:             `{"TZ":"CET","DEBUG":"1"}`,
+			},
+			func(t *testing.T, ag *agentconfig.Sidecar) {
+				assert.Equal(t, "Always", ag.PullPolicy)
+				assert.Equal(t, core.ResourceList{core.ResourceMemory: resource.MustParse("128Mi")}, ag.Resources.Limits)
+				assert.Empty(t, ag.Resources.Requests)
+				require.NotNil(t, ag.SecurityContext.RunAsNonRoot)
+				assert.True(t, *ag.SecurityContext.RunAsNonRoot)
+				assert.Equal(t, agentconfig.EnvVars{"TZ": "CET", "LANG": "C", "DEBUG": "1"}, ag.Env)
+				assert.Equal(t, agentconfig.EnvVars{"TZ": "UTC", "LANG": "C"}, cfg.Env, "global env must not be modified")
+			},
+			"",
+		},
+		{
+			"invalid pull policy",
+			map[string]string{PullPolicyAnnotation: "Sometimes"},
+			nil,
+			`invalid traffic-agent image pull policy "Sometimes"`,
+		},
+		{
+			"unparsable resources",
+			map[string]string{ResourcesAnnotation: `{"limits":{"cpu":"lots"}}`},
+			nil,
+			"unable to parse annotation " + ResourcesAnnotation,
+		},
+		{
+			"request greater than limit",
+			map[string]string{ResourcesAnnotation: `{"limits":{"cpu":"50m"},"requests":{"cpu":"100m"}}`},
+			nil,
+			"traffic-agent cpu request 100m is greater than its limit 50m",
+		},
+		{
+			"privileged without escalation",
+			map[string]string{SecurityContextAnnotation: `{"privileged":true,"allowPrivilegeEscalation":false}`},
+			nil,
+			"cannot be privileged",
+		},
+		{
+			"invalid env name",
+			map[string]string{EnvAnnotation: `{"1X":"y"}`},
+			nil,
+			`invalid traffic-agent environment variable name "1X"`,
+		},
+		{
+			"reserved env name",
+			map[string]string{EnvAnnotation: `{"_TEL_AGENT_PORT":"1"}`},
+			nil,
+			"is reserved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &core.PodTemplateSpec{ObjectMeta: meta.ObjectMeta{Annotations: tt.annotations}}
+			ag := &agentconfig.Sidecar{}
+			err := applyAgentSettings(pod, cfg, ag)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, ag)
+		})
+	}
+}
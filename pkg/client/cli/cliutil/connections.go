@@ -0,0 +1,98 @@
+package cliutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/subnet"
+)
+
+// SubnetOverlap is a subnet routed by one connection that overlaps with a subnet routed by another
+// connection. Traffic to the addresses that the subnets have in common will be routed to only one
+// of the clusters.
+type SubnetOverlap struct {
+	Connection      string
+	Subnet          *net.IPNet
+	OtherConnection string
+	OtherSubnet     *net.IPNet
+}
+
+func (o *SubnetOverlap) String() string {
+	return fmt.Sprintf("subnet %s of connection %q overlaps with subnet %s of connection %q",
+		o.Subnet, o.Connection, o.OtherSubnet, o.OtherConnection)
+}
+
+// RoutedSubnets returns the subnets that the root daemon of the connection used by the given context
+// currently routes to its TUN device.
+func RoutedSubnets(ctx context.Context) ([]*net.IPNet, error) {
+	conn, err := client.DialSocket(ctx, client.DaemonSocket(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ds, err := daemon.NewDaemonClient(conn).Status(ctx, &empty.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	subnets := make([]*net.IPNet, len(ds.Subnets))
+	for i, sn := range ds.Subnets {
+		subnets[i] = iputil.IPNetFromRPC(sn)
+	}
+	return subnets, nil
+}
+
+// ConnectionSubnetOverlaps returns the overlaps between the subnets routed by the connection used by the
+// given context and the subnets routed by all other running connections.
+func ConnectionSubnetOverlaps(ctx context.Context) ([]*SubnetOverlap, error) {
+	names, err := client.ConnectionNames()
+	if err != nil || len(names) < 2 {
+		return nil, err
+	}
+	subnets := make(map[string][]*net.IPNet, len(names))
+	for _, name := range names {
+		sns, err := RoutedSubnets(client.WithConnectionName(ctx, name))
+		if err != nil {
+			dlog.Debugf(ctx, "unable to get the subnets of connection %q: %v", name, err)
+			continue
+		}
+		subnets[name] = sns
+	}
+	return subnetOverlaps(client.GetConnectionName(ctx), subnets), nil
+}
+
+// subnetOverlaps returns the overlaps between the subnets of the connection with the given name and the
+// subnets of all other connections in the given map.
+func subnetOverlaps(name string, subnets map[string][]*net.IPNet) []*SubnetOverlap {
+	others := make([]string, 0, len(subnets))
+	for other := range subnets {
+		if other != name {
+			others = append(others, other)
+		}
+	}
+	sort.Strings(others)
+
+	var overlaps []*SubnetOverlap
+	for _, sn := range subnets[name] {
+		for _, other := range others {
+			for _, osn := range subnets[other] {
+				if subnet.Overlaps(sn, osn) {
+					overlaps = append(overlaps, &SubnetOverlap{
+						Connection:      name,
+						Subnet:          sn,
+						OtherConnection: other,
+						OtherSubnet:     osn,
+					})
+				}
+			}
+		}
+	}
+	return overlaps
+}
@@ -0,0 +1,39 @@
+package cliutil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	subnets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, sn, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		subnets[i] = sn
+	}
+	return subnets
+}
+
+func TestSubnetOverlaps(t *testing.T) {
+	subnets := map[string][]*net.IPNet{
+		"default": parseCIDRs(t, "10.96.0.0/12", "10.244.0.0/16"),
+		"apps":    parseCIDRs(t, "10.100.0.0/16", "172.20.0.0/16"),
+		"infra":   parseCIDRs(t, "10.244.1.0/24", "192.168.0.0/16"),
+	}
+
+	overlaps := subnetOverlaps("default", subnets)
+	require.Len(t, overlaps, 2)
+	assert.Equal(t, "apps", overlaps[0].OtherConnection)
+	assert.Equal(t, "10.96.0.0/12", overlaps[0].Subnet.String())
+	assert.Equal(t, "10.100.0.0/16", overlaps[0].OtherSubnet.String())
+	assert.Equal(t, "infra", overlaps[1].OtherConnection)
+	assert.Equal(t, `subnet 10.244.0.0/16 of connection "default" overlaps with subnet 10.244.1.0/24 of connection "infra"`,
+		overlaps[1].String())
+
+	assert.Empty(t, subnetOverlaps("apps", map[string][]*net.IPNet{"apps": subnets["apps"], "infra": subnets["infra"]}))
+	assert.Empty(t, subnetOverlaps("apps", map[string][]*net.IPNet{"apps": subnets["apps"]}))
+}
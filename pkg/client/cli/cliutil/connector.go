@@ -22,6 +22,7 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
@@ -46,6 +47,12 @@ func WithStartedConnector(ctx context.Context, withNotify bool, fn func(context.
 	return withConnector(ctx, false, withNotify, fn)
 }
 
+// WithQuietConnector is like WithConnector, but doesn't stream the messages that the connector wants
+// us to display to the user. It's intended for commands that produce output meant to be parsed.
+func WithQuietConnector(ctx context.Context, fn func(context.Context, connector.ConnectorClient) error) error {
+	return withConnector(ctx, true, false, fn)
+}
+
 type connectorConnPtrKey struct{}
 
 func getConnectorConn(ctx context.Context) *grpc.ClientConn {
@@ -68,8 +75,16 @@ func withConnectorConn(ctx context.Context, conn *grpc.ClientConn) context.Conte
 }
 
 func launchConnectorDaemon(ctx context.Context, connectorDaemon string, maybeStart bool) (conn *grpc.ClientConn, err error) {
+	info, err := docker.LoadInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		// The user daemon runs in the daemon container.
+		return docker.Dial(ctx, info.ConnectorAddress, info.Token)
+	}
 	for {
-		conn, err = client.DialSocket(ctx, client.ConnectorSocketName)
+		conn, err = client.DialSocket(ctx, client.ConnectorSocket(ctx))
 		if err == nil {
 			return conn, nil
 		}
@@ -81,10 +96,14 @@ func launchConnectorDaemon(ctx context.Context, connectorDaemon string, maybeSta
 				if _, err = ensureAppUserConfigDir(ctx); err != nil {
 					return nil, err
 				}
-				if err = proc.StartInBackground(connectorDaemon, "connector-foreground"); err != nil {
+				args := []string{connectorDaemon, "connector-foreground"}
+				if name := client.GetConnectionName(ctx); name != client.DefaultConnectionName {
+					args = append(args, "--name", name)
+				}
+				if err = proc.StartInBackground(args...); err != nil {
 					return nil, fmt.Errorf("failed to launch the connector service: %w", err)
 				}
-				if err = client.WaitUntilSocketAppears("connector", client.ConnectorSocketName, 10*time.Second); err != nil {
+				if err = client.WaitUntilSocketAppears("connector", client.ConnectorSocket(ctx), 10*time.Second); err != nil {
 					return nil, fmt.Errorf("connector service did not start: %w", err)
 				}
 				maybeStart = false
@@ -191,7 +210,7 @@ func UserDaemonDisconnect(ctx context.Context, quitUserDaemon bool) error {
 			// Disconnect is not implemented so daemon predates 2.4.9. Force a quit
 		}
 		if _, err = connectorClient.Quit(ctx, &empty.Empty{}); err == nil || grpcStatus.Code(err) == grpcCodes.Unavailable {
-			err = client.WaitUntilSocketVanishes("user daemon", client.ConnectorSocketName, 5*time.Second)
+			err = client.WaitUntilSocketVanishes("user daemon", client.ConnectorSocket(ctx), 5*time.Second)
 		}
 		return err
 	})
@@ -16,6 +16,7 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
@@ -33,7 +34,7 @@ func launchDaemon(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	logFile := filepath.Join(logDir, "daemon.log")
+	logFile := filepath.Join(logDir, client.ConnectionProcessName(ctx, "daemon")+".log")
 	if _, err := os.Stat(logFile); err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -52,7 +53,11 @@ func launchDaemon(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return proc.StartInBackgroundAsRoot(ctx, client.GetExe(), "daemon-foreground", logDir, configDir)
+	args := []string{client.GetExe(), "daemon-foreground", logDir, configDir}
+	if name := client.GetConnectionName(ctx); name != client.DefaultConnectionName {
+		args = append(args, "--name", name)
+	}
+	return proc.StartInBackgroundAsRoot(ctx, args...)
 }
 
 // WithNetwork (1) ensures that the daemon is running, (2) establishes a connection to it, and (3)
@@ -77,11 +82,30 @@ func withNetwork(ctx context.Context, maybeStart bool, fn func(context.Context,
 		return fn(ctx, daemonClient)
 	}
 
+	info, err := docker.LoadInfo(ctx)
+	if err != nil {
+		return err
+	}
 	var conn *grpc.ClientConn
 	started := false
-	for {
-		var err error
-		conn, err = client.DialSocket(ctx, client.DaemonSocketName)
+	if info == nil && maybeStart && docker.DaemonContainerRequested(ctx) {
+		if exists, _ := client.SocketExists(client.DaemonSocket(ctx)); exists {
+			return errcat.User.Newf("connection %q is already managed by daemons that run on this host", client.GetConnectionName(ctx))
+		}
+		stdout, _ := output.Structured(ctx)
+		fmt.Fprintln(stdout, "Launching Telepresence Daemon Container")
+		if info, err = docker.Start(ctx); err != nil {
+			return err
+		}
+		started = true
+	}
+	if info != nil {
+		if conn, err = docker.Dial(ctx, info.DaemonAddress, info.Token); err != nil {
+			return err
+		}
+	}
+	for conn == nil {
+		conn, err = client.DialSocket(ctx, client.DaemonSocket(ctx))
 		if err == nil {
 			break
 		}
@@ -92,7 +116,7 @@ func withNetwork(ctx context.Context, maybeStart bool, fn func(context.Context,
 					return fmt.Errorf("failed to launch the daemon service: %w", err)
 				}
 
-				if err = client.WaitUntilSocketAppears("daemon", client.DaemonSocketName, 10*time.Second); err != nil {
+				if err = client.WaitUntilSocketAppears("daemon", client.DaemonSocket(ctx), 10*time.Second); err != nil {
 					return fmt.Errorf("daemon service did not start: %w", err)
 				}
 
@@ -123,6 +147,10 @@ type quitting struct{}
 func Disconnect(ctx context.Context, quitUserDaemon, quitRootDaemon bool) (err error) {
 	stdout, stderr := output.Structured(ctx)
 	ctx = context.WithValue(ctx, quitting{}, true)
+	info, err := docker.LoadInfo(ctx)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		// Ensure the connector is killed even if daemon isn't running.  If the daemon already
 		// shut down the connector, then this is a no-op.
@@ -134,7 +162,12 @@ func Disconnect(ctx context.Context, quitUserDaemon, quitRootDaemon bool) (err e
 			}
 		}
 		if err == nil && quitRootDaemon {
-			err = client.WaitUntilSocketVanishes("root daemon", client.DaemonSocketName, 5*time.Second)
+			if info != nil {
+				// The daemons are gone, but the container might still be running.
+				err = docker.Stop(ctx, info)
+			} else {
+				err = client.WaitUntilSocketVanishes("root daemon", client.DaemonSocket(ctx), 5*time.Second)
+			}
 		}
 	}()
 	fmt.Fprint(stdout, "Telepresence Network ")
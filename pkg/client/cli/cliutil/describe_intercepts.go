@@ -41,13 +41,41 @@ func describeIntercept(ii *manager.InterceptInfo, volumeMountsPrevented error, d
 		return msg
 	}()})
 	fields = append(fields, kv{"Workload kind", ii.Spec.WorkloadKind})
+	if ii.VerifiedUser != "" {
+		fields = append(fields, kv{"Verified user", ii.VerifiedUser})
+	}
 
 	if debug {
 		fields = append(fields, kv{"ID", ii.Id})
 	}
 
-	fields = append(fields, kv{"Destination",
-		net.JoinHostPort(ii.Spec.TargetHost, fmt.Sprintf("%d", ii.Spec.TargetPort))})
+	destination := net.JoinHostPort(ii.Spec.TargetHost, fmt.Sprintf("%d", ii.Spec.TargetPort))
+	if ii.Spec.TargetInCluster {
+		destination += " (in cluster)"
+	}
+	fields = append(fields, kv{"Destination", destination})
+	if ii.Spec.Mirror {
+		fields = append(fields, kv{"Mirror", "responses from the destination are discarded"})
+	}
+	if ii.Spec.Weight > 0 && ii.Spec.Weight < 100 {
+		weight := fmt.Sprintf("%d%%", ii.Spec.Weight)
+		switch {
+		case ii.Spec.StickyHeader != "" && ii.Spec.StickyClientIp:
+			weight += fmt.Sprintf(", sticky by header %q or client IP", ii.Spec.StickyHeader)
+		case ii.Spec.StickyHeader != "":
+			weight += fmt.Sprintf(", sticky by header %q", ii.Spec.StickyHeader)
+		case ii.Spec.StickyClientIp:
+			weight += ", sticky by client IP"
+		}
+		fields = append(fields, kv{"Weight", weight})
+	}
+	if ii.Spec.TlsSecret != "" {
+		upstream := "TLS"
+		if ii.Spec.TlsPlaintextUpstream {
+			upstream = "plaintext"
+		}
+		fields = append(fields, kv{"TLS", fmt.Sprintf("terminated using secret %q, %s upstream", ii.Spec.TlsSecret, upstream)})
+	}
 
 	if ii.Spec.ServicePortIdentifier != "" {
 		fields = append(fields, kv{"Service Port Identifier", ii.Spec.ServicePortIdentifier})
@@ -0,0 +1,135 @@
+package cliutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+// InterceptWorkspace is a declarative list of intercepts that are started together using
+// "telepresence intercept --file" and stopped together using "telepresence leave --file".
+type InterceptWorkspace struct {
+	Intercepts []*WorkspaceIntercept `json:"intercepts"`
+}
+
+// WorkspaceIntercept declares one intercept of an InterceptWorkspace. The fields correspond to the
+// flags of the "telepresence intercept" command.
+type WorkspaceIntercept struct {
+	// Name is the base name of the intercept.
+	Name string `json:"name"`
+
+	// Workload is the name of the workload to intercept. Defaults to Name.
+	Workload string `json:"workload,omitempty"`
+
+	// Namespace is the namespace of the workload.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Service is the name of the service to intercept.
+	Service string `json:"service,omitempty"`
+
+	// Port is the port mapping, using the same format as the --port flag.
+	Port string `json:"port,omitempty"`
+
+	// ToPod are additional ports to forward from the intercepted pod.
+	ToPod []string `json:"toPod,omitempty"`
+
+	// EnvFile is the path of a file where the remote environment is written in Docker Compose format.
+	EnvFile string `json:"envFile,omitempty"`
+
+	// EnvJSON is the path of a file where the remote environment is written as a JSON blob.
+	EnvJSON string `json:"envJSON,omitempty"`
+
+	// Mount is true, false, or the path of the mount point.
+	Mount MountSetting `json:"mount,omitempty"`
+
+	// DockerRun declares that Command contains arguments to 'docker run'.
+	DockerRun bool `json:"dockerRun,omitempty"`
+
+	// DockerMount is the volume mount point in the docker container.
+	DockerMount string `json:"dockerMount,omitempty"`
+
+	// Command is run with the intercepted environment. When DockerRun is true, it contains the arguments
+	// to 'docker run'.
+	Command []string `json:"command,omitempty"`
+}
+
+// MountSetting is either "true", "false", or the path of a mount point. It can be declared using a YAML
+// boolean or string.
+type MountSetting string
+
+func (m *MountSetting) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*m = MountSetting(strconv.FormatBool(b))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("mount must be a boolean or a path: %w", err)
+	}
+	*m = MountSetting(s)
+	return nil
+}
+
+// InterceptName returns the name of the intercept that is created from this declaration. The name is
+// derived in the same way as when the intercept is created using flags. The defaultNamespace is the
+// value of the --namespace flag, which is used when the declaration has no namespace.
+func (wi *WorkspaceIntercept) InterceptName(defaultNamespace string) string {
+	ns := wi.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+	if wi.Workload == "" && ns != "" {
+		return wi.Name + "-" + ns
+	}
+	return wi.Name
+}
+
+// LoadInterceptWorkspace reads and validates the intercept workspace in the given file. Relative paths
+// in the workspace are made relative to the directory of the file. The defaultNamespace is the value of
+// the --namespace flag.
+func LoadInterceptWorkspace(file, defaultNamespace string) (*InterceptWorkspace, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errcat.User.New(err)
+	}
+	ws := &InterceptWorkspace{}
+	if err = yaml.UnmarshalStrict(data, ws); err != nil {
+		return nil, errcat.User.Newf("unable to parse intercept workspace %s: %w", file, err)
+	}
+	if len(ws.Intercepts) == 0 {
+		return nil, errcat.User.Newf("intercept workspace %s declares no intercepts", file)
+	}
+
+	dir := filepath.Dir(file)
+	absPath := func(p string) string {
+		if p != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		return p
+	}
+	names := make(map[string]struct{}, len(ws.Intercepts))
+	for i, wi := range ws.Intercepts {
+		if wi == nil || wi.Name == "" {
+			return nil, errcat.User.Newf("intercept number %d in workspace %s has no name", i+1, file)
+		}
+		name := wi.InterceptName(defaultNamespace)
+		if _, dup := names[name]; dup {
+			return nil, errcat.User.Newf("intercept workspace %s declares intercept %q more than once", file, name)
+		}
+		names[name] = struct{}{}
+
+		wi.EnvFile = absPath(wi.EnvFile)
+		wi.EnvJSON = absPath(wi.EnvJSON)
+		if _, err := strconv.ParseBool(string(wi.Mount)); err != nil {
+			wi.Mount = MountSetting(absPath(string(wi.Mount)))
+		}
+	}
+	return ws, nil
+}
@@ -0,0 +1,98 @@
+package cliutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeWorkspace(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "workspace.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestLoadInterceptWorkspace(t *testing.T) {
+	file := writeWorkspace(t, `
+intercepts:
+- name: frontend
+  port: "8080:http"
+  envFile: frontend.env
+  mount: false
+  command: [npm, start]
+- name: backend
+  namespace: dev
+  toPod: ["8081", "9000/UDP"]
+  mount: mnt/backend
+- name: worker
+  workload: worker-v2
+  namespace: dev
+  envJSON: /tmp/worker.json
+  mount: true
+  dockerRun: true
+  command: [--rm, worker:latest]
+`)
+	ws, err := LoadInterceptWorkspace(file, "")
+	require.NoError(t, err)
+	require.Len(t, ws.Intercepts, 3)
+	dir := filepath.Dir(file)
+
+	fe := ws.Intercepts[0]
+	assert.Equal(t, "frontend", fe.InterceptName(""))
+	assert.Equal(t, "8080:http", fe.Port)
+	assert.Equal(t, filepath.Join(dir, "frontend.env"), fe.EnvFile)
+	assert.Equal(t, MountSetting("false"), fe.Mount)
+	assert.Equal(t, []string{"npm", "start"}, fe.Command)
+
+	be := ws.Intercepts[1]
+	assert.Equal(t, "backend-dev", be.InterceptName(""))
+	assert.Equal(t, []string{"8081", "9000/UDP"}, be.ToPod)
+	assert.Equal(t, MountSetting(filepath.Join(dir, "mnt", "backend")), be.Mount)
+
+	wk := ws.Intercepts[2]
+	assert.Equal(t, "worker", wk.InterceptName(""))
+	assert.Equal(t, "/tmp/worker.json", wk.EnvJSON)
+	assert.Equal(t, MountSetting("true"), wk.Mount)
+	assert.True(t, wk.DockerRun)
+}
+
+func TestLoadInterceptWorkspace_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", "intercepts: []"},
+		{"no name", "intercepts:\n- workload: frontend"},
+		{"unknown field", "intercepts:\n- name: frontend\n  prot: 8080"},
+		{"duplicate", "intercepts:\n- name: frontend\n- name: frontend"},
+		{"invalid mount", "intercepts:\n- name: frontend\n  mount: [a]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadInterceptWorkspace(writeWorkspace(t, tt.content), "")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWorkspaceIntercept_InterceptName(t *testing.T) {
+	tests := []struct {
+		name             string
+		wi               WorkspaceIntercept
+		defaultNamespace string
+		want             string
+	}{
+		{"no namespace", WorkspaceIntercept{Name: "frontend"}, "", "frontend"},
+		{"default namespace", WorkspaceIntercept{Name: "frontend"}, "dev", "frontend-dev"},
+		{"declared namespace", WorkspaceIntercept{Name: "frontend", Namespace: "qa"}, "dev", "frontend-qa"},
+		{"workload", WorkspaceIntercept{Name: "frontend", Workload: "fe"}, "dev", "frontend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.wi.InterceptName(tt.defaultNamespace))
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 )
@@ -121,6 +122,41 @@ func isCommand(s string) bool {
 	return false
 }
 
+// ConnectionName returns the name of the connection that the command line uses. The name is given
+// by the global --connection flag, or by the --name flag of the connect command. It must be known
+// before the command line is parsed, because the commands that are implemented by the user daemon
+// are retrieved from the user daemon that manages the connection.
+func ConnectionName() (string, error) {
+	name := connectionNameFromArgs(os.Args[1:], isCommand("connect"))
+	if err := client.ValidateConnectionName(name); err != nil {
+		return "", errcat.User.New(err)
+	}
+	return name, nil
+}
+
+func connectionNameFromArgs(args []string, connect bool) string {
+	name := client.DefaultConnectionName
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		for _, flag := range []string{"--connection", "--name"} {
+			if flag == "--name" && !connect {
+				continue
+			}
+			switch {
+			case arg == flag && i+1 < len(args):
+				i++
+				name = args[i]
+			case strings.HasPrefix(arg, flag+"="):
+				name = strings.TrimPrefix(arg, flag+"=")
+			}
+		}
+	}
+	return name
+}
+
 func userWantsRootLevelHelp() bool {
 	if len(os.Args) <= 1 {
 		return true
@@ -148,8 +184,8 @@ func Command(ctx context.Context) *cobra.Command {
 		"Session Commands": []*cobra.Command{connectCommand(), LoginCommand(), LogoutCommand(), LicenseCommand(), statusCommand(), quitCommand()},
 		"Traffic Commands": []*cobra.Command{listCommand(), leaveCommand(), previewCommand()},
 		"Install Commands": []*cobra.Command{helmCommand(), uninstallCommand()},
-		"Debug Commands":   []*cobra.Command{loglevelCommand(), gatherLogsCommand()},
-		"Other Commands":   []*cobra.Command{versionCommand(), dashboardCommand(), ClusterIdCommand(), genYAMLCommand(), vpnDiagCommand()},
+		"Debug Commands":   []*cobra.Command{loglevelCommand(), gatherLogsCommand(), doctorCommand()},
+		"Other Commands":   []*cobra.Command{versionCommand(), dashboardCommand(), ClusterIdCommand(), genYAMLCommand(), vpnDiagCommand(), eventsCommand(), configCommand()},
 	}
 
 	var groups = make(cliutil.CommandGroups)
@@ -277,6 +313,10 @@ func GlobalFlagGroups() []cliutil.FlagGroup {
 				"output", "default",
 				"set the output format, supported values are 'json' and 'default'",
 			)
+			flags.String(
+				"connection", client.DefaultConnectionName,
+				"the name of the connection to use when more than one connection is active",
+			)
 			return flags
 		}(),
 	}}
@@ -0,0 +1,480 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+func configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the client configuration",
+	}
+	cmd.AddCommand(
+		configViewCommand(),
+		configGetCommand(),
+		configSetCommand(),
+		configUnsetCommand(),
+		configValidateCommand(),
+	)
+	return cmd
+}
+
+type configViewInfo struct {
+	context   string
+	clusterID string
+}
+
+func configViewCommand() *cobra.Command {
+	cv := &configViewInfo{}
+	cmd := &cobra.Command{
+		Use:  "view",
+		Args: cobra.NoArgs,
+
+		Short: "View the effective client configuration",
+		Long: "View the client configuration that is in effect for connections that use a kube context, " +
+			"with the profiles for that context and the given cluster ID applied. Each value is annotated " +
+			"with the file, line, and profile that it came from.",
+		RunE: cv.run,
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&cv.context, "context", "", "The kube context. Defaults to the current context of the kubeconfig")
+	flags.StringVar(&cv.clusterID, "cluster-id", "", "The ID of the cluster")
+	return cmd
+}
+
+// configViewOutput is the JSON output of the config view command.
+type configViewOutput struct {
+	Context   string                         `json:"context,omitempty"`
+	ClusterID string                         `json:"cluster_id,omitempty"`
+	Profiles  []string                       `json:"profiles,omitempty"`
+	Config    map[string]any                 `json:"config"`
+	Sources   map[string]*client.ValueSource `json:"sources,omitempty"`
+}
+
+func (cv *configViewInfo) run(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := client.LoadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	contextName := cv.context
+	if contextName == "" {
+		// The current context is only needed to find a matching profile, so errors are ignored.
+		if kc, err := clientcmd.NewDefaultClientConfigLoadingRules().Load(); err == nil {
+			contextName = kc.CurrentContext
+		}
+	}
+	profiles := cfg.MatchingProfiles(contextName, cv.clusterID)
+	sources, err := client.ConfigSources(ctx, profiles...)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err = node.Encode(cfg.ForProfiles(profiles...)); err != nil {
+		return err
+	}
+
+	stdout := cmd.OutOrStdout()
+	if output.WantsJSONOutput(cmd.Flags()) {
+		cvo := &configViewOutput{
+			Context:   contextName,
+			ClusterID: cv.clusterID,
+			Profiles:  profiles,
+			Sources:   sources,
+		}
+		if err = node.Decode(&cvo.Config); err != nil {
+			return err
+		}
+		stdout.(output.StructuredStreamer).StructuredStream(cvo, nil)
+		return nil
+	}
+
+	if len(profiles) > 0 {
+		fmt.Fprintf(stdout, "# Profiles: %s\n", strings.Join(profiles, ", "))
+	}
+	if node.Kind == yaml.MappingNode && len(node.Content) == 0 {
+		fmt.Fprintln(stdout, "# All values are defaults")
+		return nil
+	}
+	enc := yaml.NewEncoder(stdout)
+	enc.SetIndent(2)
+	if err = enc.Encode(client.AnnotateSources(&node, sources)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func configGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:  "get <key>",
+		Args: cobra.ExactArgs(1),
+
+		Short: "Get a value of the client configuration",
+		Long: "Get a value of the client configuration. The key is a dot separated path, e.g. timeouts.helm. " +
+			"A path element that contains dots, like the name of a profile, must be quoted, e.g. " +
+			`profiles."my.context".timeouts.helm. Fails when the value isn't set, i.e. when its default is used.`,
+		RunE: configGet,
+	}
+}
+
+func configGet(cmd *cobra.Command, args []string) error {
+	path, err := splitKeyPath(args[0])
+	if err != nil {
+		return err
+	}
+	cfg, err := client.LoadConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err = node.Encode(cfg); err != nil {
+		return err
+	}
+	vn := lookupKeyPath(&node, path)
+	if vn == nil {
+		return errcat.User.Newf("%s is not set", args[0])
+	}
+	stdout := cmd.OutOrStdout()
+	if output.WantsJSONOutput(cmd.Flags()) {
+		var v any
+		if err = vn.Decode(&v); err != nil {
+			return err
+		}
+		stdout.(output.StructuredStreamer).StructuredStream(v, nil)
+		return nil
+	}
+	if vn.Kind == yaml.ScalarNode {
+		fmt.Fprintln(stdout, vn.Value)
+		return nil
+	}
+	enc := yaml.NewEncoder(stdout)
+	enc.SetIndent(2)
+	if err = enc.Encode(vn); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func configSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:  "set <key> <value>",
+		Args: cobra.ExactArgs(2),
+
+		Short: "Set a value in the user's client configuration file",
+		Long: "Set a value in the user's client configuration file. The key is a dot separated path, e.g. " +
+			"timeouts.helm, and the value is parsed as YAML, so lists can be given as [a, b]. Comments in " +
+			"the file are preserved. The file is validated before it is written, and running daemons reload it.",
+		RunE: configSet,
+	}
+}
+
+func configSet(cmd *cobra.Command, args []string) error {
+	path, err := splitKeyPath(args[0])
+	if err != nil {
+		return err
+	}
+	var vd yaml.Node
+	if err = yaml.Unmarshal([]byte(args[1]), &vd); err != nil {
+		return errcat.User.Newf("invalid value %q: %w", args[1], err)
+	}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: ""}
+	if len(vd.Content) > 0 {
+		value = vd.Content[0]
+		value.Style &^= yaml.FlowStyle
+	}
+	return editConfigFile(cmd, func(doc *yaml.Node) error {
+		return setKeyPath(doc.Content[0], path, value)
+	})
+}
+
+func configUnsetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:  "unset <key>",
+		Args: cobra.ExactArgs(1),
+
+		Short: "Remove a value from the user's client configuration file",
+		Long: "Remove a value from the user's client configuration file, so that its default is used. " +
+			"Comments in the file are preserved, and running daemons reload the file.",
+		RunE: configUnset,
+	}
+}
+
+func configUnset(cmd *cobra.Command, args []string) error {
+	path, err := splitKeyPath(args[0])
+	if err != nil {
+		return err
+	}
+	return editConfigFile(cmd, func(doc *yaml.Node) error {
+		if !unsetKeyPath(doc.Content[0], path) {
+			return errcat.User.Newf("%s is not set in %s", args[0], client.GetConfigFile(cmd.Context()))
+		}
+		return nil
+	})
+}
+
+func configValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:  "validate [<file> ...]",
+		Args: cobra.ArbitraryArgs,
+
+		Short: "Validate client configuration files",
+		Long: "Validate the given client configuration files, or, when no files are given, all configuration " +
+			"files that telepresence reads. Each problem is reported with the line where it was found.",
+		RunE: configValidate,
+	}
+}
+
+func configValidate(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	files := args
+	if len(files) == 0 {
+		var err error
+		if files, err = client.ConfigFiles(ctx); err != nil {
+			return err
+		}
+	}
+	stdout := cmd.OutOrStdout()
+	count := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if len(args) == 0 && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		problems, err := client.ValidateConfig(ctx, file, data)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		for _, p := range problems {
+			fmt.Fprintln(stdout, p)
+		}
+		count += len(problems)
+	}
+	if count > 0 {
+		return errcat.Config.Newf("found %d problem(s) in the configuration", count)
+	}
+	fmt.Fprintln(stdout, "The configuration is valid")
+	return nil
+}
+
+// editConfigFile reads the user's configuration file, calls the given function to modify its
+// document, validates the result, and writes it back. Running daemons watch the file and reload
+// it when it's written.
+func editConfigFile(cmd *cobra.Command, edit func(doc *yaml.Node) error) error {
+	ctx := cmd.Context()
+	file := client.GetConfigFile(ctx)
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return errcat.Config.Newf("file %s: %w", file, err)
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return errcat.Config.Newf("file %s: the configuration must be an object", file)
+	}
+	// Problems that the file already has must not prevent unrelated edits.
+	oldProblems, _ := client.ValidateConfig(ctx, file, data)
+	if err = edit(&doc); err != nil {
+		return err
+	}
+
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err = enc.Close(); err != nil {
+		return err
+	}
+	problems, err := client.ValidateConfig(ctx, file, buf.Bytes())
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if added := addedProblems(oldProblems, problems); len(added) > 0 {
+		return errcat.User.Newf("the change would make the configuration invalid:\n%s", strings.Join(added, "\n"))
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it, so that a daemon never reads a partially written file.
+	tmp := file + ".tmp"
+	if err = os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// problemLocRx matches the location that a configuration problem starts with. The location is
+// ignored when problems are compared, because an edit may move the lines of the file.
+var problemLocRx = regexp.MustCompile(`^(?:file .*, )?line \d+: `)
+
+// addedProblems returns the problems that aren't found among the old problems.
+func addedProblems(oldProblems, problems []string) []string {
+	counts := make(map[string]int, len(oldProblems))
+	for _, p := range oldProblems {
+		counts[problemLocRx.ReplaceAllString(p, "")]++
+	}
+	var added []string
+	for _, p := range problems {
+		k := problemLocRx.ReplaceAllString(p, "")
+		if counts[k] > 0 {
+			counts[k]--
+		} else {
+			added = append(added, p)
+		}
+	}
+	return added
+}
+
+// splitKeyPath splits the given dot separated key path into its elements. An element that
+// contains dots must be enclosed in double quotes.
+func splitKeyPath(key string) ([]string, error) {
+	var path []string
+	for key != "" {
+		var elem string
+		if strings.HasPrefix(key, `"`) {
+			end := strings.IndexByte(key[1:], '"')
+			if end < 0 {
+				return nil, errcat.User.Newf("unterminated quote in key %q", key)
+			}
+			elem = key[1 : end+1]
+			key = key[end+2:]
+			if key != "" && !strings.HasPrefix(key, ".") {
+				return nil, errcat.User.Newf("expected '.' after quoted element in key %q", key)
+			}
+		} else if dot := strings.IndexByte(key, '.'); dot >= 0 {
+			elem = key[:dot]
+			key = key[dot:]
+		} else {
+			elem = key
+			key = ""
+		}
+		if elem == "" {
+			return nil, errcat.User.New("key elements cannot be empty")
+		}
+		path = append(path, elem)
+		key = strings.TrimPrefix(key, ".")
+	}
+	if len(path) == 0 {
+		return nil, errcat.User.New("key cannot be empty")
+	}
+	return path, nil
+}
+
+// lookupKeyPath returns the node at the given path in the given node, or nil if no such node exists.
+func lookupKeyPath(node *yaml.Node, path []string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// setKeyPath sets the node at the given path in the given mapping node to the given value. Mappings
+// are created as needed. The comments of a replaced value are retained.
+func setKeyPath(node *yaml.Node, path []string, value *yaml.Node) error {
+	for i, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return errcat.User.Newf("%s is not an object", strings.Join(path[:i], "."))
+		}
+		var next *yaml.Node
+		for j := 0; j < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				if i == len(path)-1 {
+					old := node.Content[j+1]
+					value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+					node.Content[j+1] = value
+					return nil
+				}
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			next = value
+			if i < len(path)-1 {
+				next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, next)
+			if i == len(path)-1 {
+				return nil
+			}
+		}
+		node = next
+	}
+	return nil
+}
+
+// unsetKeyPath removes the node at the given path from the given mapping node, together with the
+// mappings that become empty as a result. Returns false if no such node exists.
+func unsetKeyPath(node *yaml.Node, path []string) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for j := 0; j < len(node.Content); j += 2 {
+		if node.Content[j].Value != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			child := node.Content[j+1]
+			if !unsetKeyPath(child, path[1:]) {
+				return false
+			}
+			if len(child.Content) > 0 {
+				return true
+			}
+		}
+		node.Content = append(node.Content[:j], node.Content[j+2:]...)
+		return true
+	}
+	return false
+}
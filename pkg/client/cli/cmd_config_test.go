@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_splitKeyPath(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{key: "timeouts.helm", want: []string{"timeouts", "helm"}},
+		{key: "mappedNamespaces", want: []string{"mappedNamespaces"}},
+		{key: `profiles."my.context".timeouts.helm`, want: []string{"profiles", "my.context", "timeouts", "helm"}},
+		{key: `profiles."my.context"`, want: []string{"profiles", "my.context"}},
+		{key: "", wantErr: true},
+		{key: "timeouts..helm", wantErr: true},
+		{key: `profiles."my.context`, wantErr: true},
+		{key: `profiles."my"context`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := splitKeyPath(tt.key)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func editYAML(t *testing.T, data string, edit func(*yaml.Node)) string {
+	t.Helper()
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(data), &doc))
+	edit(doc.Content[0])
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	require.NoError(t, enc.Encode(&doc))
+	require.NoError(t, enc.Close())
+	return buf.String()
+}
+
+func Test_setKeyPath(t *testing.T) {
+	data := `# config
+timeouts:
+  # slow cluster
+  helm: 2m # two minutes
+`
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "3m"}
+	got := editYAML(t, data, func(n *yaml.Node) {
+		require.NoError(t, setKeyPath(n, []string{"timeouts", "helm"}, value))
+	})
+	assert.Equal(t, `# config
+timeouts:
+  # slow cluster
+  helm: 3m # two minutes
+`, got)
+
+	got = editYAML(t, data, func(n *yaml.Node) {
+		require.NoError(t, setKeyPath(n, []string{"images", "registry"}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "x.io"}))
+	})
+	assert.Equal(t, `# config
+timeouts:
+  # slow cluster
+  helm: 2m # two minutes
+images:
+  registry: x.io
+`, got)
+
+	editYAML(t, data, func(n *yaml.Node) {
+		assert.Error(t, setKeyPath(n, []string{"timeouts", "helm", "x"}, value))
+	})
+}
+
+func Test_unsetKeyPath(t *testing.T) {
+	data := `timeouts:
+  helm: 2m # two minutes
+profiles:
+  prod:
+    timeouts:
+      helm: 1m
+`
+	got := editYAML(t, data, func(n *yaml.Node) {
+		assert.True(t, unsetKeyPath(n, []string{"profiles", "prod", "timeouts", "helm"}))
+		assert.False(t, unsetKeyPath(n, []string{"timeouts", "apply"}))
+	})
+	assert.Equal(t, `timeouts:
+  helm: 2m # two minutes
+`, got)
+}
+
+func Test_addedProblems(t *testing.T) {
+	oldProblems := []string{
+		`file config.yml, line 2: unknown key "foo"`,
+		`file config.yml, line 5: unknown key "bar"`,
+	}
+	tests := []struct {
+		name     string
+		problems []string
+		want     []string
+	}{
+		{
+			"unchanged",
+			oldProblems,
+			nil,
+		},
+		{
+			"moved",
+			[]string{`file config.yml, line 3: unknown key "foo"`},
+			nil,
+		},
+		{
+			"one fixed, one added",
+			[]string{
+				`file config.yml, line 2: unknown key "foo"`,
+				`file config.yml, line 7: "x" is not a valid duration`,
+			},
+			[]string{`file config.yml, line 7: "x" is not a valid duration`},
+		},
+		{
+			"duplicated",
+			[]string{
+				`file config.yml, line 2: unknown key "foo"`,
+				`file config.yml, line 5: unknown key "bar"`,
+				`file config.yml, line 8: unknown key "foo"`,
+			},
+			[]string{`file config.yml, line 8: unknown key "foo"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, addedProblems(oldProblems, tt.problems))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/doctor"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+func doctorCommand() *cobra.Command {
+	kubeFlags := allKubeFlags()
+	cmd := &cobra.Command{
+		Use:  "doctor",
+		Args: cobra.NoArgs,
+
+		Short: "Validate the setup that Telepresence depends on",
+		Long: "Run a battery of checks that validate the kubeconfig and the RBAC permissions of the user, the " +
+			"reachability and version of the traffic-manager, the health of the agent-injector webhook and its " +
+			"certificate, the availability of the traffic-agent image, the local DNS configuration, conflicts between " +
+			"the cluster subnets and local routes, and the availability of sshfs and Docker. Each problem that is found " +
+			"is reported together with a hint on how to remedy it.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runDoctor(cmd, kubeFlagMap(kubeFlags))
+		},
+	}
+	cmd.Flags().AddFlagSet(kubeFlags)
+	return cmd
+}
+
+func runDoctor(cmd *cobra.Command, kubeFlags map[string]string) error {
+	report := doctor.NewDoctor(kubeFlags).Run(cmd.Context())
+	var err error
+	if n := report.Failed(); n > 0 {
+		err = errcat.User.Newf("%d of %d checks failed", n, len(report.Results))
+	}
+	if output.WantsJSONOutput(cmd.Flags()) {
+		cmd.OutOrStdout().(output.StructuredStreamer).StructuredStream(report, err)
+		return nil
+	}
+	report.Write(cmd.OutOrStdout())
+	return err
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+)
+
+func eventsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:  "events",
+		Args: cobra.NoArgs,
+
+		Short: "Stream events from the user daemon",
+		Long: "Stream events that describe changes to the connection, intercepts, traffic-agents, mounts, and DNS " +
+			"configuration of the user daemon, starting with events that describe the current state. The user " +
+			"daemon must be running. Use --output json to get one versioned JSON object per event.",
+		RunE: watchEvents,
+	}
+}
+
+func watchEvents(cmd *cobra.Command, _ []string) error {
+	stdout := cmd.OutOrStdout()
+	var streamer output.StructuredStreamer
+	if output.WantsJSONOutput(cmd.Flags()) {
+		streamer, _ = stdout.(output.StructuredStreamer)
+		if streamer == nil {
+			panic("writer not output.StructuredStreamer")
+		}
+	}
+	// Watching events must not start the daemons.
+	return cliutil.WithStartedConnector(cmd.Context(), false, func(ctx context.Context, userD connector.ConnectorClient) error {
+		stream, err := userD.WatchEvents(ctx, &empty.Empty{})
+		if err != nil {
+			return err
+		}
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) || ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			if streamer == nil {
+				fmt.Fprintln(stdout, describeEvent(ev))
+				continue
+			}
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			streamer.StructuredStream(json.RawMessage(data), nil)
+		}
+	})
+}
+
+// describeEvent returns a one-line human-readable description of the given event.
+func describeEvent(ev *connector.Event) string {
+	var sb strings.Builder
+	sb.WriteString(ev.Time.AsTime().Local().Format(time.RFC3339))
+	sb.WriteByte(' ')
+	switch k := ev.Kind.(type) {
+	case *connector.Event_Connection:
+		c := k.Connection
+		fmt.Fprintf(&sb, "connection %s: context %s, server %s", strings.ToLower(c.State.String()), c.ClusterContext, c.ClusterServer)
+		if c.Error != "" {
+			fmt.Fprintf(&sb, ": %s", c.Error)
+		}
+	case *connector.Event_Intercept:
+		ii := k.Intercept.Intercept
+		fmt.Fprintf(&sb, "intercept %s: %s", strings.ToLower(k.Intercept.Type.String()), ii.GetSpec().GetName())
+		if ii.GetDisposition() != 0 {
+			fmt.Fprintf(&sb, " (%s)", ii.Disposition)
+		}
+	case *connector.Event_Agent:
+		a := k.Agent.Agent
+		fmt.Fprintf(&sb, "agent %s: %s.%s", strings.ToLower(k.Agent.Type.String()), a.GetName(), a.GetNamespace())
+		if a.GetPodIp() != "" {
+			fmt.Fprintf(&sb, " at %s", a.PodIp)
+		}
+	case *connector.Event_Mount:
+		m := k.Mount
+		fmt.Fprintf(&sb, "mount %s: intercept %s at %s", strings.ToLower(m.Type.String()), m.Intercept, m.MountPoint)
+		if m.Error != "" {
+			fmt.Fprintf(&sb, ": %s", m.Error)
+		}
+	case *connector.Event_Dns:
+		fmt.Fprintf(&sb, "dns: search paths [%s], namespaces [%s]",
+			strings.Join(k.Dns.SearchPaths, " "), strings.Join(k.Dns.Namespaces, " "))
+	case *connector.Event_Notification:
+		fmt.Fprintf(&sb, "notification: %s", k.Notification.Message)
+	default:
+		sb.WriteString("unknown event")
+	}
+	return sb.String()
+}
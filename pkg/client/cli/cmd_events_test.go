@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func Test_describeEvent(t *testing.T) {
+	ts := time.Date(2022, 8, 1, 12, 0, 0, 0, time.Local)
+	prefix := ts.Format(time.RFC3339) + " "
+	tests := []struct {
+		name string
+		ev   *connector.Event
+		want string
+	}{
+		{
+			name: "connection",
+			ev: &connector.Event{Kind: &connector.Event_Connection{Connection: &connector.ConnectionEvent{
+				State:          connector.ConnectionEvent_LOST,
+				ClusterContext: "default",
+				ClusterServer:  "https://127.0.0.1:6443",
+				Error:          "connection refused",
+			}}},
+			want: "connection lost: context default, server https://127.0.0.1:6443: connection refused",
+		},
+		{
+			name: "intercept",
+			ev: &connector.Event{Kind: &connector.Event_Intercept{Intercept: &connector.InterceptEvent{
+				Type: connector.InterceptEvent_UPDATED,
+				Intercept: &manager.InterceptInfo{
+					Spec:        &manager.InterceptSpec{Name: "echo"},
+					Disposition: manager.InterceptDispositionType_ACTIVE,
+				},
+			}}},
+			want: "intercept updated: echo (ACTIVE)",
+		},
+		{
+			name: "agent",
+			ev: &connector.Event{Kind: &connector.Event_Agent{Agent: &connector.AgentEvent{
+				Type:  connector.AgentEvent_ARRIVED,
+				Agent: &manager.AgentInfo{Name: "echo", Namespace: "default", PodIp: "10.1.0.5"},
+			}}},
+			want: "agent arrived: echo.default at 10.1.0.5",
+		},
+		{
+			name: "dns",
+			ev:   &connector.Event{Kind: &connector.Event_Dns{Dns: &connector.DNSEvent{SearchPaths: []string{"default"}, Namespaces: []string{"default", "other"}}}},
+			want: "dns: search paths [default], namespaces [default other]",
+		},
+		{
+			name: "notification",
+			ev:   &connector.Event{Kind: &connector.Event_Notification{Notification: &connector.NotificationEvent{Message: "hello"}}},
+			want: "notification: hello",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ev := tt.ev
+			ev.Version = 1
+			ev.Time = timestamppb.New(ts)
+			assert.Equal(t, prefix+tt.want, describeEvent(ev))
+		})
+	}
+}
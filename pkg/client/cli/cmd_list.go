@@ -10,6 +10,7 @@ import (
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
@@ -151,12 +152,16 @@ func (s *listInfo) printList(workloads []*connector.WorkloadInfo, stdout io.Writ
 	}
 
 	state := func(workload *connector.WorkloadInfo) string {
+		upgrade := describeUpgrade(workload.AgentInfo.GetUpgrade())
+		if upgrade != "" {
+			upgrade = "\n    " + upgrade
+		}
 		if iis := workload.InterceptInfos; len(iis) > 0 {
-			return cliutil.DescribeIntercepts(iis, nil, s.debug)
+			return cliutil.DescribeIntercepts(iis, nil, s.debug) + upgrade
 		}
 		ai := workload.AgentInfo
 		if ai != nil {
-			return "ready to intercept (traffic-agent already installed)"
+			return "ready to intercept (traffic-agent already installed)" + upgrade
 		}
 		if workload.NotInterceptableReason != "" {
 			return "not interceptable (traffic-agent not installed): " + workload.NotInterceptableReason
@@ -215,3 +220,19 @@ func (s *listInfo) printList(workloads []*connector.WorkloadInfo, stdout io.Writ
 		}
 	}
 }
+
+// describeUpgrade describes the upgrade of a traffic-agent, or returns the empty string when the traffic-agent
+// is up-to-date.
+func describeUpgrade(u *manager.AgentUpgrade) string {
+	if u == nil {
+		return ""
+	}
+	switch u.Status {
+	case manager.AgentUpgrade_PENDING:
+		return fmt.Sprintf("traffic-agent upgrade from %s to %s is pending", u.Image, u.TargetImage)
+	case manager.AgentUpgrade_IN_PROGRESS:
+		return fmt.Sprintf("traffic-agent is being upgraded from %s to %s", u.Image, u.TargetImage)
+	default:
+		return fmt.Sprintf("traffic-agent %s is outdated and won't be upgraded to %s because %s", u.Image, u.TargetImage, u.Reason)
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
@@ -73,8 +74,11 @@ func previewCommand() *cobra.Command {
 		Short: "Create a preview domain for an existing intercept",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return withConnector(cmd, true, nil, func(ctx context.Context, cs *connectorState) error {
-				if _, err := cliutil.ClientEnsureLoggedIn(cmd.Context(), "", cs.userD); err != nil {
-					return err
+				// A traffic-manager that serves self-hosted preview URLs doesn't need Ambassador Cloud
+				if !client.GetConfig(ctx).Cloud.SkipLogin {
+					if _, err := cliutil.ClientEnsureLoggedIn(cmd.Context(), "", cs.userD); err != nil {
+						return err
+					}
 				}
 				reporter := scout.NewReporter(ctx, "cli")
 				reporter.Start(ctx)
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
@@ -25,8 +27,11 @@ type statusInfo struct {
 }
 
 type statusOutput struct {
-	DaemonStatus daemonStatus    `json:"root_daemon"`
-	UserDaemon   connectorStatus `json:"user_daemon"`
+	Connection       string          `json:"connection"`
+	OtherConnections []string        `json:"other_connections,omitempty"`
+	SubnetOverlaps   []string        `json:"subnet_overlaps,omitempty"`
+	DaemonStatus     daemonStatus    `json:"root_daemon"`
+	UserDaemon       connectorStatus `json:"user_daemon"`
 }
 
 type daemonStatus struct {
@@ -34,6 +39,7 @@ type daemonStatus struct {
 	Version           string           `json:"version,omitempty"`
 	APIVersion        int32            `json:"api_version,omitempty"`
 	DNS               *daemonStatusDNS `json:"dns,omitempty"`
+	Subnets           []string         `json:"subnets,omitempty"`
 	AlsoProxySubnets  []string         `json:"also_proxy_subnets,omitempty"`
 	NeverProxySubnets []string         `json:"never_proxy_subnets,omitempty"`
 }
@@ -91,20 +97,40 @@ func (s *statusInfo) status(cmd *cobra.Command, _ []string) error {
 	s.out = cmd.OutOrStdout()
 	ctx := cmd.Context()
 
+	so := &statusOutput{Connection: client.GetConnectionName(ctx)}
 	ds, err := s.daemonStatus(ctx)
 	if err != nil {
 		return err
 	}
+	so.DaemonStatus = *ds
 
 	cs, err := s.connectorStatus(ctx)
 	if err != nil {
 		return err
 	}
+	so.UserDaemon = *cs
+
+	if names, err := client.ConnectionNames(); err == nil {
+		for _, name := range names {
+			if name != so.Connection {
+				so.OtherConnections = append(so.OtherConnections, name)
+			}
+		}
+	}
+	if ds.Running && len(so.OtherConnections) > 0 {
+		overlaps, err := cliutil.ConnectionSubnetOverlaps(ctx)
+		if err != nil {
+			return err
+		}
+		for _, o := range overlaps {
+			so.SubnetOverlaps = append(so.SubnetOverlaps, o.String())
+		}
+	}
 
 	if s.json {
-		return s.printJSON(ds, cs)
+		return s.printJSON(so)
 	}
-	s.printText(ds, cs)
+	s.printText(so)
 	return nil
 }
 
@@ -125,6 +151,9 @@ func (s *statusInfo) daemonStatus(ctx context.Context) (*daemonStatus, error) {
 		ds.Running = true
 		ds.Version = version.Version
 		ds.APIVersion = version.ApiVersion
+		for _, subnet := range status.Subnets {
+			ds.Subnets = append(ds.Subnets, iputil.IPNetFromRPC(subnet).String())
+		}
 		if obc := status.OutboundConfig; obc != nil {
 			ds.DNS = &daemonStatusDNS{}
 			dns := obc.Dns
@@ -224,11 +253,8 @@ func (s *statusInfo) connectorStatus(ctx context.Context) (*connectorStatus, err
 	return cs, nil
 }
 
-func (s *statusInfo) printJSON(ds *daemonStatus, cs *connectorStatus) error {
-	output, err := json.Marshal(statusOutput{
-		DaemonStatus: *ds,
-		UserDaemon:   *cs,
-	})
+func (s *statusInfo) printJSON(so *statusOutput) error {
+	output, err := json.Marshal(so)
 	if err != nil {
 		return err
 	}
@@ -236,9 +262,21 @@ func (s *statusInfo) printJSON(ds *daemonStatus, cs *connectorStatus) error {
 	return nil
 }
 
-func (s *statusInfo) printText(ds *daemonStatus, cs *connectorStatus) {
-	s.printDaemonText(ds)
-	s.printConnectorText(cs)
+func (s *statusInfo) printText(so *statusOutput) {
+	if so.Connection != client.DefaultConnectionName || len(so.OtherConnections) > 0 {
+		s.printf("Connection: %s\n", so.Connection)
+		if len(so.OtherConnections) > 0 {
+			s.printf("  Other connections: %s\n", strings.Join(so.OtherConnections, ", "))
+		}
+		if len(so.SubnetOverlaps) > 0 {
+			s.printf("  Overlapping subnets: (%d overlaps)\n", len(so.SubnetOverlaps))
+			for _, o := range so.SubnetOverlaps {
+				s.printf("    - %s\n", o)
+			}
+		}
+	}
+	s.printDaemonText(&so.DaemonStatus)
+	s.printConnectorText(&so.UserDaemon)
 }
 
 func (s *statusInfo) printDaemonText(ds *daemonStatus) {
@@ -254,6 +292,10 @@ func (s *statusInfo) printDaemonText(ds *daemonStatus) {
 			s.printf("    Exclude suffixes: %v\n", ds.DNS.ExcludeSuffixes)
 			s.printf("    Include suffixes: %v\n", ds.DNS.IncludeSuffixes)
 			s.printf("    Timeout         : %v\n", ds.DNS.LookupTimeout)
+			s.printf("  Subnets    : (%d subnets)\n", len(ds.Subnets))
+			for _, subnet := range ds.Subnets {
+				s.printf("    - %s\n", subnet)
+			}
 			s.printf("  Also Proxy : (%d subnets)\n", len(ds.AlsoProxySubnets))
 			for _, subnet := range ds.AlsoProxySubnets {
 				s.printf("    - %s\n", subnet)
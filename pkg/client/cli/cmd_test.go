@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_connectionNameFromArgs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		connect bool
+		want    string
+	}{
+		{"no flag", []string{"status"}, false, "default"},
+		{"connection", []string{"status", "--connection", "apps"}, false, "apps"},
+		{"connection with equal", []string{"intercept", "web", "--connection=apps", "--port", "8080"}, false, "apps"},
+		{"connect name", []string{"connect", "--name", "apps", "--context", "apps-ctx"}, true, "apps"},
+		{"name of other command", []string{"intercept", "web", "--name", "apps"}, false, "default"},
+		{"after double dash", []string{"connect", "--", "cmd", "--name", "apps"}, true, "default"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, connectionNameFromArgs(tc.args, tc.connect))
+		})
+	}
+}
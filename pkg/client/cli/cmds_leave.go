@@ -18,12 +18,21 @@ import (
 )
 
 func leaveCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:  "leave [flags] <intercept_name>",
-		Args: cobra.ExactArgs(1),
+	var workspaceFile, namespace string
+	cmd := &cobra.Command{
+		Use: "leave [flags] <intercept_name>",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if workspaceFile != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 
 		Short: "Remove existing intercept",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if workspaceFile != "" {
+				return removeWorkspaceIntercepts(cmd.Context(), workspaceFile, namespace)
+			}
 			return removeIntercept(cmd.Context(), strings.TrimSpace(args[0]))
 		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -71,6 +80,11 @@ func leaveCommand() *cobra.Command {
 			return completions, shellCompDir
 		},
 	}
+	cmd.Flags().StringVar(&workspaceFile, "file", "", ``+
+		`Remove all intercepts that are declared in this intercept workspace YAML file`)
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", ``+
+		`The namespace that was used when the intercepts of the --file were created`)
+	return cmd
 }
 
 // InterceptError inspects the .Error and .ErrorText fields in an InterceptResult and returns an
@@ -150,3 +164,35 @@ func removeIntercept(ctx context.Context, name string) error {
 		return nil
 	})
 }
+
+// removeWorkspaceIntercepts removes all intercepts declared in the given intercept workspace file, in reverse
+// order of declaration. Intercepts that don't exist are ignored.
+func removeWorkspaceIntercepts(ctx context.Context, file, namespace string) error {
+	ws, err := cliutil.LoadInterceptWorkspace(file, namespace)
+	if err != nil {
+		return err
+	}
+	return cliutil.WithStartedConnector(ctx, true, func(ctx context.Context, connectorClient connector.ConnectorClient) error {
+		var errs []error
+		for i := len(ws.Intercepts) - 1; i >= 0; i-- {
+			name := ws.Intercepts[i].InterceptName(namespace)
+			r, err := connectorClient.RemoveIntercept(dcontext.WithoutCancel(ctx), &manager.RemoveInterceptRequest2{Name: name})
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case r.Error == common.InterceptError_NOT_FOUND:
+				dlog.Debugf(ctx, "intercept %s not found", name)
+			case r.Error != common.InterceptError_UNSPECIFIED:
+				errs = append(errs, InterceptError(r))
+			}
+		}
+		switch len(errs) {
+		case 0:
+			return nil
+		case 1:
+			return errs[0]
+		default:
+			return fmt.Errorf("%w (and %d more errors)", errs[0], len(errs)-1)
+		}
+	})
+}
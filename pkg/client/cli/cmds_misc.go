@@ -3,6 +3,8 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
@@ -10,9 +12,12 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/dlib/dtime"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
@@ -54,6 +59,7 @@ func ClusterIdCommand() *cobra.Command {
 func connectCommand() *cobra.Command {
 	var kubeFlags *pflag.FlagSet
 	var request *connector.ConnectRequest
+	var inDocker bool
 
 	cmd := &cobra.Command{
 		Use:   "connect [flags] [-- <command to run while connected>]",
@@ -61,21 +67,58 @@ func connectCommand() *cobra.Command {
 		Short: "Connect to a cluster",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			request.KubeFlags = kubeFlagMap(kubeFlags)
+			if inDocker {
+				cmd.SetContext(docker.WithDaemonContainer(cmd.Context()))
+			}
 			if len(args) == 0 {
-				return withConnector(cmd, true, request, func(_ context.Context, _ *connectorState) error {
+				return withConnector(cmd, true, request, func(ctx context.Context, _ *connectorState) error {
+					reportSubnetOverlaps(ctx, cmd.ErrOrStderr())
 					return nil
 				})
 			}
 
 			return withConnector(cmd, false, request, func(ctx context.Context, _ *connectorState) error {
+				reportSubnetOverlaps(ctx, cmd.ErrOrStderr())
 				return proc.Run(ctx, nil, cmd, args[0], args[1:]...)
 			})
 		},
 	}
 	request, kubeFlags = initConnectRequest(cmd)
+
+	// The name is consumed by ConnectionName before the command line is parsed.
+	cmd.Flags().String("name", client.DefaultConnectionName, ``+
+		`The name of the connection. Connections with different names are managed side by side by `+
+		`their own daemons, and other commands select a connection using --connection`)
+	cmd.Flags().BoolVar(&inDocker, "docker", false, ``+
+		`Start the daemons in a docker container. The container has its own network, so the cluster is `+
+		`only reachable from the container and from containers started with intercept --docker-run`)
 	return cmd
 }
 
+// reportSubnetOverlaps writes a warning to the given writer for each subnet routed by the connection used by
+// the given context that overlaps with a subnet routed by another connection. The subnets are routed shortly
+// after the connection has been established, so this function waits a couple of seconds for them to appear.
+func reportSubnetOverlaps(ctx context.Context, w io.Writer) {
+	names, err := client.ConnectionNames()
+	if err != nil || len(names) < 2 {
+		return
+	}
+	for giveUp := time.Now().Add(5 * time.Second); time.Now().Before(giveUp); {
+		if subnets, err := cliutil.RoutedSubnets(ctx); err != nil || len(subnets) > 0 {
+			break
+		}
+		dtime.SleepWithContext(ctx, 250*time.Millisecond)
+	}
+	overlaps, err := cliutil.ConnectionSubnetOverlaps(ctx)
+	if err != nil {
+		dlog.Debugf(ctx, "unable to check for overlapping subnets: %v", err)
+		return
+	}
+	for _, o := range overlaps {
+		fmt.Fprintf(w, "Warning: %s. Traffic to the overlapping addresses is routed to only one of the clusters\n", o)
+	}
+}
+
 func initConnectRequest(cmd *cobra.Command) (*connector.ConnectRequest, *pflag.FlagSet) {
 	cr := connector.ConnectRequest{}
 	flags := cmd.Flags()
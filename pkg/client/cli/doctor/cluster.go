@@ -0,0 +1,381 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/k8s"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+// certExpiryWarning is how long before the webhook certificate expires that the webhook check starts warning.
+const certExpiryWarning = 7 * 24 * time.Hour
+
+func (d *Doctor) timeoutContext(ctx context.Context, timeoutID client.TimeoutID) (context.Context, context.CancelFunc) {
+	if cfg := client.GetConfig(ctx); cfg != nil {
+		return cfg.Timeouts.TimeoutContext(ctx, timeoutID)
+	}
+	return context.WithTimeout(ctx, 30*time.Second)
+}
+
+func checkKubeconfig(ctx context.Context, d *Doctor) *Result {
+	const hint = "Verify that kubectl can reach the cluster using the same kubeconfig and context, e.g. using " +
+		"`kubectl cluster-info`."
+	flags := make(map[string]string, len(d.kubeFlags)+1)
+	for k, v := range d.kubeFlags {
+		flags[k] = v
+	}
+	if kc, ok := os.LookupEnv("KUBECONFIG"); ok {
+		flags["KUBECONFIG"] = kc
+	}
+	cfg, err := k8s.NewConfig(ctx, flags)
+	if err != nil {
+		return failed(hint, "unable to load the kubeconfig: %v", err)
+	}
+	rc := rest.CopyConfig(cfg.RestConfig)
+	if c := client.GetConfig(ctx); c != nil {
+		rc.Timeout = c.Timeouts.Get(client.TimeoutClusterConnect)
+	}
+	ki, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return failed(hint, "unable to create a Kubernetes client: %v", err)
+	}
+	v, err := ki.Discovery().ServerVersion()
+	if err != nil {
+		return failed(hint, "unable to reach the cluster of context %q at %s: %v", cfg.Context, cfg.Server, err)
+	}
+	if d.ki, err = kubernetes.NewForConfig(cfg.RestConfig); err != nil {
+		return failed(hint, "unable to create a Kubernetes client: %v", err)
+	}
+	d.namespace = cfg.Namespace
+	if ns := d.kubeFlags["namespace"]; ns != "" {
+		d.namespace = ns
+	}
+	d.managerNamespace = cfg.GetManagerNamespace()
+	d.restConfig = cfg.RestConfig
+	return ok("context %q, cluster %s, Kubernetes %s", cfg.Context, cfg.Server, v.GitVersion)
+}
+
+// permission is a permission that the client needs in order to connect and intercept.
+type permission struct {
+	namespace string
+	group     string
+	resource  string
+	verb      string
+
+	// optional permissions give a warning rather than a failure when they're missing.
+	optional bool
+}
+
+func (p *permission) String() string {
+	s := p.verb + " " + p.resource
+	if p.group != "" {
+		s += "." + p.group
+	}
+	if p.namespace != "" {
+		s += " in namespace " + p.namespace
+	}
+	return s
+}
+
+// clientPermissions returns the permissions that the client needs in the given namespace and in the namespace of
+// the traffic-manager. They correspond to the permissions that the Helm chart grants when clientRbac is enabled.
+func clientPermissions(namespace, managerNamespace string) []*permission {
+	var ps []*permission
+	add := func(ns, group, resource string, verbs ...string) {
+		for _, verb := range verbs {
+			ps = append(ps, &permission{namespace: ns, group: group, resource: resource, verb: verb})
+		}
+	}
+	add(managerNamespace, "", "pods", "get", "list", "watch")
+	add(managerNamespace, "", "pods/portforward", "create")
+	add(namespace, "", "services", "get", "list", "watch")
+	add(namespace, "", "pods", "get", "list")
+	add(namespace, "", "pods/log", "get")
+	for _, r := range []string{"deployments", "replicasets", "statefulsets"} {
+		add(namespace, "apps", r, "get", "list", "watch")
+	}
+	add("", "", "namespaces", "list", "watch")
+	ps[len(ps)-1].optional = true
+	ps[len(ps)-2].optional = true
+	return ps
+}
+
+func checkRBAC(ctx context.Context, d *Doctor) *Result {
+	tc, cancel := d.timeoutContext(ctx, client.TimeoutTrafficManagerAPI)
+	defer cancel()
+	var missing, missingOptional []string
+	for _, p := range clientPermissions(d.namespace, d.managerNamespace) {
+		resource, subresource := p.resource, ""
+		if slash := strings.IndexByte(resource, '/'); slash > 0 {
+			resource, subresource = resource[:slash], resource[slash+1:]
+		}
+		ssar, err := d.ki.AuthorizationV1().SelfSubjectAccessReviews().Create(tc, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   p.namespace,
+					Verb:        p.verb,
+					Group:       p.group,
+					Resource:    resource,
+					Subresource: subresource,
+				},
+			},
+		}, meta.CreateOptions{})
+		if err != nil {
+			return warning("", "unable to review the permissions of the user: %v", err)
+		}
+		if !ssar.Status.Allowed {
+			if p.optional {
+				missingOptional = append(missingOptional, p.String())
+			} else {
+				missing = append(missing, p.String())
+			}
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		return failed("Ask a cluster administrator to grant the missing permissions, e.g. by installing the traffic-manager "+
+			"with the Helm chart value clientRbac.create=true and the user in clientRbac.subjects.",
+			"the user lacks permissions that Telepresence needs: %s", strings.Join(missing, ", "))
+	case len(missingOptional) > 0:
+		return warning("Use `telepresence connect --mapped-namespaces` to limit Telepresence to the namespaces that the user "+
+			"has access to.",
+			"the user can't %s, so Telepresence can't discover the namespaces of the cluster", strings.Join(missingOptional, " or "))
+	default:
+		return ok("the user has the permissions that Telepresence needs in namespaces %s and %s",
+			d.namespace, d.managerNamespace)
+	}
+}
+
+func checkTrafficManager(ctx context.Context, d *Doctor) *Result {
+	mns := d.managerNamespace
+	tc, cancel := d.timeoutContext(ctx, client.TimeoutTrafficManagerConnect)
+	defer cancel()
+	pods, err := d.ki.CoreV1().Pods(mns).List(tc, meta.ListOptions{LabelSelector: "app=traffic-manager"})
+	if err != nil {
+		return failed("Verify that the user has permission to list pods in namespace "+mns+".",
+			"unable to list the traffic-manager pods in namespace %s: %v", mns, err)
+	}
+	if len(pods.Items) == 0 {
+		return failed("Install the traffic-manager using `telepresence helm install`. If it's installed in another namespace, "+
+			"set the manager namespace in the kubeconfig extension or in TELEPRESENCE_MANAGER_NAMESPACE.",
+			"no traffic-manager found in namespace %s", mns)
+	}
+	for i := range pods.Items {
+		if isPodReady(&pods.Items[i]) {
+			d.managerPod = &pods.Items[i]
+			break
+		}
+	}
+	if d.managerPod == nil {
+		return failed(fmt.Sprintf("Check the state of the traffic-manager using `kubectl -n %s describe pod -l app=traffic-manager`.", mns),
+			"the traffic-manager in namespace %s has no ready pod", mns)
+	}
+
+	const hint = "Verify that the user can port-forward to pods in the traffic-manager namespace. Check the logs of the " +
+		"traffic-manager using `kubectl -n %s logs -l app=traffic-manager`."
+	dialer, err := dnet.NewK8sPortForwardDialer(ctx, d.restConfig, d.ki)
+	if err != nil {
+		return failed(fmt.Sprintf(hint, mns), "unable to create a port-forward dialer: %v", err)
+	}
+	conn, err := grpc.DialContext(tc, net.JoinHostPort("svc/traffic-manager."+mns, fmt.Sprint(install.ManagerPortHTTP)),
+		grpc.WithContextDialer(dialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithNoProxy(),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError())
+	if err != nil {
+		return failed(fmt.Sprintf(hint, mns), "unable to reach the traffic-manager in namespace %s: %v", mns, err)
+	}
+	d.managerConn = conn
+	d.dial = dialer
+	mc := manager.NewManagerClient(conn)
+	vi, err := mc.Version(tc, &empty.Empty{})
+	if err != nil {
+		return failed(fmt.Sprintf(hint, mns), "unable to get the version of the traffic-manager: %v", err)
+	}
+	if v, err := semver.Parse(strings.TrimPrefix(vi.Version, "v")); err == nil {
+		d.managerVersion = &v
+	}
+
+	// The cluster info is sent as soon as the stream is established. It's only used by the subnets check, so
+	// errors are ignored here.
+	wc, wCancel := context.WithCancel(tc)
+	defer wCancel()
+	if stream, err := mc.WatchClusterInfo(wc, &manager.SessionInfo{}); err == nil {
+		d.clusterInfo, _ = stream.Recv()
+	}
+	return ok("traffic-manager %s is reachable in namespace %s", vi.Version, mns)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func checkVersionSkew(_ context.Context, d *Doctor) *Result {
+	return versionSkew(client.Version(), d.managerVersion)
+}
+
+func versionSkew(clientVersion string, managerVersion *semver.Version) *Result {
+	cv, err := semver.Parse(strings.TrimPrefix(clientVersion, "v"))
+	if err != nil || managerVersion == nil {
+		return skipped("unable to compare client version %s with the traffic-manager version", clientVersion)
+	}
+	mv := *managerVersion
+	const hint = "Upgrade the traffic-manager using `telepresence helm install --upgrade`, or install a client of the same version as the " +
+		"traffic-manager."
+	switch {
+	case cv.Major != mv.Major:
+		return failed(hint, "client version v%s and traffic-manager version v%s are incompatible", cv, mv)
+	case cv.Minor != mv.Minor:
+		return warning(hint, "client version v%s and traffic-manager version v%s differ in their minor version", cv, mv)
+	default:
+		return ok("client version v%s is compatible with traffic-manager version v%s", cv, mv)
+	}
+}
+
+// envValue returns the value of the environment variable with the given name in the first container of the
+// given pod.
+func envValue(pod *corev1.Pod, name string) string {
+	if len(pod.Spec.Containers) == 0 {
+		return ""
+	}
+	for _, e := range pod.Spec.Containers[0].Env {
+		if e.Name == name {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+func checkWebhook(ctx context.Context, d *Doctor) *Result {
+	mns := d.managerNamespace
+	name := envValue(d.managerPod, "AGENT_INJECTOR_WEBHOOK_NAME")
+	if name == "" {
+		name = "agent-injector-webhook-" + mns
+	}
+	const hint = "Reinstall the traffic-manager using `telepresence helm install --upgrade` to recreate the agent-injector webhook."
+	tc, cancel := d.timeoutContext(ctx, client.TimeoutTrafficManagerAPI)
+	defer cancel()
+
+	// Users that lack permission to read the webhook configuration can still verify that the webhook serves a
+	// valid certificate, but not that it's signed by the CA that the API server trusts.
+	svcName, svcNamespace, port := "agent-injector", mns, int32(443)
+	var caBundle []byte
+	var certificate string
+	mwc, err := d.ki.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(tc, name, meta.GetOptions{})
+	switch {
+	case err == nil:
+		certificate = mwc.Annotations[certManagerInjectAnnotation]
+		for i := range mwc.Webhooks {
+			if svc := mwc.Webhooks[i].ClientConfig.Service; svc != nil {
+				svcName, svcNamespace = svc.Name, svc.Namespace
+				if svc.Port != nil {
+					port = *svc.Port
+				}
+				caBundle = mwc.Webhooks[i].ClientConfig.CABundle
+				break
+			}
+		}
+	case k8serrors.IsNotFound(err):
+		return failed(hint, "the mutating webhook configuration %s doesn't exist", name)
+	case !k8serrors.IsForbidden(err):
+		return failed(hint, "unable to get the mutating webhook configuration %s: %v", name, err)
+	}
+
+	conn, err := d.dial(tc, net.JoinHostPort(fmt.Sprintf("svc/%s.%s", svcName, svcNamespace), fmt.Sprint(port)))
+	if err != nil {
+		return failed(fmt.Sprintf("Check the state of the traffic-manager using `kubectl -n %s describe pod -l app=traffic-manager`.", mns),
+			"unable to reach the agent-injector webhook service %s.%s: %v", svcName, svcNamespace, err)
+	}
+	defer conn.Close()
+	tlsConfig := &tls.Config{ServerName: svcName + "." + svcNamespace + ".svc"}
+	if len(caBundle) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBundle) {
+			return failed(hint, "the CA bundle of the mutating webhook configuration %s is invalid", name)
+		}
+	} else {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err = tlsConn.HandshakeContext(tc); err != nil {
+		var ue x509.UnknownAuthorityError
+		var ie x509.CertificateInvalidError
+		if errors.As(err, &ue) || errors.As(err, &ie) {
+			return failed(certRenewalHint(d.managerPod, certificate), "the certificate of the agent-injector webhook isn't valid: %v", err)
+		}
+		return failed(hint, "TLS handshake with the agent-injector webhook failed: %v", err)
+	}
+	return certExpiry(tlsConn.ConnectionState().PeerCertificates[0], time.Now(), len(caBundle) > 0, certRenewalHint(d.managerPod, certificate))
+}
+
+// certManagerInjectAnnotation is the annotation of a webhook configuration whose CA bundle is injected by
+// cert-manager. Its value is the namespace and name of the Certificate.
+const certManagerInjectAnnotation = "cert-manager.io/inject-ca-from"
+
+// certRenewalHint returns the hint for a webhook certificate that is invalid or about to expire. The given
+// certificate is the Certificate that cert-manager issues the webhook's certificate from, if any. Otherwise,
+// the traffic-manager rotates the certificate when it expires within its AGENT_INJECTOR_CERT_RENEW_BEFORE.
+func certRenewalHint(managerPod *corev1.Pod, certificate string) string {
+	if certificate != "" {
+		return fmt.Sprintf("The certificate of the webhook is issued by cert-manager. Check the state of the Certificate %s "+
+			"using `kubectl describe certificate`, and the logs of cert-manager.", certificate)
+	}
+	renewBefore := "720h"
+	if managerPod != nil {
+		if v := envValue(managerPod, "AGENT_INJECTOR_CERT_RENEW_BEFORE"); v != "" {
+			renewBefore = v
+		}
+	}
+	if dur, err := time.ParseDuration(renewBefore); err == nil && dur <= 0 {
+		return "The traffic-manager doesn't rotate the certificate of the webhook because its AGENT_INJECTOR_CERT_RENEW_BEFORE is 0. " +
+			"Set the Helm chart value `agentInjector.certificate.renewBefore`, or set `agentInjector.certificate.regenerate` to true " +
+			"to replace the certificate once, using `telepresence helm install --upgrade`."
+	}
+	return fmt.Sprintf("The traffic-manager rotates the certificate of the webhook when it expires within its "+
+		"AGENT_INJECTOR_CERT_RENEW_BEFORE (%s), so check its logs. To replace the certificate now, set the Helm chart value "+
+		"`agentInjector.certificate.regenerate` to true using `telepresence helm install --upgrade`.", renewBefore)
+}
+
+// certExpiry checks the expiry of the certificate that the webhook serves. The given hint tells how the
+// certificate is renewed.
+func certExpiry(cert *x509.Certificate, now time.Time, verified bool, hint string) *Result {
+	expires := cert.NotAfter.UTC().Format(time.RFC3339)
+	switch left := cert.NotAfter.Sub(now); {
+	case left <= 0:
+		return failed(hint, "the certificate of the agent-injector webhook expired at %s", expires)
+	case left < certExpiryWarning:
+		return warning(hint, "the certificate of the agent-injector webhook expires soon, at %s", expires)
+	case verified:
+		return ok("the agent-injector webhook is healthy and its certificate is valid until %s", expires)
+	default:
+		return ok("the agent-injector webhook is healthy and its certificate is valid until %s (the CA bundle couldn't be read)", expires)
+	}
+}
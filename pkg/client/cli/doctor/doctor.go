@@ -0,0 +1,175 @@
+// Package doctor contains the checks that are run by the "telepresence doctor" command. Each check validates one
+// aspect of the setup that Telepresence depends on, such as the kubeconfig, the traffic-manager, or the local DNS
+// configuration, and results in a Result that describes what was found and how a problem can be remedied.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/blang/semver"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// StatusOK means that the check passed.
+	StatusOK Status = "ok"
+
+	// StatusWarning means that the check found something that might cause problems, or that prevents some
+	// features from being used.
+	StatusWarning Status = "warning"
+
+	// StatusFailed means that the check found a problem that prevents Telepresence from working.
+	StatusFailed Status = "failed"
+
+	// StatusSkipped means that the check couldn't be run, typically because a check that it depends on failed.
+	StatusSkipped Status = "skipped"
+)
+
+func (s Status) symbol() string {
+	switch s {
+	case StatusOK:
+		return "✅"
+	case StatusWarning:
+		return "⚠️ "
+	case StatusFailed:
+		return "❌"
+	default:
+		return "➖"
+	}
+}
+
+// Result is the result of one check.
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+
+	// Hint describes how a problem that the check found can be remedied.
+	Hint string `json:"hint,omitempty"`
+}
+
+func ok(format string, args ...any) *Result {
+	return &Result{Status: StatusOK, Message: fmt.Sprintf(format, args...)}
+}
+
+func warning(hint, format string, args ...any) *Result {
+	return &Result{Status: StatusWarning, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+func failed(hint, format string, args ...any) *Result {
+	return &Result{Status: StatusFailed, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+func skipped(format string, args ...any) *Result {
+	return &Result{Status: StatusSkipped, Message: fmt.Sprintf(format, args...)}
+}
+
+// Report is the result of all checks.
+type Report struct {
+	Results []*Result `json:"results"`
+}
+
+// Failed returns the number of checks that failed.
+func (r *Report) Failed() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes a human readable version of the report to the given writer.
+func (r *Report) Write(w io.Writer) {
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "%s %s: %s\n", res.Status.symbol(), res.Check, res.Message)
+		if res.Hint != "" {
+			fmt.Fprintf(w, "   %s\n", res.Hint)
+		}
+	}
+}
+
+// check is a named check. The run function is only called when all the checks that the check needs have passed.
+type check struct {
+	name  string
+	needs []string
+	run   func(context.Context, *Doctor) *Result
+}
+
+// Doctor runs the checks. The state that it collects when running one check, like the connection to the cluster, is
+// made available to the checks that follow.
+type Doctor struct {
+	kubeFlags map[string]string
+
+	namespace        string
+	managerNamespace string
+	restConfig       *rest.Config
+	ki               kubernetes.Interface
+	managerPod       *corev1.Pod
+	dial             func(context.Context, string) (net.Conn, error)
+	managerConn      *grpc.ClientConn
+	managerVersion   *semver.Version
+	clusterInfo      *manager.ClusterInfo
+}
+
+// NewDoctor returns a Doctor that uses the given kubectl flags when connecting to the cluster.
+func NewDoctor(kubeFlags map[string]string) *Doctor {
+	return &Doctor{kubeFlags: kubeFlags}
+}
+
+// checks returns all the checks, in the order that they are run.
+func checks() []*check {
+	return []*check{
+		{name: "kubeconfig", run: checkKubeconfig},
+		{name: "rbac", needs: []string{"kubeconfig"}, run: checkRBAC},
+		{name: "traffic-manager", needs: []string{"kubeconfig"}, run: checkTrafficManager},
+		{name: "version-skew", needs: []string{"traffic-manager"}, run: checkVersionSkew},
+		{name: "webhook", needs: []string{"traffic-manager"}, run: checkWebhook},
+		{name: "agent-image", needs: []string{"traffic-manager"}, run: checkAgentImage},
+		{name: "dns", run: checkDNS},
+		{name: "subnets", needs: []string{"traffic-manager"}, run: checkSubnets},
+		{name: "sshfs", run: checkSSHFS},
+		{name: "docker", run: checkDocker},
+	}
+}
+
+// Run runs all checks and returns the report.
+func (d *Doctor) Run(ctx context.Context) *Report {
+	defer func() {
+		if d.managerConn != nil {
+			d.managerConn.Close()
+		}
+	}()
+	report := &Report{}
+	status := make(map[string]Status)
+	for _, c := range checks() {
+		var res *Result
+		for _, need := range c.needs {
+			if status[need] != StatusOK && status[need] != StatusWarning {
+				res = skipped("requires a successful %s check", need)
+				break
+			}
+		}
+		if res == nil {
+			dlog.Debugf(ctx, "running check %s", c.name)
+			res = c.run(ctx, d)
+		}
+		res.Check = c.name
+		status[c.name] = res.Status
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
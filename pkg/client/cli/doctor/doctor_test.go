@@ -0,0 +1,211 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/vif/routing"
+)
+
+func TestCheckRBAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		denied  func(ra *authorizationv1.ResourceAttributes) bool
+		status  Status
+		message string
+	}{
+		{
+			"all permissions",
+			func(ra *authorizationv1.ResourceAttributes) bool { return false },
+			StatusOK,
+			"the user has the permissions that Telepresence needs in namespaces default and ambassador",
+		},
+		{
+			"no port-forward",
+			func(ra *authorizationv1.ResourceAttributes) bool { return ra.Subresource == "portforward" },
+			StatusFailed,
+			"create pods/portforward in namespace ambassador",
+		},
+		{
+			"no namespaces",
+			func(ra *authorizationv1.ResourceAttributes) bool { return ra.Resource == "namespaces" },
+			StatusWarning,
+			"the user can't list namespaces or watch namespaces",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ki := fake.NewSimpleClientset()
+			ki.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				ssar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				ssar.Status.Allowed = !tt.denied(ssar.Spec.ResourceAttributes)
+				return true, ssar, nil
+			})
+			d := &Doctor{namespace: "default", managerNamespace: "ambassador", ki: ki}
+			res := checkRBAC(context.Background(), d)
+			assert.Equal(t, tt.status, res.Status)
+			assert.Contains(t, res.Message, tt.message)
+		})
+	}
+}
+
+func TestCheckTrafficManager_notFound(t *testing.T) {
+	ki := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "traffic-manager-1", Namespace: "other", Labels: map[string]string{"app": "traffic-manager"}},
+	})
+	d := &Doctor{namespace: "default", managerNamespace: "ambassador", ki: ki}
+	res := checkTrafficManager(context.Background(), d)
+	assert.Equal(t, StatusFailed, res.Status)
+	assert.Equal(t, "no traffic-manager found in namespace ambassador", res.Message)
+	assert.Contains(t, res.Hint, "telepresence helm install")
+}
+
+func TestVersionSkew(t *testing.T) {
+	mv := semver.MustParse("2.7.3")
+	assert.Equal(t, StatusOK, versionSkew("v2.7.1", &mv).Status)
+	assert.Equal(t, StatusWarning, versionSkew("v2.6.8", &mv).Status)
+	assert.Equal(t, StatusFailed, versionSkew("v3.0.0", &mv).Status)
+	assert.Equal(t, StatusSkipped, versionSkew("v2.7.1", nil).Status)
+	assert.Equal(t, StatusSkipped, versionSkew("devel", &mv).Status)
+}
+
+func TestCertExpiry(t *testing.T) {
+	now := time.Now()
+	cert := func(notAfter time.Time) *x509.Certificate {
+		return &x509.Certificate{NotAfter: notAfter}
+	}
+	assert.Equal(t, StatusOK, certExpiry(cert(now.Add(90*24*time.Hour)), now, true, "").Status)
+	assert.Equal(t, StatusWarning, certExpiry(cert(now.Add(24*time.Hour)), now, true, "").Status)
+	assert.Equal(t, StatusFailed, certExpiry(cert(now.Add(-time.Hour)), now, true, "").Status)
+}
+
+func TestCertRenewalHint(t *testing.T) {
+	pod := func(renewBefore string) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Env: []corev1.EnvVar{{Name: "AGENT_INJECTOR_CERT_RENEW_BEFORE", Value: renewBefore}},
+		}}}}
+	}
+	hint := certRenewalHint(pod("0"), "ambassador/agent-injector-webhook")
+	assert.Contains(t, hint, "cert-manager")
+	assert.Contains(t, hint, "ambassador/agent-injector-webhook")
+
+	hint = certRenewalHint(pod("0"), "")
+	assert.Contains(t, hint, "AGENT_INJECTOR_CERT_RENEW_BEFORE is 0")
+	assert.Contains(t, hint, "telepresence helm install --upgrade")
+
+	hint = certRenewalHint(pod("48h"), "")
+	assert.Contains(t, hint, "(48h)")
+	assert.Contains(t, hint, "agentInjector.certificate.regenerate")
+
+	assert.Contains(t, certRenewalHint(nil, ""), "(720h)")
+}
+
+func TestSubnetConflicts(t *testing.T) {
+	ipNet := func(s string) *net.IPNet {
+		_, n, err := net.ParseCIDR(s)
+		require.NoError(t, err)
+		return n
+	}
+	eth0 := &net.Interface{Name: "eth0", Flags: net.FlagUp}
+	routes := []*routing.Route{
+		{RoutedNet: ipNet("0.0.0.0/0"), Interface: eth0, Default: true},
+		{RoutedNet: ipNet("192.168.1.0/24"), Interface: eth0},
+		{RoutedNet: ipNet("10.0.0.0/8"), Interface: &net.Interface{Name: "vpn0", Flags: net.FlagUp}},
+		{RoutedNet: ipNet("10.96.0.0/12"), Interface: &net.Interface{Name: "tel0", Flags: net.FlagUp}},
+		{RoutedNet: ipNet("127.0.0.0/8"), Interface: &net.Interface{Name: "lo", Flags: net.FlagUp | net.FlagLoopback}},
+	}
+	conflicts := subnetConflicts([]*net.IPNet{ipNet("10.244.0.0/16"), ipNet("172.20.0.0/16")}, routes)
+	assert.Equal(t, []string{"10.244.0.0/16 overlaps 10.0.0.0/8 routed via vpn0"}, conflicts)
+	assert.Empty(t, subnetConflicts([]*net.IPNet{ipNet("172.20.0.0/16")}, routes))
+}
+
+func TestAgentImage(t *testing.T) {
+	pod := func(env ...corev1.EnvVar) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "traffic-manager", Env: env}}}}
+	}
+	mv := semver.MustParse("2.7.3")
+	assert.Equal(t, "docker.io/datawire/tel2:2.7.3", agentImage(pod(), &mv))
+	assert.Equal(t, "", agentImage(pod(), nil))
+	assert.Equal(t, "example.com/team/agent:1.0", agentImage(pod(
+		corev1.EnvVar{Name: "TELEPRESENCE_REGISTRY", Value: "example.com/team"},
+		corev1.EnvVar{Name: "TELEPRESENCE_AGENT_IMAGE", Value: "agent:1.0"},
+	), &mv))
+}
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		image string
+		want  imageRef
+	}{
+		{"docker.io/datawire/tel2:2.7.3", imageRef{"registry-1.docker.io", "datawire/tel2", "2.7.3"}},
+		{"nginx", imageRef{"registry-1.docker.io", "library/nginx", "latest"}},
+		{"localhost:5000/tel2", imageRef{"localhost:5000", "tel2", "latest"}},
+		{"ghcr.io/org/tel2@sha256:abc", imageRef{"ghcr.io", "org/tel2", "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, *parseImageRef(tt.image))
+		})
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:datawire/tel2:pull,push",
+	}, parseChallenge(`realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:datawire/tel2:pull,push"`))
+}
+
+func TestProbeImage(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.Equal(t, "repository:datawire/tel2:pull", r.URL.Query().Get("scope"))
+			_, _ = w.Write([]byte(`{"token":"t0k3n"}`))
+		case r.Header.Get("Authorization") != "Bearer t0k3n":
+			w.Header().Set("WWW-Authenticate",
+				`Bearer realm="`+srv.URL+`/token",service="test",scope="repository:datawire/tel2:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/datawire/tel2/manifests/2.7.3":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	registry := strings.TrimPrefix(srv.URL, "https://")
+	ctx := context.Background()
+	assert.NoError(t, probeImage(ctx, srv.Client(), registry+"/datawire/tel2:2.7.3"))
+	assert.ErrorIs(t, probeImage(ctx, srv.Client(), registry+"/datawire/tel2:0.0.0"), errImageNotFound)
+}
+
+func TestReport(t *testing.T) {
+	r := &Report{Results: []*Result{
+		{Check: "kubeconfig", Status: StatusOK, Message: "fine"},
+		{Check: "rbac", Status: StatusFailed, Message: "missing", Hint: "grant it"},
+		{Check: "webhook", Status: StatusSkipped, Message: "requires a successful rbac check"},
+	}}
+	assert.Equal(t, 1, r.Failed())
+	var buf bytes.Buffer
+	r.Write(&buf)
+	assert.Equal(t, "✅ kubeconfig: fine\n❌ rbac: missing\n   grant it\n➖ webhook: requires a successful rbac check\n", buf.String())
+}
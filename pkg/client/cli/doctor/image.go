@@ -0,0 +1,244 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blang/semver"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+var (
+	errImageNotFound     = errors.New("the image doesn't exist")
+	errImageUnauthorized = errors.New("the registry requires credentials to pull the image")
+)
+
+// manifestMediaTypes are the media types of the manifests that a registry may return for an image.
+var manifestMediaTypes = strings.Join([]string{ //nolint:gochecknoglobals // constant
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}, ", ")
+
+// agentImage returns the image that the traffic-manager injects as the traffic-agent, based on the environment of
+// the given traffic-manager pod.
+func agentImage(pod *corev1.Pod, managerVersion *semver.Version) string {
+	img := envValue(pod, "TELEPRESENCE_AGENT_IMAGE")
+	if img == "" {
+		if managerVersion == nil {
+			return ""
+		}
+		img = "tel2:" + managerVersion.String()
+	}
+	registry := envValue(pod, "TELEPRESENCE_REGISTRY")
+	if registry == "" {
+		registry = "docker.io/datawire"
+	}
+	return registry + "/" + img
+}
+
+func checkAgentImage(ctx context.Context, d *Doctor) *Result {
+	image := agentImage(d.managerPod, d.managerVersion)
+	if image == "" {
+		return skipped("unable to determine the traffic-agent image")
+	}
+	tc, cancel := d.timeoutContext(ctx, client.TimeoutTrafficManagerAPI)
+	defer cancel()
+
+	// Pods that already fail to pull the agent image is the most reliable indication that the cluster can't pull it.
+	if pods, err := d.ki.CoreV1().Pods(d.namespace).List(tc, meta.ListOptions{}); err == nil {
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			for j := range pod.Status.ContainerStatuses {
+				cs := &pod.Status.ContainerStatuses[j]
+				if cs.Name != agentconfig.ContainerName || cs.State.Waiting == nil {
+					continue
+				}
+				if r := cs.State.Waiting.Reason; r == "ErrImagePull" || r == "ImagePullBackOff" {
+					return failed("Verify that the cluster can reach the registry, and that the agent image is configured correctly "+
+						"using the Helm chart values agentInjector.agentImage.*.",
+						"the traffic-agent of pod %s.%s can't pull image %s: %s", pod.Name, pod.Namespace, cs.Image, cs.State.Waiting.Message)
+				}
+			}
+		}
+	}
+
+	err := probeImage(tc, http.DefaultClient, image)
+	switch {
+	case err == nil:
+		return ok("image %s is available", image)
+	case errors.Is(err, errImageNotFound):
+		return failed("Configure the agent image using the Helm chart values agentInjector.agentImage.*.",
+			"image %s: %v", image, err)
+	case errors.Is(err, errImageUnauthorized):
+		return warning("Verify that the cluster has the credentials that are needed to pull the image.",
+			"image %s: %v", image, err)
+	default:
+		return warning("Verify that the cluster can pull the image; the registry might be unreachable from this workstation only.",
+			"unable to verify image %s: %v", image, err)
+	}
+}
+
+// imageRef is a parsed image reference.
+type imageRef struct {
+	registry   string
+	repository string
+	reference  string
+}
+
+func parseImageRef(image string) *imageRef {
+	ref := &imageRef{registry: "docker.io"}
+	name := image
+	if slash := strings.IndexByte(name, '/'); slash > 0 {
+		if host := name[:slash]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.registry = host
+			name = name[slash+1:]
+		}
+	}
+	if at := strings.IndexByte(name, '@'); at > 0 {
+		ref.reference = name[at+1:]
+		name = name[:at]
+	} else if colon := strings.LastIndexByte(name, ':'); colon > strings.LastIndexByte(name, '/') {
+		ref.reference = name[colon+1:]
+		name = name[:colon]
+	} else {
+		ref.reference = "latest"
+	}
+	if ref.registry == "docker.io" || ref.registry == "index.docker.io" {
+		ref.registry = "registry-1.docker.io"
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	ref.repository = name
+	return ref
+}
+
+// probeImage uses the registry API to verify that the given image exists and can be pulled anonymously.
+func probeImage(ctx context.Context, hc *http.Client, image string) error {
+	ref := parseImageRef(image)
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.registry, ref.repository, ref.reference)
+	status, challenge, err := headManifest(ctx, hc, manifestURL, "")
+	if err != nil {
+		return err
+	}
+	if status == http.StatusUnauthorized && strings.HasPrefix(challenge, "Bearer ") {
+		// The registry wants a token. Public images can be pulled using an anonymous one.
+		token, err := anonymousToken(ctx, hc, challenge)
+		if err != nil {
+			return err
+		}
+		if status, _, err = headManifest(ctx, hc, manifestURL, token); err != nil {
+			return err
+		}
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return errImageNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errImageUnauthorized
+	default:
+		return fmt.Errorf("unexpected status %d from registry %s", status, ref.registry)
+	}
+}
+
+func headManifest(ctx context.Context, hc *http.Client, manifestURL, token string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Accept", manifestMediaTypes)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("WWW-Authenticate"), nil
+}
+
+// anonymousToken obtains an anonymous token from the token server of the given bearer challenge.
+func anonymousToken(ctx context.Context, hc *http.Client, challenge string) (string, error) {
+	params := parseChallenge(strings.TrimPrefix(challenge, "Bearer "))
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("invalid authentication challenge %q from registry", challenge)
+	}
+	q := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if v, ok := params[k]; ok {
+			q.Set(k, v)
+		}
+	}
+	tokenURL := realm
+	if len(q) > 0 {
+		tokenURL += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errImageUnauthorized
+	}
+	var tr struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("invalid token response from %s: %w", realm, err)
+	}
+	if tr.Token == "" {
+		tr.Token = tr.AccessToken
+	}
+	return tr.Token, nil
+}
+
+// parseChallenge parses the comma separated key="value" parameters of an authentication challenge.
+func parseChallenge(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				break
+			}
+			value = s[1 : end+1]
+			s = s[end+2:]
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			value = s[:comma]
+			s = s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		params[key] = value
+		s = strings.TrimPrefix(strings.TrimSpace(s), ",")
+	}
+	return params
+}
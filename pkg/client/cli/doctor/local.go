@@ -0,0 +1,112 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif/routing"
+)
+
+func checkSubnets(ctx context.Context, d *Doctor) *Result {
+	ci := d.clusterInfo
+	if ci == nil {
+		return skipped("the traffic-manager didn't provide the subnets of the cluster")
+	}
+	var subnets []*net.IPNet
+	for _, sn := range ci.PodSubnets {
+		subnets = append(subnets, iputil.IPNetFromRPC(sn))
+	}
+	if ci.ServiceSubnet != nil {
+		subnets = append(subnets, iputil.IPNetFromRPC(ci.ServiceSubnet))
+	}
+	if len(subnets) == 0 {
+		return skipped("the traffic-manager didn't provide the subnets of the cluster")
+	}
+	routes, err := routing.GetRoutingTable(ctx)
+	if err != nil {
+		return warning("", "unable to get the routing table: %v", err)
+	}
+	if conflicts := subnetConflicts(subnets, routes); len(conflicts) > 0 {
+		return failed("Run `telepresence test-vpn` to diagnose conflicts with a VPN, and see "+
+			"https://www.telepresence.io/docs/latest/reference/vpn for how to resolve them.",
+			"cluster subnets conflict with local routes: %s", strings.Join(conflicts, ", "))
+	}
+	sns := make([]string, len(subnets))
+	for i, sn := range subnets {
+		sns[i] = sn.String()
+	}
+	return ok("cluster subnets %s don't conflict with local routes", strings.Join(sns, ", "))
+}
+
+// subnetConflicts returns a description of each route that overlaps one of the given subnets. Default routes,
+// loopback routes, and routes that are added by Telepresence itself are ignored.
+func subnetConflicts(subnets []*net.IPNet, routes []*routing.Route) []string {
+	var conflicts []string
+	for _, rt := range routes {
+		if rt.Default || rt.RoutedNet == nil {
+			continue
+		}
+		if ones, _ := rt.RoutedNet.Mask.Size(); ones == 0 {
+			continue
+		}
+		ifName := ""
+		if iface := rt.Interface; iface != nil {
+			if iface.Flags&net.FlagLoopback != 0 || strings.HasPrefix(iface.Name, "tel") {
+				continue
+			}
+			ifName = iface.Name
+		}
+		for _, sn := range subnets {
+			if rt.Routes(sn.IP) || sn.Contains(rt.RoutedNet.IP) {
+				conflicts = append(conflicts, fmt.Sprintf("%s overlaps %s routed via %s", sn, rt.RoutedNet, ifName))
+			}
+		}
+	}
+	return conflicts
+}
+
+func checkSSHFS(ctx context.Context, _ *Doctor) *Result {
+	const hint = "Install sshfs to make the volumes of intercepted containers available locally, see " +
+		"https://www.telepresence.io/docs/latest/reference/volume."
+	tc, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var cmd *dexec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = proc.CommandContext(tc, "sshfs-win", "cmd", "-V")
+	} else {
+		cmd = proc.CommandContext(tc, "sshfs", "-V")
+	}
+	cmd.DisableLogging = true
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return warning(hint, "sshfs isn't installed, so the volumes of intercepted containers can't be mounted")
+	}
+	if bytes.Contains(out, []byte("OSXFUSE")) {
+		return warning("Upgrade to macFUSE 4.0.5 or higher.", "the installed OSXFUSE is known to cause kernel crashes, so volumes won't be mounted")
+	}
+	return checkFUSE()
+}
+
+func checkDocker(ctx context.Context, _ *Doctor) *Result {
+	const hint = "Docker is only needed when using `telepresence connect --docker` or `telepresence intercept --docker-run`."
+	if _, err := dexec.LookPath("docker"); err != nil {
+		return warning(hint, "docker isn't installed")
+	}
+	tc, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cmd := proc.CommandContext(tc, "docker", "version", "--format", "{{.Server.Version}}")
+	cmd.DisableLogging = true
+	out, err := cmd.Output()
+	if err != nil {
+		return warning(hint+" Verify that the Docker daemon is running.", "unable to reach the Docker daemon: %v", err)
+	}
+	return ok("Docker %s is available", strings.TrimSpace(string(out)))
+}
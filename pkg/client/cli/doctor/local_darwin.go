@@ -0,0 +1,18 @@
+package doctor
+
+import (
+	"context"
+	"os"
+)
+
+func checkDNS(context.Context, *Doctor) *Result {
+	if fi, err := os.Stat("/etc/resolver"); err == nil && !fi.IsDir() {
+		return failed("Remove /etc/resolver so that it can be recreated as a directory.",
+			"/etc/resolver isn't a directory, so the resolver files that direct cluster names to Telepresence can't be created")
+	}
+	return ok("cluster names will be resolved using files in /etc/resolver")
+}
+
+func checkFUSE() *Result {
+	return ok("sshfs and macFUSE are available")
+}
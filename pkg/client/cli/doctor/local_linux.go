@@ -0,0 +1,46 @@
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/rootd/dbus"
+)
+
+func checkDNS(ctx context.Context, _ *Doctor) *Result {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return ok("running in a docker container, so an overriding DNS server will be used")
+	}
+	if dbus.IsResolveDRunning(ctx) {
+		return ok("systemd-resolved is running and will be used to resolve cluster names")
+	}
+	if _, err := dexec.LookPath("iptables"); err != nil {
+		return failed("Enable systemd-resolved, or install iptables.",
+			"systemd-resolved isn't running, and iptables, which the overriding DNS server needs, isn't installed")
+	}
+	return warning("Enable systemd-resolved to let Telepresence integrate with the DNS configuration of the system.",
+		"systemd-resolved isn't running, so an overriding DNS server that redirects DNS traffic using iptables will be used")
+}
+
+// checkFUSE verifies that FUSE is available, and that it permits the allow_root option that Telepresence uses
+// when it mounts volumes.
+func checkFUSE() *Result {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		return warning("Load the fuse kernel module using `modprobe fuse`.", "FUSE is unavailable: %v", err)
+	}
+	f, err := os.Open("/etc/fuse.conf")
+	if err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if strings.TrimSpace(sc.Text()) == "user_allow_other" {
+				return ok("sshfs and FUSE are available")
+			}
+		}
+	}
+	return warning("Add a line with user_allow_other to /etc/fuse.conf.",
+		"FUSE doesn't allow the allow_root option, so mounting volumes will fail")
+}
@@ -0,0 +1,13 @@
+package doctor
+
+import (
+	"context"
+)
+
+func checkDNS(context.Context, *Doctor) *Result {
+	return ok("cluster names will be resolved using the DNS configuration of the Telepresence network interface")
+}
+
+func checkFUSE() *Result {
+	return ok("sshfs-win and WinFsp are available")
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/datawire/dlib/dcontext"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 )
 
@@ -74,6 +76,17 @@ func addKubeconfigEnv(cr *connector.ConnectRequest) {
 	}
 }
 
+// useDaemonContainerKubeconfig replaces the kubernetes flags of the given request with flags that the user
+// daemon can use when it runs in a daemon container.
+func useDaemonContainerKubeconfig(ctx context.Context, cr *connector.ConnectRequest) error {
+	info, err := docker.LoadInfo(ctx)
+	if err != nil || info == nil {
+		return err
+	}
+	cr.KubeFlags, err = docker.KubeFlags(ctx, cr.KubeFlags)
+	return err
+}
+
 func connect(ctx context.Context, connectorClient connector.ConnectorClient, stdout io.Writer, request *connector.ConnectRequest) (bool, *connector.ConnectInfo, error) {
 	var ci *connector.ConnectInfo
 	var err error
@@ -82,6 +95,9 @@ func connect(ctx context.Context, connectorClient connector.ConnectorClient, std
 		ci, err = connectorClient.Status(ctx, &empty.Empty{})
 	} else {
 		addKubeconfigEnv(request)
+		if err = useDaemonContainerKubeconfig(ctx, request); err != nil {
+			return false, nil, err
+		}
 		ci, err = connectorClient.Connect(ctx, request)
 	}
 	if err != nil {
@@ -92,7 +108,11 @@ func connect(ctx context.Context, connectorClient connector.ConnectorClient, std
 	cat := errcat.Unknown
 	switch ci.Error {
 	case connector.ConnectInfo_UNSPECIFIED:
-		fmt.Fprintf(stdout, "Connected to context %s (%s)\n", ci.ClusterContext, ci.ClusterServer)
+		if name := client.GetConnectionName(ctx); name != client.DefaultConnectionName {
+			fmt.Fprintf(stdout, "Connected to context %s (%s) using connection %q\n", ci.ClusterContext, ci.ClusterServer, name)
+		} else {
+			fmt.Fprintf(stdout, "Connected to context %s (%s)\n", ci.ClusterContext, ci.ClusterServer)
+		}
 		return true, ci, nil
 	case connector.ConnectInfo_ALREADY_CONNECTED:
 		return false, ci, nil
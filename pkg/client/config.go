@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -35,6 +36,15 @@ type Config struct {
 	TelepresenceAPI TelepresenceAPI `json:"telepresenceAPI,omitempty" yaml:"telepresenceAPI,omitempty"`
 	Daemons         Daemons         `json:"daemons,omitempty" yaml:"daemons,omitempty"`
 	Intercept       Intercept       `json:"intercept,omitempty" yaml:"intercept,omitempty"`
+	DNS             DNS             `json:"dns,omitempty" yaml:"dns,omitempty"`
+	OIDC            OIDC            `json:"oidc,omitempty" yaml:"oidc,omitempty"`
+
+	// MappedNamespaces are the namespaces that are mapped when a connect request doesn't declare any.
+	MappedNamespaces []string `json:"mappedNamespaces,omitempty" yaml:"mappedNamespaces,omitempty"`
+
+	// Profiles are configurations keyed by the name of a kube context or by a cluster ID. A profile
+	// overrides the values of this configuration when a connection to a matching cluster is made.
+	Profiles map[string]*Config `json:"profiles,omitempty" yaml:"profiles,omitempty"`
 }
 
 // Merge merges this instance with the non-zero values of the given argument. The argument values take priority.
@@ -47,6 +57,21 @@ func (c *Config) Merge(o *Config) {
 	c.TelepresenceAPI.merge(&o.TelepresenceAPI)
 	c.Daemons.merge(&o.Daemons)
 	c.Intercept.merge(&o.Intercept)
+	c.DNS.merge(&o.DNS)
+	c.OIDC.merge(&o.OIDC)
+	if len(o.MappedNamespaces) > 0 {
+		c.MappedNamespaces = o.MappedNamespaces
+	}
+	for name, op := range o.Profiles {
+		if cp, ok := c.Profiles[name]; ok {
+			cp.Merge(op)
+			continue
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]*Config, len(o.Profiles))
+		}
+		c.Profiles[name] = op
+	}
 }
 
 // Watch uses a file system watcher that receives events when the configuration changes
@@ -129,6 +154,14 @@ func (c *Config) UnmarshalYAML(node *yaml.Node) error {
 			err = ms[i+1].Decode(&c.Daemons)
 		case kv == "intercept":
 			err = ms[i+1].Decode(&c.Intercept)
+		case kv == "dns":
+			err = ms[i+1].Decode(&c.DNS)
+		case kv == "oidc":
+			err = ms[i+1].Decode(&c.OIDC)
+		case kv == "mappedNamespaces":
+			err = ms[i+1].Decode(&c.MappedNamespaces)
+		case kv == "profiles":
+			c.Profiles, err = unmarshalProfiles(ms[i+1])
 		case parseContext != nil:
 			dlog.Warn(parseContext, withLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
 		}
@@ -541,6 +574,7 @@ type Images struct {
 	PrivateRegistry        string `json:"registry,omitempty" yaml:"registry,omitempty"`
 	PrivateAgentImage      string `json:"agentImage,omitempty" yaml:"agentImage,omitempty"`
 	PrivateWebhookRegistry string `json:"webhookRegistry,omitempty" yaml:"webhookRegistry,omitempty"`
+	PrivateClientImage     string `json:"clientImage,omitempty" yaml:"clientImage,omitempty"`
 }
 
 // UnmarshalYAML parses the images YAML
@@ -564,6 +598,8 @@ func (img *Images) UnmarshalYAML(node *yaml.Node) (err error) {
 			img.PrivateAgentImage = v.Value
 		case "webhookRegistry":
 			img.PrivateWebhookRegistry = v.Value
+		case "clientImage":
+			img.PrivateClientImage = v.Value
 		case "webhookAgentImage":
 			dlog.Warn(parseContext, withLoc(fmt.Sprintf(`deprecated key %q, please use "agentImage" instead`, kv), ms[i]))
 			img.PrivateAgentImage = v.Value
@@ -586,6 +622,9 @@ func (img *Images) merge(o *Images) {
 	if o.PrivateWebhookRegistry != "" {
 		img.PrivateWebhookRegistry = o.PrivateWebhookRegistry
 	}
+	if o.PrivateClientImage != "" {
+		img.PrivateClientImage = o.PrivateClientImage
+	}
 }
 
 func (img *Images) Registry(c context.Context) string {
@@ -609,6 +648,15 @@ func (img *Images) AgentImage(c context.Context) string {
 	return GetEnv(c).AgentImage
 }
 
+// ClientImage returns the image that runs the daemons when they are started using
+// 'telepresence connect --docker'.
+func (img *Images) ClientImage(c context.Context) string {
+	if img.PrivateClientImage != "" {
+		return img.PrivateClientImage
+	}
+	return fmt.Sprintf("%s/telepresence:%s", img.Registry(c), strings.TrimPrefix(Version(), "v"))
+}
+
 type Cloud struct {
 	SkipLogin       bool          `json:"skipLogin,omitempty" yaml:"skipLogin,omitempty"`
 	RefreshMessages time.Duration `json:"refreshMessages,omitempty" yaml:"refreshMessages,omitempty"`
@@ -771,12 +819,84 @@ func (g *TelepresenceAPI) merge(o *TelepresenceAPI) {
 
 type Daemons struct {
 	UserDaemonBinary string `json:"userDaemonBinary,omitempty" yaml:"userDaemonBinary,omitempty"`
+
+	// ResilientSession makes the user daemon reconnect to the traffic-manager using the same session ID when
+	// its session is lost, and recreate the intercepts that the traffic-manager no longer knows about.
+	ResilientSession bool `json:"resilientSession,omitempty" yaml:"resilientSession,omitempty"`
+
+	// HTTPGatewayPort is the port on the loopback interface where the user daemon serves an HTTP/JSON
+	// gateway to its API. The gateway is disabled when the port is zero. Only the default connection uses
+	// this port; other connections use a port chosen by the system. The gateway isn't available when the
+	// daemons run in a container (--docker).
+	HTTPGatewayPort int `json:"httpGatewayPort,omitempty" yaml:"httpGatewayPort,omitempty"`
 }
 
 func (d *Daemons) merge(o *Daemons) {
 	if o.UserDaemonBinary != "" {
 		d.UserDaemonBinary = o.UserDaemonBinary
 	}
+	if o.ResilientSession {
+		d.ResilientSession = true
+	}
+	if o.HTTPGatewayPort != 0 {
+		d.HTTPGatewayPort = o.HTTPGatewayPort
+	}
+}
+
+// DNS contains the DNS settings that are used unless the telepresence.io extension of the cluster in the
+// kubeconfig declares them.
+type DNS struct {
+	// ExcludeSuffixes are suffixes for which the DNS resolver will always return NXDOMAIN.
+	ExcludeSuffixes []string `json:"excludeSuffixes,omitempty" yaml:"excludeSuffixes,omitempty"`
+
+	// IncludeSuffixes are suffixes for which the DNS resolver will always attempt to do a lookup.
+	IncludeSuffixes []string `json:"includeSuffixes,omitempty" yaml:"includeSuffixes,omitempty"`
+
+	// LookupTimeout is the maximum time to wait for a cluster side host lookup.
+	LookupTimeout time.Duration `json:"lookupTimeout,omitempty" yaml:"lookupTimeout,omitempty"`
+}
+
+func (d *DNS) merge(o *DNS) {
+	if len(o.ExcludeSuffixes) > 0 {
+		d.ExcludeSuffixes = o.ExcludeSuffixes
+	}
+	if len(o.IncludeSuffixes) > 0 {
+		d.IncludeSuffixes = o.IncludeSuffixes
+	}
+	if o.LookupTimeout != 0 {
+		d.LookupTimeout = o.LookupTimeout
+	}
+}
+
+// OIDC configures a login using an OpenID Connect provider instead of Ambassador Cloud. The ID token that
+// the provider issues is sent to the traffic-manager, which uses it as the identity of the client's session.
+type OIDC struct {
+	// IssuerURL is the URL of the provider. Its configuration is discovered from the
+	// "/.well-known/openid-configuration" of this URL, unless the URL already is a discovery URL.
+	IssuerURL string `json:"issuerURL,omitempty" yaml:"issuerURL,omitempty"`
+
+	// ClientID is the ID that the client is registered with at the provider.
+	ClientID string `json:"clientID,omitempty" yaml:"clientID,omitempty"`
+
+	// Scopes are the scopes to request. The "openid" scope is always requested.
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+}
+
+// Enabled returns true if an OpenID Connect provider is configured.
+func (o *OIDC) Enabled() bool {
+	return o.IssuerURL != ""
+}
+
+func (o *OIDC) merge(oo *OIDC) {
+	if oo.IssuerURL != "" {
+		o.IssuerURL = oo.IssuerURL
+	}
+	if oo.ClientID != "" {
+		o.ClientID = oo.ClientID
+	}
+	if len(oo.Scopes) > 0 {
+		o.Scopes = oo.Scopes
+	}
 }
 
 const defaultInterceptDefaultPort = 8080
@@ -836,9 +956,15 @@ func WithConfig(ctx context.Context, config *Config) context.Context {
 	return context.WithValue(ctx, configKey{}, (*unsafe.Pointer)(unsafe.Pointer(&config)))
 }
 
+// GetConfig returns the Config last stored using WithConfig or ReplaceConfig, with the profiles
+// selected using WithProfiles applied.
 func GetConfig(ctx context.Context) *Config {
 	if configPtr, ok := ctx.Value(configKey{}).(*unsafe.Pointer); ok {
-		return (*Config)(atomic.LoadPointer(configPtr))
+		cfg := (*Config)(atomic.LoadPointer(configPtr))
+		if ps, ok := ctx.Value(profilesKey{}).(*profileSelection); ok && cfg != nil {
+			cfg = ps.apply(cfg)
+		}
+		return cfg
 	}
 	return nil
 }
@@ -891,6 +1017,32 @@ func GetDefaultConfig() Config {
 	}
 }
 
+// ConfigFiles returns the paths of the configuration files in the directories returned by
+// filelocation.AppSystemConfigDirs and filelocation.AppUserConfigDir, in the order that they are
+// merged. Directories that don't exist are skipped.
+func ConfigFiles(c context.Context) ([]string, error) {
+	dirs, err := filelocation.AppSystemConfigDirs(c)
+	if err != nil {
+		return nil, err
+	}
+	appDir, err := filelocation.AppUserConfigDir(c)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else {
+		dirs = append(dirs, appDir)
+	}
+	files := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if stat, err := os.Stat(dir); err != nil || !stat.IsDir() { // skip unless directory
+			continue
+		}
+		files = append(files, filepath.Join(dir, configFile))
+	}
+	return files, nil
+}
+
 // LoadConfig loads and returns the Telepresence configuration as stored in filelocation.AppUserConfigDir
 // or filelocation.AppSystemConfigDirs
 func LoadConfig(c context.Context) (cfg *Config, err error) {
@@ -900,19 +1052,14 @@ func LoadConfig(c context.Context) (cfg *Config, err error) {
 		}
 	}()
 
-	var dirs []string
-	dirs, err = filelocation.AppSystemConfigDirs(c)
+	files, err := ConfigFiles(c)
 	if err != nil {
 		return nil, err
 	}
 
 	dflt := GetDefaultConfig()
 	cfg = &dflt
-	readMerge := func(dir string) error {
-		if stat, err := os.Stat(dir); err != nil || !stat.IsDir() { // skip unless directory
-			return nil
-		}
-		fileName := filepath.Join(dir, configFile)
+	readMerge := func(fileName string) error {
 		bs, err := os.ReadFile(fileName)
 		if err != nil {
 			if os.IsNotExist(err) {
@@ -932,21 +1079,11 @@ func LoadConfig(c context.Context) (cfg *Config, err error) {
 		return nil
 	}
 
-	for _, dir := range dirs {
-		if err = readMerge(dir); err != nil {
+	for _, file := range files {
+		if err = readMerge(file); err != nil {
 			return nil, err
 		}
 	}
-	appDir, err := filelocation.AppUserConfigDir(c)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
-		}
-		return nil, err
-	}
-	if err = readMerge(appDir); err != nil {
-		return nil, err
-	}
 
 	// Sanity check
 	if os.Getenv("SYSTEMA_ENV") == "staging" && cfg.Cloud.SystemaHost != "staging-app.datawire.io" {
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"unsafe"
+
+	"gopkg.in/yaml.v3"
+)
+
+func unmarshalProfiles(node *yaml.Node) (map[string]*Config, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New(withLoc("profiles must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	profiles := make(map[string]*Config, top/2)
+	for i := 0; i < top; i += 2 {
+		name, err := stringKey(ms[i])
+		if err != nil {
+			return nil, err
+		}
+		v := ms[i+1]
+		if v.Kind == yaml.MappingNode {
+			for j := 0; j < len(v.Content); j += 2 {
+				if v.Content[j].Value == "profiles" {
+					return nil, errors.New(withLoc("profiles cannot be nested", v.Content[j]))
+				}
+			}
+		}
+		// Decode on top of the defaults, so that the profile's Merge only overrides what it declares.
+		pc := GetDefaultConfig()
+		if err = v.Decode(&pc); err != nil {
+			return nil, err
+		}
+		profiles[name] = &pc
+	}
+	return profiles, nil
+}
+
+// ForProfiles returns a copy of this Config with the profiles of the given names merged, in order,
+// on top of it. Names that don't match a profile are ignored, and so are empty names. The returned
+// Config has no profiles.
+func (c *Config) ForProfiles(names ...string) *Config {
+	cfg := *c
+	cfg.Profiles = nil
+	for _, name := range names {
+		if p, ok := c.Profiles[name]; ok && name != "" {
+			cfg.Merge(p)
+		}
+	}
+	return &cfg
+}
+
+// MatchingProfiles returns the names of the profiles that match the given kube context name and
+// cluster ID, in the order that they are applied. A profile keyed by a cluster ID is applied
+// before a profile keyed by a context name, because several contexts may use the same cluster.
+func (c *Config) MatchingProfiles(contextName, clusterID string) []string {
+	var names []string
+	for _, name := range []string{clusterID, contextName} {
+		if _, ok := c.Profiles[name]; ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type profilesKey struct{}
+
+// profileSelection selects the profiles that match a kube context and cluster ID.
+type profileSelection struct {
+	contextName string
+	clusterID   string
+
+	// applied is a *appliedProfiles, the result of the last apply.
+	applied unsafe.Pointer
+}
+
+type appliedProfiles struct {
+	base *Config
+	cfg  *Config
+}
+
+// apply returns the given Config with the selected profiles merged on top of it. The result is
+// retained, so that it can be returned again until the given Config is replaced.
+func (ps *profileSelection) apply(base *Config) *Config {
+	if ap := (*appliedProfiles)(atomic.LoadPointer(&ps.applied)); ap != nil && ap.base == base {
+		return ap.cfg
+	}
+	cfg := base
+	if names := base.MatchingProfiles(ps.contextName, ps.clusterID); len(names) > 0 {
+		cfg = base.ForProfiles(names...)
+	}
+	atomic.StorePointer(&ps.applied, unsafe.Pointer(&appliedProfiles{base: base, cfg: cfg}))
+	return cfg
+}
+
+// WithProfiles returns a context where GetConfig applies the profiles that match the given kube context
+// name and cluster ID. The profiles are applied to the Config that is current when GetConfig is called,
+// so they remain in effect when the Config is replaced using ReplaceConfig.
+func WithProfiles(ctx context.Context, contextName, clusterID string) context.Context {
+	return context.WithValue(ctx, profilesKey{}, &profileSelection{contextName: contextName, clusterID: clusterID})
+}
+
+// ValueSource describes where the value of a configuration key was declared.
+type ValueSource struct {
+	// File is the configuration file that declares the value.
+	File string `json:"file"`
+
+	// Line is the line in the file where the value is declared.
+	Line int `json:"line"`
+
+	// Profile is the name of the profile that declares the value, or empty when the
+	// value is declared outside of a profile.
+	Profile string `json:"profile,omitempty"`
+}
+
+func (vs *ValueSource) String() string {
+	if vs.Profile != "" {
+		return fmt.Sprintf("%s:%d, profile %q", vs.File, vs.Line, vs.Profile)
+	}
+	return fmt.Sprintf("%s:%d", vs.File, vs.Line)
+}
+
+// ConfigSources returns the sources of the values of the configuration that is obtained when the
+// profiles of the given names are applied to the configuration loaded by LoadConfig. The map is
+// keyed by the dotted path of each value, e.g. "timeouts.agentInstall". Values that aren't declared
+// in any of the configuration files have no entry.
+func ConfigSources(c context.Context, profiles ...string) (map[string]*ValueSource, error) {
+	files, err := ConfigFiles(c)
+	if err != nil {
+		return nil, err
+	}
+	type fileNode struct {
+		file string
+		node *yaml.Node
+	}
+	var fns []fileNode
+	for _, file := range files {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		var doc yaml.Node
+		if err = yaml.Unmarshal(bs, &doc); err != nil {
+			return nil, fmt.Errorf("file %s: %w", file, err)
+		}
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+			fns = append(fns, fileNode{file: file, node: doc.Content[0]})
+		}
+	}
+
+	sources := make(map[string]*ValueSource)
+	for _, fn := range fns {
+		ms := fn.node.Content
+		for i := 0; i < len(ms); i += 2 {
+			if ms[i].Value != "profiles" {
+				addValueSources(sources, ms[i].Value, ms[i+1], &ValueSource{File: fn.file, Line: ms[i].Line})
+			}
+		}
+	}
+	for _, profile := range profiles {
+		for _, fn := range fns {
+			pn := mappingValue(mappingValue(fn.node, "profiles"), profile)
+			if pn == nil || pn.Kind != yaml.MappingNode {
+				continue
+			}
+			ms := pn.Content
+			for i := 0; i < len(ms); i += 2 {
+				addValueSources(sources, ms[i].Value, ms[i+1], &ValueSource{File: fn.file, Line: ms[i].Line, Profile: profile})
+			}
+		}
+	}
+	return sources, nil
+}
+
+// addValueSources adds the given source for the given path, or, if the node is a mapping, for the
+// paths of each of its values.
+func addValueSources(sources map[string]*ValueSource, path string, node *yaml.Node, vs *ValueSource) {
+	if node.Kind != yaml.MappingNode {
+		sources[path] = vs
+		return
+	}
+	ms := node.Content
+	for i := 0; i < len(ms); i += 2 {
+		addValueSources(sources, path+"."+ms[i].Value, ms[i+1], &ValueSource{File: vs.File, Line: ms[i].Line, Profile: vs.Profile})
+	}
+}
+
+// mappingValue returns the value of the given key in the given mapping node, or nil if the node
+// isn't a mapping or has no such key.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	ms := node.Content
+	for i := 0; i < len(ms); i += 2 {
+		if ms[i].Value == key {
+			return ms[i+1]
+		}
+	}
+	return nil
+}
+
+// AnnotateSources adds a line comment with the source of each value in the given YAML node, which
+// must be the result of marshalling a Config, and returns the node.
+func AnnotateSources(node *yaml.Node, sources map[string]*ValueSource) *yaml.Node {
+	annotateSources(node, "", sources)
+	return node
+}
+
+func annotateSources(node *yaml.Node, path string, sources map[string]*ValueSource) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			annotateSources(n, path, sources)
+		}
+	case yaml.MappingNode:
+		ms := node.Content
+		for i := 0; i < len(ms); i += 2 {
+			p := ms[i].Value
+			if path != "" {
+				p = path + "." + p
+			}
+			if ms[i+1].Kind == yaml.MappingNode {
+				annotateSources(ms[i+1], p, sources)
+				continue
+			}
+			if vs, ok := sources[p]; ok {
+				ms[i].LineComment = vs.String()
+			} else {
+				ms[i].LineComment = "default"
+			}
+		}
+	}
+}
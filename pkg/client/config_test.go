@@ -47,6 +47,12 @@ telepresenceAPI:
 intercept:
   appProtocolStrategy: portName
   defaultPort: 9080
+oidc:
+  issuerURL: https://idp.example.com
+  clientID: telepresence
+  scopes:
+  - email
+  - groups
 `,
 	}
 
@@ -86,6 +92,11 @@ intercept:
 	assert.Equal(t, 1234, cfg.TelepresenceAPI.Port)                                            // from user
 	assert.Equal(t, k8sapi.PortName, cfg.Intercept.AppProtocolStrategy)                        // from user
 	assert.Equal(t, 9080, cfg.Intercept.DefaultPort)                                           // from user
+	assert.Equal(t, OIDC{
+		IssuerURL: "https://idp.example.com",
+		ClientID:  "telepresence",
+		Scopes:    []string{"email", "groups"},
+	}, cfg.OIDC) // from user
 }
 
 func Test_ConfigMarshalYAML(t *testing.T) {
@@ -121,3 +132,156 @@ func Test_ConfigMarshalYAMLDefaults(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "{}\n", string(cfgBytes))
 }
+
+func TestConfigProfiles(t *testing.T) {
+	configs := []string{
+		/* sys */ `
+timeouts:
+  agentInstall: 2m10s
+profiles:
+  prod:
+    timeouts:
+      helm: 1m
+`,
+		/* user */ `
+mappedNamespaces: [default]
+dns:
+  excludeSuffixes: [.com]
+  lookupTimeout: 3s
+profiles:
+  prod:
+    timeouts:
+      agentInstall: 5m
+    mappedNamespaces: [prod]
+  4a4a2a3c-bc2a-4a2b-8d0b-53c3a0a3c1b9:
+    images:
+      registry: cluster.io
+    mappedNamespaces: [cluster]
+`,
+	}
+
+	tmp := t.TempDir()
+	sys := filepath.Join(tmp, "sys")
+	user := filepath.Join(tmp, "user")
+	for i, dir := range []string{sys, user} {
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, configFile), []byte(configs[i]), 0600))
+	}
+
+	c := dlog.NewTestContext(t, false)
+	c = filelocation.WithAppSystemConfigDirs(c, []string{sys})
+	c = filelocation.WithAppUserConfigDir(c, user)
+	env, err := LoadEnv(c)
+	require.NoError(t, err)
+	c = WithEnv(c, env)
+
+	cfg, err := LoadConfig(c)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, cfg.MappedNamespaces)
+	assert.Equal(t, []string{".com"}, cfg.DNS.ExcludeSuffixes)
+	assert.Equal(t, 3*time.Second, cfg.DNS.LookupTimeout)
+	require.Len(t, cfg.Profiles, 2)
+
+	clusterID := "4a4a2a3c-bc2a-4a2b-8d0b-53c3a0a3c1b9"
+	assert.Empty(t, cfg.MatchingProfiles("dev", ""))
+	profiles := cfg.MatchingProfiles("prod", clusterID)
+	assert.Equal(t, []string{clusterID, "prod"}, profiles)
+
+	pc := cfg.ForProfiles(profiles...)
+	assert.Nil(t, pc.Profiles)
+	assert.Equal(t, 5*time.Minute, pc.Timeouts.PrivateAgentInstall)                 // from user profile prod
+	assert.Equal(t, time.Minute, pc.Timeouts.PrivateHelm)                           // from sys profile prod
+	assert.Equal(t, defaultTimeoutsApply, pc.Timeouts.PrivateApply)                 // default
+	assert.Equal(t, "cluster.io", pc.Images.PrivateRegistry)                        // from user profile clusterID
+	assert.Equal(t, []string{"prod"}, pc.MappedNamespaces)                          // context profile is applied last
+	assert.Equal(t, []string{".com"}, pc.DNS.ExcludeSuffixes)                       // from user
+	assert.Equal(t, 2*time.Minute+10*time.Second, cfg.Timeouts.PrivateAgentInstall) // original is unchanged
+
+	sources, err := ConfigSources(c, profiles...)
+	require.NoError(t, err)
+	userFile := filepath.Join(user, configFile)
+	assert.Equal(t, &ValueSource{File: userFile, Line: 9, Profile: "prod"}, sources["timeouts.agentInstall"])
+	assert.Equal(t, &ValueSource{File: filepath.Join(sys, configFile), Line: 7, Profile: "prod"}, sources["timeouts.helm"])
+	assert.Equal(t, &ValueSource{File: userFile, Line: 4}, sources["dns.excludeSuffixes"])
+	assert.Equal(t, &ValueSource{File: userFile, Line: 10, Profile: "prod"}, sources["mappedNamespaces"])
+	assert.NotContains(t, sources, "timeouts.apply")
+}
+
+func TestWithProfiles(t *testing.T) {
+	parse := func(doc string) *Config {
+		cfg := GetDefaultConfig()
+		require.NoError(t, yaml.Unmarshal([]byte(doc), &cfg))
+		return &cfg
+	}
+	c := dlog.NewTestContext(t, false)
+	c = WithConfig(c, parse(`
+mappedNamespaces: [default]
+profiles:
+  prod:
+    mappedNamespaces: [prod]
+`))
+	pc := WithProfiles(c, "prod", "")
+	assert.Equal(t, []string{"prod"}, GetConfig(pc).MappedNamespaces)
+	assert.Same(t, GetConfig(pc), GetConfig(pc))
+	assert.Equal(t, []string{"default"}, GetConfig(c).MappedNamespaces)
+
+	// The profiles remain applied when the config is replaced, also when they didn't exist before.
+	ReplaceConfig(c, parse(`
+mappedNamespaces: [default]
+profiles:
+  prod:
+    mappedNamespaces: [prod, staging]
+`))
+	assert.Equal(t, []string{"prod", "staging"}, GetConfig(pc).MappedNamespaces)
+
+	dc := WithProfiles(c, "dev", "")
+	assert.Equal(t, []string{"default"}, GetConfig(dc).MappedNamespaces)
+	ReplaceConfig(c, parse(`
+profiles:
+  dev:
+    mappedNamespaces: [dev]
+`))
+	assert.Equal(t, []string{"dev"}, GetConfig(dc).MappedNamespaces)
+}
+
+func TestConfigProfiles_nested(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+profiles:
+  prod:
+    profiles:
+      dev: {}
+`), &cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 4: profiles cannot be nested")
+}
+
+func TestValidateConfig(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	problems, err := ValidateConfig(ctx, "config.yml", []byte(`
+timeouts:
+  bogus: 1s
+images:
+  registry: x.io
+foo: bar
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`file config.yml, line 3: unknown key "bogus"`,
+		`file config.yml, line 6: unknown key "foo"`,
+	}, problems)
+
+	_, err = ValidateConfig(ctx, "config.yml", []byte(`
+timeouts:
+  helm: xyz
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+
+	problems, err = ValidateConfig(ctx, "config.yml", []byte(`
+timeouts:
+  helm: 1m
+`))
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
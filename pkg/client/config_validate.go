@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// problemLogger is a dlog.Logger that collects the warnings and errors that are logged when a
+// configuration is parsed.
+type problemLogger struct {
+	problems *[]string
+}
+
+func (l problemLogger) Helper() {}
+
+func (l problemLogger) WithField(string, any) dlog.Logger {
+	return l
+}
+
+func (l problemLogger) StdLogger(dlog.LogLevel) *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+func (l problemLogger) Log(level dlog.LogLevel, msg string) {
+	if level <= dlog.LogLevelWarn {
+		*l.problems = append(*l.problems, msg)
+	}
+}
+
+// ValidateConfig parses the given contents of the given configuration file. Problems that
+// LoadConfig would just log, like unknown keys or unparsable values, are returned as problems,
+// and problems that would make LoadConfig fail are returned as an error. Both include the line
+// where the problem was found.
+func ValidateConfig(c context.Context, fileName string, data []byte) (problems []string, err error) {
+	parseContext = context.WithValue(dlog.WithLogger(c, problemLogger{problems: &problems}), parsedFile{}, fileName)
+	defer func() {
+		parseContext = nil
+	}()
+	cfg := GetDefaultConfig()
+	err = yaml.Unmarshal(data, &cfg)
+	return problems, err
+}
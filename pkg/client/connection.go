@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultConnectionName is the name of the connection that is used when no name is given.
+const DefaultConnectionName = "default"
+
+var connectionNameRx = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// ValidateConnectionName returns an error unless the given name can be used as the name of a
+// connection. The name becomes part of socket and log file names, so it must be a short lower
+// case DNS label.
+func ValidateConnectionName(name string) error {
+	if !connectionNameRx.MatchString(name) {
+		return fmt.Errorf(
+			"invalid connection name %q, it must consist of at most 32 lower case alphanumeric characters or '-', "+
+				"and start and end with an alphanumeric character", name)
+	}
+	return nil
+}
+
+type connectionNameKey struct{}
+
+// WithConnectionName returns a context that uses the connection with the given name.
+func WithConnectionName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, connectionNameKey{}, name)
+}
+
+// GetConnectionName returns the name of the connection used by the given context, or
+// DefaultConnectionName when no name has been assigned.
+func GetConnectionName(ctx context.Context) string {
+	if name, ok := ctx.Value(connectionNameKey{}).(string); ok && name != "" {
+		return name
+	}
+	return DefaultConnectionName
+}
+
+// ConnectionProcessName returns the given process name, qualified with the name of the connection
+// used by the given context unless that connection is the default connection. The returned name
+// is used when naming the log file of a daemon.
+func ConnectionProcessName(ctx context.Context, processName string) string {
+	if name := GetConnectionName(ctx); name != DefaultConnectionName {
+		return processName + "-" + name
+	}
+	return processName
+}
+
+// ConnectorSocket returns the name of the socket of the user daemon that manages the connection
+// used by the given context.
+func ConnectorSocket(ctx context.Context) string {
+	return connectionSocketName(ConnectorSocketName, GetConnectionName(ctx))
+}
+
+// DaemonSocket returns the name of the socket of the root daemon that manages the connection used
+// by the given context.
+func DaemonSocket(ctx context.Context) string {
+	return connectionSocketName(DaemonSocketName, GetConnectionName(ctx))
+}
+
+func connectionSocketName(socketName, name string) string {
+	if name == DefaultConnectionName {
+		return socketName
+	}
+	return strings.TrimSuffix(socketName, socketSuffix) + "-" + name + socketSuffix
+}
+
+// ConnectionNames returns the sorted names of all connections that have a running root daemon.
+func ConnectionNames() ([]string, error) {
+	prefix := strings.TrimSuffix(DaemonSocketName, socketSuffix)
+	socketNames, err := socketNamesWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, socketName := range socketNames {
+		name := strings.TrimSuffix(strings.TrimPrefix(socketName, prefix), socketSuffix)
+		switch {
+		case name == "":
+			names = append(names, DefaultConnectionName)
+		case strings.HasPrefix(name, "-") && ValidateConnectionName(name[1:]) == nil:
+			names = append(names, name[1:])
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
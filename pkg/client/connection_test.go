@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionSockets(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, DefaultConnectionName, GetConnectionName(ctx))
+	assert.Equal(t, ConnectorSocketName, ConnectorSocket(ctx))
+	assert.Equal(t, DaemonSocketName, DaemonSocket(ctx))
+	assert.Equal(t, "connector", ConnectionProcessName(ctx, "connector"))
+
+	ctx = WithConnectionName(ctx, "apps")
+	assert.NotEqual(t, ConnectorSocketName, ConnectorSocket(ctx))
+	assert.Contains(t, ConnectorSocket(ctx), "-apps")
+	assert.Contains(t, DaemonSocket(ctx), "-apps")
+	assert.Equal(t, "daemon-apps", ConnectionProcessName(ctx, "daemon"))
+}
+
+func TestValidateConnectionName(t *testing.T) {
+	for _, name := range []string{"default", "apps", "apps-2", "a"} {
+		assert.NoError(t, ValidateConnectionName(name), name)
+	}
+	for _, name := range []string{"", "Apps", "-apps", "apps-", "apps/x", "a.b", "abcdefghijklmnopqrstuvwxyz0123456789"} {
+		assert.Error(t, ValidateConnectionName(name), name)
+	}
+}
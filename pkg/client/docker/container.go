@@ -0,0 +1,258 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+// Paths of the daemon container's home directory. The daemons run as root in the container.
+const (
+	containerKubeDir   = "/root/.kube"
+	containerCacheDir  = "/root/.cache/telepresence"
+	containerLogDir    = "/root/.cache/telepresence/logs"
+	containerConfigDir = "/root/.config/telepresence"
+)
+
+// Info describes a running daemon container. It is stored in the user's cache directory, so that
+// all telepresence commands that use the connection find the daemons in the container.
+type Info struct {
+	// Container is the name of the daemon container.
+	Container string `json:"container"`
+
+	// ConnectorAddress is the host address where the user daemon can be reached.
+	ConnectorAddress string `json:"connector_address"`
+
+	// DaemonAddress is the host address where the root daemon can be reached.
+	DaemonAddress string `json:"daemon_address"`
+
+	// Token must be presented in all calls to the daemons in the container.
+	Token string `json:"token"`
+}
+
+type requestedKey struct{}
+
+// WithDaemonContainer returns a context that requests that the daemons of its connection are started
+// in a container.
+func WithDaemonContainer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestedKey{}, true)
+}
+
+// DaemonContainerRequested returns true if the given context requests that the daemons of its
+// connection are started in a container.
+func DaemonContainerRequested(ctx context.Context) bool {
+	r, _ := ctx.Value(requestedKey{}).(bool)
+	return r
+}
+
+// connectionDir returns the directory that contains the files of the daemon container of the
+// connection used by the given context.
+func connectionDir(ctx context.Context) (string, error) {
+	cacheDir, err := filelocation.AppUserCacheDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "daemons", client.GetConnectionName(ctx)), nil
+}
+
+func infoFile(ctx context.Context) (string, error) {
+	dir, err := connectionDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "container.json"), nil
+}
+
+// LoadInfo returns the Info of the daemon container of the connection used by the given context, or
+// nil if the daemons of that connection don't run in a container.
+func LoadInfo(ctx context.Context) (*Info, error) {
+	file, err := infoFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	var info Info
+	if err = json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+	if !containerRunning(ctx, info.Container) {
+		// The container is gone, so the info is stale.
+		dlog.Debugf(ctx, "daemon container %s is not running", info.Container)
+		_ = os.Remove(file)
+		return nil, nil
+	}
+	return &info, nil
+}
+
+func containerRunning(ctx context.Context, container string) bool {
+	out, err := dockerOutput(ctx, "container", "inspect", "--format", "{{.State.Running}}", container)
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// Start starts the daemons of the connection used by the given context in a privileged container. The
+// container has its own TUN device and DNS configuration, and the gRPC ports of the daemons are published
+// on the loopback interface of the host. Calls to the published ports must present the token of the
+// returned Info.
+func Start(ctx context.Context) (*Info, error) {
+	name := client.GetConnectionName(ctx)
+	dir, err := connectionDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kubeDir := filepath.Join(dir, "kube")
+	if err = os.MkdirAll(kubeDir, 0o700); err != nil {
+		return nil, err
+	}
+	logDir, err := filelocation.AppUserLogDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(logDir, 0o700); err != nil {
+		return nil, err
+	}
+	configDir, err := filelocation.AppUserConfigDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(configDir, 0o700); err != nil {
+		return nil, err
+	}
+	cacheDir, err := filelocation.AppUserCacheDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Container: "telepresence-" + name}
+	if info.Token, err = newToken(); err != nil {
+		return nil, err
+	}
+	args := runArgs(info, name, client.GetConfig(ctx).Images.ClientImage(ctx), dirs{
+		kube:   kubeDir,
+		cache:  cacheDir,
+		log:    logDir,
+		config: configDir,
+	})
+	if out, err := dockerOutput(ctx, args...); err != nil {
+		return nil, fmt.Errorf("failed to start daemon container: %s: %w", strings.TrimSpace(out), err)
+	}
+	if info.ConnectorAddress, err = publishedAddress(ctx, info.Container, connectorPort); err != nil {
+		return nil, err
+	}
+	if info.DaemonAddress, err = publishedAddress(ctx, info.Container, daemonPort); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	file, err := infoFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(file, data, 0o600); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// dirs are the host directories that are mounted into the daemon container.
+type dirs struct {
+	kube   string
+	cache  string
+	log    string
+	config string
+}
+
+// runArgs returns the arguments to 'docker run' that start the daemon container described by the given
+// info. Only the given directories of the host are mounted into the container, so paths on the host, like
+// the path of an --env-file, are not valid in the container.
+func runArgs(info *Info, name, image string, d dirs) []string {
+	return []string{
+		"run", "--detach", "--rm",
+		"--name", info.Container,
+		"--cap-add", "NET_ADMIN",
+		"--device", "/dev/net/tun:/dev/net/tun",
+		"--sysctl", "net.ipv6.conf.all.disable_ipv6=0",
+		"--add-host", "host.docker.internal:host-gateway",
+		"--publish", fmt.Sprintf("127.0.0.1::%d", connectorPort),
+		"--publish", fmt.Sprintf("127.0.0.1::%d", daemonPort),
+		"--env", "TELEPRESENCE_DAEMON_CONTAINER=" + info.Container,
+		"--env", "TELEPRESENCE_DAEMON_TOKEN=" + info.Token,
+		"--volume", d.kube + ":" + containerKubeDir,
+		"--volume", d.cache + ":" + containerCacheDir,
+		"--volume", d.log + ":" + containerLogDir,
+		"--volume", d.config + ":" + containerConfigDir + ":ro",
+		// Allows the user daemon to start intercept containers using --docker-run.
+		"--volume", "/var/run/docker.sock:/var/run/docker.sock",
+		image,
+		"daemons-foreground", "--name", name,
+	}
+}
+
+// Stop stops the given daemon container.
+func Stop(ctx context.Context, info *Info) error {
+	if out, err := dockerOutput(ctx, "stop", info.Container); err != nil && containerRunning(ctx, info.Container) {
+		return fmt.Errorf("failed to stop daemon container: %s: %w", strings.TrimSpace(out), err)
+	}
+	file, err := infoFile(ctx)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Dial dials the gRPC server of a daemon in the daemon container at the given host address, using the
+// given token of the container. The daemon might still be starting, so the dial is retried for a while.
+func Dial(ctx context.Context, address, token string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, address, append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(tokenCredentials(token)),
+		grpc.WithNoProxy(),
+		grpc.WithBlock(),
+	}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the daemon container at %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// publishedAddress returns the host address that the given port of the given container is published on.
+func publishedAddress(ctx context.Context, container string, port int) (string, error) {
+	out, err := dockerOutput(ctx, "port", container, fmt.Sprintf("%d/tcp", port))
+	if err != nil {
+		return "", fmt.Errorf("unable to get the published address of port %d: %s: %w", port, strings.TrimSpace(out), err)
+	}
+	// Docker lists one address per line, e.g. "127.0.0.1:55001".
+	return strings.TrimSpace(strings.SplitN(out, "\n", 2)[0]), nil
+}
+
+func dockerOutput(ctx context.Context, args ...string) (string, error) {
+	cmd := proc.CommandContext(ctx, "docker", args...)
+	cmd.DisableLogging = true
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_runArgs(t *testing.T) {
+	info := &Info{Container: "telepresence-default", Token: "secret"}
+	args := runArgs(info, "default", "datawire/telepresence:2.7.0", dirs{
+		kube:   "/home/u/.cache/telepresence/daemons/default/kube",
+		cache:  "/home/u/.cache/telepresence",
+		log:    "/home/u/.cache/telepresence/logs",
+		config: "/home/u/.config/telepresence",
+	})
+
+	values := func(flag string) []string {
+		var vs []string
+		for i := 0; i < len(args)-1; i++ {
+			if args[i] == flag {
+				vs = append(vs, args[i+1])
+			}
+		}
+		return vs
+	}
+
+	assert.Equal(t, []string{"run", "--detach", "--rm"}, args[:3])
+	assert.Equal(t, []string{"datawire/telepresence:2.7.0", "daemons-foreground", "--name", "default"}, args[len(args)-4:])
+	assert.Equal(t, []string{"telepresence-default", "default"}, values("--name"))
+	assert.Equal(t, []string{"127.0.0.1::4039", "127.0.0.1::4040"}, values("--publish"))
+	assert.Equal(t, []string{
+		"TELEPRESENCE_DAEMON_CONTAINER=telepresence-default",
+		"TELEPRESENCE_DAEMON_TOKEN=secret",
+	}, values("--env"))
+	assert.Equal(t, []string{
+		"/home/u/.cache/telepresence/daemons/default/kube:/root/.kube",
+		"/home/u/.cache/telepresence:/root/.cache/telepresence",
+		"/home/u/.cache/telepresence/logs:/root/.cache/telepresence/logs",
+		"/home/u/.config/telepresence:/root/.config/telepresence:ro",
+		"/var/run/docker.sock:/var/run/docker.sock",
+	}, values("--volume"))
+	for _, v := range values("--volume") {
+		assert.False(t, strings.HasPrefix(v, "/home/u:"), "the home directory must not be mounted")
+	}
+}
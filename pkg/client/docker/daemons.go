@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dgroup"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+const (
+	// connectorPort is the port that the user daemon listens to inside the daemon container.
+	connectorPort = 4039
+
+	// daemonPort is the port that the root daemon listens to inside the daemon container.
+	daemonPort = 4040
+)
+
+// Command returns the telepresence sub-command "daemons-foreground" that runs the root daemon and
+// the user daemon side by side. It is the command of the container that is started by
+// 'telepresence connect --docker'.
+func Command() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:    "daemons-foreground",
+		Short:  "Launch the Telepresence root and user daemons in the foreground",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := client.ValidateConnectionName(name); err != nil {
+				return err
+			}
+			return runDaemons(cmd.Context(), name)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", client.DefaultConnectionName, "The name of the connection managed by the daemons")
+	return cmd
+}
+
+// runDaemons starts the root daemon and the user daemon, and waits for them to exit. Both daemons
+// are terminated when one of them exits, which in turn terminates the container.
+func runDaemons(ctx context.Context, name string) error {
+	logDir, err := filelocation.AppUserLogDir(ctx)
+	if err != nil {
+		return err
+	}
+	configDir, err := filelocation.AppUserConfigDir(ctx)
+	if err != nil {
+		return err
+	}
+	exe := client.GetExe()
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{
+		EnableSignalHandling: true,
+		ShutdownOnNonError:   true,
+	})
+	start := func(args ...string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			cmd := dexec.CommandContext(ctx, exe, args...)
+			cmd.DisableLogging = true
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			return cmd.Run()
+		}
+	}
+	g.Go("root-daemon", start("daemon-foreground", logDir, configDir,
+		"--name", name, "--address", fmt.Sprintf(":%d", daemonPort)))
+	g.Go("user-daemon", start("connector-foreground",
+		"--name", name, "--address", fmt.Sprintf(":%d", connectorPort)))
+	return g.Wait()
+}
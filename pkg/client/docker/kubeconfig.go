@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// dockerHost is the name that the daemon container uses when it connects to the host.
+const dockerHost = "host.docker.internal"
+
+// KubeFlags writes a kubeconfig file that the user daemon in the daemon container can use, and returns
+// the kubernetes flags that must be sent to the user daemon instead of the given ones.
+//
+// The user daemon can't read the kubeconfig files of the host, so the configuration that the given flags
+// resolve to is minified, flattened, and written to a directory that is mounted into the container.
+// Cluster servers that run on the host's loopback interface are rewritten so that they can be reached
+// from the container.
+func KubeFlags(ctx context.Context, flags map[string]string) (map[string]string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kc, ok := flags["KUBECONFIG"]; ok {
+		rules.Precedence = filepath.SplitList(kc)
+	}
+	rules.ExplicitPath = flags["kubeconfig"]
+	config, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+	if cn := flags["context"]; cn != "" {
+		config.CurrentContext = cn
+	}
+	if kc, ok := config.Contexts[config.CurrentContext]; ok {
+		if cl := flags["cluster"]; cl != "" {
+			kc.Cluster = cl
+		}
+		if ai := flags["user"]; ai != "" {
+			kc.AuthInfo = ai
+		}
+	}
+	if err = api.MinifyConfig(config); err != nil {
+		return nil, err
+	}
+	if err = api.FlattenConfig(config); err != nil {
+		return nil, err
+	}
+	for name, cluster := range config.Clusters {
+		if rewriteLoopbackServer(cluster) {
+			dlog.Debugf(ctx, "server of cluster %q rewritten to %s", name, cluster.Server)
+		}
+	}
+
+	dir, err := connectionDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kubeDir := filepath.Join(dir, "kube")
+	if err = os.MkdirAll(kubeDir, 0o700); err != nil {
+		return nil, err
+	}
+	if err = clientcmd.WriteToFile(*config, filepath.Join(kubeDir, "config")); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(flags))
+	for k, v := range flags {
+		switch k {
+		case "KUBECONFIG", "kubeconfig", "context", "cluster", "user":
+			// Resolved into the written kubeconfig
+		default:
+			result[k] = v
+		}
+	}
+	result["kubeconfig"] = containerKubeDir + "/config"
+	return result, nil
+}
+
+// rewriteLoopbackServer rewrites the server of the given cluster so that it refers to the docker host
+// when it refers to the loopback interface. The original host name is retained as the TLS server name
+// so that the server's certificate can still be verified. Returns true if the server was rewritten.
+func rewriteLoopbackServer(cluster *api.Cluster) bool {
+	u, err := url.Parse(cluster.Server)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return false
+		}
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(dockerHost, port)
+	} else {
+		u.Host = dockerHost
+	}
+	cluster.Server = u.String()
+	if cluster.TLSServerName == "" && !cluster.InsecureSkipTLSVerify {
+		cluster.TLSServerName = host
+	}
+	return true
+}
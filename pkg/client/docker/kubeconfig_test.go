@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func Test_rewriteLoopbackServer(t *testing.T) {
+	tests := []struct {
+		name          string
+		cluster       api.Cluster
+		rewritten     bool
+		server        string
+		tlsServerName string
+	}{
+		{
+			name:          "localhost",
+			cluster:       api.Cluster{Server: "https://localhost:6443"},
+			rewritten:     true,
+			server:        "https://host.docker.internal:6443",
+			tlsServerName: "localhost",
+		},
+		{
+			name:          "IPv4 loopback",
+			cluster:       api.Cluster{Server: "https://127.0.0.1:41235"},
+			rewritten:     true,
+			server:        "https://host.docker.internal:41235",
+			tlsServerName: "127.0.0.1",
+		},
+		{
+			name:          "IPv6 loopback without port",
+			cluster:       api.Cluster{Server: "https://[::1]"},
+			rewritten:     true,
+			server:        "https://host.docker.internal",
+			tlsServerName: "::1",
+		},
+		{
+			name:          "explicit TLS server name",
+			cluster:       api.Cluster{Server: "https://127.0.0.1:6443", TLSServerName: "kubernetes"},
+			rewritten:     true,
+			server:        "https://host.docker.internal:6443",
+			tlsServerName: "kubernetes",
+		},
+		{
+			name:      "insecure",
+			cluster:   api.Cluster{Server: "https://localhost:6443", InsecureSkipTLSVerify: true},
+			rewritten: true,
+			server:    "https://host.docker.internal:6443",
+		},
+		{
+			name:    "remote",
+			cluster: api.Cluster{Server: "https://k8s.example.com:443"},
+			server:  "https://k8s.example.com:443",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := tt.cluster
+			assert.Equal(t, tt.rewritten, rewriteLoopbackServer(&cluster))
+			assert.Equal(t, tt.server, cluster.Server)
+			assert.Equal(t, tt.tlsServerName, cluster.TLSServerName)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+	"net/http"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// tokenHeader is the gRPC metadata key that carries the token of the daemon container. gRPC metadata
+// is sent as HTTP/2 headers, so the token can be verified before the request reaches the gRPC server.
+const tokenHeader = "x-telepresence-daemon-token"
+
+// newToken returns a new random token for a daemon container.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// tokenCredentials adds the token of the daemon container to each call made by a gRPC client.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{tokenHeader: string(t)}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// ListenerHandler returns the handler that serves the given gRPC server on the given listener. The TCP
+// listeners of the daemons in a daemon container are published on the host, so when the daemons run in a
+// container, requests that arrive on a TCP listener are refused unless they carry the container's token.
+func ListenerHandler(ctx context.Context, l net.Listener, h http.Handler) http.Handler {
+	env := client.GetEnv(ctx)
+	if env == nil || env.DaemonToken == "" || l.Addr().Network() != "tcp" {
+		return h
+	}
+	token := []byte(env.DaemonToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(tokenHeader)), token) != 1 {
+			// gRPC clients report this as codes.Unauthenticated.
+			http.Error(w, "invalid daemon token", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
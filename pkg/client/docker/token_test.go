@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestListenerHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tcpL, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcpL.Close()
+
+	serve := func(ctx context.Context, l net.Listener, token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/telepresence.daemon.Daemon/Status", nil)
+		if token != "" {
+			r.Header.Set(tokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		ListenerHandler(ctx, l, ok).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	ctx := client.WithEnv(context.Background(), &client.Env{DaemonToken: "secret"})
+	assert.Equal(t, http.StatusUnauthorized, serve(ctx, tcpL, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(ctx, tcpL, "wrong"))
+	assert.Equal(t, http.StatusNoContent, serve(ctx, tcpL, "secret"))
+
+	// Without a daemon container, there's no token to check.
+	assert.Equal(t, http.StatusNoContent, serve(client.WithEnv(context.Background(), &client.Env{}), tcpL, ""))
+}
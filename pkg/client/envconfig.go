@@ -26,6 +26,14 @@ type Env struct {
 	// This environment variable becomes the default for the images.agentImage and images.webhookAgentImage
 	AgentImage string `env:"TELEPRESENCE_AGENT_IMAGE,default="`
 
+	// DaemonContainer is set to the name of the container that runs the daemons when they were
+	// started using 'telepresence connect --docker'
+	DaemonContainer string `env:"TELEPRESENCE_DAEMON_CONTAINER,default="`
+
+	// DaemonToken is set to the token that clients must present to the TCP listeners of the daemons
+	// when they run in a daemon container.
+	DaemonToken string `env:"TELEPRESENCE_DAEMON_TOKEN,default="`
+
 	lookuper envconfig.Lookuper
 }
 
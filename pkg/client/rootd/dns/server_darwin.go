@@ -13,6 +13,7 @@ import (
 
 	"github.com/datawire/dlib/dgroup"
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/vif"
 )
 
@@ -124,6 +125,10 @@ func (r *resolveFile) setSearchPaths(paths ...string) {
 // that the Telepresence DNS server listens to. The file is removed, and the DNS is flushed when
 // the worker terminates
 //
+// The names of all files that the worker creates include the name of the connection unless it's the
+// default connection, so that the root daemons of several connections don't overwrite or remove
+// each other's files.
+//
 // For more information about /etc/resolver files, please view the man pages available at
 //
 //   man 5 resolver
@@ -131,7 +136,8 @@ func (r *resolveFile) setSearchPaths(paths ...string) {
 // or, if not on a Mac, follow this link: https://www.manpagez.com/man/5/resolver/
 func (s *Server) Worker(c context.Context, dev vif.Device, configureDNS func(net.IP, *net.UDPAddr)) error {
 	resolverDirName := filepath.Join("/etc", "resolver")
-	resolverFileName := filepath.Join(resolverDirName, "telepresence.local")
+	filePrefix := client.ConnectionProcessName(c, "telepresence")
+	resolverFileName := filepath.Join(resolverDirName, filePrefix+".local")
 
 	listener, err := newLocalUDPListener(c)
 	if err != nil {
@@ -167,7 +173,7 @@ func (s *Server) Worker(c context.Context, dev vif.Device, configureDNS func(net
 
 		// Remove each namespace resolver file
 		for domain := range s.domains {
-			_ = os.Remove(domainResolverFile(resolverDirName, domain))
+			_ = os.Remove(domainResolverFile(resolverDirName, filePrefix, domain))
 		}
 		s.flushDNS()
 	}()
@@ -177,15 +183,16 @@ func (s *Server) Worker(c context.Context, dev vif.Device, configureDNS func(net
 	g.Go("Server", func(c context.Context) error {
 		// Server will close the listener, so no need to close it here.
 		s.processSearchPaths(g, func(c context.Context, paths []string, device vif.Device) error {
-			return s.updateResolverFiles(c, resolverDirName, resolverFileName, dnsAddr, paths)
+			return s.updateResolverFiles(c, resolverDirName, filePrefix, dnsAddr, paths)
 		}, dev)
 		return s.Run(c, make(chan struct{}), []net.PacketConn{listener}, nil, s.resolveInCluster)
 	})
 	return g.Wait()
 }
 
-func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolverFileName string, dnsAddr *net.UDPAddr, paths []string) error {
+func (s *Server) updateResolverFiles(c context.Context, resolverDirName, filePrefix string, dnsAddr *net.UDPAddr, paths []string) error {
 	dlog.Infof(c, "setting search paths %s", strings.Join(paths, " "))
+	resolverFileName := filepath.Join(resolverDirName, filePrefix+".local")
 	rf, err := readResolveFile(resolverFileName)
 	if err != nil {
 		return err
@@ -217,7 +224,7 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 
 	// On Darwin, we provide resolution of NAME.NAMESPACE by adding one domain
 	// for each namespace in its own domain file under /etc/resolver. Each file
-	// is named "telepresence.<domain>.local"
+	// is named "telepresence.<domain>.local", or "telepresence-<connection>.<domain>.local"
 	var removals []string
 	var additions []string
 	for domain := range s.domains {
@@ -236,7 +243,7 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 	s.domains = domains
 
 	for _, domain := range removals {
-		nsFile := domainResolverFile(resolverDirName, domain)
+		nsFile := domainResolverFile(resolverDirName, filePrefix, domain)
 		dlog.Infof(c, "Removing %s", nsFile)
 		if err = os.Remove(nsFile); err != nil {
 			dlog.Error(c, err)
@@ -248,7 +255,7 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 			domain:      domain,
 			nameservers: []net.IP{dnsAddr.IP},
 		}
-		nsFile := domainResolverFile(resolverDirName, domain)
+		nsFile := domainResolverFile(resolverDirName, filePrefix, domain)
 		dlog.Infof(c, "Generated new %s", nsFile)
 		if err = df.write(nsFile); err != nil {
 			dlog.Error(c, err)
@@ -267,6 +274,6 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 	return nil
 }
 
-func domainResolverFile(resolverDirName, domain string) string {
-	return filepath.Join(resolverDirName, "telepresence."+domain+".local")
+func domainResolverFile(resolverDirName, filePrefix, domain string) string {
+	return filepath.Join(resolverDirName, filePrefix+"."+domain+".local")
 }
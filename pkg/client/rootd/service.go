@@ -28,6 +28,7 @@ import (
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
@@ -74,16 +75,23 @@ type service struct {
 
 // Command returns the telepresence sub-command "daemon-foreground"
 func Command() *cobra.Command {
-	return &cobra.Command{
+	var name, address string
+	cmd := &cobra.Command{
 		Use:    ProcessName + "-foreground <logging dir> <config dir>",
 		Short:  "Launch Telepresence " + titleName + " in the foreground (debug)",
 		Args:   cobra.ExactArgs(2),
 		Hidden: true,
 		Long:   help,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(cmd.Context(), args[0], args[1])
+			if err := client.ValidateConnectionName(name); err != nil {
+				return err
+			}
+			return run(client.WithConnectionName(cmd.Context(), name), args[0], args[1], address)
 		},
 	}
+	cmd.Flags().StringVar(&name, "name", client.DefaultConnectionName, "The name of the connection managed by this daemon")
+	cmd.Flags().StringVar(&address, "address", "", "A TCP address that the daemon listens to in addition to its socket")
+	return cmd
 }
 
 func (d *service) Version(_ context.Context, _ *empty.Empty) (*common.VersionInfo, error) {
@@ -99,6 +107,7 @@ func (d *service) Status(_ context.Context, _ *empty.Empty) (*rpc.DaemonStatus,
 	r := &rpc.DaemonStatus{}
 	if d.session != nil {
 		r.OutboundConfig = d.session.getInfo()
+		r.Subnets = d.session.getRoutedSubnets()
 	}
 	return r, nil
 }
@@ -284,7 +293,7 @@ nextSession:
 	return nil
 }
 
-func (d *service) serveGrpc(c context.Context, l net.Listener, tracer common.TracingServer) error {
+func (d *service) serveGrpc(c context.Context, tracer common.TracingServer, listeners ...net.Listener) error {
 	defer func() {
 		// Error recovery.
 		if perr := derror.PanicToError(recover()); perr != nil {
@@ -306,11 +315,18 @@ func (d *service) serveGrpc(c context.Context, l net.Listener, tracer common.Tra
 	rpc.RegisterDaemonServer(svc, d)
 	common.RegisterTracingServer(svc, tracer)
 
-	sc := &dhttp.ServerConfig{
-		Handler: svc,
-	}
 	dlog.Info(c, "gRPC server started")
-	err := sc.Serve(c, l)
+	g := dgroup.NewGroup(c, dgroup.GroupConfig{})
+	for _, l := range listeners {
+		l := l
+		sc := &dhttp.ServerConfig{
+			Handler: docker.ListenerHandler(c, l, svc),
+		}
+		g.Go(l.Addr().Network(), func(c context.Context) error {
+			return sc.Serve(c, l)
+		})
+	}
+	err := g.Wait()
 	if err != nil {
 		dlog.Errorf(c, "gRPC server ended with: %v", err)
 	} else {
@@ -320,7 +336,7 @@ func (d *service) serveGrpc(c context.Context, l net.Listener, tracer common.Tra
 }
 
 // run is the main function when executing as the daemon
-func run(c context.Context, loggingDir, configDir string) error {
+func run(c context.Context, loggingDir, configDir, address string) error {
 	if !proc.IsAdmin() {
 		return fmt.Errorf("telepresence %s must run with elevated privileges", ProcessName)
 	}
@@ -340,7 +356,7 @@ func run(c context.Context, loggingDir, configDir string) error {
 	c = client.WithConfig(c, cfg)
 
 	c = dgroup.WithGoroutineName(c, "/"+ProcessName)
-	c, err = logging.InitContext(c, ProcessName, logging.RotateDaily, true)
+	c, err = logging.InitContext(c, client.ConnectionProcessName(c, ProcessName), logging.RotateDaily, true)
 	if err != nil {
 		return err
 	}
@@ -358,7 +374,7 @@ func run(c context.Context, loggingDir, configDir string) error {
 	// Listen on domain unix domain socket or windows named pipe. The listener must be opened
 	// before other tasks because the CLI client will only wait for a short period of time for
 	// the socket/pipe to appear before it gives up.
-	grpcListener, err := client.ListenSocket(c, ProcessName, client.DaemonSocketName)
+	grpcListener, err := client.ListenSocket(c, ProcessName, client.DaemonSocket(c))
 	if err != nil {
 		return err
 	}
@@ -366,6 +382,15 @@ func run(c context.Context, loggingDir, configDir string) error {
 		_ = client.RemoveSocket(grpcListener)
 	}()
 	dlog.Debug(c, "Listener opened")
+	listeners := []net.Listener{grpcListener}
+	if address != "" {
+		tcpListener, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		dlog.Debugf(c, "Listening on %s", tcpListener.Addr())
+		listeners = append(listeners, tcpListener)
+	}
 
 	d := &service{
 		scout:          scout.NewReporter(c, "daemon"),
@@ -387,7 +412,7 @@ func run(c context.Context, loggingDir, configDir string) error {
 	// Add a reload function that triggers on create and write of the config.yml file.
 	g.Go("config-reload", d.configReload)
 	g.Go("session", d.manageSessions)
-	g.Go("server-grpc", func(c context.Context) error { return d.serveGrpc(c, grpcListener, tracer) })
+	g.Go("server-grpc", func(c context.Context) error { return d.serveGrpc(c, tracer, listeners...) })
 	g.Go("metriton", d.scout.Run)
 	err = g.Wait()
 	if err != nil {
@@ -117,6 +117,10 @@ type session struct {
 	curSubnets      []*net.IPNet
 	curStaticRoutes []*routing.Route
 
+	// A copy of curSubnets that is safe to read from other goroutines.
+	routedSubnetsLock sync.RWMutex
+	routedSubnets     []*net.IPNet
+
 	// closing is set during shutdown and can have the values:
 	//   0 = running
 	//   1 = closing
@@ -146,7 +150,7 @@ func connectToManager(c context.Context) (*grpc.ClientConn, manager.ManagerClien
 	defer cancel()
 
 	var conn *grpc.ClientConn
-	conn, err := client.DialSocket(tc, client.ConnectorSocketName,
+	conn, err := client.DialSocket(tc, client.ConnectorSocket(tc),
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
 	)
@@ -281,6 +285,17 @@ func (s *session) getInfo() *rpc.OutboundInfo {
 	return &info
 }
 
+// getRoutedSubnets returns the subnets that are currently routed to the TUN device.
+func (s *session) getRoutedSubnets() []*manager.IPNet {
+	s.routedSubnetsLock.RLock()
+	defer s.routedSubnetsLock.RUnlock()
+	subnets := make([]*manager.IPNet, len(s.routedSubnets))
+	for i, sn := range s.routedSubnets {
+		subnets[i] = iputil.IPNetToRPC(sn)
+	}
+	return subnets
+}
+
 func (s *session) configureDNS(dnsIP net.IP, dnsLocalAddr *net.UDPAddr) {
 	s.remoteDnsIP = dnsIP
 	s.dnsLocalAddr = dnsLocalAddr
@@ -363,6 +378,12 @@ func (s *session) refreshSubnets(ctx context.Context) (err error) {
 	// Add desiredSubnets to the currently routed subnets
 	s.curSubnets = append(s.curSubnets, added...)
 
+	routed := make([]*net.IPNet, len(s.curSubnets))
+	copy(routed, s.curSubnets)
+	s.routedSubnetsLock.Lock()
+	s.routedSubnets = routed
+	s.routedSubnetsLock.Unlock()
+
 	for _, sn := range removed {
 		if err := s.dev.RemoveSubnet(ctx, sn); err != nil {
 			dlog.Errorf(ctx, "failed to remove subnet %s: %v", sn, err)
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -24,6 +25,9 @@ const (
 
 	// DaemonSocketName is the path used when communicating to the daemon process
 	DaemonSocketName = "/var/run/telepresence-daemon.socket"
+
+	// socketSuffix is the suffix shared by all socket names.
+	socketSuffix = ".socket"
 )
 
 func dialSocket(ctx context.Context, socketName string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
@@ -99,6 +103,21 @@ func removeSocket(listener net.Listener) error {
 	return os.Remove(listener.Addr().String())
 }
 
+// socketNamesWithPrefix returns the paths of all sockets that have the given prefix.
+func socketNamesWithPrefix(prefix string) ([]string, error) {
+	paths, err := filepath.Glob(prefix + "*" + socketSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var socketNames []string
+	for _, path := range paths {
+		if exists, err := socketExists(path); err == nil && exists {
+			socketNames = append(socketNames, path)
+		}
+	}
+	return socketNames, nil
+}
+
 // socketExists returns true if a socket is found at the given path
 func socketExists(path string) (bool, error) {
 	s, err := os.Stat(path)
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
+	"strings"
 
 	"github.com/Microsoft/go-winio"
 	"golang.org/x/sys/windows"
@@ -23,6 +25,12 @@ const (
 
 	// DaemonSocketName is the name used when communicating to the daemon process
 	DaemonSocketName = `\\.\pipe\telepresence-daemon`
+
+	// socketSuffix is the suffix shared by all socket names.
+	socketSuffix = ""
+
+	// pipeDir is the directory that lists all named pipes.
+	pipeDir = `\\.\pipe\`
 )
 
 // dialSocket dials the given named pipe and returns the resulting connection
@@ -66,6 +74,21 @@ func removeSocket(listener net.Listener) error {
 	return nil
 }
 
+// socketNamesWithPrefix returns the names of all named pipes that have the given prefix.
+func socketNamesWithPrefix(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(pipeDir)
+	if err != nil {
+		return nil, err
+	}
+	var socketNames []string
+	for _, entry := range entries {
+		if name := pipeDir + entry.Name(); strings.HasPrefix(name, prefix) {
+			socketNames = append(socketNames, name)
+		}
+	}
+	return socketNames, nil
+}
+
 // socketExists returns true if a socket exists with the given name
 func socketExists(name string) (bool, error) {
 	uPath, err := windows.UTF16PtrFromString(name)
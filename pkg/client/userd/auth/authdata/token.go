@@ -9,7 +9,8 @@ import (
 )
 
 const (
-	tokenFile = "tokens.json"
+	tokenFile   = "tokens.json"
+	idTokenFile = "id-token.json"
 )
 
 // SaveTokenToUserCache saves the provided token to user cache and returns an error if something
@@ -33,3 +34,24 @@ func LoadTokenFromUserCache(ctx context.Context) (*oauth2.Token, error) {
 func DeleteTokenFromUserCache(ctx context.Context) error {
 	return cache.DeleteFromUserCache(ctx, tokenFile)
 }
+
+// SaveIDTokenToUserCache saves the provided OpenID Connect ID token to user cache and returns an error if
+// something goes wrong while marshalling or persisting.
+func SaveIDTokenToUserCache(ctx context.Context, idToken string) error {
+	return cache.SaveToUserCache(ctx, idToken, idTokenFile)
+}
+
+// LoadIDTokenFromUserCache gets the OpenID Connect ID token from cache or returns an error if something
+// goes wrong while loading or unmarshalling.
+func LoadIDTokenFromUserCache(ctx context.Context) (string, error) {
+	var idToken string
+	if err := cache.LoadFromUserCache(ctx, &idToken, idTokenFile); err != nil {
+		return "", err
+	}
+	return idToken, nil
+}
+
+// DeleteIDTokenFromUserCache removes the OpenID Connect ID token cache if existing or returns an error
+func DeleteIDTokenFromUserCache(ctx context.Context) error {
+	return cache.DeleteFromUserCache(ctx, idTokenFile)
+}
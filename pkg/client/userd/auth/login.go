@@ -54,12 +54,14 @@ type loginExecutor struct {
 
 	oauth2ConfigMu sync.RWMutex // locked unless a .Worker is running
 	oauth2Config   oauth2.Config
+	oidc           bool // true when the login is using an OpenID Connect provider instead of Ambassador Cloud
 
 	loginMu               sync.Mutex
 	callbacks             chan oauth2Callback
 	tokenSource           oauth2.TokenSource
 	loginToken            chan oauth2.Token // used to pass token from login command to refresh goroutine
 	userInfo              *authdata.UserInfo
+	idToken               string                       // the ID token of the OpenID Connect provider
 	apikeys               map[string]map[string]string // map[env.LoginDomain]map[apikeyDescription]apikey
 	refreshTimer          *time.Timer
 	refreshTimerIsStopped bool
@@ -75,6 +77,7 @@ type LoginExecutor interface {
 	GetAPIKey(ctx context.Context, description string) (string, error)
 	GetLicense(ctx context.Context, id string) (string, string, error)
 	GetUserInfo(ctx context.Context, refresh bool) (*authdata.UserInfo, error)
+	GetIDToken(ctx context.Context) (string, error)
 }
 
 // NewLoginExecutor returns an instance of LoginExecutor
@@ -133,6 +136,11 @@ func (l *loginExecutor) tokenErrCB(ctx context.Context, err error) error {
 }
 
 func (l *loginExecutor) tokenCB(ctx context.Context, tokenInfo *oauth2.Token) error {
+	if l.oidc {
+		if err := l.lockedSetIDToken(ctx, tokenInfo); err != nil {
+			return err
+		}
+	}
 	if err := l.SaveTokenFunc(ctx, tokenInfo); err != nil {
 		return fmt.Errorf("could not save access token to user cache: %w", err)
 	}
@@ -194,6 +202,13 @@ func (l *loginExecutor) Worker(ctx context.Context) error {
 		},
 		Scopes: []string{"openid", "profile", "email"},
 	}
+	if oidcCfg := &client.GetConfig(ctx).OIDC; oidcCfg.Enabled() {
+		// A failed discovery is logged rather than returned, because the worker must keep running. The
+		// login will fail until the worker is restarted with a provider that can be discovered.
+		if err = l.configureOIDC(ctx, oidcCfg); err != nil {
+			dlog.Error(ctx, err)
+		}
+	}
 
 	l.tokenSource, err = func() (oauth2.TokenSource, error) {
 		l.resetRefreshTimerUnlocked(0)
@@ -214,6 +229,13 @@ func (l *loginExecutor) Worker(ctx context.Context) error {
 		return err
 	}
 
+	if l.oidc {
+		l.idToken, err = authdata.LoadIDTokenFromUserCache(ctx)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
 	if err := cache.LoadFromUserCache(ctx, &l.apikeys, apikeysFile); err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -306,6 +328,10 @@ func (l *loginExecutor) Login(ctx context.Context) (err error) {
 		l.reportLoginResult(ctx, err, "browser")
 	}()
 
+	if l.oidc && l.oauth2Config.Endpoint.AuthURL == "" {
+		return fmt.Errorf("the OpenID Connect provider %s has not been discovered", client.GetConfig(ctx).OIDC.IssuerURL)
+	}
+
 	// create OAuth2 authentication code flow URL
 	state := uuid.New().String()
 	var pkceVerifier CodeVerifier
@@ -349,11 +375,18 @@ func (l *loginExecutor) Login(ctx context.Context) (err error) {
 			return err
 		}
 
-		err = l.lockedRetrieveUserInfo(ctx, map[string]string{
-			"Authorization": "Bearer " + token.AccessToken,
-		})
-		if err != nil {
-			return err
+		if l.oidc {
+			// The user info is obtained from the ID token by the l.tokenCB.
+			if l.idToken == "" {
+				return errors.New("the OpenID Connect provider did not issue an ID token")
+			}
+		} else {
+			err = l.lockedRetrieveUserInfo(ctx, map[string]string{
+				"Authorization": "Bearer " + token.AccessToken,
+			})
+			if err != nil {
+				return err
+			}
 		}
 
 		// We pass the token to the goroutine that ensures the token
@@ -415,6 +448,9 @@ func (l *loginExecutor) Logout(ctx context.Context) (err error) {
 	l.userInfo = nil
 	_ = authdata.DeleteUserInfoFromUserCache(ctx)
 
+	l.idToken = ""
+	_ = authdata.DeleteIDTokenFromUserCache(ctx)
+
 	l.apikeys[env.LoginDomain] = make(map[string]string)
 	if saveErr := cache.SaveToUserCache(ctx, l.apikeys, apikeysFile); saveErr != nil {
 		if err == nil {
@@ -492,7 +528,15 @@ func (l *loginExecutor) GetUserInfo(ctx context.Context, refresh bool) (*authdat
 	l.loginMu.Lock()
 	defer l.loginMu.Unlock()
 
-	if refresh {
+	if refresh && l.oidc {
+		// Refreshing the tokens also refreshes the user info that is obtained from the ID token.
+		if l.tokenSource == nil {
+			return nil, fmt.Errorf("GetUserInfo: %w", ErrNotLoggedIn)
+		}
+		if _, err := l.tokenSource.Token(); err != nil {
+			return nil, err
+		}
+	} else if refresh {
 		creds, err := l.lockedGetCreds(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("GetUserInfo: %w", err)
@@ -507,6 +551,27 @@ func (l *loginExecutor) GetUserInfo(ctx context.Context, refresh bool) (*authdat
 	return l.userInfo, nil
 }
 
+// GetIDToken returns the ID token of the OpenID Connect provider. The tokens are refreshed first if the ID
+// token has expired. An empty string is returned when no OpenID Connect provider is configured.
+func (l *loginExecutor) GetIDToken(ctx context.Context) (string, error) {
+	l.loginMu.Lock()
+	defer l.loginMu.Unlock()
+
+	if !l.oidc {
+		return "", nil
+	}
+	if l.tokenSource == nil {
+		return "", fmt.Errorf("GetIDToken: %w", ErrNotLoggedIn)
+	}
+	if _, err := l.tokenSource.Token(); err != nil {
+		return "", err
+	}
+	if l.idToken == "" {
+		return "", fmt.Errorf("GetIDToken: %w", ErrNotLoggedIn)
+	}
+	return l.idToken, nil
+}
+
 func (l *loginExecutor) GetAPIKey(ctx context.Context, description string) (string, error) {
 	l.loginMu.Lock()
 	defer l.loginMu.Unlock()
@@ -577,7 +642,10 @@ func (l *loginExecutor) httpHandler(ctx context.Context, w http.ResponseWriter,
 
 	var sb strings.Builder
 	sb.WriteString("<!DOCTYPE html><html><head><title>Authentication Successful</title></head><body>")
-	if errorName == "" && code != "" {
+	if errorName == "" && code != "" && l.oidc {
+		sb.WriteString("<h1>Authentication Successful</h1>")
+		sb.WriteString("<p>You can now close this tab and resume on the CLI.</p>")
+	} else if errorName == "" && code != "" {
 		completionURL := client.GetEnv(ctx).LoginCompletionURL
 		// Attribute login to the correct client
 		if mech, _ := client.GetInstallMechanism(); mech == "docker" {
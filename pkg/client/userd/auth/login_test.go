@@ -15,6 +15,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 
 	"github.com/datawire/dlib/dcontext"
 	"github.com/datawire/dlib/dgroup"
@@ -25,6 +27,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/auth"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/auth/authdata"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/oidc"
 )
 
 type MockSaveTokenWrapper struct {
@@ -65,6 +68,7 @@ type MockOauth2Server struct {
 	TokenRequestFormValues []url.Values
 	TokenResponseCode      int
 	UserInfo               *authdata.UserInfo
+	IDToken                string
 }
 
 func newMockOauth2Server(t *testing.T) *MockOauth2Server {
@@ -102,6 +106,7 @@ func newMockOauth2Server(t *testing.T) *MockOauth2Server {
 	handler.Handle("/auth", http.NotFoundHandler())
 	handler.Handle("/token", oauth2Server.HandleToken())
 	handler.Handle("/api/userinfo", oauth2Server.HandleUserInfo())
+	handler.Handle(oidc.DiscoveryPath, oauth2Server.HandleDiscovery())
 	return oauth2Server
 }
 
@@ -133,7 +138,12 @@ func (s *MockOauth2Server) HandleToken() http.Handler {
 		s.TokenRequestFormValues = append(s.TokenRequestFormValues, r.Form)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(s.TokenResponseCode)
+		idToken := ""
+		if s.IDToken != "" {
+			idToken = fmt.Sprintf(`"id_token": %q,`, s.IDToken)
+		}
 		_, _ = w.Write([]byte(`{
+				` + idToken + `
 				"access_token": "mock-access-token",
 				"expires_in": 3600,
 				"refresh_token": "mock-refresh-token",
@@ -144,6 +154,18 @@ func (s *MockOauth2Server) HandleToken() http.Handler {
 	})
 }
 
+func (s *MockOauth2Server) HandleDiscovery() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&oidc.Provider{
+			Issuer:                s.urlForPath(""),
+			AuthorizationEndpoint: s.AuthUrl(),
+			TokenEndpoint:         s.TokenUrl(),
+			JWKSURI:               s.urlForPath("/keys"),
+		})
+	})
+}
+
 func (s *MockOauth2Server) HandleUserInfo() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if s.UserInfo == nil {
@@ -447,6 +469,83 @@ func TestLoginFlow(t *testing.T) {
 		cancel()
 		require.NoError(t, grp.Wait())
 	})
+
+	t.Run("will use an OpenID Connect provider when configured", func(t *testing.T) {
+		// given
+		f := setup(t)
+		defer f.MockOauth2Server.TearDown(t)
+		idToken := mockIDToken(t, time.Now().Add(30*time.Minute))
+		f.MockOauth2Server.IDToken = idToken
+		f.MockOauth2Server.UserInfo = nil // must not be used
+		client.GetConfig(f.Context).OIDC = client.OIDC{
+			IssuerURL: f.MockOauth2Server.urlForPath(""),
+			ClientID:  "mock-client-id",
+		}
+
+		// a fake user cache directory
+		ctx := filelocation.WithUserHomeDir(f.Context, t.TempDir())
+
+		// when
+		ctx, cancel := context.WithCancel(dcontext.WithSoftness(ctx))
+		grp := dgroup.NewGroup(ctx, dgroup.GroupConfig{})
+		grp.Go("worker", f.Runner.Worker)
+		loginErrCh := make(chan error)
+		grp.Go("login", func(ctx context.Context) error {
+			err := f.Runner.Login(ctx)
+			loginErrCh <- err
+			return err
+		})
+		rawAuthUrl := <-f.OpenedUrls
+		callbackUrl := extractRedirectUriFromAuthUrl(t, rawAuthUrl)
+		callbackQuery := callbackUrl.Query()
+		callbackQuery.Set("code", "mock-code")
+		callbackUrl.RawQuery = callbackQuery.Encode()
+		callbackResponse := sendCallbackRequest(t, callbackUrl)
+		defer callbackResponse.Body.Close()
+		err := <-loginErrCh
+
+		// then
+		require.NoError(t, err, "no error running login flow")
+		assert.Equal(t, http.StatusOK, callbackResponse.StatusCode, "callback status is 200")
+		authUrl, err := url.Parse(rawAuthUrl)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rawAuthUrl, f.MockOauth2Server.AuthUrl()), "auth url")
+		assert.Equal(t, "mock-client-id", authUrl.Query().Get("client_id"), "client id")
+		assert.Equal(t, "openid profile email", authUrl.Query().Get("scope"), "scopes")
+		require.Len(t, f.MockSaveTokenWrapper.CallArguments, 1, "one call to save the token")
+		token := f.MockSaveTokenWrapper.CallArguments[0]
+		assert.True(t, token.Expiry.Before(time.Now().Add(31*time.Minute)), "token expires with the ID token")
+		require.Len(t, f.MockSaveUserInfoWrapper.CallArguments, 1, "one call to save the user info")
+		userInfo := f.MockSaveUserInfoWrapper.CallArguments[0]
+		assert.Equal(t, "mock-subject", userInfo.Id, "user id")
+		assert.Equal(t, "mock-user-name", userInfo.Name, "user name")
+		assert.Equal(t, "mock-user@example.com", userInfo.Email, "user email")
+		cachedIDToken, err := authdata.LoadIDTokenFromUserCache(ctx)
+		require.NoError(t, err, "no error reading ID token")
+		assert.Equal(t, idToken, cachedIDToken)
+		currentIDToken, err := f.Runner.GetIDToken(ctx)
+		require.NoError(t, err, "no error getting ID token")
+		assert.Equal(t, idToken, currentIDToken)
+
+		require.NoError(t, f.Runner.Logout(ctx), "no error executing logout")
+		_, err = authdata.LoadIDTokenFromUserCache(ctx)
+		require.Error(t, err, "error reading ID token")
+		cancel()
+		require.NoError(t, grp.Wait())
+	})
+}
+
+func mockIDToken(t *testing.T, expiry time.Time) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("mock-signing-key")}, nil)
+	require.NoError(t, err)
+	idToken, err := jwt.Signed(signer).Claims(map[string]any{
+		"sub":   "mock-subject",
+		"name":  "mock-user-name",
+		"email": "mock-user@example.com",
+		"exp":   expiry.Unix(),
+	}).CompactSerialize()
+	require.NoError(t, err)
+	return idToken
 }
 
 func sendCallbackRequest(t *testing.T, callbackUrl *url.URL) *http.Response {
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/auth/authdata"
+	"github.com/telepresenceio/telepresence/v2/pkg/oidc"
+)
+
+// configureOIDC makes the login use the given OpenID Connect provider instead of Ambassador Cloud. The
+// endpoints of the provider are discovered using its issuer URL. May only be called from .Worker().
+func (l *loginExecutor) configureOIDC(ctx context.Context, cfg *client.OIDC) error {
+	l.oidc = true
+	p, err := oidc.Discover(ctx, cfg.IssuerURL)
+	if err != nil {
+		return err
+	}
+	scopes := []string{"openid"}
+	for _, scope := range cfg.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(cfg.Scopes) == 0 {
+		scopes = append(scopes, "profile", "email")
+	}
+	l.oauth2Config.ClientID = cfg.ClientID
+	l.oauth2Config.Scopes = scopes
+	l.oauth2Config.Endpoint = oauth2.Endpoint{
+		AuthURL:  p.AuthorizationEndpoint,
+		TokenURL: p.TokenEndpoint,
+	}
+	return nil
+}
+
+// lockedSetIDToken stores the ID token that the OpenID Connect provider issued together with the given
+// token, and derives the user info from its claims. The expiry of the given token is lowered to the
+// expiry of the ID token when that is earlier, so that the tokens are refreshed before the ID token
+// expires.
+//
+// Must hold l.loginMu to call this.
+func (l *loginExecutor) lockedSetIDToken(ctx context.Context, tokenInfo *oauth2.Token) error {
+	idToken, _ := tokenInfo.Extra("id_token").(string)
+	if idToken == "" {
+		// A provider isn't required to issue a new ID token when the tokens are refreshed.
+		return nil
+	}
+	claims, err := oidc.UnverifiedClaims(idToken)
+	if err != nil {
+		return err
+	}
+	if exp := claims.Expiry; exp != nil && (tokenInfo.Expiry.IsZero() || exp.Time().Before(tokenInfo.Expiry)) {
+		tokenInfo.Expiry = exp.Time()
+	}
+	if err = authdata.SaveIDTokenToUserCache(ctx, idToken); err != nil {
+		return fmt.Errorf("could not save ID token to user cache: %w", err)
+	}
+	l.idToken = idToken
+
+	userInfo := &authdata.UserInfo{
+		Id:        claims.Subject,
+		Name:      claims.Name,
+		Email:     claims.Email,
+		AvatarUrl: claims.Picture,
+	}
+	if userInfo.Name == "" {
+		userInfo.Name = claims.PreferredUsername
+	}
+	l.userInfo = userInfo
+	return l.SaveUserInfoFunc(ctx, userInfo)
+}
@@ -37,6 +37,7 @@ type AutocompletionFunc func(ctx context.Context, cmd *cobra.Command, args []str
 func commands() []command {
 	return []command{
 		&interceptCommand{},
+		&composeCommand{},
 		&traceCommand{},
 		&pushTracesCommand{},
 	}
@@ -0,0 +1,344 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/extensions"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+// composeExtension is the name of the compose service extension that declares an intercept.
+const composeExtension = "x-telepresence"
+
+// composeIntercept is the content of the x-telepresence extension of a compose service.
+type composeIntercept struct {
+	// Name is the name of the intercept. Defaults to the name of the compose service.
+	Name string `yaml:"name"`
+
+	// Workload is the name of the workload to intercept. Defaults to the name of the compose service.
+	Workload string `yaml:"workload"`
+
+	// Namespace is the namespace of the workload.
+	Namespace string `yaml:"namespace"`
+
+	// Service is the name of the Kubernetes service to intercept.
+	Service string `yaml:"service"`
+
+	// Port is <local port>:<container port>[:<svcPortIdentifier>], i.e. the same format as the --port
+	// flag uses together with --docker-run.
+	Port string `yaml:"port"`
+
+	// ToPod are additional ports to forward from the intercepted pod.
+	ToPod []string `yaml:"toPod"`
+
+	// Mount is "true", "false", or the local mount point of the remote volumes.
+	Mount string `yaml:"mount"`
+
+	// DockerMount is the volume mount point in the container. Defaults to the local mount point.
+	DockerMount string `yaml:"dockerMount"`
+}
+
+type composeCommand struct {
+	file    string
+	command *cobra.Command
+}
+
+func (c *composeCommand) group() string {
+	return "Traffic Commands"
+}
+
+func (c *composeCommand) cobraCommand(ctx context.Context) *cobra.Command {
+	if c.command != nil {
+		return c.command
+	}
+	c.command = &cobra.Command{
+		Use:       "compose up [flags]",
+		Args:      cobra.ExactValidArgs(1),
+		ValidArgs: []string{"up"},
+		Short:     "Run Docker Compose with intercepted services",
+		Long: `Run Docker Compose with intercepted services.
+
+Services in the compose file that have an "x-telepresence" extension are intercepted, and the
+intercepts are removed when 'docker compose up' ends. The remote environment and volume mounts
+of each intercept are injected into its service, and the service's container port is published
+on the local port of the intercept. All services can reach the cluster.`,
+
+		Annotations: map[string]string{
+			CommandRequiresSession:         "",
+			CommandRequiresConnectorServer: "",
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	c.command.Flags().StringVarP(&c.file, "file", "f", "docker-compose.yml", "The compose file")
+	return c.command
+}
+
+func (c *composeCommand) init(ctx context.Context) {
+	if c.command == nil {
+		_ = c.cobraCommand(ctx)
+	}
+	c.command.RunE = func(cmd *cobra.Command, _ []string) error {
+		return c.up(cmd.Context())
+	}
+}
+
+func (c *composeCommand) up(ctx context.Context) (err error) {
+	if daemonContainer(ctx) != "" {
+		return errHostFile(ctx, "compose up")
+	}
+	file := c.file
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(GetCwd(ctx), file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return errcat.User.New(err)
+	}
+	var project map[string]any
+	if err = yaml.Unmarshal(data, &project); err != nil {
+		return errcat.User.Newf("unable to parse compose file %s: %w", file, err)
+	}
+	services, ok := project["services"].(map[string]any)
+	if !ok || len(services) == 0 {
+		return errcat.User.Newf("compose file %s declares no services", file)
+	}
+
+	session := trafficmgr.GetSession(ctx)
+	if session == nil {
+		return errors.New("no session found")
+	}
+	cs := GetConnectorServer(ctx)
+	if cs == nil {
+		return errors.New("no connector found")
+	}
+
+	// Extension flags aren't exposed by this command, so the default intercept mechanism is used.
+	extFlags := pflag.NewFlagSet("compose", pflag.ContinueOnError)
+	exts, err := extensions.LoadExtensions(ctx, extFlags)
+	if err != nil {
+		return err
+	}
+	extState, err := exts.AddToFlagSet(ctx, extFlags)
+	if err != nil {
+		return err
+	}
+	extRequiresLogin, err := extState.RequiresAPIKeyOrLicense()
+	if err != nil {
+		return err
+	}
+
+	// Process the services in a predictable order.
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	safeCmd := safeCobraCommandImpl{c.command}
+	var states []*interceptState
+	var stateServices []string
+	defer func() {
+		for _, is := range states {
+			is.scout.Close()
+		}
+	}()
+	for _, name := range names {
+		svc, ok := services[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		ci, err := composeServiceIntercept(name, svc)
+		if err != nil {
+			return err
+		}
+		if ci == nil {
+			continue
+		}
+		args := interceptArgs{
+			name:        ci.Name,
+			agentName:   ci.Workload,
+			namespace:   ci.Namespace,
+			port:        ci.Port,
+			serviceName: ci.Service,
+			weight:      100,
+			tlsUpstream: "tls",
+			previewSpec: &manager.PreviewSpec{},
+			mount:       ci.Mount,
+			mountSet:    ci.Mount != "",
+			toPod:       ci.ToPod,
+			dockerRun:   true,
+			dockerMount: ci.DockerMount,
+
+			extState:         extState,
+			extRequiresLogin: extRequiresLogin,
+		}
+		states = append(states, newInterceptState(ctx, safeCmd, args, cs, session.ManagerClient()))
+		stateServices = append(stateServices, name)
+	}
+	if len(states) == 0 {
+		return errcat.User.Newf("no service in compose file %s has an %q extension", file, composeExtension)
+	}
+
+	if err = ensureIntercepts(ctx, states); err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := removeIntercepts(ctx, states); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	for i, is := range states {
+		envFile, err := os.CreateTemp("", "tel-*.env")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary environment file. %w", err)
+		}
+		defer os.Remove(envFile.Name())
+		if err = is.writeEnvToFileAndClose(envFile); err != nil {
+			return err
+		}
+		injectIntercept(services[stateServices[i]].(map[string]any), envFile.Name(), is.localPort, is.dockerPort, is.mountPoint, is.args.dockerMount)
+	}
+	for _, svc := range services {
+		if svc, ok := svc.(map[string]any); ok {
+			// Make cluster names resolvable in all containers, just like --docker-run does.
+			appendToList(svc, "dns_search", "tel2-search")
+		}
+	}
+
+	data, err = yaml.Marshal(project)
+	if err != nil {
+		return err
+	}
+	composeFile, err := os.CreateTemp("", "tel-compose-*.yml")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary compose file. %w", err)
+	}
+	defer os.Remove(composeFile.Name())
+	if _, err = composeFile.Write(data); err != nil {
+		_ = composeFile.Close()
+		return err
+	}
+	if err = composeFile.Close(); err != nil {
+		return err
+	}
+	return c.runCompose(ctx, states, filepath.Dir(file), composeFile.Name())
+}
+
+// runCompose runs 'docker compose up' using the given compose file and waits for it to finish.
+func (c *composeCommand) runCompose(ctx context.Context, states []*interceptState, projectDir, composeFile string) error {
+	// The project directory is the directory of the original compose file, so that relative paths in that
+	// file are resolved in the same way as when docker compose is run without telepresence.
+	cmd, err := proc.Start(ctx, nil, c.command, "docker", "compose", "--project-directory", projectDir, "--file", composeFile, "up")
+	if err != nil {
+		dlog.Errorf(ctx, "error starting docker compose: %v", err)
+		return errcat.NoDaemonLogs.New(err)
+	}
+
+	// Send info about the pid and intercept ids to the traffic-manager so that it kills
+	// the process if it receives a leave of quit call.
+	for _, is := range states {
+		ior := connector.Interceptor{
+			InterceptId: is.env["TELEPRESENCE_INTERCEPT_ID"],
+			Pid:         int32(cmd.Process.Pid),
+		}
+		if _, err = is.connectorServer.AddInterceptor(ctx, &ior); err != nil {
+			if grpcStatus.Code(err) == grpcCodes.Canceled {
+				// Deactivation was caused by a disconnect
+				err = nil
+			}
+			dlog.Errorf(ctx, "error adding process with pid %d as interceptor: %v", ior.Pid, err)
+			_ = cmd.Process.Kill()
+			return err
+		}
+	}
+
+	// The external command will not output anything to the logs. An error here
+	// is likely caused by the user hitting <ctrl>-C to terminate the process.
+	return errcat.NoDaemonLogs.New(proc.Wait(ctx, nil, cmd))
+}
+
+// composeServiceIntercept returns the intercept declared by the x-telepresence extension of the given compose
+// service, or nil if the service has no such extension. The extension is removed from the service.
+func composeServiceIntercept(name string, svc map[string]any) (*composeIntercept, error) {
+	ext, ok := svc[composeExtension]
+	if !ok {
+		return nil, nil
+	}
+	delete(svc, composeExtension)
+
+	// Round-trip the extension to get it in the form of a composeIntercept.
+	data, err := yaml.Marshal(ext)
+	if err != nil {
+		return nil, err
+	}
+	ci := &composeIntercept{}
+	if ext != nil {
+		if err = yaml.Unmarshal(data, ci); err != nil {
+			return nil, errcat.User.Newf("invalid %s extension of service %s: %w", composeExtension, name, err)
+		}
+	}
+	if ci.Name == "" {
+		ci.Name = name
+	}
+	if ci.Workload == "" {
+		ci.Workload = name
+	}
+	if ci.Port == "" {
+		return nil, errcat.User.Newf("the %s extension of service %s must declare a port", composeExtension, name)
+	}
+	if ci.Mount == "" {
+		ci.Mount = "true"
+	}
+	return ci, nil
+}
+
+// injectIntercept modifies the given compose service so that it uses the given environment file, publishes
+// its container port on the local port of the intercept, and mounts the remote volumes.
+func injectIntercept(svc map[string]any, envFile string, localPort, containerPort uint16, mountPoint, dockerMount string) {
+	appendToList(svc, "env_file", envFile)
+	appendToList(svc, "ports", fmt.Sprintf("%d:%d", localPort, containerPort))
+	if mountPoint != "" {
+		if dockerMount == "" {
+			dockerMount = mountPoint
+		}
+		appendToList(svc, "volumes", fmt.Sprintf("%s:%s", mountPoint, dockerMount))
+	}
+}
+
+// appendToList appends the given value to the list of the given compose service key, unless the list already
+// contains the value. Compose accepts a single string in place of a list for some keys, so such a string is
+// converted into a list.
+func appendToList(svc map[string]any, key, value string) {
+	var list []any
+	switch v := svc[key].(type) {
+	case nil:
+	case []any:
+		list = v
+	default:
+		list = []any{v}
+	}
+	for _, e := range list {
+		if e == value {
+			return
+		}
+	}
+	svc[key] = append(list, value)
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testComposeFile = `
+services:
+  frontend:
+    image: frontend:latest
+    ports: ["3000:3000"]
+    env_file: common.env
+    x-telepresence:
+      workload: web
+      namespace: dev
+      port: "8080:80:http"
+      mount: false
+  backend:
+    image: backend:latest
+    x-telepresence:
+      port: "9090"
+  db:
+    image: postgres
+`
+
+func TestComposeServiceIntercept(t *testing.T) {
+	var project map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(testComposeFile), &project))
+	services := project["services"].(map[string]any)
+
+	fe := services["frontend"].(map[string]any)
+	ci, err := composeServiceIntercept("frontend", fe)
+	require.NoError(t, err)
+	assert.Equal(t, &composeIntercept{
+		Name:      "frontend",
+		Workload:  "web",
+		Namespace: "dev",
+		Port:      "8080:80:http",
+		Mount:     "false",
+	}, ci)
+	assert.NotContains(t, fe, composeExtension)
+
+	ci, err = composeServiceIntercept("backend", services["backend"].(map[string]any))
+	require.NoError(t, err)
+	assert.Equal(t, "backend", ci.Workload)
+	assert.Equal(t, "true", ci.Mount)
+
+	ci, err = composeServiceIntercept("db", services["db"].(map[string]any))
+	require.NoError(t, err)
+	assert.Nil(t, ci)
+
+	_, err = composeServiceIntercept("x", map[string]any{composeExtension: map[string]any{"workload": "x"}})
+	assert.Error(t, err, "port is required")
+}
+
+func TestInjectIntercept(t *testing.T) {
+	var project map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(testComposeFile), &project))
+	services := project["services"].(map[string]any)
+
+	fe := services["frontend"].(map[string]any)
+	injectIntercept(fe, "/tmp/tel-1.env", 8080, 80, "/tmp/mnt", "")
+	assert.Equal(t, []any{"common.env", "/tmp/tel-1.env"}, fe["env_file"])
+	assert.Equal(t, []any{"3000:3000", "8080:80"}, fe["ports"])
+	assert.Equal(t, []any{"/tmp/mnt:/tmp/mnt"}, fe["volumes"])
+
+	be := services["backend"].(map[string]any)
+	injectIntercept(be, "/tmp/tel-2.env", 9090, 9090, "", "")
+	assert.Equal(t, []any{"/tmp/tel-2.env"}, be["env_file"])
+	assert.Equal(t, []any{"9090:9090"}, be["ports"])
+	assert.NotContains(t, be, "volumes")
+
+	// Values are only added once.
+	appendToList(be, "ports", "9090:9090")
+	assert.Equal(t, []any{"9090:9090"}, be["ports"])
+}
@@ -144,7 +144,7 @@ func (*traceCommand) launchTraceWriter(ctx context.Context, destFile string) (ch
 }
 
 func (c *traceCommand) userdTraces(ctx context.Context, tCh chan<- []byte) error {
-	userdConn, err := client.DialSocket(ctx, client.ConnectorSocketName, grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
+	userdConn, err := client.DialSocket(ctx, client.ConnectorSocket(ctx), grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
 	if err != nil {
 		return err
 	}
@@ -158,7 +158,7 @@ func (c *traceCommand) userdTraces(ctx context.Context, tCh chan<- []byte) error
 }
 
 func (c *traceCommand) rootdTraces(ctx context.Context, tCh chan<- []byte) error {
-	dConn, err := client.DialSocket(ctx, client.DaemonSocketName, grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
+	dConn, err := client.DialSocket(ctx, client.DaemonSocket(ctx), grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
 	if err != nil {
 		return err
 	}
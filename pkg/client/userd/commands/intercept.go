@@ -1,15 +1,14 @@
 package commands
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -33,6 +32,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
@@ -52,8 +52,13 @@ func (cmd *interceptCommand) cobraCommand(ctx context.Context) *cobra.Command {
 	}
 
 	cmd.command = &cobra.Command{
-		Use:   "intercept [flags] <intercept_base_name> [-- <command with arguments...>]",
-		Args:  cobra.MinimumNArgs(1),
+		Use: "intercept [flags] <intercept_base_name> [-- <command with arguments...>]",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flag("file").Changed {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		Short: "Intercept a service",
 
 		Annotations: map[string]string{
@@ -77,6 +82,43 @@ func (cmd *interceptCommand) cobraCommand(ctx context.Context) *cobra.Command {
 
 	flags.StringVar(&cmd.args.serviceName, "service", "", "Name of service to intercept. If not provided, we will try to auto-detect one")
 
+	flags.StringVar(&cmd.args.targetHost, "target-host", "", ``+
+		`Direct the intercepted traffic to this host in the cluster instead of to the workstation. The traffic-agent `+
+		`will dial the host directly from the intercepted pod, e.g. "--target-host other-svc.dev --target-port 8080"`)
+	flags.Uint16Var(&cmd.args.targetPort, "target-port", 0, ``+
+		`The port to use together with --target-host. Defaults to the local port given with --port`)
+
+	flags.BoolVar(&cmd.args.mirror, "mirror", false, ``+
+		`Mirror the traffic instead of taking it over. The intercepted container will continue to serve all requests `+
+		`while a copy of the inbound traffic is sent to the workstation. Responses from the workstation are discarded`)
+
+	flags.Int32Var(&cmd.args.weight, "weight", 100, ``+
+		`The percentage of new connections, or HTTP requests, that will be routed to the workstation. The rest is `+
+		`served by the intercepted container`)
+	flags.StringVar(&cmd.args.stickyHeader, "sticky-header", "", ``+
+		`Route HTTP requests with the same value for this header to the same destination. Requires --weight`)
+	flags.BoolVar(&cmd.args.stickyClientIP, "sticky-client-ip", false, ``+
+		`Route connections and requests from the same client IP to the same destination. Requires --weight`)
+
+	flags.StringVar(&cmd.args.grpcMethod, "grpc-method", "", ``+
+		`Only route gRPC calls to this method to the workstation, e.g. "pkg.Svc/Method". A '*' matches any sequence `+
+		`of characters except '/', so "pkg.Svc/*" matches all methods of the service. Other calls are served by the `+
+		`intercepted container`)
+	flags.StringToStringVar(&cmd.args.grpcMetadata, "grpc-metadata", nil, ``+
+		`Only route gRPC calls with this metadata to the workstation, e.g. "--grpc-metadata x-user=alice". The value `+
+		`may be a regular expression`)
+
+	flags.StringVar(&cmd.args.tlsSecret, "tls-secret", "", ``+
+		`Terminate TLS in the traffic-agent using the certificate in this Kubernetes TLS secret. The secret must `+
+		`reside in the namespace of the intercepted workload`)
+	flags.StringVar(&cmd.args.tlsUpstream, "tls-upstream", "tls", ``+
+		`How the traffic-agent connects to the intercepted container and the workstation when TLS is terminated. `+
+		`One of "tls" or "plaintext". Requires --tls-secret`)
+
+	flags.StringVar(&cmd.args.workspaceFile, "file", "", ``+
+		`Start all intercepts that are declared in this intercept workspace YAML file. If one intercept cannot be `+
+		`created, the ones already created are removed. Only --namespace can be combined with this flag`)
+
 	flags.BoolVarP(&cmd.args.localOnly, "local-only", "l", false, ``+
 		`Declare a local-only intercept for the purpose of getting direct outbound access to the intercept's namespace`)
 
@@ -88,11 +130,18 @@ func (cmd *interceptCommand) cobraCommand(ctx context.Context) *cobra.Command {
 	addPreviewFlags("preview-url-", flags, cmd.args.previewSpec)
 
 	flags.StringVarP(&cmd.args.envFile, "env-file", "e", "", ``+
-		`Also emit the remote environment to an env file in Docker Compose format. `+
+		`Also emit the remote environment to an env file in Docker Compose format, or in the format given by --env-format. `+
 		`See https://docs.docker.com/compose/env-file/ for more information on the limitations of this format.`)
 
 	flags.StringVarP(&cmd.args.envJSON, "env-json", "j", "", `Also emit the remote environment to a file as a JSON blob.`)
 
+	flags.StringVar(&cmd.args.envFormat, "env-format", "compose", ``+
+		`The format of the file given by --env-file. One of `+strings.Join(envFormatNames(), ", "))
+	flags.StringSliceVar(&cmd.args.envInclude, "env-include", nil, ``+
+		`Only emit environment variables with names that match one of these glob patterns to --env-file and --env-json`)
+	flags.StringSliceVar(&cmd.args.envExclude, "env-exclude", nil, ``+
+		`Don't emit environment variables with names that match one of these glob patterns to --env-file and --env-json`)
+
 	flags.StringVarP(&cmd.args.mount, "mount", "", "true", ``+
 		`The absolute path for the root directory where volumes will be mounted, $TELEPRESENCE_ROOT. Use "true" to `+
 		`have Telepresence pick a random mount point (default). Use "false" to disable filesystem mounting entirely.`)
@@ -152,6 +201,9 @@ func (c *interceptCommand) init(ctx context.Context) {
 		if err != nil {
 			return err
 		}
+		if args.workspaceFile != "" {
+			return c.interceptWorkspace(ccmd.Context(), args)
+		}
 		args.name = positional[0]
 		args.cmdline = positional[1:]
 
@@ -174,6 +226,9 @@ func (c *interceptCommand) init(ctx context.Context) {
 			if cmd.Flag("preview-url").Changed && args.previewEnabled {
 				return errcat.User.New("a local-only intercept cannot be previewed")
 			}
+			if args.targetHost != "" {
+				return errcat.User.New("a local-only intercept cannot have a target host")
+			}
 		case false:
 			// Actually intercepting something
 			if args.agentName == "" {
@@ -184,6 +239,30 @@ func (c *interceptCommand) init(ctx context.Context) {
 			}
 		}
 		args.mountSet = cmd.Flag("mount").Changed
+		if err := validateClusterTarget(&args); err != nil {
+			return err
+		}
+		if err := validateMirror(&args); err != nil {
+			return err
+		}
+		if err := validateWeight(&args); err != nil {
+			return err
+		}
+		if err := validateGRPCMatch(&args); err != nil {
+			return err
+		}
+		if err := validatePreview(cmd, &args); err != nil {
+			return err
+		}
+		if err := validateTLS(cmd, &args); err != nil {
+			return err
+		}
+		if err := validateEnvArgs(&args); err != nil {
+			return err
+		}
+		if err := validateDaemonContainer(ccmd.Context(), &args); err != nil {
+			return err
+		}
 		if args.dockerRun {
 			if err := validateDockerArgs(args.cmdline); err != nil {
 				return err
@@ -294,55 +373,62 @@ func (c *interceptCommand) intercept(ctx context.Context, args interceptArgs) er
 		return client.WithEnsuredState(ctx, is, true, func() error { return nil })
 	}
 
-	return client.WithEnsuredState(ctx, is, false, func() (err error) {
-		// start the interceptor process
+	return client.WithEnsuredState(ctx, is, false, func() error {
+		return is.runCommand(ctx)
+	})
+}
 
-		var cmd *dexec.Cmd
-		if args.dockerRun {
-			envFile := is.args.envFile
-			if envFile == "" {
-				file, err := os.CreateTemp("", "tel-*.env")
-				if err != nil {
-					return fmt.Errorf("failed to create temporary environment file. %w", err)
-				}
-				defer os.Remove(file.Name())
+// runCommand starts the command given by the intercept args, either directly or using 'docker run',
+// and waits for it to finish.
+func (is *interceptState) runCommand(ctx context.Context) (err error) {
+	// start the interceptor process
+	args := &is.args
+	var cmd *dexec.Cmd
+	if args.dockerRun {
+		envFile := is.args.envFile
+		if envFile == "" || is.args.envFormat != "compose" || len(is.args.envInclude) > 0 || len(is.args.envExclude) > 0 {
+			// Docker needs an unfiltered env file in Docker Compose format.
+			file, err := os.CreateTemp("", "tel-*.env")
+			if err != nil {
+				return fmt.Errorf("failed to create temporary environment file. %w", err)
+			}
+			defer os.Remove(file.Name())
 
-				if err = is.writeEnvToFileAndClose(file); err != nil {
-					return err
-				}
-				envFile = file.Name()
+			if err = is.writeEnvToFileAndClose(file); err != nil {
+				return err
 			}
-			cmd, err = is.startInDocker(ctx, envFile, args.cmdline)
-		} else {
-			cmd, err = proc.Start(ctx, is.env, c.command, args.cmdline[0], args.cmdline[1:]...)
-		}
-		if err != nil {
-			dlog.Errorf(ctx, "error interceptor starting process: %v", err)
-			return errcat.NoDaemonLogs.New(err)
+			envFile = file.Name()
 		}
+		cmd, err = is.startInDocker(ctx, envFile, args.cmdline)
+	} else {
+		cmd, err = proc.Start(ctx, is.env, is.cmd, args.cmdline[0], args.cmdline[1:]...)
+	}
+	if err != nil {
+		dlog.Errorf(ctx, "error interceptor starting process: %v", err)
+		return errcat.NoDaemonLogs.New(err)
+	}
 
-		// setup cleanup for the interceptor process
-		ior := connector.Interceptor{
-			InterceptId: is.env["TELEPRESENCE_INTERCEPT_ID"],
-			Pid:         int32(cmd.Process.Pid),
-		}
+	// setup cleanup for the interceptor process
+	ior := connector.Interceptor{
+		InterceptId: is.env["TELEPRESENCE_INTERCEPT_ID"],
+		Pid:         int32(cmd.Process.Pid),
+	}
 
-		// Send info about the pid and intercept id to the traffic-manager so that it kills
-		// the process if it receives a leave of quit call.
-		if _, err = is.connectorServer.AddInterceptor(ctx, &ior); err != nil {
-			if grpcStatus.Code(err) == grpcCodes.Canceled {
-				// Deactivation was caused by a disconnect
-				err = nil
-			}
-			dlog.Errorf(ctx, "error adding process with pid %d as interceptor: %v", ior.Pid, err)
-			_ = cmd.Process.Kill()
-			return err
+	// Send info about the pid and intercept id to the traffic-manager so that it kills
+	// the process if it receives a leave of quit call.
+	if _, err = is.connectorServer.AddInterceptor(ctx, &ior); err != nil {
+		if grpcStatus.Code(err) == grpcCodes.Canceled {
+			// Deactivation was caused by a disconnect
+			err = nil
 		}
+		dlog.Errorf(ctx, "error adding process with pid %d as interceptor: %v", ior.Pid, err)
+		_ = cmd.Process.Kill()
+		return err
+	}
 
-		// The external command will not output anything to the logs. An error here
-		// is likely caused by the user hitting <ctrl>-C to terminate the process.
-		return errcat.NoDaemonLogs.New(proc.Wait(ctx, nil, cmd))
-	})
+	// The external command will not output anything to the logs. An error here
+	// is likely caused by the user hitting <ctrl>-C to terminate the process.
+	return errcat.NoDaemonLogs.New(proc.Wait(ctx, nil, cmd))
 }
 
 type interceptArgs struct {
@@ -352,15 +438,35 @@ type interceptArgs struct {
 	port        string // --port // only valid if !localOnly
 	serviceName string // --service // only valid if !localOnly
 	localOnly   bool   // --local-only
+	targetHost  string // --target-host // only valid if !localOnly
+	targetPort  uint16 // --target-port // only valid together with --target-host
+	mirror      bool   // --mirror // only valid if !localOnly
+
+	weight         int32  // --weight // only valid if !localOnly
+	stickyHeader   string // --sticky-header // only valid if weight < 100
+	stickyClientIP bool   // --sticky-client-ip // only valid if weight < 100
+
+	grpcMethod   string            // --grpc-method // only valid if !localOnly
+	grpcMetadata map[string]string // --grpc-metadata // only valid if !localOnly
+	grpcMatch    map[string]string // the combination of grpcMethod and grpcMetadata
+
+	tlsSecret   string // --tls-secret // only valid if !localOnly
+	tlsUpstream string // --tls-upstream // only valid together with --tls-secret
+
+	workspaceFile string // --file // only valid together with --namespace
 
 	previewEnabled bool                 // --preview-url // only valid if !localOnly
 	previewSpec    *manager.PreviewSpec // --preview-url-* // only valid if !localOnly
 
-	envFile  string   // --env-file
-	envJSON  string   // --env-json
-	mount    string   // --mount // "true", "false", or desired mount point // only valid if !localOnly
-	mountSet bool     // whether --mount was passed
-	toPod    []string // --to-pod
+	envFile    string   // --env-file
+	envJSON    string   // --env-json
+	envFormat  string   // --env-format // the format of envFile
+	envInclude []string // --env-include // patterns of variables to emit to envFile and envJSON
+	envExclude []string // --env-exclude // patterns of variables not to emit to envFile and envJSON
+	mount      string   // --mount // "true", "false", or desired mount point // only valid if !localOnly
+	mountSet   bool     // whether --mount was passed
+	noMount    bool     // remote volumes can't be used by this intercept, regardless of --mount
+	toPod      []string // --to-pod
 
 	dockerRun   bool   // --docker-run
 	dockerMount string // --docker-mount // where to mount in a docker container. Defaults to mount unless mount is "true" or "false".
@@ -601,17 +707,43 @@ func (is *interceptState) createRequest(ctx context.Context) (*connector.CreateI
 	}
 	spec.TargetPort = int32(is.localPort)
 
-	doMount := false
-	if err = checkMountCapability(ctx); err == nil {
-		if ir.MountPoint, doMount, err = is.getMountPoint(ctx); err != nil {
+	spec.Mirror = is.args.mirror
+	if is.args.weight < 100 {
+		spec.Weight = is.args.weight
+		spec.StickyHeader = is.args.stickyHeader
+		spec.StickyClientIp = is.args.stickyClientIP
+	}
+	spec.GrpcMatch = is.args.grpcMatch
+	spec.TlsSecret = is.args.tlsSecret
+	spec.TlsPlaintextUpstream = is.args.tlsSecret != "" && is.args.tlsUpstream == "plaintext"
+	if is.args.targetHost != "" {
+		spec.TargetInCluster = true
+		spec.TargetHost = is.args.targetHost
+		if is.args.targetPort != 0 {
+			spec.TargetPort = int32(is.args.targetPort)
+		}
+	} else if daemonContainer(ctx) != "" {
+		if is.args.dockerRun {
+			// The intercept handler's container joins the network of the daemon container.
+			spec.TargetPort = int32(is.dockerPort)
+		} else if spec.TargetHost, err = dockerHostIP(ctx); err != nil {
 			return nil, err
 		}
-	} else if is.args.mountSet {
-		var boolErr error
-		doMount, boolErr = strconv.ParseBool(is.args.mount)
-		if boolErr != nil || doMount {
-			// not --mount=false, so refuse.
-			return nil, errcat.User.Newf("remote volume mounts are disabled: %w", err)
+	}
+
+	doMount := false
+	if !is.args.noMount {
+		if err = checkMountCapability(ctx); err == nil {
+			if ir.MountPoint, doMount, err = is.getMountPoint(ctx); err != nil {
+				return nil, err
+			}
+		} else if is.args.mountSet {
+			var boolErr error
+			doMount, boolErr = strconv.ParseBool(is.args.mount)
+			if boolErr != nil || doMount {
+				// not --mount=false, so refuse.
+				return nil, errcat.User.Newf("remote volume mounts are disabled: %w", err)
+			}
 		}
 	}
 
@@ -902,7 +1034,7 @@ func (is *interceptState) EnsureState(ctx context.Context) (acquired bool, err e
 
 	var volumeMountProblem error
 	doMount, err := strconv.ParseBool(args.mount)
-	if doMount || err != nil {
+	if !args.noMount && (doMount || err != nil) {
 		volumeMountProblem = checkMountCapability(ctx)
 	}
 	fmt.Fprintln(is.cmd.OutOrStdout(), cliutil.DescribeIntercepts([]*manager.InterceptInfo{intercept}, volumeMountProblem, false))
@@ -925,6 +1057,181 @@ func (is *interceptState) DeactivateState(ctx context.Context) error {
 	return nil
 }
 
+// validateClusterTarget checks that the flags make sense for an intercept that is directed to
+// an address in the cluster rather than to the workstation.
+func validateClusterTarget(args *interceptArgs) error {
+	if args.targetHost == "" {
+		if args.targetPort != 0 {
+			return errcat.User.New("--target-port can only be used together with --target-host")
+		}
+		return nil
+	}
+	if args.dockerRun {
+		return errcat.User.New("--docker-run cannot be used together with --target-host")
+	}
+	if len(args.cmdline) > 0 {
+		return errcat.User.New("a command cannot be run when the intercept has a --target-host")
+	}
+	// There's no process on the workstation that can make use of the remote mounts.
+	return disableMount(args, "--mount cannot be used together with --target-host")
+}
+
+// disableMount ensures that no remote volumes are mounted for the intercept. An explicit request for
+// a mount is an error that is reported using the given message.
+func disableMount(args *interceptArgs, msg string) error {
+	if args.mountSet {
+		if doMount, err := strconv.ParseBool(args.mount); err != nil || doMount {
+			return errcat.User.New(msg)
+		}
+	}
+	args.noMount = true
+	return nil
+}
+
+// validateMirror checks that the flags make sense for an intercept that mirrors the traffic rather
+// than taking it over.
+func validateMirror(args *interceptArgs) error {
+	if !args.mirror {
+		return nil
+	}
+	if args.localOnly {
+		return errcat.User.New("a local-only intercept cannot be mirrored")
+	}
+	if args.targetHost != "" {
+		return errcat.User.New("--mirror cannot be used together with --target-host")
+	}
+	return nil
+}
+
+// validateWeight checks that the flags make sense for an intercept that only receives a percentage
+// of the traffic.
+func validateWeight(args *interceptArgs) error {
+	if args.weight < 1 || args.weight > 100 {
+		return errcat.User.Newf("--weight must be a percentage between 1 and 100, not %d", args.weight)
+	}
+	if args.weight == 100 {
+		if args.stickyHeader != "" || args.stickyClientIP {
+			return errcat.User.New("--sticky-header and --sticky-client-ip can only be used together with a --weight less than 100")
+		}
+		return nil
+	}
+	if args.localOnly {
+		return errcat.User.New("a local-only intercept cannot have a weight")
+	}
+	if args.mirror {
+		return errcat.User.New("--mirror cannot be used together with --weight")
+	}
+	return nil
+}
+
+// validateGRPCMatch checks that the flags make sense for an intercept that only receives matching gRPC calls,
+// and creates the map that describes the match.
+func validateGRPCMatch(args *interceptArgs) error {
+	if args.grpcMethod == "" && len(args.grpcMetadata) == 0 {
+		return nil
+	}
+	switch {
+	case args.localOnly:
+		return errcat.User.New("a local-only intercept cannot have a gRPC match")
+	case args.mirror:
+		return errcat.User.New("--mirror cannot be used together with --grpc-method or --grpc-metadata")
+	case args.weight < 100:
+		return errcat.User.New("--weight cannot be used together with --grpc-method or --grpc-metadata")
+	}
+	m := make(map[string]string, len(args.grpcMetadata)+1)
+	for k, v := range args.grpcMetadata {
+		m[k] = v
+	}
+	if args.grpcMethod != "" {
+		m[":grpc-method:"] = args.grpcMethod
+	}
+	gm, err := matcher.NewGRPCRequestFromMap(m)
+	if err != nil {
+		return errcat.User.New(err)
+	}
+	args.grpcMatch = gm.Map()
+	return nil
+}
+
+// validatePreview disables the preview URL for intercepts that only receive some of the requests, because
+// the requests that use the preview URL must reach the workstation. It's an error to ask for a preview URL
+// explicitly for such an intercept.
+func validatePreview(cmd *cobra.Command, args *interceptArgs) error {
+	if !args.previewEnabled {
+		return nil
+	}
+	var msg string
+	switch {
+	case args.mirror:
+		msg = "a mirrored intercept cannot be previewed"
+	case args.weight < 100:
+		msg = "an intercept with a weight cannot be previewed"
+	case len(args.grpcMatch) > 0:
+		msg = "an intercept with a gRPC match cannot be previewed"
+	default:
+		return nil
+	}
+	if cmd.Flag("preview-url").Changed {
+		return errcat.User.New(msg)
+	}
+	args.previewEnabled = false
+	return nil
+}
+
+// validateTLS checks that the flags make sense for an intercept where the traffic-agent terminates TLS.
+func validateTLS(cmd *cobra.Command, args *interceptArgs) error {
+	if args.tlsSecret == "" {
+		if cmd.Flag("tls-upstream").Changed {
+			return errcat.User.New("--tls-upstream can only be used together with --tls-secret")
+		}
+		return nil
+	}
+	if args.localOnly {
+		return errcat.User.New("a local-only intercept cannot terminate TLS")
+	}
+	switch args.tlsUpstream {
+	case "tls", "plaintext":
+		return nil
+	default:
+		return errcat.User.Newf("invalid --tls-upstream %q, must be one of \"tls\" or \"plaintext\"", args.tlsUpstream)
+	}
+}
+
+// daemonContainer returns the name of the daemon container that this daemon runs in, or an empty string if it
+// doesn't run in a container.
+func daemonContainer(ctx context.Context) string {
+	if env := client.GetEnv(ctx); env != nil {
+		return env.DaemonContainer
+	}
+	return ""
+}
+
+// validateDaemonContainer checks that the flags make sense when the daemons run in a daemon container. A
+// command given to the intercept would run in that container, so only --docker-run can be used to start the
+// intercept handler, remote volumes can't be mounted, and files can't be written on the host.
+func validateDaemonContainer(ctx context.Context, args *interceptArgs) error {
+	if daemonContainer(ctx) == "" {
+		return nil
+	}
+	if len(args.cmdline) > 0 && !args.dockerRun {
+		return errcat.User.New("a command can only be started using --docker-run when the daemons run in a container")
+	}
+	if args.envFile != "" {
+		return errHostFile(ctx, "--env-file")
+	}
+	if args.envJSON != "" {
+		return errHostFile(ctx, "--env-json")
+	}
+	return disableMount(args, "remote volume mounts are not supported when the daemons run in a container")
+}
+
+// errHostFile returns the error for the given flag or command, which uses a file on the host, when the daemons
+// run in a daemon container. The container has no access to the files of the host.
+func errHostFile(ctx context.Context, what string) error {
+	return errcat.User.Newf("%s cannot be used when the daemons run in container %s, because it has no access to the files of the host",
+		what, daemonContainer(ctx))
+}
+
 func validateDockerArgs(args []string) error {
 	for _, arg := range args {
 		if arg == "-d" || arg == "--detach" {
@@ -934,12 +1241,28 @@ func validateDockerArgs(args []string) error {
 	return nil
 }
 
+// dockerHostIP returns the IP of the host that the daemon container runs on. The tunnel to the intercept
+// handler is identified by IP, so the name of the host must be resolved.
+func dockerHostIP(ctx context.Context) (string, error) {
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", "host.docker.internal")
+	if err != nil || len(ips) == 0 {
+		return "", fmt.Errorf("unable to resolve the host of the daemon container: %w", err)
+	}
+	return ips[0].String(), nil
+}
+
 func (is *interceptState) startInDocker(ctx context.Context, envFile string, args []string) (*dexec.Cmd, error) {
 	ourArgs := []string{
 		"run",
-		"--dns-search", "tel2-search",
 		"--env-file", envFile,
 	}
+	container := daemonContainer(ctx)
+	if container != "" {
+		// Share the network of the daemon container, and thereby its connection to the cluster.
+		ourArgs = append(ourArgs, "--network", "container:"+container)
+	} else {
+		ourArgs = append(ourArgs, "--dns-search", "tel2-search")
+	}
 	getArg := func(s string) (string, bool) {
 		for i, arg := range args {
 			if strings.Contains(arg, s) {
@@ -965,7 +1288,7 @@ func (is *interceptState) startInDocker(ctx context.Context, envFile string, arg
 		ourArgs = append(ourArgs, "--name", name)
 	}
 
-	if is.dockerPort != 0 {
+	if is.dockerPort != 0 && container == "" {
 		ourArgs = append(ourArgs, "-p", fmt.Sprintf("%d:%d", is.localPort, is.dockerPort))
 	}
 
@@ -995,40 +1318,22 @@ func (is *interceptState) writeEnvFile() error {
 	if err != nil {
 		return errcat.NoDaemonLogs.Newf("failed to create environment file %q: %w", is.args.envFile, err)
 	}
-	return is.writeEnvToFileAndClose(file)
-}
-
-func (is *interceptState) writeEnvToFileAndClose(file *os.File) (err error) {
 	defer file.Close()
-	w := bufio.NewWriter(file)
-
-	keys := make([]string, len(is.env))
-	i := 0
-	for k := range is.env {
-		keys[i] = k
-		i++
+	if err = writeEnv(file, is.args.envFormat, is.args.name, filterEnv(is.env, is.args.envInclude, is.args.envExclude)); err != nil {
+		return errcat.NoDaemonLogs.Newf("failed to write environment file %q: %w", is.args.envFile, err)
 	}
-	sort.Strings(keys)
+	return nil
+}
 
-	for _, k := range keys {
-		if _, err = w.WriteString(k); err != nil {
-			return err
-		}
-		if err = w.WriteByte('='); err != nil {
-			return err
-		}
-		if _, err = w.WriteString(is.env[k]); err != nil {
-			return err
-		}
-		if err = w.WriteByte('\n'); err != nil {
-			return err
-		}
-	}
-	return w.Flush()
+// writeEnvToFileAndClose writes the unfiltered environment to the given file in the Docker Compose format
+// that docker understands.
+func (is *interceptState) writeEnvToFileAndClose(file *os.File) error {
+	defer file.Close()
+	return writeEnv(file, "compose", is.args.name, is.env)
 }
 
 func (is *interceptState) writeEnvJSON() error {
-	data, err := json.MarshalIndent(is.env, "", "  ")
+	data, err := json.MarshalIndent(filterEnv(is.env, is.args.envInclude, is.args.envExclude), "", "  ")
 	if err != nil {
 		// Creating JSON from a map[string]string should never fail
 		panic(err)
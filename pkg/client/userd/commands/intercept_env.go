@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+)
+
+// envFormatter writes the given environment, which belongs to the intercept with the given name, to a
+// buffer. The keys are the sorted keys of the environment.
+type envFormatter func(buf *bytes.Buffer, name string, keys []string, env map[string]string) error
+
+// envFormats are the formats accepted by the --env-format flag.
+var envFormats = map[string]envFormatter{
+	"compose":       formatEnvCompose,
+	"bash":          formatEnvPOSIX,
+	"zsh":           formatEnvPOSIX,
+	"fish":          formatEnvFish,
+	"powershell":    formatEnvPowerShell,
+	"systemd":       formatEnvSystemd,
+	"k8s-secret":    formatEnvSecret,
+	"k8s-configmap": formatEnvConfigMap,
+	"vscode":        formatEnvVSCode,
+	"intellij":      formatEnvIntelliJ,
+}
+
+// envFormatNames returns the names of all formats accepted by the --env-format flag.
+func envFormatNames() []string {
+	names := make([]string, 0, len(envFormats))
+	for name := range envFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateEnvArgs checks the format and the glob patterns used when emitting the remote environment.
+func validateEnvArgs(args *interceptArgs) error {
+	if _, ok := envFormats[args.envFormat]; !ok {
+		return errcat.User.Newf("invalid --env-format %q, must be one of %s", args.envFormat, strings.Join(envFormatNames(), ", "))
+	}
+	for _, ps := range [][]string{args.envInclude, args.envExclude} {
+		for _, p := range ps {
+			if _, err := path.Match(p, ""); err != nil {
+				return errcat.User.Newf("invalid environment variable pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// filterEnv returns the variables of the given environment whose names match at least one of the include
+// patterns, unless no include patterns are given, and none of the exclude patterns.
+func filterEnv(env map[string]string, include, exclude []string) map[string]string {
+	if len(include) == 0 && len(exclude) == 0 {
+		return env
+	}
+	matchesAny := func(k string, ps []string) bool {
+		for _, p := range ps {
+			if ok, _ := path.Match(p, k); ok {
+				return true
+			}
+		}
+		return false
+	}
+	filtered := make(map[string]string, len(env))
+	for k, v := range env {
+		if (len(include) == 0 || matchesAny(k, include)) && !matchesAny(k, exclude) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// writeEnv writes the given environment, which belongs to the intercept with the given name, to w using
+// the given format.
+func writeEnv(w io.Writer, format, name string, env map[string]string) error {
+	f, ok := envFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown environment format %q", format)
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	if err := f(&buf, name, keys, env); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func formatEnvCompose(buf *bytes.Buffer, _ string, keys []string, env map[string]string) error {
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s=%s\n", k, env[k])
+	}
+	return nil
+}
+
+func formatEnvPOSIX(buf *bytes.Buffer, _ string, keys []string, env map[string]string) error {
+	for _, k := range keys {
+		fmt.Fprintf(buf, "export %s='%s'\n", k, strings.ReplaceAll(env[k], `'`, `'\''`))
+	}
+	return nil
+}
+
+func formatEnvFish(buf *bytes.Buffer, _ string, keys []string, env map[string]string) error {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "set -gx %s '%s'\n", k, r.Replace(env[k]))
+	}
+	return nil
+}
+
+func formatEnvPowerShell(buf *bytes.Buffer, _ string, keys []string, env map[string]string) error {
+	// The braces allow any name, e.g. "ProgramFiles(x86)", as long as the backtick and the closing brace
+	// are escaped.
+	nr := strings.NewReplacer("`", "``", "}", "`}")
+	for _, k := range keys {
+		fmt.Fprintf(buf, "${Env:%s} = '%s'\n", nr.Replace(k), strings.ReplaceAll(env[k], `'`, `''`))
+	}
+	return nil
+}
+
+func formatEnvSystemd(buf *bytes.Buffer, _ string, keys []string, env map[string]string) error {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s=\"%s\"\n", k, r.Replace(env[k]))
+	}
+	return nil
+}
+
+func formatEnvSecret(buf *bytes.Buffer, name string, _ []string, env map[string]string) error {
+	return writeYAML(buf, &core.Secret{
+		TypeMeta:   meta.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: meta.ObjectMeta{Name: name + "-env"},
+		Type:       core.SecretTypeOpaque,
+		StringData: env,
+	})
+}
+
+func formatEnvConfigMap(buf *bytes.Buffer, name string, _ []string, env map[string]string) error {
+	return writeYAML(buf, &core.ConfigMap{
+		TypeMeta:   meta.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: meta.ObjectMeta{Name: name + "-env"},
+		Data:       env,
+	})
+}
+
+func writeYAML(buf *bytes.Buffer, obj any) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// formatEnvVSCode writes the "env" block of a VS Code launch.json configuration.
+func formatEnvVSCode(buf *bytes.Buffer, _ string, _ []string, env map[string]string) error {
+	data, err := json.MarshalIndent(map[string]any{"env": env}, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// formatEnvIntelliJ writes an IntelliJ run configuration that declares the environment.
+func formatEnvIntelliJ(buf *bytes.Buffer, name string, keys []string, env map[string]string) error {
+	type envVar struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+	type configuration struct {
+		Name        string   `xml:"name,attr"`
+		Type        string   `xml:"type,attr"`
+		FactoryName string   `xml:"factoryName,attr"`
+		Envs        []envVar `xml:"envs>env"`
+	}
+	type component struct {
+		XMLName       xml.Name      `xml:"component"`
+		Name          string        `xml:"name,attr"`
+		Configuration configuration `xml:"configuration"`
+	}
+	c := component{
+		Name: "ProjectRunConfigurationManager",
+		Configuration: configuration{
+			Name:        name,
+			Type:        "Application",
+			FactoryName: "Application",
+			Envs:        make([]envVar, len(keys)),
+		},
+	}
+	for i, k := range keys {
+		c.Configuration.Envs[i] = envVar{Name: k, Value: env[k]}
+	}
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&c); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	return nil
+}
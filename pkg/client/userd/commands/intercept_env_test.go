@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterEnv(t *testing.T) {
+	env := map[string]string{
+		"DB_HOST":     "db",
+		"DB_PASSWORD": "secret",
+		"API_TOKEN":   "token",
+		"PORT":        "8080",
+	}
+	assert.Equal(t, env, filterEnv(env, nil, nil))
+	assert.Equal(t, map[string]string{"DB_HOST": "db", "PORT": "8080"},
+		filterEnv(env, nil, []string{"*_PASSWORD", "*_TOKEN"}))
+	assert.Equal(t, map[string]string{"DB_HOST": "db"},
+		filterEnv(env, []string{"DB_*"}, []string{"*_PASSWORD"}))
+}
+
+func TestValidateEnvArgs(t *testing.T) {
+	assert.NoError(t, validateEnvArgs(&interceptArgs{envFormat: "compose", envExclude: []string{"*_TOKEN"}}))
+	assert.Error(t, validateEnvArgs(&interceptArgs{envFormat: "csh"}))
+	assert.Error(t, validateEnvArgs(&interceptArgs{envFormat: "compose", envInclude: []string{"["}}))
+}
+
+func TestFormatEnv(t *testing.T) {
+	env := map[string]string{
+		"B": `it's "quoted"`,
+		"A": "1",
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"compose", "A=1\nB=it's \"quoted\"\n"},
+		{"bash", "export A='1'\nexport B='it'\\''s \"quoted\"'\n"},
+		{"fish", "set -gx A '1'\nset -gx B 'it\\'s \"quoted\"'\n"},
+		{"powershell", "${Env:A} = '1'\n${Env:B} = 'it''s \"quoted\"'\n"},
+		{"systemd", "A=\"1\"\nB=\"it's \\\"quoted\\\"\"\n"},
+		{"k8s-configmap", "apiVersion: v1\ndata:\n  A: \"1\"\n  B: it's \"quoted\"\nkind: ConfigMap\nmetadata:\n  creationTimestamp: null\n  name: echo-env\n"},
+		{"vscode", "{\n  \"env\": {\n    \"A\": \"1\",\n    \"B\": \"it's \\\"quoted\\\"\"\n  }\n}\n"},
+		{"intellij", `<component name="ProjectRunConfigurationManager">
+  <configuration name="echo" type="Application" factoryName="Application">
+    <envs>
+      <env name="A" value="1"></env>
+      <env name="B" value="it&#39;s &#34;quoted&#34;"></env>
+    </envs>
+  </configuration>
+</component>
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeEnv(&buf, tt.format, "echo", env))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFormatEnvPowerShell_names(t *testing.T) {
+	env := map[string]string{
+		"ProgramFiles(x86)": `C:\Program Files (x86)`,
+		"odd}name`":         "1",
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeEnv(&buf, "powershell", "echo", env))
+	assert.Equal(t, "${Env:ProgramFiles(x86)} = 'C:\\Program Files (x86)'\n${Env:odd`}name``} = '1'\n", buf.String())
+}
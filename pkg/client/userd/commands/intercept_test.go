@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestValidateDaemonContainer(t *testing.T) {
+	ctx := client.WithEnv(context.Background(), &client.Env{DaemonContainer: "telepresence-default"})
+	tests := []struct {
+		name    string
+		args    interceptArgs
+		wantErr string
+	}{
+		{"defaults", interceptArgs{mount: "true"}, ""},
+		{"explicit mount=false", interceptArgs{mount: "false", mountSet: true}, ""},
+		{"explicit mount=true", interceptArgs{mount: "true", mountSet: true}, "remote volume mounts are not supported"},
+		{"mount point", interceptArgs{mount: "/tmp/mnt", mountSet: true}, "remote volume mounts are not supported"},
+		{"command", interceptArgs{mount: "true", cmdline: []string{"npm", "start"}}, "can only be started using --docker-run"},
+		{"docker-run", interceptArgs{mount: "true", dockerRun: true, cmdline: []string{"--rm", "app"}}, ""},
+		{"env-file", interceptArgs{mount: "true", envFile: "/tmp/app.env"}, "--env-file cannot be used"},
+		{"env-json", interceptArgs{mount: "true", envJSON: "/tmp/app.json"}, "--env-json cannot be used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			err := validateDaemonContainer(ctx, &args)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, args.noMount)
+			assert.Equal(t, tt.args.mount, args.mount, "the --mount flag must not be changed")
+		})
+	}
+
+	t.Run("no daemon container", func(t *testing.T) {
+		args := interceptArgs{mount: "true", mountSet: true, envFile: "/tmp/app.env", cmdline: []string{"npm"}}
+		require.NoError(t, validateDaemonContainer(context.Background(), &args))
+		assert.False(t, args.noMount)
+	})
+}
@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
+)
+
+// interceptWorkspace creates all intercepts declared in the workspace file given by the args. The creation
+// is transactional, so if one intercept cannot be created, the ones already created are removed again. The
+// intercepts are retained unless at least one of them declares a command, in which case all commands are
+// run concurrently and the intercepts are removed when all commands have finished.
+func (c *interceptCommand) interceptWorkspace(ctx context.Context, args interceptArgs) (err error) {
+	var flagErr error
+	c.command.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name != "file" && f.Name != "namespace" && flagErr == nil {
+			flagErr = errcat.User.Newf("--%s cannot be used together with --file", f.Name)
+		}
+	})
+	if flagErr != nil {
+		return flagErr
+	}
+
+	if daemonContainer(ctx) != "" {
+		return errHostFile(ctx, "--file")
+	}
+	file := args.workspaceFile
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(GetCwd(ctx), file)
+	}
+	ws, err := cliutil.LoadInterceptWorkspace(file, args.namespace)
+	if err != nil {
+		return err
+	}
+
+	session := trafficmgr.GetSession(ctx)
+	if session == nil {
+		return errors.New("no session found")
+	}
+	mc := session.ManagerClient()
+	cs := GetConnectorServer(ctx)
+	if cs == nil {
+		return errors.New("no connector found")
+	}
+	safeCmd := safeCobraCommandImpl{c.command}
+
+	// Validate all declarations before any intercept is created.
+	states := make([]*interceptState, 0, len(ws.Intercepts))
+	defer func() {
+		for _, is := range states {
+			is.scout.Close()
+		}
+	}()
+	hasCommands := false
+	for _, wi := range ws.Intercepts {
+		wa, err := workspaceInterceptArgs(ctx, wi, args)
+		if err != nil {
+			return err
+		}
+		if len(wa.cmdline) > 0 || wa.dockerRun {
+			hasCommands = true
+		}
+		states = append(states, newInterceptState(ctx, safeCmd, wa, cs, mc))
+	}
+
+	if err = ensureIntercepts(ctx, states); err != nil {
+		return err
+	}
+	if !hasCommands {
+		return nil
+	}
+	defer func() {
+		if rerr := removeIntercepts(ctx, states); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{})
+	for _, is := range states {
+		if len(is.args.cmdline) == 0 && !is.args.dockerRun {
+			continue
+		}
+		is := is
+		g.Go(is.args.name, func(ctx context.Context) error {
+			return is.runCommand(ctx)
+		})
+	}
+	return g.Wait()
+}
+
+// ensureIntercepts creates the intercepts of the given states in order. If one intercept cannot be created,
+// the ones already created are removed again.
+func ensureIntercepts(ctx context.Context, states []*interceptState) error {
+	for i, is := range states {
+		acquired, err := is.EnsureState(ctx)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("intercept %s: %w", is.args.name, err)
+		created := states[:i]
+		if acquired {
+			created = states[:i+1]
+		}
+		if rerr := removeIntercepts(ctx, created); rerr != nil {
+			err = fmt.Errorf("%w\n%v", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// removeIntercepts removes the intercepts of the given states in reverse order of creation.
+func removeIntercepts(ctx context.Context, states []*interceptState) error {
+	// The intercepts might be removed because the context has been cancelled, so the original
+	// context is used without cancellation, but with a deactivation timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(dcontext.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	var errs []error
+	for i := len(states) - 1; i >= 0; i-- {
+		is := states[i]
+		if err := is.DeactivateState(ctx); err != nil {
+			dlog.Errorf(ctx, "unable to remove intercept %s: %v", is.args.name, err)
+			errs = append(errs, fmt.Errorf("intercept %s: %w", is.args.name, err))
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%w (and %d more errors)", errs[0], len(errs)-1)
+	}
+}
+
+// workspaceInterceptArgs returns the interceptArgs that correspond to the given workspace declaration. Args
+// that cannot be declared in a workspace retain the default values of their flags.
+func workspaceInterceptArgs(ctx context.Context, wi *cliutil.WorkspaceIntercept, defaults interceptArgs) (interceptArgs, error) {
+	args := interceptArgs{
+		name:        wi.InterceptName(defaults.namespace),
+		agentName:   wi.Workload,
+		namespace:   wi.Namespace,
+		port:        wi.Port,
+		serviceName: wi.Service,
+		weight:      100,
+		tlsUpstream: "tls",
+		previewSpec: &manager.PreviewSpec{},
+		envFile:     wi.EnvFile,
+		envJSON:     wi.EnvJSON,
+		envFormat:   "compose",
+		mount:       string(wi.Mount),
+		mountSet:    wi.Mount != "",
+		toPod:       wi.ToPod,
+		dockerRun:   wi.DockerRun,
+		dockerMount: wi.DockerMount,
+		cmdline:     wi.Command,
+
+		extState:         defaults.extState,
+		extRequiresLogin: defaults.extRequiresLogin,
+	}
+	if args.agentName == "" {
+		args.agentName = wi.Name
+	}
+	if args.namespace == "" {
+		args.namespace = defaults.namespace
+	}
+	if args.port == "" {
+		args.port = strconv.Itoa(client.GetConfig(ctx).Intercept.DefaultPort)
+	}
+	if args.mount == "" {
+		args.mount = "true"
+	}
+	if args.dockerRun {
+		if len(args.cmdline) == 0 {
+			return args, errcat.User.Newf("intercept %s: dockerRun requires a command with arguments to 'docker run'", args.name)
+		}
+		if err := validateDockerArgs(args.cmdline); err != nil {
+			return args, fmt.Errorf("intercept %s: %w", args.name, err)
+		}
+	}
+	return args, nil
+}
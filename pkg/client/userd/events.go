@@ -0,0 +1,66 @@
+package userd
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+// EventVersion is the version of the schema of the events streamed by WatchEvents. It must be
+// incremented when a field of an event is removed or changes meaning.
+const EventVersion = 1
+
+// PublishEvent sends the given event to the clients that watch events. The version and time of the
+// event are assigned unless already set.
+func (s *Service) PublishEvent(ev *rpc.Event) {
+	stampEvent(ev)
+	s.events.Push(ev)
+}
+
+func stampEvent(ev *rpc.Event) {
+	if ev.Version == 0 {
+		ev.Version = EventVersion
+	}
+	if ev.Time == nil {
+		ev.Time = timestamppb.Now()
+	}
+}
+
+// stateEvents returns events that describe the current connection and the state of its session, or
+// nothing when there's no connection.
+func (s *Service) stateEvents() []*rpc.Event {
+	s.sessionLock.RLock()
+	defer s.sessionLock.RUnlock()
+	if s.session == nil {
+		return nil
+	}
+	evs := append([]*rpc.Event{connectionEvent(rpc.ConnectionEvent_CONNECTED, s.session.Status(s.sessionContext), nil)},
+		s.session.StateEvents()...)
+	for _, ev := range evs {
+		stampEvent(ev)
+	}
+	return evs
+}
+
+// SubscribeEvents returns a channel that receives the published events until the given context
+// is cancelled.
+func (s *Service) SubscribeEvents(ctx context.Context) <-chan *rpc.Event {
+	return s.events.Subscribe(ctx)
+}
+
+// connectionEvent returns an event with the given connection state for the connection described
+// by the given ConnectInfo.
+func connectionEvent(state rpc.ConnectionEvent_State, ci *rpc.ConnectInfo, err error) *rpc.Event {
+	ce := &rpc.ConnectionEvent{
+		State:          state,
+		ClusterContext: ci.ClusterContext,
+		ClusterServer:  ci.ClusterServer,
+		SessionId:      ci.GetSessionInfo().GetSessionId(),
+	}
+	if err != nil {
+		ce.Error = err.Error()
+	}
+	return &rpc.Event{Kind: &rpc.Event_Connection{Connection: ce}}
+}
@@ -0,0 +1,313 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// Server is the part of the user daemon's Connector service that the gateway exposes.
+type Server interface {
+	Connect(context.Context, *connector.ConnectRequest) (*connector.ConnectInfo, error)
+	Status(context.Context, *empty.Empty) (*connector.ConnectInfo, error)
+	List(context.Context, *connector.ListRequest) (*connector.WorkloadInfoSnapshot, error)
+	CreateIntercept(context.Context, *connector.CreateInterceptRequest) (*connector.InterceptResult, error)
+	RemoveIntercept(context.Context, *manager.RemoveInterceptRequest2) (*connector.InterceptResult, error)
+
+	// SubscribeEvents returns a channel that receives the events of the user daemon until the
+	// given context is cancelled.
+	SubscribeEvents(context.Context) <-chan *connector.Event
+}
+
+// Info describes a running gateway. It is written to a file that only the user can read, so that
+// local tools can find the gateway and authenticate with it.
+type Info struct {
+	// Address is the base URL of the gateway.
+	Address string `json:"address"`
+
+	// Token must be passed as a bearer token in the Authorization header of each request.
+	Token string `json:"token"`
+}
+
+// InfoFile returns the path of the file that describes the gateway of the connection used by the
+// given context.
+func InfoFile(ctx context.Context) (string, error) {
+	cacheDir, err := filelocation.AppUserCacheDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, client.ConnectionProcessName(ctx, "http-gateway")+".json"), nil
+}
+
+var marshaller = protojson.MarshalOptions{UseProtoNames: true}
+
+var unmarshaller = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// Gateway is an http.Handler that maps REST endpoints to the calls of a Server.
+type Gateway struct {
+	server Server
+	token  string
+}
+
+// New returns a Gateway that serves the given Server to requests that carry the given token.
+func New(server Server, token string) *Gateway {
+	return &Gateway{server: server, token: token}
+}
+
+// NewToken returns a new random token.
+func NewToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// Serve runs a gateway for the given Server on the given port of the loopback interface until
+// the given context is cancelled. A new token is created and written, together with the address
+// of the gateway, to the InfoFile. The file is removed when the gateway ends.
+//
+// The port is only used by the default connection. Other connections listen on a port chosen by
+// the system, so that several user daemons can run gateways at the same time, and clients find
+// the port in the InfoFile of the connection. A gateway that can't listen is logged and skipped,
+// because it must not take the user daemon down with it.
+func Serve(ctx context.Context, server Server, port int) error {
+	token, err := NewToken()
+	if err != nil {
+		return err
+	}
+	if client.GetConnectionName(ctx) != client.DefaultConnectionName {
+		port = 0
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		dlog.Errorf(ctx, "HTTP gateway disabled: unable to listen for requests: %v", err)
+		return nil
+	}
+	file, err := InfoFile(ctx)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	data, err := json.Marshal(&Info{Address: "http://" + listener.Addr().String(), Token: token})
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	if err = os.WriteFile(file, data, 0o600); err != nil {
+		listener.Close()
+		return err
+	}
+	defer func() {
+		_ = os.Remove(file)
+	}()
+
+	dlog.Infof(ctx, "HTTP gateway listening on %s", listener.Addr())
+	sc := &dhttp.ServerConfig{Handler: New(server, token)}
+	if err = sc.Serve(ctx, listener); err != nil && ctx.Err() != nil {
+		err = nil // Normal shutdown
+	}
+	return err
+}
+
+// ServeHTTP implements http.Handler.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == openAPIPath {
+		// The document is public so that tools can discover the API before they authenticate.
+		g.openAPI(w, r)
+		return
+	}
+	if !g.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, status.Error(codes.Unauthenticated, "missing or invalid bearer token"))
+		return
+	}
+
+	ctx := r.Context()
+	path := r.URL.Path
+	switch {
+	case path == "/v1/connect" && r.Method == http.MethodPost:
+		rq := &connector.ConnectRequest{}
+		if readRequest(w, r, rq) {
+			rs, err := g.server.Connect(ctx, rq)
+			writeResponse(w, rs, err)
+		}
+	case path == "/v1/status" && r.Method == http.MethodGet:
+		rs, err := g.server.Status(ctx, &empty.Empty{})
+		writeResponse(w, rs, err)
+	case path == "/v1/workloads" && r.Method == http.MethodGet:
+		q := r.URL.Query()
+		rq := &connector.ListRequest{Namespace: q.Get("namespace")}
+		if f := q.Get("filter"); f != "" {
+			v, ok := connector.ListRequest_Filter_value[strings.ToUpper(f)]
+			if !ok {
+				writeError(w, status.Errorf(codes.InvalidArgument, "invalid filter %q", f))
+				return
+			}
+			rq.Filter = connector.ListRequest_Filter(v)
+		}
+		rs, err := g.server.List(ctx, rq)
+		writeResponse(w, rs, err)
+	case path == "/v1/intercepts" && r.Method == http.MethodPost:
+		rq := &connector.CreateInterceptRequest{}
+		if readRequest(w, r, rq) {
+			rs, err := g.server.CreateIntercept(ctx, rq)
+			writeResponse(w, rs, err)
+		}
+	case strings.HasPrefix(path, "/v1/intercepts/") && r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(path, "/v1/intercepts/")
+		if name == "" || strings.Contains(name, "/") {
+			writeError(w, status.Error(codes.NotFound, "not found"))
+			return
+		}
+		rs, err := g.server.RemoveIntercept(ctx, &manager.RemoveInterceptRequest2{Name: name})
+		writeResponse(w, rs, err)
+	case path == "/v1/events" && r.Method == http.MethodGet:
+		g.streamEvents(w, r)
+	default:
+		writeError(w, status.Error(codes.NotFound, "not found"))
+	}
+}
+
+func (g *Gateway) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) == 1
+}
+
+// streamEvents streams the events of the user daemon as server-sent events. Each event is sent as
+// a JSON object in the data field of an SSE message of the type "event".
+func (g *Gateway) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, status.Error(codes.Unimplemented, "streaming is not supported"))
+		return
+	}
+	ctx := r.Context()
+	evs := g.server.SubscribeEvents(ctx)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for ev := range evs {
+		data, err := marshaller.Marshal(ev)
+		if err != nil {
+			dlog.Errorf(ctx, "unable to marshal event: %v", err)
+			continue
+		}
+		if _, err = fmt.Fprintf(w, "event: event\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// readRequest unmarshals the JSON body of the given request into the given message. An empty body
+// leaves the message unchanged. False is returned when an error response has been written.
+func readRequest(w http.ResponseWriter, r *http.Request, msg proto.Message) bool {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "unable to read request body: %v", err))
+		return false
+	}
+	if len(data) > 0 {
+		if err = unmarshaller.Unmarshal(data, msg); err != nil {
+			writeError(w, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err))
+			return false
+		}
+	}
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, msg proto.Message, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	data, err := marshaller.Marshal(msg)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// errorResponse is the body of responses to requests that failed.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		var se interface{ GRPCStatus() *status.Status }
+		if errors.As(err, &se) {
+			st = se.GRPCStatus()
+		} else {
+			st = status.New(codes.Unknown, err.Error())
+		}
+	}
+	data, _ := json.Marshal(&errorResponse{Code: st.Code().String(), Message: st.Message()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	_, _ = w.Write(data)
+}
+
+// httpStatus returns the HTTP status code that corresponds to the given gRPC code.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
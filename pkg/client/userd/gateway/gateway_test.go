@@ -0,0 +1,240 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+type fakeServer struct {
+	listRequest   *connector.ListRequest
+	createRequest *connector.CreateInterceptRequest
+	events        chan *connector.Event
+}
+
+func (f *fakeServer) Connect(context.Context, *connector.ConnectRequest) (*connector.ConnectInfo, error) {
+	return &connector.ConnectInfo{Error: connector.ConnectInfo_ALREADY_CONNECTED}, nil
+}
+
+func (f *fakeServer) Status(context.Context, *empty.Empty) (*connector.ConnectInfo, error) {
+	return &connector.ConnectInfo{ClusterContext: "default"}, nil
+}
+
+func (f *fakeServer) List(_ context.Context, rq *connector.ListRequest) (*connector.WorkloadInfoSnapshot, error) {
+	f.listRequest = rq
+	return &connector.WorkloadInfoSnapshot{Workloads: []*connector.WorkloadInfo{{Name: "echo"}}}, nil
+}
+
+func (f *fakeServer) CreateIntercept(_ context.Context, rq *connector.CreateInterceptRequest) (*connector.InterceptResult, error) {
+	f.createRequest = rq
+	return &connector.InterceptResult{}, nil
+}
+
+func (f *fakeServer) RemoveIntercept(_ context.Context, rq *manager.RemoveInterceptRequest2) (*connector.InterceptResult, error) {
+	return nil, status.Errorf(codes.NotFound, "intercept %s not found", rq.Name)
+}
+
+func (f *fakeServer) SubscribeEvents(ctx context.Context) <-chan *connector.Event {
+	return f.events
+}
+
+func request(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rq := httptest.NewRequest(method, path, strings.NewReader(body))
+	rq.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, rq)
+	return w
+}
+
+func TestGateway(t *testing.T) {
+	fs := &fakeServer{}
+	g := New(fs, "secret")
+
+	t.Run("unauthorized", func(t *testing.T) {
+		rq := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		rq.Header.Set("Authorization", "Bearer wrong")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, rq)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("raw token", func(t *testing.T) {
+		rq := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		rq.Header.Set("Authorization", "secret")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, rq)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		w := request(t, g, http.MethodGet, "/v1/status", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		ci := &connector.ConnectInfo{}
+		require.NoError(t, protojson.Unmarshal(w.Body.Bytes(), ci))
+		assert.Equal(t, "default", ci.ClusterContext)
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		w := request(t, g, http.MethodPost, "/v1/connect", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		ci := &connector.ConnectInfo{}
+		require.NoError(t, protojson.Unmarshal(w.Body.Bytes(), ci))
+		assert.Equal(t, connector.ConnectInfo_ALREADY_CONNECTED, ci.Error)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := request(t, g, http.MethodGet, "/v1/workloads?filter=intercepts&namespace=other", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, connector.ListRequest_INTERCEPTS, fs.listRequest.Filter)
+		assert.Equal(t, "other", fs.listRequest.Namespace)
+
+		w = request(t, g, http.MethodGet, "/v1/workloads?filter=bogus", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("create intercept", func(t *testing.T) {
+		w := request(t, g, http.MethodPost, "/v1/intercepts", `{"spec":{"name":"echo","namespace":"default"},"mount_point":"/tmp/echo"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "echo", fs.createRequest.Spec.Name)
+		assert.Equal(t, "/tmp/echo", fs.createRequest.MountPoint)
+
+		w = request(t, g, http.MethodPost, "/v1/intercepts", `{"spec":`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("remove intercept", func(t *testing.T) {
+		w := request(t, g, http.MethodDelete, "/v1/intercepts/echo", "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		var er errorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &er))
+		assert.Equal(t, "NotFound", er.Code)
+		assert.Equal(t, "intercept echo not found", er.Message)
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		w := request(t, g, http.MethodGet, "/v1/bogus", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestServe(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	ctx := dlog.NewTestContext(t, false)
+	ctx = filelocation.WithUserHomeDir(ctx, home)
+
+	// Occupy the configured port, so that only a gateway that doesn't use it can listen.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	t.Run("port in use", func(t *testing.T) {
+		// The failure is logged, not returned, so that the user daemon keeps running.
+		require.NoError(t, Serve(ctx, &fakeServer{}, port))
+	})
+
+	t.Run("named connection", func(t *testing.T) {
+		ctx := client.WithConnectionName(ctx, "other")
+		file, err := InfoFile(ctx)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o700))
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- Serve(ctx, &fakeServer{}, port)
+		}()
+
+		var info Info
+		require.Eventually(t, func() bool {
+			data, err := os.ReadFile(file)
+			return err == nil && json.Unmarshal(data, &info) == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.NotEqual(t, fmt.Sprintf("http://127.0.0.1:%d", port), info.Address)
+
+		rq, err := http.NewRequest(http.MethodGet, info.Address+"/v1/status", nil)
+		require.NoError(t, err)
+		rq.Header.Set("Authorization", "Bearer "+info.Token)
+		rs, err := http.DefaultClient.Do(rq)
+		require.NoError(t, err)
+		_ = rs.Body.Close()
+		assert.Equal(t, http.StatusOK, rs.StatusCode)
+
+		cancel()
+		require.NoError(t, <-done)
+		assert.NoFileExists(t, file)
+	})
+}
+
+func TestGateway_events(t *testing.T) {
+	fs := &fakeServer{events: make(chan *connector.Event, 2)}
+	fs.events <- &connector.Event{Version: 1, Kind: &connector.Event_Notification{Notification: &connector.NotificationEvent{Message: "hello"}}}
+	close(fs.events)
+
+	w := request(t, New(fs, "secret"), http.MethodGet, "/v1/events", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+	sc := bufio.NewScanner(w.Body)
+	require.True(t, sc.Scan())
+	assert.Equal(t, "event: event", sc.Text())
+	require.True(t, sc.Scan())
+	data := strings.TrimPrefix(sc.Text(), "data: ")
+	ev := &connector.Event{}
+	require.NoError(t, protojson.Unmarshal([]byte(data), ev))
+	assert.Equal(t, "hello", ev.GetNotification().GetMessage())
+}
+
+func TestOpenAPI(t *testing.T) {
+	// The document is served without authentication
+	w := httptest.NewRecorder()
+	New(&fakeServer{}, "secret").ServeHTTP(w, httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Type       string                     `json:"type"`
+				Format     string                     `json:"format"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	for _, rt := range routes {
+		assert.Contains(t, doc.Paths[rt.path], httpMethodKey(rt.method), rt.path)
+	}
+	ci, ok := doc.Components.Schemas["telepresence.connector.ConnectInfo"]
+	require.True(t, ok)
+	assert.Equal(t, "object", ci.Type)
+	assert.Contains(t, ci.Properties, "cluster_context")
+	assert.Contains(t, doc.Components.Schemas, "telepresence.manager.InterceptSpec")
+	assert.Equal(t, "date-time", doc.Components.Schemas["google.protobuf.Timestamp"].Format)
+}
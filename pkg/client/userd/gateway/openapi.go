@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+const openAPIPath = "/v1/openapi.json"
+
+// parameter is an OpenAPI parameter of a route.
+type parameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      map[string]any `json:"schema"`
+}
+
+// route maps an HTTP method and path to a method of the Connector service.
+type route struct {
+	method     string
+	path       string
+	rpc        protoreflect.Name
+	summary    string
+	parameters []parameter
+
+	// body is true when the request message is read from the request body.
+	body bool
+
+	// stream is true when the responses are streamed as server-sent events.
+	stream bool
+}
+
+var routes = []route{
+	{
+		method:  http.MethodPost,
+		path:    "/v1/connect",
+		rpc:     "Connect",
+		summary: "Connect to the cluster and the traffic-manager",
+		body:    true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/v1/status",
+		rpc:     "Status",
+		summary: "Get the status of the connection",
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/v1/workloads",
+		rpc:     "List",
+		summary: "List the workloads and their intercepts",
+		parameters: []parameter{
+			{
+				Name:        "filter",
+				In:          "query",
+				Description: "The workloads to list",
+				Schema:      enumSchema(connector.File_rpc_connector_connector_proto.Messages().ByName("ListRequest").Enums().ByName("Filter")),
+			},
+			{
+				Name:        "namespace",
+				In:          "query",
+				Description: "The namespace of the workloads. Defaults to the namespace of the connection",
+				Schema:      map[string]any{"type": "string"},
+			},
+		},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/v1/intercepts",
+		rpc:     "CreateIntercept",
+		summary: "Create an intercept",
+		body:    true,
+	},
+	{
+		method:  http.MethodDelete,
+		path:    "/v1/intercepts/{name}",
+		rpc:     "RemoveIntercept",
+		summary: "Remove an intercept",
+		parameters: []parameter{
+			{
+				Name:        "name",
+				In:          "path",
+				Description: "The name of the intercept",
+				Required:    true,
+				Schema:      map[string]any{"type": "string"},
+			},
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/v1/events",
+		rpc:     "WatchEvents",
+		summary: "Stream the events of the user daemon as server-sent events",
+		stream:  true,
+	},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIData []byte
+)
+
+func (g *Gateway) openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	openAPIOnce.Do(func() {
+		openAPIData, _ = json.MarshalIndent(OpenAPI(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIData)
+}
+
+// OpenAPI returns an OpenAPI 3 document that describes the gateway. The schemas of the requests and
+// responses are generated from the descriptors of the messages of the Connector service.
+func OpenAPI() map[string]any {
+	svc := connector.File_rpc_connector_connector_proto.Services().ByName("Connector")
+	sg := &schemaGenerator{schemas: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+	for _, rt := range routes {
+		md := svc.Methods().ByName(rt.rpc)
+		op := map[string]any{
+			"operationId": string(rt.rpc),
+			"summary":     rt.summary,
+		}
+		if len(rt.parameters) > 0 {
+			op["parameters"] = rt.parameters
+		}
+		if rt.body {
+			op["requestBody"] = map[string]any{
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": sg.messageRef(md.Input())},
+				},
+			}
+		}
+		contentType := "application/json"
+		description := "The response of the " + string(rt.rpc) + " call"
+		if rt.stream {
+			contentType = "text/event-stream"
+			description = "A stream of server-sent events. The data of each event is a JSON object"
+		}
+		op["responses"] = map[string]any{
+			"200": map[string]any{
+				"description": description,
+				"content": map[string]any{
+					contentType: map[string]any{"schema": sg.messageRef(md.Output())},
+				},
+			},
+			"default": map[string]any{
+				"description": "An error",
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}},
+				},
+			},
+		}
+		if paths[rt.path] == nil {
+			paths[rt.path] = make(map[string]any)
+		}
+		paths[rt.path][httpMethodKey(rt.method)] = op
+	}
+	sg.schemas["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "string", "description": "The name of the gRPC status code of the error"},
+			"message": map[string]any{"type": "string"},
+		},
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Telepresence user daemon",
+			"version": client.Version(),
+		},
+		"servers":  []any{map[string]any{"url": "/"}},
+		"security": []any{map[string]any{"bearer": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": sg.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+func httpMethodKey(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "post"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+// schemaGenerator generates the schemas of messages, and of the messages that they refer to, in the
+// form that protojson marshals them.
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+func (sg *schemaGenerator) messageRef(md protoreflect.MessageDescriptor) map[string]any {
+	name := string(md.FullName())
+	if _, ok := sg.schemas[name]; !ok {
+		sg.schemas[name] = nil // Prevents infinite recursion
+		sg.schemas[name] = sg.messageSchema(md)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (sg *schemaGenerator) messageSchema(md protoreflect.MessageDescriptor) map[string]any {
+	if s := wellKnownSchema(md.FullName()); s != nil {
+		return s
+	}
+	props := make(map[string]any)
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		props[string(fd.Name())] = sg.fieldSchema(fd)
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
+
+func (sg *schemaGenerator) fieldSchema(fd protoreflect.FieldDescriptor) map[string]any {
+	switch {
+	case fd.IsMap():
+		return map[string]any{"type": "object", "additionalProperties": sg.singularSchema(fd.MapValue())}
+	case fd.IsList():
+		return map[string]any{"type": "array", "items": sg.singularSchema(fd)}
+	default:
+		return sg.singularSchema(fd)
+	}
+}
+
+func (sg *schemaGenerator) singularSchema(fd protoreflect.FieldDescriptor) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson marshals 64-bit integers as strings
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		return enumSchema(fd.Enum())
+	default:
+		return sg.messageRef(fd.Message())
+	}
+}
+
+func enumSchema(ed protoreflect.EnumDescriptor) map[string]any {
+	values := ed.Values()
+	names := make([]string, values.Len())
+	for i := range names {
+		names[i] = string(values.Get(i).Name())
+	}
+	return map[string]any{"type": "string", "enum": names}
+}
+
+// wellKnownSchema returns the schema of the given well-known type in the form that protojson
+// marshals it, or nil if the type isn't a well-known type with a special JSON form.
+func wellKnownSchema(name protoreflect.FullName) map[string]any {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string", "example": "1.5s"}
+	case "google.protobuf.Empty":
+		return map[string]any{"type": "object"}
+	case "google.protobuf.Struct":
+		return map[string]any{"type": "object", "additionalProperties": true}
+	case "google.protobuf.Value":
+		return map[string]any{}
+	default:
+		return nil
+	}
+}
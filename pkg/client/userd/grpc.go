@@ -360,6 +360,24 @@ func (s *Service) UserNotifications(_ *empty.Empty, stream rpc.Connector_UserNot
 	return nil
 }
 
+func (s *Service) WatchEvents(_ *empty.Empty, stream rpc.Connector_WatchEventsServer) (err error) {
+	s.logCall(stream.Context(), "WatchEvents", func(c context.Context) {
+		// Subscribe before the current state is sent, so that no change is missed.
+		evs := s.SubscribeEvents(c)
+		for _, ev := range s.stateEvents() {
+			if err = stream.Send(ev); err != nil {
+				return
+			}
+		}
+		for ev := range evs {
+			if err = stream.Send(ev); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
 func (s *Service) Login(ctx context.Context, req *rpc.LoginRequest) (result *rpc.LoginResult, err error) {
 	s.logCall(ctx, "Login", func(c context.Context) {
 		defer func() {
@@ -6,16 +6,22 @@ import (
 	"sync"
 )
 
-// BroadcastQueue represents a set of infinitely buffering queues.  The zero value is valid and may
-// be used.
-type BroadcastQueue struct {
+// Queue represents a set of infinitely buffering queues of values of type T.  The zero value is
+// valid and may be used.
+type Queue[T any] struct {
 	lock sync.Mutex
 	// things protected by 'lock'
-	close       chan struct{}                   // can read from the channel while unlocked, IF you've already validated it's non-nil
-	subscribers map[<-chan string]chan<- string // readEnd ↦ writeEnd
+	close       chan struct{}         // can read from the channel while unlocked, IF you've already validated it's non-nil
+	subscribers map[<-chan T]chan<- T // readEnd ↦ writeEnd
+}
+
+// BroadcastQueue is a Queue of messages that also implements io.Writer.  The zero value is valid
+// and may be used.
+type BroadcastQueue struct {
+	Queue[string]
 }
 
-func (bq *BroadcastQueue) buffer(ctx context.Context, upstream <-chan string, downstream chan<- string) {
+func (bq *Queue[T]) buffer(ctx context.Context, upstream <-chan T, downstream chan<- T) {
 	var shutdown func()
 	shutdown = func() {
 		shutdown = func() {}
@@ -35,7 +41,7 @@ func (bq *BroadcastQueue) buffer(ctx context.Context, upstream <-chan string, do
 	defer func() {
 		for buffer.Len() > 0 {
 			el := buffer.Front()
-			downstream <- el.Value.(string)
+			downstream <- el.Value.(T)
 			buffer.Remove(el)
 		}
 		close(downstream)
@@ -62,7 +68,7 @@ func (bq *BroadcastQueue) buffer(ctx context.Context, upstream <-chan string, do
 					return
 				}
 				buffer.PushBack(msg)
-			case downstream <- el.Value.(string):
+			case downstream <- el.Value.(T):
 				buffer.Remove(el)
 			case <-ctx.Done():
 				shutdown()
@@ -72,23 +78,23 @@ func (bq *BroadcastQueue) buffer(ctx context.Context, upstream <-chan string, do
 	}
 }
 
-func (bq *BroadcastQueue) init() {
+func (bq *Queue[T]) init() {
 	if bq.close == nil {
 		bq.close = make(chan struct{})
-		bq.subscribers = make(map[<-chan string]chan<- string)
+		bq.subscribers = make(map[<-chan T]chan<- T)
 	}
 }
 
 // Push pushes a value to each of the subscriber queues.  Push does not block.  It is a runtime
-// error (panic) to call Push on a BroadcastQueue that has already had Close called on it.
-func (bq *BroadcastQueue) Push(msg string) {
+// error (panic) to call Push on a Queue that has already had Close called on it.
+func (bq *Queue[T]) Push(msg T) {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 	bq.init()
 
 	select {
 	case <-bq.close:
-		panic("queue: Push called on closed BroadcastQueue")
+		panic("queue: Push called on closed Queue")
 	default:
 		for _, subscriber := range bq.subscribers {
 			subscriber <- msg
@@ -113,7 +119,7 @@ func (bq *BroadcastQueue) Write(msg []byte) (int, error) {
 //
 // - any existing subscriber queue channels will be closed once they have been drained of messages
 // that have already been Pushed.
-func (bq *BroadcastQueue) Close() {
+func (bq *Queue[T]) Close() {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 	bq.init()
@@ -128,19 +134,19 @@ func (bq *BroadcastQueue) Close() {
 // Subscribe allocates a new queue that future calls to Push will be broadcast to.  The queue is
 // removed from the pool that is broadcast to when the Context is canceled.  Reading from the
 // returned channel will pop from the queue.  The channel will be closed when either the Context is
-// canceled or the BroadcastQueue is closed.
-func (bq *BroadcastQueue) Subscribe(ctx context.Context) <-chan string {
+// canceled or the Queue is closed.
+func (bq *Queue[T]) Subscribe(ctx context.Context) <-chan T {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 	bq.init()
 
-	downstream := make(chan string)
+	downstream := make(chan T)
 
 	select {
 	case <-bq.close:
 		close(downstream)
 	default:
-		upstream := make(chan string)
+		upstream := make(chan T)
 		bq.subscribers[upstream] = upstream
 		go bq.buffer(ctx, upstream, downstream)
 	}
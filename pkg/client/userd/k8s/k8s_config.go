@@ -3,7 +3,10 @@ package k8s
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -220,6 +223,47 @@ func (kf *Config) GetRestConfig() *rest.Config {
 	return kf.RestConfig
 }
 
+// errTokenCaptured is returned by the round tripper that captures the bearer token, so that the request
+// is never sent.
+var errTokenCaptured = errors.New("token captured")
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// BearerToken returns the bearer token that is used when authenticating with the cluster. The token is
+// obtained by the same means as when a request is made to the API server, so it may be a static token, a
+// token file, or a token provided by an exec or auth-provider plugin. An empty string is returned when the
+// config doesn't authenticate using a bearer token, e.g. when it uses client certificates.
+func (kf *Config) BearerToken(ctx context.Context) (string, error) {
+	var token string
+	rt, err := rest.HTTPWrappersForConfig(kf.RestConfig, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		token = strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if token == req.Header.Get("Authorization") {
+			// Not a bearer token.
+			token = ""
+		}
+		return nil, errTokenCaptured
+	}))
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kf.RestConfig.Host, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := rt.RoundTrip(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil && !errors.Is(err, errTokenCaptured) {
+		return "", err
+	}
+	return token, nil
+}
+
 func mapEqual(a, b map[string]string) bool {
 	if len(a) != len(b) {
 		return false
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"sync"
@@ -24,9 +25,11 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/a8rcloud"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/cliutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/auth"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/gateway"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/internal/broadcastqueue"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
@@ -72,6 +75,8 @@ type Service struct {
 	daemonClient      daemon.DaemonClient
 	loginExecutor     auth.LoginExecutor
 	userNotifications func(context.Context) <-chan string
+	notify            func(string)
+	events            *broadcastqueue.Queue[*rpc.Event]
 	ucn               int64
 
 	scout *scout.Reporter
@@ -102,7 +107,7 @@ func (s *Service) RootDaemonClient(c context.Context) (daemon.DaemonClient, erro
 	}
 	// establish a connection to the root daemon gRPC grpcService
 	dlog.Info(c, "Connecting to root daemon...")
-	conn, err := client.DialSocket(c, client.DaemonSocketName,
+	conn, err := client.DialSocket(c, client.DaemonSocket(c),
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
 	)
@@ -118,8 +123,17 @@ func (s *Service) LoginExecutor() auth.LoginExecutor {
 	return s.loginExecutor
 }
 
+// Notify sends the given message to the CLI commands that are subscribed to user notifications.
+func (s *Service) Notify(msg string) {
+	if s.notify != nil {
+		s.notify(msg)
+	}
+	s.PublishEvent(&rpc.Event{Kind: &rpc.Event_Notification{Notification: &rpc.NotificationEvent{Message: msg}}})
+}
+
 // Command returns the CLI sub-command for "connector-foreground"
 func Command(getCommands CommandFactory, daemonServices []DaemonService, sessionServices []trafficmgr.SessionService) *cobra.Command {
+	var name, address string
 	c := &cobra.Command{
 		Use:    ProcessName + "-foreground",
 		Short:  "Launch Telepresence " + titleName + " in the foreground (debug)",
@@ -127,9 +141,14 @@ func Command(getCommands CommandFactory, daemonServices []DaemonService, session
 		Hidden: true,
 		Long:   help,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(cmd.Context(), getCommands, daemonServices, sessionServices)
+			if err := client.ValidateConnectionName(name); err != nil {
+				return err
+			}
+			return run(client.WithConnectionName(cmd.Context(), name), address, getCommands, daemonServices, sessionServices)
 		},
 	}
+	c.Flags().StringVar(&name, "name", client.DefaultConnectionName, "The name of the connection managed by this daemon")
+	c.Flags().StringVar(&address, "address", "", "A TCP address that the daemon listens to in addition to its socket")
 	return c
 }
 
@@ -173,6 +192,7 @@ nextSession:
 					s.sessionContext = session.WithK8sInterface(sCtx)
 					s.sessionCancel = sCancel
 					s.session = session
+					s.PublishEvent(connectionEvent(rpc.ConnectionEvent_CONNECTED, rsp, nil))
 				} else {
 					sCancel()
 				}
@@ -197,9 +217,11 @@ nextSession:
 		// Run the session asynchronously. We must be able to respond to connect (with UpdateStatus) while
 		// the session is running. The s.sessionCancel is called from Disconnect
 		wg.Add(1)
-		go func(cr *rpc.ConnectRequest) {
+		go func(cr *rpc.ConnectRequest, rsp *rpc.ConnectInfo) {
 			defer wg.Done()
-			if err := s.session.Run(s.sessionContext); err != nil {
+			err := s.session.Run(s.sessionContext)
+			s.PublishEvent(connectionEvent(rpc.ConnectionEvent_DISCONNECTED, rsp, err))
+			if err != nil {
 				if errors.Is(err, trafficmgr.SessionExpiredErr) {
 					// Session has expired. We need to cancel the owner session and reconnect
 					dlog.Info(c, "refreshing session")
@@ -213,7 +235,7 @@ nextSession:
 
 				dlog.Error(c, err)
 			}
-		}(cr)
+		}(cr, rsp)
 	}
 	wg.Wait()
 	return nil
@@ -252,19 +274,20 @@ func GetPoddService(sc *scout.Reporter, cfg client.Config, login auth.LoginExecu
 		connectResponse: make(chan *rpc.ConnectInfo),
 		ManagerProxy:    trafficmgr.NewManagerProxy(),
 		loginExecutor:   login,
+		events:          &broadcastqueue.Queue[*rpc.Event]{},
 		timedLogLevel:   log.NewTimedLevel(cfg.LogLevels.UserDaemon.String(), log.SetLevel),
 	}
 }
 
 // run is the main function when executing as the connector
-func run(c context.Context, getCommands CommandFactory, daemonServices []DaemonService, sessionServices []trafficmgr.SessionService) error {
+func run(c context.Context, address string, getCommands CommandFactory, daemonServices []DaemonService, sessionServices []trafficmgr.SessionService) error {
 	cfg, err := client.LoadConfig(c)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	c = client.WithConfig(c, cfg)
 	c = dgroup.WithGoroutineName(c, "/"+ProcessName)
-	c, err = logging.InitContext(c, ProcessName, logging.RotateDaily, true)
+	c, err = logging.InitContext(c, client.ConnectionProcessName(c, ProcessName), logging.RotateDaily, true)
 	if err != nil {
 		return err
 	}
@@ -272,7 +295,7 @@ func run(c context.Context, getCommands CommandFactory, daemonServices []DaemonS
 	// Listen on domain unix domain socket or windows named pipe. The listener must be opened
 	// before other tasks because the CLI client will only wait for a short period of time for
 	// the socket/pipe to appear before it gives up.
-	grpcListener, err := client.ListenSocket(c, ProcessName, client.ConnectorSocketName)
+	grpcListener, err := client.ListenSocket(c, ProcessName, client.ConnectorSocket(c))
 	if err != nil {
 		return err
 	}
@@ -280,6 +303,15 @@ func run(c context.Context, getCommands CommandFactory, daemonServices []DaemonS
 		_ = client.RemoveSocket(grpcListener)
 	}()
 	dlog.Debug(c, "Listener opened")
+	listeners := []net.Listener{grpcListener}
+	if address != "" {
+		tcpListener, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		dlog.Debugf(c, "Listening on %s", tcpListener.Addr())
+		listeners = append(listeners, tcpListener)
+	}
 
 	dlog.Info(c, "---")
 	dlog.Infof(c, "Telepresence %s %s starting...", titleName, client.DisplayVersion())
@@ -304,6 +336,8 @@ func run(c context.Context, getCommands CommandFactory, daemonServices []DaemonS
 		ManagerProxy:      trafficmgr.NewManagerProxy(),
 		loginExecutor:     auth.NewStandardLoginExecutor(cliio, sr),
 		userNotifications: cliio.Subscribe,
+		notify:            cliio.Push,
+		events:            &broadcastqueue.Queue[*rpc.Event]{},
 		timedLogLevel:     log.NewTimedLevel(cfg.LogLevels.UserDaemon.String(), log.SetLevel),
 		getCommands:       getCommands,
 	}
@@ -339,9 +373,16 @@ func run(c context.Context, getCommands CommandFactory, daemonServices []DaemonS
 			}
 		}
 
-		sc := &dhttp.ServerConfig{Handler: s.svc}
 		dlog.Info(c, "gRPC server started")
-		if err = sc.Serve(c, grpcListener); err != nil && c.Err() != nil {
+		sg := dgroup.NewGroup(c, dgroup.GroupConfig{})
+		for _, l := range listeners {
+			l := l
+			sc := &dhttp.ServerConfig{Handler: docker.ListenerHandler(c, l, s.svc)}
+			sg.Go(l.Addr().Network(), func(c context.Context) error {
+				return sc.Serve(c, l)
+			})
+		}
+		if err = sg.Wait(); err != nil && c.Err() != nil {
 			err = nil // Normal shutdown
 		}
 		if err != nil {
@@ -352,6 +393,18 @@ func run(c context.Context, getCommands CommandFactory, daemonServices []DaemonS
 		return err
 	})
 
+	if port := cfg.Daemons.HTTPGatewayPort; port != 0 {
+		if env := client.GetEnv(c); env != nil && env.DaemonContainer != "" {
+			// The gateway would listen on the loopback interface of the container, which can't be
+			// reached from the host.
+			dlog.Info(c, "HTTP gateway disabled: not available when the daemon runs in a container")
+		} else {
+			g.Go("server-http-gateway", func(c context.Context) error {
+				return gateway.Serve(c, s, port)
+			})
+		}
+	}
+
 	g.Go("config-reload", s.configReload)
 	g.Go("session", func(c context.Context) error {
 		err := s.ManageSessions(c, sessionServices)
@@ -72,9 +72,13 @@ func (as agentsStringer) String() string {
 
 func (tm *TrafficManager) setCurrentAgents(ctx context.Context, agents []*manager.AgentInfo) {
 	tm.currentAgentsLock.Lock()
+	evs := agentEvents(tm.currentAgents, agents)
 	tm.currentAgents = agents
 	dlog.Debugf(ctx, "setCurrentAgents %s", agentsStringer(agents))
 	tm.currentAgentsLock.Unlock()
+	for _, ev := range evs {
+		tm.publish(ev)
+	}
 }
 
 func (tm *TrafficManager) notifyAgentWatchers(ctx context.Context, agents []*manager.AgentInfo) {
@@ -2,15 +2,57 @@ package trafficmgr
 
 import (
 	"context"
+	"time"
 
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/dlib/dtime"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 func (tm *TrafficManager) dialRequestWatcher(ctx context.Context) error {
-	// Deal with dial requests from the manager
+	if !tm.resilient(ctx) {
+		return tm.watchDialRequests(ctx, func() {})
+	}
+
+	// The dial request stream ends when the session is lost, so keep watching until the session has been
+	// reclaimed. A stream that delivered requests was healthy, so the backoff starts over after it ends.
+	const initialBackoff = 100 * time.Millisecond
+	backoff := initialBackoff
+	received := func() { backoff = initialBackoff }
+	for ctx.Err() == nil {
+		if err := tm.watchDialRequests(ctx, received); err != nil {
+			dlog.Error(ctx, err)
+		}
+		dtime.SleepWithContext(ctx, backoff)
+		backoff *= 2
+		if backoff > 3*time.Second {
+			backoff = 3 * time.Second
+		}
+	}
+	return nil
+}
+
+// watchDialRequests deals with dial requests from the manager until the stream of requests ends. The
+// received function is called for each request.
+func (tm *TrafficManager) watchDialRequests(ctx context.Context, received func()) error {
 	dialerStream, err := tm.managerClient.WatchDial(ctx, tm.sessionInfo)
 	if err != nil {
 		return err
 	}
-	return tunnel.DialWaitLoop(ctx, tm.managerClient, dialerStream, tm.sessionInfo.SessionId)
+	return tunnel.DialWaitLoop(ctx, tm.managerClient, &receiveNotifier{dialerStream, received}, tm.sessionInfo.SessionId)
+}
+
+// receiveNotifier is a stream of dial requests that calls received after each request that it receives.
+type receiveNotifier struct {
+	manager.Manager_WatchDialClient
+	received func()
+}
+
+func (s *receiveNotifier) Recv() (*manager.DialRequest, error) {
+	dr, err := s.Manager_WatchDialClient.Recv()
+	if err == nil {
+		s.received()
+	}
+	return dr, err
 }
@@ -0,0 +1,104 @@
+package trafficmgr
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// interceptEvents returns the events that describe the transition from one snapshot of intercepts to another.
+func interceptEvents(oldIIs, newIIs []*manager.InterceptInfo) []*connector.Event {
+	oldMap := make(map[string]*manager.InterceptInfo, len(oldIIs))
+	for _, ii := range oldIIs {
+		oldMap[ii.Id] = ii
+	}
+	var evs []*connector.Event
+	add := func(tp connector.InterceptEvent_Type, ii *manager.InterceptInfo) {
+		evs = append(evs, &connector.Event{Kind: &connector.Event_Intercept{Intercept: &connector.InterceptEvent{
+			Type:      tp,
+			Intercept: ii,
+		}}})
+	}
+	for _, ii := range newIIs {
+		oldII, ok := oldMap[ii.Id]
+		switch {
+		case !ok:
+			add(connector.InterceptEvent_CREATED, ii)
+		case !proto.Equal(oldII, ii):
+			add(connector.InterceptEvent_UPDATED, ii)
+		}
+		delete(oldMap, ii.Id)
+	}
+	for _, ii := range oldIIs {
+		if _, ok := oldMap[ii.Id]; ok {
+			add(connector.InterceptEvent_REMOVED, ii)
+		}
+	}
+	return evs
+}
+
+func agentKey(a *manager.AgentInfo) string {
+	return a.Name + "." + a.Namespace + "/" + a.PodIp
+}
+
+// agentEvents returns the events that describe the transition from one snapshot of agents to another.
+func agentEvents(oldAs, newAs []*manager.AgentInfo) []*connector.Event {
+	oldMap := make(map[string]struct{}, len(oldAs))
+	for _, a := range oldAs {
+		oldMap[agentKey(a)] = struct{}{}
+	}
+	var evs []*connector.Event
+	add := func(tp connector.AgentEvent_Type, a *manager.AgentInfo) {
+		evs = append(evs, &connector.Event{Kind: &connector.Event_Agent{Agent: &connector.AgentEvent{
+			Type:  tp,
+			Agent: a,
+		}}})
+	}
+	for _, a := range newAs {
+		k := agentKey(a)
+		if _, ok := oldMap[k]; ok {
+			delete(oldMap, k)
+		} else {
+			add(connector.AgentEvent_ARRIVED, a)
+		}
+	}
+	for _, a := range oldAs {
+		if _, ok := oldMap[agentKey(a)]; ok {
+			add(connector.AgentEvent_DEPARTED, a)
+		}
+	}
+	return evs
+}
+
+// publishMountEvent publishes an event with the given state of the mount of the given intercept.
+func (tm *TrafficManager) publishMountEvent(tp connector.MountEvent_Type, intercept, mountPoint string, err error) {
+	me := &connector.MountEvent{
+		Type:       tp,
+		Intercept:  intercept,
+		MountPoint: mountPoint,
+	}
+	if err != nil {
+		me.Error = err.Error()
+	}
+	tm.publish(&connector.Event{Kind: &connector.Event_Mount{Mount: me}})
+}
+
+// StateEvents returns events that describe the current intercepts, agents, and DNS configuration of the
+// session as if they had just been created, so that a client that starts to watch events learns about
+// the state that the events then change.
+func (tm *TrafficManager) StateEvents() []*connector.Event {
+	evs := interceptEvents(nil, tm.getCurrentIntercepts())
+
+	tm.currentAgentsLock.Lock()
+	evs = append(evs, agentEvents(nil, tm.currentAgents)...)
+	tm.currentAgentsLock.Unlock()
+
+	tm.currentDNSLock.Lock()
+	if tm.currentDNS != nil {
+		dns := proto.Clone(tm.currentDNS).(*connector.DNSEvent)
+		evs = append(evs, &connector.Event{Kind: &connector.Event_Dns{Dns: dns}})
+	}
+	tm.currentDNSLock.Unlock()
+	return evs
+}
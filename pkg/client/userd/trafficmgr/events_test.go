@@ -0,0 +1,92 @@
+package trafficmgr
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func Test_interceptEvents(t *testing.T) {
+	a := &manager.InterceptInfo{Id: "a", Disposition: manager.InterceptDispositionType_WAITING}
+	aActive := &manager.InterceptInfo{Id: "a", Disposition: manager.InterceptDispositionType_ACTIVE}
+	b := &manager.InterceptInfo{Id: "b", Disposition: manager.InterceptDispositionType_ACTIVE}
+	c := &manager.InterceptInfo{Id: "c", Disposition: manager.InterceptDispositionType_ACTIVE}
+
+	evs := interceptEvents([]*manager.InterceptInfo{a, b}, []*manager.InterceptInfo{aActive, b, c})
+	require.Len(t, evs, 2)
+	assert.Equal(t, connector.InterceptEvent_UPDATED, evs[0].GetIntercept().Type)
+	assert.Equal(t, aActive, evs[0].GetIntercept().Intercept)
+	assert.Equal(t, connector.InterceptEvent_CREATED, evs[1].GetIntercept().Type)
+	assert.Equal(t, c, evs[1].GetIntercept().Intercept)
+
+	evs = interceptEvents([]*manager.InterceptInfo{aActive, b, c}, []*manager.InterceptInfo{c})
+	require.Len(t, evs, 2)
+	assert.Equal(t, connector.InterceptEvent_REMOVED, evs[0].GetIntercept().Type)
+	assert.Equal(t, aActive, evs[0].GetIntercept().Intercept)
+	assert.Equal(t, connector.InterceptEvent_REMOVED, evs[1].GetIntercept().Type)
+	assert.Equal(t, b, evs[1].GetIntercept().Intercept)
+
+	assert.Empty(t, interceptEvents([]*manager.InterceptInfo{c}, []*manager.InterceptInfo{c}))
+}
+
+func Test_agentEvents(t *testing.T) {
+	a1 := &manager.AgentInfo{Name: "echo", Namespace: "default", PodIp: "10.1.0.5"}
+	a2 := &manager.AgentInfo{Name: "echo", Namespace: "default", PodIp: "10.1.0.6"}
+	b := &manager.AgentInfo{Name: "hello", Namespace: "default", PodIp: "10.1.0.7"}
+
+	evs := agentEvents([]*manager.AgentInfo{a1, b}, []*manager.AgentInfo{a2, b})
+	require.Len(t, evs, 2)
+	assert.Equal(t, connector.AgentEvent_ARRIVED, evs[0].GetAgent().Type)
+	assert.Equal(t, a2, evs[0].GetAgent().Agent)
+	assert.Equal(t, connector.AgentEvent_DEPARTED, evs[1].GetAgent().Type)
+	assert.Equal(t, a1, evs[1].GetAgent().Agent)
+
+	assert.Empty(t, agentEvents([]*manager.AgentInfo{a1}, []*manager.AgentInfo{a1}))
+}
+
+func Test_publishWhenMounted(t *testing.T) {
+	evs := make(chan *connector.Event, 10)
+	tm := &TrafficManager{publish: func(ev *connector.Event) { evs <- ev }}
+
+	// Nothing is mounted in a new directory, so no event is published.
+	established := tm.publishWhenMounted(context.Background(), "echo", t.TempDir())
+	time.Sleep(3 * mountPollInterval)
+	assert.False(t, established())
+	assert.Empty(t, evs)
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	// /proc is always mounted on Linux
+	established = tm.publishWhenMounted(context.Background(), "echo", "/proc")
+	select {
+	case ev := <-evs:
+		assert.Equal(t, connector.MountEvent_ESTABLISHED, ev.GetMount().Type)
+		assert.Equal(t, "/proc", ev.GetMount().MountPoint)
+	case <-time.After(time.Second):
+		t.Fatal("no event published for /proc")
+	}
+	assert.True(t, established())
+}
+
+func TestTrafficManager_StateEvents(t *testing.T) {
+	tm := &TrafficManager{}
+	assert.Empty(t, tm.StateEvents())
+
+	tm.currentIntercepts = []*manager.InterceptInfo{{Id: "a", Spec: &manager.InterceptSpec{Name: "echo"}}}
+	tm.currentAgents = []*manager.AgentInfo{{Name: "echo", Namespace: "default", PodIp: "10.1.0.5"}}
+	tm.currentDNS = &connector.DNSEvent{SearchPaths: []string{"default"}}
+	evs := tm.StateEvents()
+	require.Len(t, evs, 3)
+	assert.Equal(t, connector.InterceptEvent_CREATED, evs[0].GetIntercept().Type)
+	assert.Equal(t, "a", evs[0].GetIntercept().Intercept.Id)
+	assert.Equal(t, connector.AgentEvent_ARRIVED, evs[1].GetAgent().Type)
+	assert.Equal(t, []string{"default"}, evs[2].GetDns().SearchPaths)
+}
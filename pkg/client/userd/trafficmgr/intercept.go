@@ -2,6 +2,7 @@ package trafficmgr
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -22,6 +23,7 @@ import (
 	empty "google.golang.org/protobuf/types/known/emptypb"
 	core "k8s.io/api/core/v1"
 	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/datawire/dlib/dcontext"
 	"github.com/datawire/dlib/dgroup"
@@ -275,9 +277,13 @@ func (tm *TrafficManager) getCurrentIntercepts() []*manager.InterceptInfo {
 
 func (tm *TrafficManager) setCurrentIntercepts(ctx context.Context, intercepts []*manager.InterceptInfo) {
 	tm.currentInterceptsLock.Lock()
+	evs := interceptEvents(tm.currentIntercepts, intercepts)
 	tm.currentIntercepts = intercepts
 	tm.reconcileAPIServers(ctx)
 	tm.currentInterceptsLock.Unlock()
+	for _, ev := range evs {
+		tm.publish(ev)
+	}
 }
 
 func interceptError(tp common.InterceptError, err error) *rpc.InterceptResult {
@@ -502,6 +508,15 @@ func (tm *TrafficManager) AddIntercept(c context.Context, ir *rpc.CreateIntercep
 		spec.Mechanism = "tcp"
 	}
 
+	var tlsCert *manager.TLSCertificate
+	if spec.TlsSecret != "" {
+		// The secret is read using the user's credentials, so that an intercept can only use the
+		// secrets that the user has access to.
+		if tlsCert, err = getTLSCertificate(c, spec.TlsSecret, spec.Namespace); err != nil {
+			return interceptError(common.InterceptError_NOT_FOUND, err), nil
+		}
+	}
+
 	cfg := client.GetConfig(c)
 	apiPort := uint16(cfg.TelepresenceAPI.Port)
 	if apiPort == 0 {
@@ -575,11 +590,13 @@ func (tm *TrafficManager) AddIntercept(c context.Context, ir *rpc.CreateIntercep
 	}()
 
 	var ii *manager.InterceptInfo
-	ii, err = tm.managerClient.CreateIntercept(c, &manager.CreateInterceptRequest{
-		Session:       tm.session(),
-		InterceptSpec: spec,
-		ApiKey:        svcProps.apiKey,
-	})
+	createReq := &manager.CreateInterceptRequest{
+		Session:        tm.session(),
+		InterceptSpec:  spec,
+		ApiKey:         svcProps.apiKey,
+		TlsCertificate: tlsCert,
+	}
+	ii, err = tm.managerClient.CreateIntercept(c, createReq)
 	if err != nil {
 		dlog.Debugf(c, "manager responded to CreateIntercept with error %v", err)
 		err = client.CheckTimeout(c, err)
@@ -642,6 +659,7 @@ func (tm *TrafficManager) AddIntercept(c context.Context, ir *rpc.CreateIntercep
 				}
 			}
 			success = true
+			tm.addDesiredIntercept(createReq)
 			return result, nil
 		}
 	}
@@ -759,7 +777,20 @@ func (tm *TrafficManager) workerMountForwardIntercept(ctx context.Context, mf mo
 			sshfsArgs = append([]string{"cmd", "-ouid=-1", "-ogid=-1"}, sshfsArgs...)
 			exe = "sshfs-win"
 		}
+		established := tm.publishWhenMounted(ctx, mf.Name, mountPoint)
 		err = dpipe.DPipe(ctx, conn, exe, sshfsArgs...)
+		wasEstablished := established()
+		switch {
+		case ctx.Err() != nil:
+			if wasEstablished {
+				tm.publishMountEvent(rpc.MountEvent_LOST, mf.Name, mountPoint, nil)
+			}
+		case !wasEstablished && err == nil:
+			err = errors.New("sshfs exited before the mount was established")
+			fallthrough
+		default:
+			tm.publishMountEvent(rpc.MountEvent_LOST, mf.Name, mountPoint, err)
+		}
 		time.Sleep(time.Second)
 
 		// sshfs sometimes leave the mount point in a bad state. This will clean it up
@@ -774,6 +805,37 @@ func (tm *TrafficManager) workerMountForwardIntercept(ctx context.Context, mf mo
 	}
 }
 
+// mountPollInterval is how often a mount point is checked while waiting for its mount to be established.
+const mountPollInterval = 100 * time.Millisecond
+
+// publishWhenMounted publishes a MountEvent_ESTABLISHED for the given intercept once a file system is mounted
+// at the given mount point. The returned function stops the wait, and reports if the event was published.
+func (tm *TrafficManager) publishWhenMounted(ctx context.Context, intercept, mountPoint string) func() bool {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan bool, 1)
+	go func() {
+		ticker := time.NewTicker(mountPollInterval)
+		defer ticker.Stop()
+		for {
+			if isMounted(mountPoint) {
+				tm.publishMountEvent(rpc.MountEvent_ESTABLISHED, intercept, mountPoint, nil)
+				done <- true
+				return
+			}
+			select {
+			case <-ctx.Done():
+				done <- false
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() bool {
+		cancel()
+		return <-done
+	}
+}
+
 // RemoveIntercept removes one intercept by name
 func (tm *TrafficManager) RemoveIntercept(c context.Context, name string) error {
 	dlog.Debugf(c, "Removing intercept %s", name)
@@ -782,6 +844,9 @@ func (tm *TrafficManager) RemoveIntercept(c context.Context, name string) error
 		return tm.RemoveLocalOnlyIntercept(c, name, ns)
 	}
 
+	// Ensure that the intercept isn't restored, even if it's currently unknown due to a lost session.
+	tm.removeDesiredIntercept(name)
+
 	var ii *manager.InterceptInfo
 	for _, cept := range tm.getCurrentIntercepts() {
 		if cept.Spec.Name == name {
@@ -815,6 +880,7 @@ func (tm *TrafficManager) removeIntercept(c context.Context, ii *manager.Interce
 		}
 	}
 
+	tm.removeDesiredIntercept(name)
 	dlog.Debugf(c, "telling manager to remove intercept %s", name)
 	_, err := tm.managerClient.RemoveIntercept(c, &manager.RemoveInterceptRequest2{
 		Session: tm.session(),
@@ -1017,3 +1083,22 @@ func (tm *TrafficManager) RemoveLocalOnlyIntercept(c context.Context, name, name
 	tm.updateDaemonNamespaces(c)
 	return nil
 }
+
+// getTLSCertificate returns the certificate of the given Kubernetes TLS secret.
+func getTLSCertificate(ctx context.Context, name, namespace string) (*manager.TLSCertificate, error) {
+	secret, err := k8sapi.GetK8sInterface(ctx).CoreV1().Secrets(namespace).Get(ctx, name, meta.GetOptions{})
+	if err != nil {
+		return nil, errcat.User.Newf("unable to get TLS secret %s.%s: %v", name, namespace, err)
+	}
+	if secret.Type != core.SecretTypeTLS {
+		return nil, errcat.User.Newf("secret %s.%s is of type %s, not %s", name, namespace, secret.Type, core.SecretTypeTLS)
+	}
+	cert := &manager.TLSCertificate{
+		CertPem: secret.Data[core.TLSCertKey],
+		KeyPem:  secret.Data[core.TLSPrivateKeyKey],
+	}
+	if _, err = tls.X509KeyPair(cert.CertPem, cert.KeyPem); err != nil {
+		return nil, errcat.User.Newf("TLS secret %s.%s doesn't contain a valid certificate: %v", name, namespace, err)
+	}
+	return cert, nil
+}
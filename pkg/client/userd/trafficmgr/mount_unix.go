@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package trafficmgr
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+// isMounted returns true when a file system is mounted at the given mount point, i.e. when the mount
+// point resides on another device than its parent directory.
+func isMounted(mountPoint string) bool {
+	var st, parentSt syscall.Stat_t
+	if err := syscall.Stat(mountPoint, &st); err != nil {
+		return false
+	}
+	if err := syscall.Stat(filepath.Dir(mountPoint), &parentSt); err != nil {
+		return false
+	}
+	return st.Dev != parentSt.Dev
+}
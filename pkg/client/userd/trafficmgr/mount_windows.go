@@ -0,0 +1,11 @@
+package trafficmgr
+
+import (
+	"os"
+)
+
+// isMounted returns true when a file system is mounted at the given mount point, which is a drive letter.
+func isMounted(mountPoint string) bool {
+	_, err := os.Stat(mountPoint + `\`)
+	return err == nil
+}
@@ -338,6 +338,10 @@ func (p *mgrProxy) AgentLookupHostResponse(ctx context.Context, arg *managerrpc.
 	return client.AgentLookupHostResponse(ctx, arg, callOptions...)
 }
 
+func (p *mgrProxy) GetInterceptTLSCertificate(context.Context, *managerrpc.GetInterceptTLSCertificateRequest) (*managerrpc.TLSCertificate, error) {
+	return nil, status.Error(codes.Unimplemented, "must call manager.GetInterceptTLSCertificate from an agent (intercepted Pod), not from a client (workstation)")
+}
+
 func (p *mgrProxy) WatchLookupHost(*managerrpc.SessionInfo, managerrpc.Manager_WatchLookupHostServer) error {
 	return status.Error(codes.Unimplemented, "must call manager.WatchLookupHost from an agent (intercepted Pod), not from a client (workstation)")
 }
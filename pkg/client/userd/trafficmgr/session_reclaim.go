@@ -0,0 +1,117 @@
+package trafficmgr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/a8rcloud"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// resilient returns true if this session should survive the loss of its session in the traffic-manager.
+func (tm *TrafficManager) resilient(ctx context.Context) bool {
+	return !tm.isPodDaemon && client.GetConfig(ctx).Daemons.ResilientSession
+}
+
+// addDesiredIntercept remembers the request of a successfully created intercept, so that the intercept
+// can be recreated if the session is lost.
+func (tm *TrafficManager) addDesiredIntercept(req *manager.CreateInterceptRequest) {
+	tm.desiredInterceptsLock.Lock()
+	tm.desiredIntercepts[req.InterceptSpec.Name] = req
+	tm.desiredInterceptsLock.Unlock()
+}
+
+// removeDesiredIntercept forgets the request of the intercept with the given name.
+func (tm *TrafficManager) removeDesiredIntercept(name string) {
+	tm.desiredInterceptsLock.Lock()
+	delete(tm.desiredIntercepts, name)
+	tm.desiredInterceptsLock.Unlock()
+}
+
+// reclaimSession makes the traffic-manager create a new session using the ID of the lost session, and then
+// recreates the intercepts that were lost together with that session. The port-forwards and mounts of the
+// recreated intercepts are started by the intercept watcher once the intercepts become active.
+func (tm *TrafficManager) reclaimSession(ctx context.Context) error {
+	tm.publishConnectionEvent(connector.ConnectionEvent_LOST, nil)
+	tm.notify("The session with the traffic-manager was lost, reconnecting...")
+	apiKey, _ := tm.getCloudAPIKey(ctx, a8rcloud.KeyDescTrafficManager, false)
+	idToken, _ := tm.getIDToken(ctx)
+	si, err := arriveAsClient(ctx, tm.managerClient, tm.Config.BearerToken, &manager.ClientInfo{
+		Name:      tm.userAndHost,
+		InstallId: tm.installID,
+		Product:   "telepresence",
+		Version:   client.Version(),
+		ApiKey:    apiKey,
+		SessionId: tm.sessionInfo.SessionId,
+		IdToken:   idToken,
+	})
+	if err != nil {
+		return fmt.Errorf("manager.ArriveAsClient: %w", err)
+	}
+	if si.SessionId != tm.sessionInfo.SessionId {
+		// The root daemon uses the session ID too, so a session with a new ID must be established
+		// from scratch.
+		if _, err = tm.managerClient.Depart(ctx, si); err != nil {
+			dlog.Errorf(ctx, "failed to depart from manager: %v", err)
+		}
+		return fmt.Errorf("unable to reclaim session %s", tm.sessionInfo.SessionId)
+	}
+	dlog.Infof(ctx, "Session %s reclaimed", si.SessionId)
+	tm.publishConnectionEvent(connector.ConnectionEvent_RECONNECTED, nil)
+	tm.restoreIntercepts(ctx)
+	tm.notify("Reconnected to the traffic-manager")
+	return nil
+}
+
+// restoreIntercepts recreates the desired intercepts. Intercepts that can't be recreated are forgotten.
+func (tm *TrafficManager) restoreIntercepts(ctx context.Context) {
+	tm.desiredInterceptsLock.Lock()
+	names := make([]string, 0, len(tm.desiredIntercepts))
+	for name := range tm.desiredIntercepts {
+		names = append(names, name)
+	}
+	tm.desiredInterceptsLock.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		tm.desiredInterceptsLock.Lock()
+		req, ok := tm.desiredIntercepts[name]
+		tm.desiredInterceptsLock.Unlock()
+		if !ok {
+			// Removed while we were busy restoring others
+			continue
+		}
+		_, err := tm.managerClient.CreateIntercept(ctx, req)
+		switch {
+		case err == nil:
+			tm.notify(fmt.Sprintf("Intercept %s restored", name))
+		case grpcStatus.Code(err) == grpcCodes.AlreadyExists:
+			dlog.Debugf(ctx, "Intercept %s was retained by the traffic-manager", name)
+		default:
+			dlog.Errorf(ctx, "unable to restore intercept %s: %v", name, err)
+			tm.notify(fmt.Sprintf("Unable to restore intercept %s: %v", name, grpcStatus.Convert(err).Message()))
+			tm.removeDesiredIntercept(name)
+		}
+	}
+}
+
+// publishConnectionEvent publishes an event with the given state of the connection to the traffic-manager.
+func (tm *TrafficManager) publishConnectionEvent(state connector.ConnectionEvent_State, err error) {
+	ce := &connector.ConnectionEvent{
+		State:          state,
+		ClusterContext: tm.Config.Context,
+		ClusterServer:  tm.Config.Server,
+		SessionId:      tm.sessionInfo.SessionId,
+	}
+	if err != nil {
+		ce.Error = err.Error()
+	}
+	tm.publish(&connector.Event{Kind: &connector.Event_Connection{Connection: ce}})
+}
@@ -18,6 +18,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
 	empty "google.golang.org/protobuf/types/known/emptypb"
@@ -88,6 +89,7 @@ type Session interface {
 	GetCurrentNamespaces(forClientAccess bool) []string
 	ActualNamespace(string) string
 	RemainWithToken(context.Context) error
+	StateEvents() []*rpc.Event
 	AddNamespaceListener(k8s.NamespaceListener)
 	GatherLogs(context.Context, *connector.LogsRequest) (*connector.LogsResponse, error)
 	ForeachAgentPod(ctx context.Context, fn func(context.Context, typed.PodInterface, *core.Pod), filter func(*core.Pod) bool) error
@@ -97,6 +99,8 @@ type Service interface {
 	RootDaemonClient(context.Context) (daemon.DaemonClient, error)
 	SetManagerClient(manager.ManagerClient, ...grpc.CallOption)
 	LoginExecutor() auth.LoginExecutor
+	Notify(msg string)
+	PublishEvent(*rpc.Event)
 }
 
 type apiServer struct {
@@ -118,6 +122,10 @@ type TrafficManager struct {
 
 	getCloudAPIKey func(context.Context, string, bool) (string, error)
 
+	// getIDToken returns the ID token of the OpenID Connect provider that the user is logged in to, or an
+	// empty string when no such provider is configured.
+	getIDToken func(context.Context) (string, error)
+
 	ingressInfo []*manager.IngressInfo
 
 	// manager client
@@ -134,6 +142,17 @@ type TrafficManager struct {
 
 	sessionInfo *manager.SessionInfo // sessionInfo returned by the traffic-manager
 
+	// notify sends a message to the CLI commands that are subscribed to user notifications
+	notify func(string)
+
+	// publish sends an event to the clients that watch the events of the user daemon
+	publish func(*rpc.Event)
+
+	// desiredIntercepts are the requests of the intercepts that this client has created, keyed by
+	// intercept name. They are used when recreating the intercepts after a lost session.
+	desiredIntercepts     map[string]*manager.CreateInterceptRequest
+	desiredInterceptsLock sync.Mutex
+
 	// Map of desired mount points for intercepts
 	mountPoints sync.Map
 
@@ -168,6 +187,10 @@ type TrafficManager struct {
 	currentAgents     []*manager.AgentInfo
 	currentAgentsLock sync.Mutex
 
+	// currentDNS is the DNS configuration that was last sent to the root daemon
+	currentDNS     *rpc.DNSEvent
+	currentDNSLock sync.Mutex
+
 	// activeInterceptsWaiters contains chan interceptResult keyed by intercept name
 	activeInterceptsWaiters sync.Map
 
@@ -217,6 +240,7 @@ func NewSession(c context.Context, sr *scout.Reporter, cr *rpc.ConnectRequest, s
 
 	// Phone home with the information about the size of the cluster
 	c = cluster.WithK8sInterface(c)
+	c = withProfiles(c, cluster.Context, cluster.GetClusterId(c))
 	sr.SetMetadatum(c, "cluster_id", cluster.GetClusterId(c))
 	if !cr.IsPodDaemon {
 		sr.Report(c, "connecting_traffic_manager", scout.Entry{
@@ -337,7 +361,10 @@ func connectCluster(c context.Context, cr *rpc.ConnectRequest) (*k8s.Cluster, er
 		return nil, err
 	}
 
-	mappedNamespaces := cr.MappedNamespaces
+	// Apply the profile of the kube context, so that it's in effect while connecting to the cluster
+	c = withProfiles(c, config.Context, "")
+
+	mappedNamespaces := getMappedNamespaces(c, cr)
 	if len(mappedNamespaces) == 1 && mappedNamespaces[0] == "all" {
 		mappedNamespaces = nil
 	} else {
@@ -351,6 +378,27 @@ func connectCluster(c context.Context, cr *rpc.ConnectRequest) (*k8s.Cluster, er
 	return cluster, nil
 }
 
+// withProfiles returns a context where the configuration has the profiles that match the given kube
+// context and cluster ID applied.
+func withProfiles(c context.Context, contextName, clusterID string) context.Context {
+	if names := client.GetConfig(c).MatchingProfiles(contextName, clusterID); len(names) > 0 {
+		dlog.Debugf(c, "Using configuration profiles %v", names)
+	}
+	return client.WithProfiles(c, contextName, clusterID)
+}
+
+// getMappedNamespaces returns the mapped namespaces of the given request, or the ones of the
+// configuration when the request has none.
+func getMappedNamespaces(c context.Context, cr *rpc.ConnectRequest) []string {
+	if len(cr.MappedNamespaces) > 0 {
+		return cr.MappedNamespaces
+	}
+	cns := client.GetConfig(c).MappedNamespaces
+	mns := make([]string, len(cns))
+	copy(mns, cns)
+	return mns
+}
+
 func DeleteManager(ctx context.Context, req *rpc.HelmRequest) error {
 	cr := req.GetConnectRequest()
 	if cr == nil {
@@ -491,12 +539,18 @@ func connectMgr(c context.Context, cluster *k8s.Cluster, installID string, svc S
 
 	if si == nil {
 		dlog.Debugf(c, "traffic-manager port-forward established, making client known to the traffic-manager as %q", userAndHost)
-		si, err = mClient.ArriveAsClient(tc, &manager.ClientInfo{
+		idToken, idErr := svc.LoginExecutor().GetIDToken(c)
+		if idErr != nil {
+			// The traffic-manager decides whether an ID token is required.
+			dlog.Debugf(c, "unable to get ID token: %v", idErr)
+		}
+		si, err = arriveAsClient(tc, mClient, cluster.Config.BearerToken, &manager.ClientInfo{
 			Name:      userAndHost,
 			InstallId: installID,
 			Product:   "telepresence",
 			Version:   client.Version(),
 			ApiKey:    apiKey,
+			IdToken:   idToken,
 		})
 		if err != nil {
 			return nil, client.CheckTimeout(tc, fmt.Errorf("manager.ArriveAsClient: %w", err))
@@ -513,10 +567,14 @@ func connectMgr(c context.Context, cluster *k8s.Cluster, installID string, svc S
 		getCloudAPIKey: func(ctx context.Context, desc string, autoLogin bool) (string, error) {
 			return auth.GetCloudAPIKey(ctx, svc.LoginExecutor(), desc, autoLogin)
 		},
+		getIDToken:          svc.LoginExecutor().GetIDToken,
 		managerClient:       mClient,
 		managerConn:         conn,
 		managerVersion:      managerVersion,
 		sessionInfo:         si,
+		notify:              svc.Notify,
+		publish:             svc.PublishEvent,
+		desiredIntercepts:   map[string]*manager.CreateInterceptRequest{},
 		rootDaemon:          rootDaemon,
 		localIntercepts:     map[string]string{},
 		currentInterceptors: map[string]int{},
@@ -525,6 +583,28 @@ func connectMgr(c context.Context, cluster *k8s.Cluster, installID string, svc S
 	}, nil
 }
 
+// arriveAsClient makes the client known to the traffic-manager. The Kubernetes bearer token that the given
+// function returns is only sent when the traffic-manager refuses the client because it requires the token.
+func arriveAsClient(
+	ctx context.Context,
+	mClient manager.ManagerClient,
+	bearerToken func(context.Context) (string, error),
+	ci *manager.ClientInfo,
+) (*manager.SessionInfo, error) {
+	var trailer metadata.MD
+	si, err := mClient.ArriveAsClient(ctx, ci, grpc.Trailer(&trailer))
+	if err == nil || status.Code(err) != codes.Unauthenticated || len(trailer.Get(k8sapi.BearerTokenRequiredKey)) == 0 {
+		return si, err
+	}
+	token, btErr := bearerToken(ctx)
+	if btErr != nil || token == "" {
+		dlog.Errorf(ctx, "the traffic-manager requires a Kubernetes bearer token, but none could be found: %v", btErr)
+		return nil, err
+	}
+	ci.BearerToken = token
+	return mClient.ArriveAsClient(ctx, ci)
+}
+
 func connectError(t rpc.ConnectInfo_ErrType, err error) *rpc.ConnectInfo {
 	return &rpc.ConnectInfo{
 		Error:         t,
@@ -566,8 +646,14 @@ func (tm *TrafficManager) updateDaemonNamespaces(c context.Context) {
 
 	if _, err := tm.rootDaemon.SetDnsSearchPath(c, &daemon.Paths{Paths: paths, Namespaces: namespaces}); err != nil {
 		dlog.Errorf(c, "error posting search paths %v and namespaces %v to root daemon: %v", paths, namespaces, err)
+		return
 	}
 	dlog.Debug(c, "search paths posted successfully")
+	dns := &rpc.DNSEvent{SearchPaths: paths, Namespaces: namespaces}
+	tm.currentDNSLock.Lock()
+	tm.currentDNS = dns
+	tm.currentDNSLock.Unlock()
+	tm.publish(&rpc.Event{Kind: &rpc.Event_Dns{Dns: dns}})
 }
 
 // Run (1) starts up with ensuring that the manager is installed and running,
@@ -829,6 +915,7 @@ var SessionExpiredErr = errors.New("session expired")
 
 func (tm *TrafficManager) remain(c context.Context) error {
 	ticker := time.NewTicker(5 * time.Second)
+	lost := false
 	defer func() {
 		ticker.Stop()
 		c = dcontext.WithoutCancel(c)
@@ -862,9 +949,25 @@ func (tm *TrafficManager) remain(c context.Context) error {
 			if err != nil && c.Err() == nil {
 				dlog.Error(c, err)
 				if gErr, ok := status.FromError(err); ok && gErr.Code() == codes.NotFound {
+					if tm.resilient(c) {
+						if err = tm.reclaimSession(c); err == nil {
+							lost = false
+							continue
+						}
+						dlog.Error(c, err)
+					}
 					// Session has expired. We need to cancel the owner session and reconnect
 					return SessionExpiredErr
 				}
+				if !lost && tm.resilient(c) {
+					lost = true
+					tm.publishConnectionEvent(rpc.ConnectionEvent_LOST, err)
+					tm.notify("Lost connection to the traffic-manager, reconnecting...")
+				}
+			} else if err == nil && lost {
+				lost = false
+				tm.publishConnectionEvent(rpc.ConnectionEvent_RECONNECTED, nil)
+				tm.notify("Reconnected to the traffic-manager")
 			}
 		}
 	}
@@ -891,7 +994,7 @@ func (tm *TrafficManager) UpdateStatus(c context.Context, cr *rpc.ConnectRequest
 		}
 	}
 
-	if tm.SetMappedNamespaces(c, cr.MappedNamespaces) {
+	if tm.SetMappedNamespaces(c, getMappedNamespaces(c, cr)) {
 		tm.insLock.Lock()
 		tm.ingressInfo = nil
 		tm.insLock.Unlock()
@@ -1131,17 +1234,30 @@ func (tm *TrafficManager) getOutboundInfo(ctx context.Context) *daemon.OutboundI
 		NeverProxySubnets: neverProxy,
 	}
 
-	if tm.DNS != nil {
+	// The DNS settings of the client configuration are used unless the kubeconfig extension declares them.
+	cfgDNS := &client.GetConfig(ctx).DNS
+	if tm.DNS != nil || len(cfgDNS.ExcludeSuffixes) > 0 || len(cfgDNS.IncludeSuffixes) > 0 || cfgDNS.LookupTimeout != 0 {
 		info.Dns = &daemon.DNSConfig{
-			ExcludeSuffixes: tm.DNS.ExcludeSuffixes,
-			IncludeSuffixes: tm.DNS.IncludeSuffixes,
-			LookupTimeout:   durationpb.New(tm.DNS.LookupTimeout.Duration),
+			ExcludeSuffixes: cfgDNS.ExcludeSuffixes,
+			IncludeSuffixes: cfgDNS.IncludeSuffixes,
+			LookupTimeout:   durationpb.New(cfgDNS.LookupTimeout),
 		}
-		if len(tm.DNS.LocalIP) > 0 {
-			info.Dns.LocalIp = tm.DNS.LocalIP.IP()
-		}
-		if len(tm.DNS.RemoteIP) > 0 {
-			info.Dns.RemoteIp = tm.DNS.RemoteIP.IP()
+		if tm.DNS != nil {
+			if len(tm.DNS.ExcludeSuffixes) > 0 {
+				info.Dns.ExcludeSuffixes = tm.DNS.ExcludeSuffixes
+			}
+			if len(tm.DNS.IncludeSuffixes) > 0 {
+				info.Dns.IncludeSuffixes = tm.DNS.IncludeSuffixes
+			}
+			if tm.DNS.LookupTimeout.Duration != 0 {
+				info.Dns.LookupTimeout = durationpb.New(tm.DNS.LookupTimeout.Duration)
+			}
+			if len(tm.DNS.LocalIP) > 0 {
+				info.Dns.LocalIp = tm.DNS.LocalIP.IP()
+			}
+			if len(tm.DNS.RemoteIP) > 0 {
+				info.Dns.RemoteIp = tm.DNS.RemoteIP.IP()
+			}
 		}
 	}
 
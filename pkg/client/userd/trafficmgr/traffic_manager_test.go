@@ -0,0 +1,81 @@
+package trafficmgr
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/k8sapi"
+)
+
+// tokenManager is a traffic-manager that records the bearer tokens of the clients that arrive, and
+// optionally requires one.
+type tokenManager struct {
+	manager.UnimplementedManagerServer
+	requireToken bool
+	tokens       []string
+}
+
+func (m *tokenManager) ArriveAsClient(ctx context.Context, ci *manager.ClientInfo) (*manager.SessionInfo, error) {
+	m.tokens = append(m.tokens, ci.BearerToken)
+	if m.requireToken && ci.BearerToken == "" {
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(k8sapi.BearerTokenRequiredKey, "true"))
+		return nil, status.Error(codes.Unauthenticated, "bearer token required")
+	}
+	if ci.IdToken == "" {
+		return nil, status.Error(codes.Unauthenticated, "ID token required")
+	}
+	return &manager.SessionInfo{SessionId: "session-1"}, nil
+}
+
+func Test_arriveAsClient(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	m := &tokenManager{}
+	srv := grpc.NewServer()
+	manager.RegisterManagerServer(srv, m)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Stop()
+	conn, err := grpc.DialContext(ctx, l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	mClient := manager.NewManagerClient(conn)
+
+	bearerToken := func(context.Context) (string, error) {
+		return "kube-token", nil
+	}
+	arrive := func(idToken string) error {
+		m.tokens = nil
+		_, err := arriveAsClient(ctx, mClient, bearerToken, &manager.ClientInfo{Name: "alice", IdToken: idToken})
+		return err
+	}
+
+	t.Run("not required", func(t *testing.T) {
+		require.NoError(t, arrive("id-token"))
+		assert.Equal(t, []string{""}, m.tokens)
+	})
+
+	t.Run("refused for another reason", func(t *testing.T) {
+		assert.Equal(t, codes.Unauthenticated, status.Code(arrive("")))
+		assert.Equal(t, []string{""}, m.tokens)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		m.requireToken = true
+		require.NoError(t, arrive("id-token"))
+		assert.Equal(t, []string{"", "kube-token"}, m.tokens)
+	})
+}
@@ -0,0 +1,136 @@
+package forwarder
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
+)
+
+// sniffH2C returns true if the client of the given connection starts with the HTTP/2 client preface,
+// i.e. uses HTTP/2 with prior knowledge over cleartext, which is what gRPC does when TLS isn't used.
+func sniffH2C(conn net.Conn, br *bufio.Reader) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(httpSniffTimeout)); err != nil {
+		return false
+	}
+	b, _ := br.Peek(len(http2.ClientPreface))
+	_ = conn.SetReadDeadline(time.Time{})
+	return string(b) == http2.ClientPreface
+}
+
+// newH2CTransport returns an http.RoundTripper that uses HTTP/2 over connections returned by the given dial
+// function.
+func newH2CTransport(dial func() (net.Conn, error)) *http2.Transport {
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(string, string, *tls.Config) (net.Conn, error) {
+			return dial()
+		},
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// routeGRPC serves an HTTP/2 connection and routes each one of its streams to either the intercepted
+// container or the intercept, depending on if the gRPC call matches the gRPC matcher of the intercept.
+// A connection that isn't using HTTP/2 over cleartext is forwarded to the intercepted container.
+func (f *tcp) routeGRPC(ctx context.Context, clientConn net.Conn, iCept *manager.InterceptInfo) error {
+	m, err := matcher.NewGRPCRequestFromMap(iCept.Spec.GrpcMatch)
+	if err != nil {
+		return fmt.Errorf("invalid gRPC match for intercept %s: %w", iCept.Spec.Name, err)
+	}
+	targetHost, targetPort := f.Target()
+	br := bufio.NewReader(clientConn)
+	if !sniffH2C(clientConn, br) {
+		// There are no gRPC calls to match.
+		return f.forwardTo(ctx, clientConn, br, targetHost, targetPort, nil)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	clientAddr := clientConn.RemoteAddr()
+	ctx = dlog.WithField(ctx, "client", clientAddr.String())
+	go func() {
+		// Terminate the HTTP/2 connection when the intercept changes.
+		<-ctx.Done()
+		_ = clientConn.Close()
+	}()
+
+	app := newH2CTransport(func() (net.Conn, error) {
+		return dialTarget(ctx, targetHost, targetPort)
+	})
+	defer app.CloseIdleConnections()
+	icpt := newH2CTransport(func() (net.Conn, error) {
+		return f.dialIntercept(ctx, clientAddr, iCept)
+	})
+	defer icpt.CloseIdleConnections()
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "http"
+			r.URL.Host = r.Host
+		},
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if m.Matches(r.URL.Path, r.Header) {
+				dlog.Debugf(ctx, "routing %s to intercept", r.URL.Path)
+				return icpt.RoundTrip(r)
+			}
+			return app.RoundTrip(r)
+		}),
+		// Flush immediately so that streaming calls aren't delayed.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			dlog.Errorf(ctx, "unable to route %s: %v", r.URL.Path, err)
+			writeGRPCStatus(w, status.Newf(codes.Unavailable, "unable to route %s: %v", r.URL.Path, err))
+		},
+	}
+	srv := &http2.Server{}
+	srv.ServeConn(&bufferedConn{Conn: clientConn, r: br}, &http2.ServeConnOpts{
+		Context: ctx,
+		Handler: proxy,
+	})
+	return nil
+}
+
+// writeGRPCStatus writes a gRPC response that consists of the given status only, so that the client of the
+// call gets a proper gRPC error rather than an HTTP error.
+func writeGRPCStatus(w http.ResponseWriter, st *status.Status) {
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(int(st.Code())))
+	h.Set("Grpc-Message", encodeGRPCMessage(st.Message()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes the given message the way that the gRPC protocol requires for the
+// grpc-message header.
+func encodeGRPCMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,150 @@
+package forwarder
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// startH2CServer starts a server that responds with the given name to all HTTP/2 requests.
+func startH2CServer(t *testing.T, name string) *net.TCPAddr {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		_, _ = io.WriteString(w, name)
+	})
+	go func() {
+		srv := &http2.Server{}
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	return l.Addr().(*net.TCPAddr)
+}
+
+func TestRouteGRPC(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	appAddr := startH2CServer(t, "app")
+	iceptAddr := startH2CServer(t, "intercept")
+
+	f := newTCP(&net.TCPAddr{}, appAddr.IP.String(), uint16(appAddr.Port)).(*tcp)
+	iCept := &manager.InterceptInfo{Spec: &manager.InterceptSpec{
+		Name:            "grpc",
+		TargetInCluster: true,
+		TargetHost:      iceptAddr.IP.String(),
+		TargetPort:      int32(iceptAddr.Port),
+		GrpcMatch:       map[string]string{":grpc-method:": "pkg.Svc/*"},
+	}}
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}})
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.AcceptTCP()
+		if err != nil {
+			return
+		}
+		_ = f.routeGRPC(ctx, conn, iCept)
+	}()
+
+	// A single HTTP/2 connection is used for all calls, yet each call is routed on its own.
+	var dialed int
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			dialed++
+			return net.Dial(network, l.Addr().String())
+		},
+	}}
+	call := func(path string) string {
+		rq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://grpc.example.com"+path, strings.NewReader(""))
+		require.NoError(t, err)
+		rq.Header.Set("Content-Type", "application/grpc")
+		rs, err := client.Do(rq)
+		require.NoError(t, err)
+		defer rs.Body.Close()
+		body, err := io.ReadAll(rs.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "intercept", call("/pkg.Svc/Get"))
+		assert.Equal(t, "app", call("/other.Svc/Get"))
+	}
+	assert.Equal(t, 1, dialed)
+}
+
+func TestRouteGRPC_unavailable(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The intercept can't be reached, because nothing listens on its port.
+	il, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	iceptAddr := il.Addr().(*net.TCPAddr)
+	require.NoError(t, il.Close())
+
+	appAddr := startH2CServer(t, "app")
+	f := newTCP(&net.TCPAddr{}, appAddr.IP.String(), uint16(appAddr.Port)).(*tcp)
+	iCept := &manager.InterceptInfo{Spec: &manager.InterceptSpec{
+		Name:            "grpc",
+		TargetInCluster: true,
+		TargetHost:      iceptAddr.IP.String(),
+		TargetPort:      int32(iceptAddr.Port),
+		GrpcMatch:       map[string]string{":grpc-method:": "pkg.Svc/*"},
+	}}
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}})
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.AcceptTCP()
+		if err != nil {
+			return
+		}
+		_ = f.routeGRPC(ctx, conn, iCept)
+	}()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, l.Addr().String())
+		},
+	}}
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://grpc.example.com/pkg.Svc/Get", strings.NewReader(""))
+	require.NoError(t, err)
+	rq.Header.Set("Content-Type", "application/grpc")
+	rs, err := client.Do(rq)
+	require.NoError(t, err)
+	defer rs.Body.Close()
+	assert.Equal(t, http.StatusOK, rs.StatusCode)
+	assert.Equal(t, "application/grpc", rs.Header.Get("Content-Type"))
+	assert.Equal(t, "14", rs.Header.Get("Grpc-Status"))
+	assert.True(t, strings.HasPrefix(rs.Header.Get("Grpc-Message"), "unable to route /pkg.Svc/Get: "))
+}
+
+func Test_encodeGRPCMessage(t *testing.T) {
+	assert.Equal(t, "no route", encodeGRPCMessage("no route"))
+	assert.Equal(t, "100%25 failed%0Ahere: %C3%A5", encodeGRPCMessage("100% failed\nhere: å"))
+}
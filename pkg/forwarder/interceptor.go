@@ -2,6 +2,7 @@ package forwarder
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -41,6 +42,10 @@ type interceptor struct {
 
 	intercept  *manager.InterceptInfo
 	mgrVersion semver.Version
+
+	// tlsCert is the certificate used when terminating TLS for the intercept with ID tlsCertInterceptID.
+	tlsCert            *tls.Certificate
+	tlsCertInterceptID string
 }
 
 func NewInterceptor(addr net.Addr, targetHost string, targetPort uint16) Interceptor {
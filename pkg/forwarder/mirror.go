@@ -0,0 +1,200 @@
+package forwarder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+const (
+	// mirrorQueueSize is the max number of chunks that can be queued up for a mirror. The mirror
+	// must never slow down the traffic to the intercepted container, so when the queue is full, the
+	// mirroring of the connection is abandoned.
+	mirrorQueueSize = 64
+
+	// mirrorResponseTimeout is the max time to wait for the workstation to respond to a mirrored
+	// HTTP request.
+	mirrorResponseTimeout = 30 * time.Second
+)
+
+// httpMethodPrefixes are used when sniffing a connection to determine if it's using HTTP/1.x.
+var httpMethodPrefixes = [][]byte{
+	[]byte("GET "),
+	[]byte("HEAD "),
+	[]byte("POST "),
+	[]byte("PUT "),
+	[]byte("DELETE "),
+	[]byte("CONNECT "),
+	[]byte("OPTIONS "),
+	[]byte("TRACE "),
+	[]byte("PATCH "),
+}
+
+// mirror is an io.WriteCloser that never blocks the writer. Everything written to it is passed on
+// to a reader which sends it to the workstation.
+type mirror struct {
+	ch   chan []byte
+	done bool   // only accessed by the writer
+	buf  []byte // only accessed by the reader
+}
+
+// startMirror starts a goroutine that sends everything that is written to the returned io.WriteCloser
+// to the workstation of the given intercept. HTTP/1.x requests are mirrored one by one, anything else
+// is mirrored as a stream. Responses are always discarded.
+func (f *interceptor) startMirror(ctx context.Context, clientAddr net.Addr, iCept *manager.InterceptInfo) io.WriteCloser {
+	m := &mirror{ch: make(chan []byte, mirrorQueueSize)}
+	go func() {
+		ctx := dlog.WithField(ctx, "mirror", clientAddr.String())
+		br := bufio.NewReader(m)
+		if isHTTP1(br) {
+			f.mirrorHTTP(ctx, clientAddr, br, iCept)
+		} else {
+			f.mirrorStream(ctx, clientAddr, br, iCept)
+		}
+	}()
+	return m
+}
+
+func (m *mirror) Write(p []byte) (int, error) {
+	if !m.done {
+		select {
+		case m.ch <- append([]byte(nil), p...):
+		default:
+			// The workstation can't keep up. Give up on this connection.
+			m.done = true
+			close(m.ch)
+		}
+	}
+	return len(p), nil
+}
+
+func (m *mirror) Close() error {
+	if !m.done {
+		m.done = true
+		close(m.ch)
+	}
+	return nil
+}
+
+func (m *mirror) Read(p []byte) (int, error) {
+	if len(m.buf) == 0 {
+		var ok bool
+		if m.buf, ok = <-m.ch; !ok {
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+func isHTTP1(br *bufio.Reader) bool {
+	// The error is ignored because whatever was read is still good enough to base a decision on.
+	b, _ := br.Peek(len("CONNECT "))
+	for _, pfx := range httpMethodPrefixes {
+		if bytes.HasPrefix(b, pfx) {
+			return true
+		}
+	}
+	return false
+}
+
+// mirrorHTTP reads requests from the given reader and sends each one of them to the workstation. A failure
+// to mirror one request doesn't prevent the next one from being mirrored.
+func (f *interceptor) mirrorHTTP(ctx context.Context, clientAddr net.Addr, br *bufio.Reader, iCept *manager.InterceptInfo) {
+	var conn net.Conn
+	var connReader *bufio.Reader
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				dlog.Debugf(ctx, "unable to read mirrored request: %v", err)
+			}
+			return
+		}
+		if conn == nil {
+			if conn, err = f.dialIntercept(ctx, clientAddr, iCept); err != nil {
+				dlog.Debugf(ctx, "unable to dial mirror: %v", err)
+				return
+			}
+			connReader = bufio.NewReader(conn)
+		}
+		if req.Header.Get("Upgrade") != "" {
+			// The connection will switch to another protocol, so mirror the rest as a stream
+			if err = req.Write(conn); err == nil {
+				mirrorStreamTo(ctx, conn, br)
+			}
+			return
+		}
+		keepAlive, err := mirrorRequest(conn, connReader, req)
+		if err != nil {
+			dlog.Debugf(ctx, "mirror of %s %s failed: %v", req.Method, req.URL, err)
+		}
+		// The body must be consumed before the next request can be read.
+		_, _ = io.Copy(io.Discard, req.Body)
+		_ = req.Body.Close()
+		if err != nil || !keepAlive {
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+// mirrorRequest writes the request to the given connection and discards the response. It returns
+// true if the connection can be used for another request.
+func mirrorRequest(conn net.Conn, br *bufio.Reader, req *http.Request) (bool, error) {
+	if err := conn.SetDeadline(time.Now().Add(mirrorResponseTimeout)); err != nil {
+		return false, err
+	}
+	if err := req.Write(conn); err != nil {
+		return false, err
+	}
+	for {
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			return false, err
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return false, err
+		}
+		// Informational responses are followed by the final response.
+		if resp.StatusCode >= http.StatusOK {
+			return !resp.Close, nil
+		}
+	}
+}
+
+// mirrorStream sends everything read from the given reader to the workstation.
+func (f *interceptor) mirrorStream(ctx context.Context, clientAddr net.Addr, r io.Reader, iCept *manager.InterceptInfo) {
+	conn, err := f.dialIntercept(ctx, clientAddr, iCept)
+	if err != nil {
+		dlog.Debugf(ctx, "unable to dial mirror: %v", err)
+		return
+	}
+	defer conn.Close()
+	mirrorStreamTo(ctx, conn, r)
+}
+
+func mirrorStreamTo(ctx context.Context, conn net.Conn, r io.Reader) {
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+	if _, err := io.Copy(conn, r); err != nil {
+		dlog.Debugf(ctx, "mirror stream failed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package forwarder
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHTTP1(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"GET / HTTP/1.1\r\nHost: x\r\n\r\n", true},
+		{"OPTIONS * HTTP/1.1\r\n\r\n", true},
+		{"PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n", false},
+		{"\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03", false},
+		{"GET", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isHTTP1(bufio.NewReader(strings.NewReader(tt.data))), "%q", tt.data)
+	}
+}
+
+func TestMirror_neverBlocks(t *testing.T) {
+	m := &mirror{ch: make(chan []byte, 2)}
+	for i := 0; i < 5; i++ {
+		n, err := m.Write([]byte("abc"))
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+	}
+	assert.True(t, m.done)
+	require.NoError(t, m.Close())
+
+	// What was queued before the mirror gave up is still readable.
+	data, err := io.ReadAll(m)
+	require.NoError(t, err)
+	assert.Equal(t, "abcabc", string(data))
+}
+
+func TestMirrorRequest(t *testing.T) {
+	conn, server := net.Pipe()
+	defer conn.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		defer server.Close()
+		br := bufio.NewReader(server)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		received <- req.Method + " " + req.URL.Path + " " + string(body)
+		_, _ = io.WriteString(server, "HTTP/1.1 100 Continue\r\n\r\n")
+		_, _ = io.WriteString(server, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+	}()
+
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(
+		"POST /echo HTTP/1.1\r\nHost: example.com\r\nContent-Length: 4\r\n\r\nping")))
+	require.NoError(t, err)
+
+	keepAlive, err := mirrorRequest(conn, bufio.NewReader(conn), req)
+	require.NoError(t, err)
+	assert.True(t, keepAlive)
+	assert.Equal(t, "POST /echo ping", <-received)
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
@@ -19,6 +23,10 @@ import (
 
 type tcp struct {
 	interceptor
+
+	// rnd decides where connections to weighted intercepts are routed when the routing isn't sticky.
+	rndMu sync.Mutex
+	rnd   *rand.Rand
 }
 
 func newTCP(listen net.Addr, targetHost string, targetPort uint16) Interceptor {
@@ -28,6 +36,7 @@ func newTCP(listen net.Addr, targetHost string, targetPort uint16) Interceptor {
 			targetHost: targetHost,
 			targetPort: targetPort,
 		},
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
@@ -98,28 +107,64 @@ func (f *tcp) forwardConn(clientConn *net.TCPConn) error {
 	targetPort := f.targetPort
 	intercept := f.intercept
 	f.mu.Unlock()
-	if intercept != nil {
-		return f.interceptConn(ctx, clientConn, intercept)
+	switch {
+	case intercept == nil:
+		return f.forwardTo(ctx, clientConn, clientConn, targetHost, targetPort, nil)
+	case intercept.Spec.TlsSecret != "":
+		return f.terminateTLS(ctx, clientConn, intercept)
+	default:
+		return f.forwardIntercepted(ctx, clientConn, intercept)
 	}
+}
 
-	targetAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", targetHost, targetPort))
-	if err != nil {
-		return fmt.Errorf("error on resolve(%s:%d): %w", targetHost, targetPort, err)
+// forwardIntercepted forwards a connection to the intercepted port in the way that the spec of the given
+// intercept dictates.
+func (f *tcp) forwardIntercepted(ctx context.Context, conn net.Conn, intercept *manager.InterceptInfo) error {
+	spec := intercept.Spec
+	switch {
+	case spec.Mirror:
+		// The connection is forwarded to the target as usual, and a copy of what the
+		// client sends is also sent to the workstation.
+		targetHost, targetPort := f.Target()
+		return f.forwardTo(ctx, conn, conn, targetHost, targetPort, intercept)
+	case len(spec.GrpcMatch) > 0:
+		return f.routeGRPC(ctx, conn, intercept)
+	case spec.Weight > 0 && spec.Weight < 100:
+		return f.routeConn(ctx, conn, intercept)
+	case spec.TargetInCluster:
+		// The intercept is directed to another address in the cluster, so there's no
+		// need to involve the workstation. Just forward to that address.
+		return f.forwardTo(ctx, conn, conn, spec.TargetHost, uint16(spec.TargetPort), nil)
+	default:
+		return f.forwardToIntercept(ctx, conn, conn, intercept)
 	}
+}
 
-	span.SetAttributes(
+// forwardTo forwards the given client connection to the given target host:port. Data sent by the client is
+// read from src, which is either the client connection itself or a reader that buffers it. When mirrored isn't
+// nil, a copy of that data is also sent to the workstation of that intercept.
+func (f *tcp) forwardTo(
+	ctx context.Context,
+	clientConn net.Conn,
+	src io.Reader,
+	targetHost string,
+	targetPort uint16,
+	mirrored *manager.InterceptInfo,
+) error {
+	targetAddr := net.JoinHostPort(targetHost, strconv.Itoa(int(targetPort)))
+	trace.SpanFromContext(ctx).SetAttributes(
 		attribute.String("client", clientConn.RemoteAddr().String()),
-		attribute.String("target", targetAddr.String()),
+		attribute.String("target", targetAddr),
 	)
 	ctx = dlog.WithField(ctx, "client", clientConn.RemoteAddr().String())
-	ctx = dlog.WithField(ctx, "target", targetAddr.String())
+	ctx = dlog.WithField(ctx, "target", targetAddr)
 
 	dlog.Debug(ctx, "Forwarding...")
 	defer dlog.Debug(ctx, "Done forwarding")
 
 	defer clientConn.Close()
 
-	targetConn, err := net.DialTCP("tcp", nil, targetAddr)
+	targetConn, err := dialTarget(ctx, targetHost, targetPort)
 	if err != nil {
 		return fmt.Errorf("error on dial: %w", err)
 	}
@@ -128,17 +173,22 @@ func (f *tcp) forwardConn(clientConn *net.TCPConn) error {
 	done := make(chan struct{})
 
 	go func() {
-		if _, err := io.Copy(targetConn, clientConn); err != nil {
+		if mirrored != nil {
+			m := f.startMirror(ctx, clientConn.RemoteAddr(), mirrored)
+			defer m.Close()
+			src = io.TeeReader(src, m)
+		}
+		if _, err := io.Copy(targetConn, src); err != nil {
 			dlog.Debugf(ctx, "Error clientConn->targetConn: %+v", err)
 		}
-		_ = targetConn.CloseWrite()
+		closeWrite(targetConn)
 		done <- struct{}{}
 	}()
 	go func() {
 		if _, err := io.Copy(clientConn, targetConn); err != nil {
 			dlog.Debugf(ctx, "Error targetConn->clientConn: %+v", err)
 		}
-		_ = clientConn.CloseWrite()
+		closeWrite(clientConn)
 		done <- struct{}{}
 	}()
 
@@ -154,6 +204,45 @@ func (f *tcp) forwardConn(clientConn *net.TCPConn) error {
 	return nil
 }
 
+// closeWrite shuts down the writing side of the given connection, provided that the connection supports that.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	}
+}
+
+// dialTarget dials the given host and port. TLS is used when the context has an upstream TLS config, i.e. when
+// the forwarded connection has been TLS-terminated and TLS must be re-originated.
+func dialTarget(ctx context.Context, host string, port uint16) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return nil, err
+	}
+	return withUpstreamTLS(ctx, conn), nil
+}
+
+// forwardToIntercept forwards the given client connection to the workstation of the given intercept. Data sent
+// by the client is read from src, which is either the client connection itself or a reader that buffers it.
+func (f *interceptor) forwardToIntercept(ctx context.Context, clientConn net.Conn, src io.Reader, iCept *manager.InterceptInfo) error {
+	if src != clientConn {
+		clientConn = &bufferedConn{Conn: clientConn, r: src}
+	}
+	if getUpstreamTLSConfig(ctx) == nil {
+		return f.interceptConn(ctx, clientConn, iCept)
+	}
+
+	// TLS must be re-originated, so the connection is spliced with a TLS connection to the workstation.
+	defer clientConn.Close()
+	conn, err := f.dialIntercept(ctx, clientConn.RemoteAddr(), iCept)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	spliceConns(ctx, clientConn, clientConn, conn, conn)
+	return nil
+}
+
 func (f *interceptor) interceptConn(ctx context.Context, conn net.Conn, iCept *manager.InterceptInfo) error {
 	ctx, span := otel.Tracer("").Start(ctx, "interceptConn")
 	defer span.End()
@@ -192,3 +281,32 @@ func (f *interceptor) interceptConn(ctx context.Context, conn net.Conn, iCept *m
 	<-d.Done()
 	return nil
 }
+
+// tunneledConn is a net.Conn that reports the address of the client of the intercepted connection
+// as its remote address.
+type tunneledConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *tunneledConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// dialIntercept returns a connection to the destination of the given intercept, which is either an
+// address in the cluster or a tunnel to the workstation. TLS is used when the context has an upstream
+// TLS config.
+func (f *interceptor) dialIntercept(ctx context.Context, clientAddr net.Addr, iCept *manager.InterceptInfo) (net.Conn, error) {
+	spec := iCept.Spec
+	if spec.TargetInCluster {
+		return dialTarget(ctx, spec.TargetHost, uint16(spec.TargetPort))
+	}
+	conn, tunneled := net.Pipe()
+	go func() {
+		defer tunneled.Close()
+		if err := f.interceptConn(ctx, &tunneledConn{Conn: tunneled, remoteAddr: clientAddr}, iCept); err != nil {
+			dlog.Debugf(ctx, "unable to tunnel to workstation: %v", err)
+		}
+	}()
+	return withUpstreamTLS(ctx, conn), nil
+}
@@ -0,0 +1,120 @@
+package forwarder
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// tlsHandshakeTimeout is the max time to wait for a client to complete the TLS handshake.
+const tlsHandshakeTimeout = 10 * time.Second
+
+type upstreamTLSKey struct{}
+
+// withUpstreamTLSConfig returns a context that makes connections to the intercepted container or the intercept
+// use TLS with the given config.
+func withUpstreamTLSConfig(ctx context.Context, cfg *tls.Config) context.Context {
+	return context.WithValue(ctx, upstreamTLSKey{}, cfg)
+}
+
+// getUpstreamTLSConfig returns the upstream TLS config of the given context, or nil if no such config exists.
+func getUpstreamTLSConfig(ctx context.Context) *tls.Config {
+	if cfg, ok := ctx.Value(upstreamTLSKey{}).(*tls.Config); ok {
+		return cfg
+	}
+	return nil
+}
+
+// withUpstreamTLS wraps the given connection in a TLS client connection if the context has an upstream TLS
+// config. The connection is returned unchanged otherwise.
+func withUpstreamTLS(ctx context.Context, conn net.Conn) net.Conn {
+	if cfg := getUpstreamTLSConfig(ctx); cfg != nil {
+		return tls.Client(conn, cfg)
+	}
+	return conn
+}
+
+// tlsCertificate returns the certificate to use when terminating TLS for the given intercept. The certificate
+// is retrieved from the traffic-manager once per intercept.
+func (f *interceptor) tlsCertificate(ctx context.Context, iCept *manager.InterceptInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	if f.tlsCert != nil && f.tlsCertInterceptID == iCept.Id {
+		cert := f.tlsCert
+		f.mu.Unlock()
+		return cert, nil
+	}
+	mgr := f.manager
+	sessionInfo := f.sessionInfo
+	f.mu.Unlock()
+
+	if mgr == nil {
+		return nil, fmt.Errorf("no traffic-manager available to provide the TLS certificate for intercept %s", iCept.Spec.Name)
+	}
+	tc, err := mgr.GetInterceptTLSCertificate(ctx, &manager.GetInterceptTLSCertificateRequest{
+		Session:     sessionInfo,
+		InterceptId: iCept.Id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the TLS certificate for intercept %s: %w", iCept.Spec.Name, err)
+	}
+	cert, err := tls.X509KeyPair(tc.CertPem, tc.KeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS certificate for intercept %s: %w", iCept.Spec.Name, err)
+	}
+
+	f.mu.Lock()
+	f.tlsCert = &cert
+	f.tlsCertInterceptID = iCept.Id
+	f.mu.Unlock()
+	return &cert, nil
+}
+
+// terminateTLS performs the server side of a TLS handshake with the client using the certificate of the given
+// intercept, and then forwards the decrypted connection in the way that the spec of the intercept dictates.
+// Unless the intercept declares that the upstream uses plaintext, TLS is re-originated when connecting to the
+// intercepted container or the intercept.
+func (f *tcp) terminateTLS(ctx context.Context, clientConn *net.TCPConn, iCept *manager.InterceptInfo) error {
+	cert, err := f.tlsCertificate(ctx, iCept)
+	if err != nil {
+		_ = clientConn.Close()
+		return err
+	}
+	nextProtos := []string{"http/1.1"}
+	if len(iCept.Spec.GrpcMatch) > 0 {
+		// HTTP/2 is only negotiated when it's required, because the upstream might not support it.
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+	tlsConn := tls.Server(clientConn, &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   nextProtos,
+		MinVersion:   tls.VersionTLS12,
+	})
+	hsCtx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+	err = tlsConn.HandshakeContext(hsCtx)
+	cancel()
+	if err != nil {
+		_ = tlsConn.Close()
+		dlog.Debugf(ctx, "TLS handshake with %s failed: %v", clientConn.RemoteAddr(), err)
+		return nil
+	}
+
+	if !iCept.Spec.TlsPlaintextUpstream {
+		st := tlsConn.ConnectionState()
+		upstream := &tls.Config{
+			// The upstream is reached using an address that its certificate isn't issued for, and the client
+			// has already verified the certificate of the intercepted service.
+			InsecureSkipVerify: true, //nolint:gosec // see comment above
+			ServerName:         st.ServerName,
+		}
+		if st.NegotiatedProtocol != "" {
+			upstream.NextProtos = []string{st.NegotiatedProtocol}
+		}
+		ctx = withUpstreamTLSConfig(ctx, upstream)
+	}
+	return f.forwardIntercepted(ctx, tlsConn, iCept)
+}
@@ -0,0 +1,118 @@
+package forwarder
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func selfSignedCertificate(t *testing.T, host string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTerminateTLS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto := "plaintext"
+		if r.TLS != nil {
+			proto = "tls " + r.TLS.ServerName
+		}
+		_, _ = io.WriteString(w, proto)
+	})
+	tlsUpstream := httptest.NewTLSServer(handler)
+	defer tlsUpstream.Close()
+	plainUpstream := httptest.NewServer(handler)
+	defer plainUpstream.Close()
+
+	cert := selfSignedCertificate(t, "svc.example.com")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	tests := []struct {
+		name      string
+		upstream  *httptest.Server
+		plaintext bool
+		want      string
+	}{
+		{"re-originate TLS", tlsUpstream, false, "tls svc.example.com"},
+		{"plaintext upstream", plainUpstream, true, "plaintext"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := dlog.NewTestContext(t, false)
+			upAddr := tt.upstream.Listener.Addr().(*net.TCPAddr)
+			iCept := &manager.InterceptInfo{Id: "tls-intercept", Spec: &manager.InterceptSpec{
+				Name:                 "tls",
+				TargetInCluster:      true,
+				TargetHost:           upAddr.IP.String(),
+				TargetPort:           int32(upAddr.Port),
+				TlsSecret:            "svc-tls",
+				TlsPlaintextUpstream: tt.plaintext,
+			}}
+			f := newTCP(&net.TCPAddr{}, "127.0.0.1", 1).(*tcp)
+			f.tlsCert = &cert
+			f.tlsCertInterceptID = iCept.Id
+
+			l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}})
+			require.NoError(t, err)
+			defer l.Close()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				conn, err := l.AcceptTCP()
+				if err != nil {
+					return
+				}
+				_ = f.terminateTLS(ctx, conn, iCept)
+			}()
+
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "svc.example.com", MinVersion: tls.VersionTLS12},
+				DialContext: func(_ context.Context, network, _ string) (net.Conn, error) {
+					return net.Dial(network, l.Addr().String())
+				},
+			}}
+			rs, err := client.Get("https://svc.example.com/")
+			require.NoError(t, err)
+			body, err := io.ReadAll(rs.Body)
+			_ = rs.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(body))
+
+			// Wait for the forwarder to finish so that it doesn't log after the test has ended.
+			client.CloseIdleConnections()
+			<-done
+		})
+	}
+}
@@ -86,24 +86,31 @@ func (f *udp) Serve(ctx context.Context, initCh chan<- net.Addr) error {
 func (f *udp) forward(ctx context.Context, conn *net.UDPConn, intercept *manager.InterceptInfo) error {
 	defer conn.Close()
 	var err error
-	if intercept != nil {
+	switch {
+	case intercept == nil, intercept.Spec.Mirror, intercept.Spec.Weight > 0 && intercept.Spec.Weight < 100, len(intercept.Spec.GrpcMatch) > 0:
+		// UDP traffic is neither mirrored, split by weight, nor gRPC, so such intercepts just forward to the target.
+		targetHost, targetPort := f.Target()
+		err = f.forwardConn(ctx, conn, targetHost, targetPort)
+	case intercept.Spec.TargetInCluster:
+		// The intercept is directed to another address in the cluster, so there's no
+		// need to involve the workstation. Just forward to that address.
+		err = f.forwardConn(ctx, conn, intercept.Spec.TargetHost, uint16(intercept.Spec.TargetPort))
+	default:
 		err = f.interceptConn(ctx, conn, intercept)
-	} else {
-		err = f.forwardConn(ctx, conn)
 	}
 	return err
 }
 
 // forwardConn reads packets from the given connection and writes the packages to the
-// target host:port of this forwarder using a connection that will use the reply address
-// from the read as the destination for packages going in the other direction.
-func (f *udp) forwardConn(ctx context.Context, conn *net.UDPConn) error {
+// given target host:port using a connection that will use the reply address from the
+// read as the destination for packages going in the other direction.
+func (f *udp) forwardConn(ctx context.Context, conn *net.UDPConn, targetHost string, targetPort uint16) error {
 	ctx, span := otel.Tracer("").Start(ctx, "forwardConn")
 	defer span.End()
 
-	targetAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", f.targetHost, f.targetPort))
+	targetAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", targetHost, targetPort))
 	if err != nil {
-		return fmt.Errorf("error on resolve(%s:%d): %w", f.targetHost, f.targetPort, err)
+		return fmt.Errorf("error on resolve(%s:%d): %w", targetHost, targetPort, err)
 	}
 
 	la := conn.LocalAddr()
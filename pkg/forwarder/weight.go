@@ -0,0 +1,206 @@
+package forwarder
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+// httpSniffTimeout is the max time to wait for the first bytes from a client when determining if the
+// connection uses HTTP/1.x. Protocols where the server speaks first will be delayed by this timeout.
+const httpSniffTimeout = time.Second
+
+// routeToIntercept decides if a connection or request should be routed to the intercept rather than to the
+// intercepted container, based on the weight of the intercept. The headers are nil unless the decision
+// concerns an HTTP request.
+func (f *tcp) routeToIntercept(spec *manager.InterceptSpec, clientAddr net.Addr, headers http.Header) bool {
+	var key string
+	if spec.StickyHeader != "" && headers != nil {
+		key = headers.Get(spec.StickyHeader)
+	}
+	if key == "" && spec.StickyClientIp {
+		if ip, _, err := iputil.SplitToIPPort(clientAddr); err == nil {
+			key = ip.String()
+		}
+	}
+	var n uint32
+	if key == "" {
+		f.rndMu.Lock()
+		n = uint32(f.rnd.Intn(100))
+		f.rndMu.Unlock()
+	} else {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		n = h.Sum32() % 100
+	}
+	return n < uint32(spec.Weight)
+}
+
+// bufferedConn is a net.Conn that reads from a reader that buffers the connection.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// sniffHTTP1 returns true if the client of the given connection sends an HTTP/1.x request.
+func sniffHTTP1(conn net.Conn, br *bufio.Reader) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(httpSniffTimeout)); err != nil {
+		return false
+	}
+	isHTTP := isHTTP1(br)
+	_ = conn.SetReadDeadline(time.Time{})
+	return isHTTP
+}
+
+// routeConn routes a connection to a weighted intercept. HTTP/1.x requests are routed one by one. Other
+// connections are routed in their entirety.
+func (f *tcp) routeConn(ctx context.Context, clientConn net.Conn, iCept *manager.InterceptInfo) error {
+	br := bufio.NewReader(clientConn)
+	if sniffHTTP1(clientConn, br) {
+		return f.routeHTTP(ctx, clientConn, br, iCept)
+	}
+	spec := iCept.Spec
+	switch {
+	case !f.routeToIntercept(spec, clientConn.RemoteAddr(), nil):
+		targetHost, targetPort := f.Target()
+		return f.forwardTo(ctx, clientConn, br, targetHost, targetPort, nil)
+	case spec.TargetInCluster:
+		return f.forwardTo(ctx, clientConn, br, spec.TargetHost, uint16(spec.TargetPort), nil)
+	default:
+		return f.forwardToIntercept(ctx, clientConn, br, iCept)
+	}
+}
+
+// httpBackend is a connection to either the intercepted container or the intercept.
+type httpBackend struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (b *httpBackend) close() {
+	if b != nil {
+		_ = b.conn.Close()
+	}
+}
+
+// routeHTTP reads requests from the client and routes each one of them to either the intercepted container or
+// the intercept. Responses are sent back to the client in the same order as the requests were read.
+func (f *tcp) routeHTTP(ctx context.Context, clientConn net.Conn, br *bufio.Reader, iCept *manager.InterceptInfo) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	clientAddr := clientConn.RemoteAddr()
+	targetHost, targetPort := f.Target()
+	ctx = dlog.WithField(ctx, "client", clientAddr.String())
+	go func() {
+		// Unblock pending reads when the intercept changes.
+		<-ctx.Done()
+		_ = clientConn.Close()
+	}()
+
+	var app, icpt *httpBackend
+	defer func() {
+		app.close()
+		icpt.close()
+	}()
+
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error reading request: %w", err)
+		}
+
+		var b **httpBackend
+		if f.routeToIntercept(iCept.Spec, clientAddr, req.Header) {
+			b = &icpt
+		} else {
+			b = &app
+		}
+		if *b == nil {
+			var conn net.Conn
+			if b == &icpt {
+				conn, err = f.dialIntercept(ctx, clientAddr, iCept)
+			} else {
+				conn, err = dialTarget(ctx, targetHost, targetPort)
+			}
+			if err != nil {
+				return fmt.Errorf("error on dial: %w", err)
+			}
+			*b = &httpBackend{conn: conn, r: bufio.NewReader(conn)}
+		}
+		be := *b
+
+		if err = req.Write(be.conn); err != nil {
+			return fmt.Errorf("error writing request: %w", err)
+		}
+		if req.Header.Get("Upgrade") != "" {
+			// The connection might switch to another protocol, so the rest cannot be routed per request.
+			spliceConns(ctx, clientConn, br, be.conn, be.r)
+			return nil
+		}
+		closeConn, err := relayResponse(clientConn, be.r, req)
+		if err != nil {
+			return err
+		}
+		if closeConn {
+			return nil
+		}
+	}
+}
+
+// relayResponse reads the response to the given request and writes it to the client. It returns true if
+// the connection to the client should be closed.
+func relayResponse(clientConn net.Conn, r *bufio.Reader, req *http.Request) (bool, error) {
+	for {
+		resp, err := http.ReadResponse(r, req)
+		if err != nil {
+			return true, fmt.Errorf("error reading response: %w", err)
+		}
+		err = resp.Write(clientConn)
+		_ = resp.Body.Close()
+		if err != nil {
+			return true, fmt.Errorf("error writing response: %w", err)
+		}
+		// Informational responses are followed by the final response.
+		if resp.StatusCode >= http.StatusOK {
+			return req.Close || resp.Close, nil
+		}
+	}
+}
+
+// spliceConns copies data in both directions until one of the directions is closed.
+func spliceConns(ctx context.Context, a net.Conn, ar io.Reader, b net.Conn, br io.Reader) {
+	done := make(chan struct{}, 2)
+	go func() {
+		if _, err := io.Copy(b, ar); err != nil {
+			dlog.Debugf(ctx, "Error clientConn->targetConn: %+v", err)
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		if _, err := io.Copy(a, br); err != nil {
+			dlog.Debugf(ctx, "Error targetConn->clientConn: %+v", err)
+		}
+		done <- struct{}{}
+	}()
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+}
@@ -0,0 +1,127 @@
+package forwarder
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestRouteToIntercept(t *testing.T) {
+	clientAddr := &net.TCPAddr{IP: net.IP{10, 0, 0, 1}, Port: 34567}
+	otherAddr := &net.TCPAddr{IP: net.IP{10, 0, 0, 1}, Port: 45678}
+	f := newTCP(&net.TCPAddr{}, "", 0).(*tcp)
+
+	t.Run("sticky client IP", func(t *testing.T) {
+		spec := &manager.InterceptSpec{Weight: 50, StickyClientIp: true}
+		first := f.routeToIntercept(spec, clientAddr, nil)
+		for i := 0; i < 20; i++ {
+			// The port differs between connections from the same client, but the IP doesn't.
+			assert.Equal(t, first, f.routeToIntercept(spec, otherAddr, nil))
+		}
+	})
+
+	t.Run("sticky header", func(t *testing.T) {
+		spec := &manager.InterceptSpec{Weight: 50, StickyHeader: "X-User"}
+		hits := 0
+		for i := 0; i < 100; i++ {
+			h := http.Header{"X-User": []string{strconv.Itoa(i)}}
+			first := f.routeToIntercept(spec, clientAddr, h)
+			assert.Equal(t, first, f.routeToIntercept(spec, otherAddr, h))
+			if first {
+				hits++
+			}
+		}
+		assert.Greater(t, hits, 0)
+		assert.Less(t, hits, 100)
+	})
+
+	t.Run("weight", func(t *testing.T) {
+		spec := &manager.InterceptSpec{Weight: 10}
+		hits := 0
+		for i := 0; i < 10000; i++ {
+			if f.routeToIntercept(spec, clientAddr, nil) {
+				hits++
+			}
+		}
+		assert.InDelta(t, 1000, hits, 300)
+	})
+}
+
+func TestRouteHTTP(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	newServer := func(name string) *url.URL {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+		t.Cleanup(s.Close)
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err)
+		return u
+	}
+	appURL := newServer("app")
+	iceptURL := newServer("intercept")
+	appPort, _ := strconv.Atoi(appURL.Port())
+	iceptPort, _ := strconv.Atoi(iceptURL.Port())
+
+	f := newTCP(&net.TCPAddr{}, appURL.Hostname(), uint16(appPort)).(*tcp)
+	iCept := &manager.InterceptInfo{Spec: &manager.InterceptSpec{
+		TargetInCluster: true,
+		TargetHost:      iceptURL.Hostname(),
+		TargetPort:      int32(iceptPort),
+		Weight:          50,
+		StickyHeader:    "X-User",
+	}}
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IP{127, 0, 0, 1}})
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.AcceptTCP()
+		if err != nil {
+			return
+		}
+		_ = f.routeHTTP(ctx, conn, bufio.NewReader(conn), iCept)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	// All requests use the same connection, yet each one is routed on its own.
+	seen := map[string]int{}
+	for i := 0; i < 40; i++ {
+		user := strconv.Itoa(i % 20)
+		_, err = fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nX-User: %s\r\n\r\n", user)
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		want := "app"
+		if f.routeToIntercept(iCept.Spec, nil, http.Header{"X-User": []string{user}}) {
+			want = "intercept"
+		}
+		assert.Equal(t, want, string(body))
+		seen[string(body)]++
+	}
+	assert.Len(t, seen, 2)
+}
@@ -0,0 +1,6 @@
+package k8sapi
+
+// BearerTokenRequiredKey is the key of the gRPC trailer that the traffic-manager sends when it refuses a
+// client that didn't provide the Kubernetes bearer token that the manager requires. Clients only send
+// their token when asked to, so that it isn't exposed to traffic-managers that have no use for it.
+const BearerTokenRequiredKey = "x-telepresence-bearer-token-required"
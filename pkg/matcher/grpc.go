@@ -0,0 +1,144 @@
+package matcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// The GRPCRequest matcher uses a Value matcher and a Headers matcher to match the full method name and the
+// metadata of a gRPC call.
+type GRPCRequest interface {
+	fmt.Stringer
+
+	// Map returns the map correspondence of this instance. The returned value can be
+	// used as an argument to NewGRPCRequestFromMap to create an identical GRPCRequest.
+	Map() map[string]string
+
+	// Matches returns true if the given request is a gRPC call and both the method Value matcher and the
+	// metadata Headers matcher in this instance are matched by the :path and headers of the request.
+	Matches(path string, headers http.Header) bool
+
+	// Metadata returns the metadata Headers of this instance.
+	Metadata() Headers
+
+	// Method returns the method Value matcher of this instance.
+	Method() Value
+}
+
+type grpcRequest struct {
+	method   Value
+	metadata HeaderMap
+}
+
+// NewGRPCRequestFromMap creates a new GRPCRequest based on the values of the given map. Aside from gRPC
+// metadata, the map may contain the special key:
+//
+//   :grpc-method: the full method name, i.e. "package.Service/Method", will match the glob value
+//
+func NewGRPCRequestFromMap(m map[string]string) (GRPCRequest, error) {
+	var mm Value
+	hm := make(HeaderMap, len(m))
+
+	var err error
+	for k, v := range m {
+		switch {
+		case k == ":grpc-method:":
+			if mm, err = NewGRPCMethod(v); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(k, ":"):
+			return nil, fmt.Errorf("unknown gRPC match %s", k)
+		default:
+			vm, err := NewValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("the value of gRPC metadata match %s=%s is invalid: %w", k, v, err)
+			}
+			hm[textproto.CanonicalMIMEHeaderKey(k)] = vm
+		}
+	}
+	return NewGRPCRequest(mm, hm), nil
+}
+
+// NewGRPCRequest creates a new GRPCRequest from the given method Value and metadata HeaderMap.
+func NewGRPCRequest(method Value, hm HeaderMap) GRPCRequest {
+	if len(hm) == 0 {
+		hm = nil
+	}
+	return &grpcRequest{method: method, metadata: hm}
+}
+
+// NewGRPCMethod returns a glob Value that matches full gRPC method names. A leading slash is ignored, and
+// a pattern without a slash is considered to be a service name that matches all methods of that service,
+// so "pkg.Svc", "pkg.Svc/*", and "/pkg.Svc/*" are all equivalent.
+func NewGRPCMethod(v string) (Value, error) {
+	v = strings.TrimPrefix(v, "/")
+	if v == "" {
+		return nil, fmt.Errorf("gRPC method must not be empty")
+	}
+	if !strings.Contains(v, "/") {
+		v += "/*"
+	}
+	return NewGlob(v)
+}
+
+// Map returns the map correspondence of this instance. The returned value can be
+// used as an argument to NewGRPCRequestFromMap to create an identical GRPCRequest.
+func (r *grpcRequest) Map() map[string]string {
+	var m map[string]string
+	if r.metadata != nil {
+		m = r.metadata.Map()
+	}
+	if r.method != nil {
+		if m == nil {
+			m = make(map[string]string, 1)
+		}
+		m[":grpc-method:"] = r.method.String()
+	}
+	return m
+}
+
+// Matches returns true if the given request is a gRPC call and both the method Value matcher and the
+// metadata Headers matcher in this instance are matched by the :path and headers of the request.
+func (r *grpcRequest) Matches(path string, headers http.Header) bool {
+	if !strings.HasPrefix(headers.Get("Content-Type"), "application/grpc") {
+		return false
+	}
+	return r == nil ||
+		(r.method == nil || r.method.Matches(strings.TrimPrefix(path, "/"))) && (r.metadata == nil || r.metadata.Matches(headers))
+}
+
+// Metadata returns the metadata Headers of this instance.
+func (r *grpcRequest) Metadata() Headers {
+	return r.metadata
+}
+
+// Method returns the method Value matcher of this instance.
+func (r *grpcRequest) Method() Value {
+	return r.method
+}
+
+func (r *grpcRequest) String() string {
+	sb := strings.Builder{}
+	if r == nil || r.method == nil && len(r.metadata) == 0 {
+		return "all gRPC calls"
+	}
+	sb.WriteString("gRPC calls with")
+	if r.method != nil {
+		if r.metadata != nil {
+			sb.WriteString("\n ")
+		}
+		fmt.Fprintf(&sb, " method %s %s", r.method.Op(), r.method.String())
+	}
+	if r.metadata != nil {
+		indent := "  "
+		if r.method != nil {
+			indent += "  "
+			sb.WriteString("\n ")
+		}
+		sb.WriteString(" metadata")
+		r.metadata.appendString(&sb, indent)
+	}
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package matcher
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGRPCMethod(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"pkg.Svc/Method", "pkg.Svc/Method"},
+		{"/pkg.Svc/Method", "pkg.Svc/Method"},
+		{"pkg.Svc", "pkg.Svc/*"},
+		{"pkg.Svc/*", "pkg.Svc/*"},
+	}
+	for _, tt := range tests {
+		v, err := NewGRPCMethod(tt.pattern)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, v.String())
+		assert.Equal(t, "glob", v.Op())
+	}
+	_, err := NewGRPCMethod("/")
+	assert.Error(t, err)
+}
+
+func TestGRPCRequest_Matches(t *testing.T) {
+	grpcHeaders := func(kv ...string) http.Header {
+		h := http.Header{"Content-Type": []string{"application/grpc+proto"}}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+	tests := []struct {
+		name    string
+		match   map[string]string
+		path    string
+		headers http.Header
+		want    bool
+	}{
+		{
+			name:    "any method in service",
+			match:   map[string]string{":grpc-method:": "pkg.Svc/*"},
+			path:    "/pkg.Svc/Get",
+			headers: grpcHeaders(),
+			want:    true,
+		},
+		{
+			name:    "other service",
+			match:   map[string]string{":grpc-method:": "pkg.Svc/*"},
+			path:    "/pkg.SvcX/Get",
+			headers: grpcHeaders(),
+			want:    false,
+		},
+		{
+			name:    "wildcard package",
+			match:   map[string]string{":grpc-method:": "pkg.*"},
+			path:    "/pkg.Svc/Get",
+			headers: grpcHeaders(),
+			want:    true,
+		},
+		{
+			name:    "wildcard doesn't cross slash",
+			match:   map[string]string{":grpc-method:": "pkg.Svc/G*"},
+			path:    "/pkg.Svc/Get/x",
+			headers: grpcHeaders(),
+			want:    false,
+		},
+		{
+			name:    "wildcard service",
+			match:   map[string]string{":grpc-method:": "pkg.*/Get"},
+			path:    "/pkg.Svc/Get",
+			headers: grpcHeaders(),
+			want:    true,
+		},
+		{
+			name:    "metadata",
+			match:   map[string]string{":grpc-method:": "pkg.Svc/Get", "x-user": "alice"},
+			path:    "/pkg.Svc/Get",
+			headers: grpcHeaders("x-user", "alice"),
+			want:    true,
+		},
+		{
+			name:    "metadata mismatch",
+			match:   map[string]string{"x-user": "alice"},
+			path:    "/pkg.Svc/Get",
+			headers: grpcHeaders("x-user", "bob"),
+			want:    false,
+		},
+		{
+			name:    "not gRPC",
+			match:   map[string]string{":grpc-method:": "pkg.Svc/*"},
+			path:    "/pkg.Svc/Get",
+			headers: http.Header{"Content-Type": []string{"application/json"}},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewGRPCRequestFromMap(tt.match)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, r.Matches(tt.path, tt.headers))
+		})
+	}
+}
+
+func TestGRPCRequest_Map(t *testing.T) {
+	m := map[string]string{":grpc-method:": "pkg.Svc/*", "X-User": "alice"}
+	r, err := NewGRPCRequestFromMap(m)
+	require.NoError(t, err)
+	assert.Equal(t, m, r.Map())
+
+	_, err = NewGRPCRequestFromMap(map[string]string{":path-equal:": "/x"})
+	assert.Error(t, err)
+}
+
+func TestGRPCRequest_String(t *testing.T) {
+	r, err := NewGRPCRequestFromMap(map[string]string{":grpc-method:": "pkg.Svc/*", "X-User": "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, "gRPC calls with\n  method glob pkg.Svc/*\n  metadata\n    'X-User: alice'", r.String())
+	assert.Equal(t, "all gRPC calls", NewGRPCRequest(nil, nil).String())
+}
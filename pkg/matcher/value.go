@@ -6,15 +6,15 @@ import (
 	"strings"
 )
 
-// Value comes in three flavors. One that performs an exact match against a string, one that
-// uses a regular expression, and one that uses prefix matching.
+// Value comes in four flavors. One that performs an exact match against a string, one that
+// uses a regular expression, one that uses prefix matching, and one that uses glob matching.
 type Value interface {
 	fmt.Stringer
 
 	// Matches returns true if the given string matches this Value
 	Matches(value string) bool
 
-	// Op returns either ==, =~, or prefix
+	// Op returns either ==, =~, prefix, or glob
 	Op() string
 }
 
@@ -58,6 +58,23 @@ func (p prefixValue) Op() string {
 	return "prefix"
 }
 
+type globValue struct {
+	glob string
+	rx   *regexp.Regexp
+}
+
+func (g globValue) Matches(value string) bool {
+	return g.rx.MatchString(value)
+}
+
+func (g globValue) String() string {
+	return g.glob
+}
+
+func (g globValue) Op() string {
+	return "glob"
+}
+
 // NewValue returns a Value that is either an exact or a regexp matcher. The latter is chosen
 // when the given string contains regexp meta characters. An error is returned if the string contains
 // meta characters but cannot be compiled into a regexp.
@@ -87,3 +104,26 @@ func NewPrefix(v string) Value {
 func NewEqual(v string) Value {
 	return textValue(v)
 }
+
+// NewGlob returns a Value that is a glob matcher. A '*' in the glob matches any sequence of characters
+// except '/', and a '?' matches any single character except '/'.
+func NewGlob(v string) (Value, error) {
+	sb := strings.Builder{}
+	sb.WriteByte('^')
+	for _, r := range v {
+		switch r {
+		case '*':
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteByte('$')
+	rx, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	return globValue{glob: v, rx: rx}, nil
+}
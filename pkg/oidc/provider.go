@@ -0,0 +1,61 @@
+// Package oidc contains the parts of OpenID Connect that are shared by the client, which logs in using an
+// OpenID Connect provider, and the traffic-manager, which verifies the ID tokens that the provider issues.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DiscoveryPath is the path of the discovery document, relative to the issuer URL.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// Provider is the subset of the discovery document of an OpenID Connect provider that Telepresence uses.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint,omitempty"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// DiscoveryURL returns the URL of the discovery document of the given issuer URL. The URL is returned
+// unchanged if it already is a discovery URL.
+func DiscoveryURL(issuerURL string) string {
+	if strings.HasSuffix(issuerURL, DiscoveryPath) {
+		return issuerURL
+	}
+	return strings.TrimSuffix(issuerURL, "/") + DiscoveryPath
+}
+
+// Discover fetches the discovery document of the given issuer URL.
+func Discover(ctx context.Context, issuerURL string) (*Provider, error) {
+	var p Provider
+	if err := getJSON(ctx, DiscoveryURL(issuerURL), &p); err != nil {
+		return nil, fmt.Errorf("unable to discover OpenID Connect provider %s: %w", issuerURL, err)
+	}
+	if p.Issuer == "" || p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" {
+		return nil, fmt.Errorf("the discovery document of OpenID Connect provider %s is incomplete", issuerURL)
+	}
+	return &p, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,156 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// leeway is the clock skew that is tolerated when validating the time claims of an ID token.
+	leeway = time.Minute
+
+	// minKeysRefresh is the minimum time between two fetches of the provider's keys. The keys are refetched
+	// when an ID token is signed with an unknown key, which happens when the provider rotates its keys.
+	minKeysRefresh = time.Minute
+)
+
+// Claims are the claims of an ID token.
+type Claims struct {
+	jwt.Claims
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+
+	// Raw contains all claims of the ID token.
+	Raw map[string]any `json:"-"`
+}
+
+// Identity returns the value of the given claim, or the subject if the claim isn't a non-empty string.
+func (c *Claims) Identity(claim string) string {
+	if s, ok := c.Raw[claim].(string); ok && s != "" {
+		return s
+	}
+	return c.Subject
+}
+
+// UnverifiedClaims returns the claims of the given ID token without verifying its signature. It must only be
+// used by a client that obtained the token directly from the provider.
+func UnverifiedClaims(rawIDToken string) (*Claims, error) {
+	tok, err := jwt.ParseSigned(rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ID token: %w", err)
+	}
+	var claims Claims
+	if err = tok.UnsafeClaimsWithoutVerification(&claims, &claims.Raw); err != nil {
+		return nil, fmt.Errorf("unable to parse ID token claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// Verifier verifies ID tokens issued by an OpenID Connect provider. The provider is discovered, and its
+// keys are fetched, when the first token is verified.
+type Verifier struct {
+	issuerURL string
+	clientID  string
+
+	mu        sync.Mutex
+	provider  *Provider
+	keys      jose.JSONWebKeySet
+	keysFetch time.Time
+}
+
+// NewVerifier returns a Verifier for ID tokens issued by the provider at the given issuer URL to the
+// given client ID. The audience of the tokens isn't verified when the client ID is empty.
+func NewVerifier(issuerURL, clientID string) *Verifier {
+	return &Verifier{issuerURL: issuerURL, clientID: clientID}
+}
+
+// Verify verifies the signature of the given ID token using the provider's keys, and validates its issuer,
+// audience, and time claims. The claims of the token are returned when it's valid.
+func (v *Verifier) Verify(ctx context.Context, rawIDToken string) (*Claims, error) {
+	tok, err := jwt.ParseSigned(rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ID token: %w", err)
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.New("ID token must have exactly one signature")
+	}
+	issuer, key, err := v.getKey(ctx, tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err = tok.Claims(key, &claims, &claims.Raw); err != nil {
+		return nil, fmt.Errorf("unable to verify ID token: %w", err)
+	}
+	expected := jwt.Expected{Issuer: issuer, Time: time.Now()}
+	if v.clientID != "" {
+		expected.Audience = jwt.Audience{v.clientID}
+	}
+	if err = claims.ValidateWithLeeway(expected, leeway); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Expiry == nil {
+		return nil, errors.New("invalid ID token: it has no expiry")
+	}
+	return &claims, nil
+}
+
+// getKey returns the issuer of the provider, and its key with the given ID. The provider is discovered if
+// that hasn't been done yet, and its keys are fetched again if none of them have the given ID.
+func (v *Verifier) getKey(ctx context.Context, kid string) (string, *jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.provider == nil {
+		p, err := Discover(ctx, v.issuerURL)
+		if err != nil {
+			return "", nil, err
+		}
+		if p.JWKSURI == "" {
+			return "", nil, fmt.Errorf("the discovery document of OpenID Connect provider %s has no jwks_uri", v.issuerURL)
+		}
+		// The issuer of the document must be the configured one, or tokens would be accepted from whatever
+		// issuer the document names.
+		if strings.TrimSuffix(p.Issuer, "/") != strings.TrimSuffix(v.issuerURL, "/") {
+			return "", nil, fmt.Errorf("the discovery document of OpenID Connect provider %s names another issuer, %s", v.issuerURL, p.Issuer)
+		}
+		v.provider = p
+	}
+	key := v.findKey(kid)
+	if key == nil && time.Since(v.keysFetch) >= minKeysRefresh {
+		var keys jose.JSONWebKeySet
+		if err := getJSON(ctx, v.provider.JWKSURI, &keys); err != nil {
+			return "", nil, fmt.Errorf("unable to fetch the keys of OpenID Connect provider %s: %w", v.issuerURL, err)
+		}
+		v.keys = keys
+		v.keysFetch = time.Now()
+		key = v.findKey(kid)
+	}
+	if key == nil {
+		return "", nil, fmt.Errorf("ID token is signed with unknown key %q", kid)
+	}
+	return v.provider.Issuer, key, nil
+}
+
+// findKey returns the key with the given ID. A token without a key ID is accepted when the provider has
+// only one key.
+func (v *Verifier) findKey(kid string) *jose.JSONWebKey {
+	if kid == "" {
+		if len(v.keys.Keys) == 1 {
+			return &v.keys.Keys[0]
+		}
+		return nil
+	}
+	if keys := v.keys.Key(kid); len(keys) > 0 {
+		return &keys[0]
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+type testProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// issuer is the issuer of the discovery document. The URL of the server is used when it's empty.
+	issuer string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tp := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc(DiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		issuer := tp.issuer
+		if issuer == "" {
+			issuer = tp.URL
+		}
+		_ = json.NewEncoder(w).Encode(&Provider{
+			Issuer:                issuer,
+			AuthorizationEndpoint: tp.URL + "/authorize",
+			TokenEndpoint:         tp.URL + "/token",
+			JWKSURI:               tp.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+			Key:       &key.PublicKey,
+			KeyID:     "key-1",
+			Algorithm: string(jose.RS256),
+			Use:       "sig",
+		}}})
+	})
+	tp.Server = httptest.NewServer(mux)
+	t.Cleanup(tp.Close)
+	return tp
+}
+
+func (tp *testProvider) sign(t *testing.T, key *rsa.PrivateKey, kid string, claims any) string {
+	opts := (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, opts)
+	require.NoError(t, err)
+	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return raw
+}
+
+func TestVerifier(t *testing.T) {
+	tp := newTestProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	now := time.Now()
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   tp.URL,
+			"sub":   "1234",
+			"aud":   "telepresence",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+			"email": "alice@example.com",
+			"name":  "Alice",
+		}
+	}
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr string
+	}{
+		{
+			"valid",
+			func() string { return tp.sign(t, tp.key, "key-1", validClaims()) },
+			"",
+		},
+		{
+			"wrong audience",
+			func() string {
+				c := validClaims()
+				c["aud"] = "other"
+				return tp.sign(t, tp.key, "key-1", c)
+			},
+			"invalid audience",
+		},
+		{
+			"wrong issuer",
+			func() string {
+				c := validClaims()
+				c["iss"] = "https://other.example.com"
+				return tp.sign(t, tp.key, "key-1", c)
+			},
+			"invalid issuer",
+		},
+		{
+			"expired",
+			func() string {
+				c := validClaims()
+				c["exp"] = now.Add(-time.Hour).Unix()
+				return tp.sign(t, tp.key, "key-1", c)
+			},
+			"token is expired",
+		},
+		{
+			"no expiry",
+			func() string {
+				c := validClaims()
+				delete(c, "exp")
+				return tp.sign(t, tp.key, "key-1", c)
+			},
+			"it has no expiry",
+		},
+		{
+			"unknown key",
+			func() string { return tp.sign(t, otherKey, "key-2", validClaims()) },
+			`unknown key "key-2"`,
+		},
+		{
+			"forged signature",
+			func() string { return tp.sign(t, otherKey, "key-1", validClaims()) },
+			"unable to verify ID token",
+		},
+		{
+			"garbage",
+			func() string { return "not-a-token" },
+			"unable to parse ID token",
+		},
+	}
+	v := NewVerifier(tp.URL, "telepresence")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token())
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "1234", claims.Subject)
+			assert.Equal(t, "Alice", claims.Name)
+			assert.Equal(t, "alice@example.com", claims.Identity("email"))
+			assert.Equal(t, "1234", claims.Identity("groups"))
+		})
+	}
+}
+
+func TestVerifier_issuer(t *testing.T) {
+	tp := newTestProvider(t)
+	claims := func(iss string) map[string]any {
+		return map[string]any{
+			"iss": iss,
+			"sub": "1234",
+			"aud": "telepresence",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	// A trailing slash is ignored.
+	v := NewVerifier(tp.URL+"/", "telepresence")
+	_, err := v.Verify(context.Background(), tp.sign(t, tp.key, "key-1", claims(tp.URL)))
+	require.NoError(t, err)
+
+	// A document that names another issuer is rejected, even when the token is issued by that issuer.
+	tp.issuer = "https://other.example.com"
+	v = NewVerifier(tp.URL, "telepresence")
+	_, err = v.Verify(context.Background(), tp.sign(t, tp.key, "key-1", claims(tp.issuer)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "names another issuer")
+}
+
+func TestUnverifiedClaims(t *testing.T) {
+	tp := newTestProvider(t)
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	raw := tp.sign(t, tp.key, "key-1", map[string]any{
+		"sub":     "1234",
+		"exp":     exp.Unix(),
+		"picture": "https://example.com/alice.png",
+	})
+	claims, err := UnverifiedClaims(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "1234", claims.Subject)
+	assert.Equal(t, "https://example.com/alice.png", claims.Picture)
+	assert.True(t, exp.Equal(claims.Expiry.Time()))
+}
+
+func TestDiscoveryURL(t *testing.T) {
+	assert.Equal(t, "https://idp.example.com/.well-known/openid-configuration", DiscoveryURL("https://idp.example.com"))
+	assert.Equal(t, "https://idp.example.com/realm/.well-known/openid-configuration", DiscoveryURL("https://idp.example.com/realm/"))
+	assert.Equal(t, "https://idp.example.com/.well-known/openid-configuration", DiscoveryURL("https://idp.example.com/.well-known/openid-configuration"))
+}
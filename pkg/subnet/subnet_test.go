@@ -51,6 +51,23 @@ func Test_covers(t *testing.T) {
 	assert.False(t, Covers(network1, network2))
 }
 
+func Test_overlaps(t *testing.T) {
+	_, network1, _ := net.ParseCIDR("10.127.0.0/16")
+	_, network2, _ := net.ParseCIDR("10.127.201.0/24")
+	assert.True(t, Overlaps(network1, network2))
+	assert.True(t, Overlaps(network2, network1))
+
+	_, network2, _ = net.ParseCIDR("10.0.0.0/8")
+	assert.True(t, Overlaps(network1, network2))
+
+	_, network2, _ = net.ParseCIDR("10.128.0.0/16")
+	assert.False(t, Overlaps(network1, network2))
+	assert.False(t, Overlaps(network2, network1))
+
+	_, network2, _ = net.ParseCIDR("2001:db8::/32")
+	assert.False(t, Overlaps(network1, network2))
+}
+
 func TestCoveringCIDRs(t *testing.T) {
 	ips := loadIPs(t)
 	ipNets := CoveringCIDRs(ips)
@@ -35,7 +35,15 @@ func openTun(ctx context.Context) (td *nativeDevice, err error) {
 			dlog.Errorf(ctx, "%+v", err)
 		}
 	}()
-	interfaceName := "tel0"
+	// Use the first free "tel%d" name, so that the TUN devices of several connections can coexist,
+	// just like they do on Linux and macOS.
+	var interfaceName string
+	for i := 0; ; i++ {
+		interfaceName = fmt.Sprintf("tel%d", i)
+		if _, err := net.InterfaceByName(interfaceName); err != nil {
+			break
+		}
+	}
 	td = &nativeDevice{}
 	if td.Device, err = tun.CreateTUN(interfaceName, 0); err != nil {
 		return nil, fmt.Errorf("failed to create TUN device: %w", err)
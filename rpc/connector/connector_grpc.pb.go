@@ -91,6 +91,12 @@ type ConnectorClient interface {
 	RemoveInterceptor(ctx context.Context, in *Interceptor, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// GetNamespaces gets the mapped namespaces with an optional prefix
 	GetNamespaces(ctx context.Context, in *GetNamespacesRequest, opts ...grpc.CallOption) (*GetNamespacesResponse, error)
+	// WatchEvents streams events that describe changes to the state of the
+	// connector and its session with the traffic-manager. The stream starts
+	// with events that describe the current connection, intercepts, agents,
+	// and DNS configuration. The stream is intended for IDE plugins and other
+	// tools.
+	WatchEvents(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Connector_WatchEventsClient, error)
 }
 
 type connectorClient struct {
@@ -421,6 +427,38 @@ func (c *connectorClient) GetNamespaces(ctx context.Context, in *GetNamespacesRe
 	return out, nil
 }
 
+func (c *connectorClient) WatchEvents(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Connector_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Connector_ServiceDesc.Streams[3], "/telepresence.connector.Connector/WatchEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &connectorWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Connector_WatchEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type connectorWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *connectorWatchEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ConnectorServer is the server API for Connector service.
 // All implementations must embed UnimplementedConnectorServer
 // for forward compatibility
@@ -490,6 +528,12 @@ type ConnectorServer interface {
 	RemoveInterceptor(context.Context, *Interceptor) (*emptypb.Empty, error)
 	// GetNamespaces gets the mapped namespaces with an optional prefix
 	GetNamespaces(context.Context, *GetNamespacesRequest) (*GetNamespacesResponse, error)
+	// WatchEvents streams events that describe changes to the state of the
+	// connector and its session with the traffic-manager. The stream starts
+	// with events that describe the current connection, intercepts, agents,
+	// and DNS configuration. The stream is intended for IDE plugins and other
+	// tools.
+	WatchEvents(*emptypb.Empty, Connector_WatchEventsServer) error
 	mustEmbedUnimplementedConnectorServer()
 }
 
@@ -581,6 +625,9 @@ func (UnimplementedConnectorServer) RemoveInterceptor(context.Context, *Intercep
 func (UnimplementedConnectorServer) GetNamespaces(context.Context, *GetNamespacesRequest) (*GetNamespacesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetNamespaces not implemented")
 }
+func (UnimplementedConnectorServer) WatchEvents(*emptypb.Empty, Connector_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
 func (UnimplementedConnectorServer) mustEmbedUnimplementedConnectorServer() {}
 
 // UnsafeConnectorServer may be embedded to opt out of forward compatibility for this service.
@@ -1112,6 +1159,27 @@ func _Connector_GetNamespaces_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(emptypb.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConnectorServer).WatchEvents(m, &connectorWatchEventsServer{stream})
+}
+
+type Connector_WatchEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type connectorWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *connectorWatchEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Connector_ServiceDesc is the grpc.ServiceDesc for Connector service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1237,6 +1305,11 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Connector_WatchEvents_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "rpc/connector/connector.proto",
 }
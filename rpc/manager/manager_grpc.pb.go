@@ -83,6 +83,10 @@ type ManagerClient interface {
 	UpdateIntercept(ctx context.Context, in *UpdateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
 	// GetIntercept gets info from intercept name
 	GetIntercept(ctx context.Context, in *GetInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
+	// GetInterceptTLSCertificate lets an agent retrieve the certificate that it
+	// uses to terminate TLS for an intercept. Only the agents of the intercepted
+	// workload are allowed to retrieve it.
+	GetInterceptTLSCertificate(ctx context.Context, in *GetInterceptTLSCertificateRequest, opts ...grpc.CallOption) (*TLSCertificate, error)
 	// ReviewIntercept lets an agent approve or reject an intercept by
 	// changing the disposition from "WATING" to "ACTIVE" or to an
 	// error, and setting a human-readable status message.
@@ -402,6 +406,15 @@ func (c *managerClient) GetIntercept(ctx context.Context, in *GetInterceptReques
 	return out, nil
 }
 
+func (c *managerClient) GetInterceptTLSCertificate(ctx context.Context, in *GetInterceptTLSCertificateRequest, opts ...grpc.CallOption) (*TLSCertificate, error) {
+	out := new(TLSCertificate)
+	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/GetInterceptTLSCertificate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *managerClient) ReviewIntercept(ctx context.Context, in *ReviewInterceptRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/telepresence.manager.Manager/ReviewIntercept", in, out, opts...)
@@ -682,6 +695,10 @@ type ManagerServer interface {
 	UpdateIntercept(context.Context, *UpdateInterceptRequest) (*InterceptInfo, error)
 	// GetIntercept gets info from intercept name
 	GetIntercept(context.Context, *GetInterceptRequest) (*InterceptInfo, error)
+	// GetInterceptTLSCertificate lets an agent retrieve the certificate that it
+	// uses to terminate TLS for an intercept. Only the agents of the intercepted
+	// workload are allowed to retrieve it.
+	GetInterceptTLSCertificate(context.Context, *GetInterceptTLSCertificateRequest) (*TLSCertificate, error)
 	// ReviewIntercept lets an agent approve or reject an intercept by
 	// changing the disposition from "WATING" to "ACTIVE" or to an
 	// error, and setting a human-readable status message.
@@ -786,6 +803,9 @@ func (UnimplementedManagerServer) UpdateIntercept(context.Context, *UpdateInterc
 func (UnimplementedManagerServer) GetIntercept(context.Context, *GetInterceptRequest) (*InterceptInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetIntercept not implemented")
 }
+func (UnimplementedManagerServer) GetInterceptTLSCertificate(context.Context, *GetInterceptTLSCertificateRequest) (*TLSCertificate, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInterceptTLSCertificate not implemented")
+}
 func (UnimplementedManagerServer) ReviewIntercept(context.Context, *ReviewInterceptRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReviewIntercept not implemented")
 }
@@ -1198,6 +1218,24 @@ func _Manager_GetIntercept_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Manager_GetInterceptTLSCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInterceptTLSCertificateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetInterceptTLSCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/telepresence.manager.Manager/GetInterceptTLSCertificate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetInterceptTLSCertificate(ctx, req.(*GetInterceptTLSCertificateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Manager_ReviewIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ReviewInterceptRequest)
 	if err := dec(in); err != nil {
@@ -1464,6 +1502,10 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetIntercept",
 			Handler:    _Manager_GetIntercept_Handler,
 		},
+		{
+			MethodName: "GetInterceptTLSCertificate",
+			Handler:    _Manager_GetInterceptTLSCertificate_Handler,
+		},
 		{
 			MethodName: "ReviewIntercept",
 			Handler:    _Manager_ReviewIntercept_Handler,